@@ -58,6 +58,30 @@ type DiagnosisSpec struct {
 	Parameters map[string]string `json:"parameters,omitempty"`
 	// TargetSelector contains information to calculate target node to schedule tasks on.
 	TargetSelector *TargetSelector `json:"targetSelector,omitempty"`
+	// Parallelism specifies the maximum number of tasks to be created for target nodes in one reconcile
+	// pass. Tasks are created in batches until all target nodes have been covered.
+	// Tasks for all target nodes are created in one pass if not specified.
+	// +optional
+	Parallelism *int32 `json:"parallelism,omitempty"`
+	// CompletionWebhook is the url which a summary of the diagnosis is sent to on reaching a
+	// terminal phase.
+	// No notification will be sent if not specified.
+	// +optional
+	CompletionWebhook *string `json:"completionWebhook,omitempty"`
+	// RecordPodEvents indicates whether an event summarizing the diagnosis outcome is recorded
+	// on pods referenced by the target selector on reaching a terminal phase.
+	// +optional
+	RecordPodEvents bool `json:"recordPodEvents,omitempty"`
+	// MirrorResultsToConfigMap indicates whether a summary of the diagnosis results is written
+	// into a config map in the diagnosis namespace on reaching a terminal phase. The config map
+	// is owner referenced to the diagnosis so it is garbage collected with it.
+	// +optional
+	MirrorResultsToConfigMap bool `json:"mirrorResultsToConfigMap,omitempty"`
+	// PathTimeoutSeconds is the timeout in seconds for executing one path of the diagnosis
+	// pipeline. A path exceeding the timeout is abandoned as failed and the next path is tried.
+	// Paths never time out if not specified.
+	// +optional
+	PathTimeoutSeconds *int64 `json:"pathTimeoutSeconds,omitempty"`
 }
 
 // TargetSelector contains information to calculate target node to schedule tasks on.
@@ -96,6 +120,9 @@ type DiagnosisStatus struct {
 	// StartTime is RFC 3339 date and time at which the object was acknowledged by the system.
 	// +optional
 	StartTime metav1.Time `json:"startTime,omitempty"`
+	// EndTime is RFC 3339 date and time at which the diagnosis reached a terminal phase.
+	// +optional
+	EndTime metav1.Time `json:"endTime,omitempty"`
 	// NodeNames contains all nodes which tasks should be scheduled on.
 	NodeNames []string `json:"nodeNames,omitempty"`
 	// FailedPaths contains all failed paths in diagnosis pipeline.
@@ -108,6 +135,10 @@ type DiagnosisStatus struct {
 	// Checkpoint is the checkpoint for resuming unfinished diagnosis.
 	// +optional
 	Checkpoint *Checkpoint `json:"checkpoint,omitempty"`
+	// ExecutedOperations is a flat trace of the operations executed during the diagnosis in
+	// execution order across all paths.
+	// +optional
+	ExecutedOperations []ExecutedOperation `json:"executedOperations,omitempty"`
 	// Context stores all information generated during the diagnosis execution.
 	// +optional
 	Context *DiagnosisContext `json:"context,omitempty"`
@@ -142,12 +173,39 @@ type Checkpoint struct {
 	Desired int `json:"desired"`
 	// The number of pending and running tasks.
 	Active int `json:"active"`
-	// The number of tasks which reached phase Failed.
-	Succeeded int `json:"succeeded"`
 	// The number of tasks which reached phase Succeeded.
+	Succeeded int `json:"succeeded"`
+	// The number of tasks which reached phase Failed.
 	Failed int `json:"failed"`
 	// SynchronizedTasks contains a list of synchronized task of current checkpoint.
 	SynchronizedTasks []string `json:"synchronizedTasks,omitempty"`
+	// PathStartTime is RFC 3339 date and time at which the execution of current path started.
+	// +optional
+	PathStartTime metav1.Time `json:"pathStartTime,omitempty"`
+	// PathFailed indicates that an operation in current path has failed and only remaining
+	// nodes flagged as always run are being executed before advancing to the next path.
+	// +optional
+	PathFailed bool `json:"pathFailed,omitempty"`
+}
+
+// ExecutedOperation is a record of one executed operation in the execution trace of a diagnosis.
+type ExecutedOperation struct {
+	// Name is the name of the operation.
+	Name string `json:"name"`
+	// Node is the id of the executed adjacency node.
+	Node int `json:"node"`
+	// PathIndex is the index of the path containing the node.
+	PathIndex int `json:"pathIndex"`
+	// NodeIndex is the index of the node in the path.
+	NodeIndex int `json:"nodeIndex"`
+	// Phase is Succeeded if the operation succeeded on any node, otherwise Failed.
+	Phase DiagnosisPhase `json:"phase"`
+	// StartTime is RFC 3339 date and time at which the first task of the operation started.
+	// +optional
+	StartTime metav1.Time `json:"startTime,omitempty"`
+	// EndTime is RFC 3339 date and time at which the operation completed.
+	// +optional
+	EndTime metav1.Time `json:"endTime,omitempty"`
 }
 
 // DiagnosisContext stores all information generated during the diagnosis execution.
@@ -176,6 +234,11 @@ type DiagnosisConditionType string
 // +kubebuilder:subresource:status
 // +kubebuilder:printcolumn:JSONPath=".spec.operationSet",name=OperationSet,type=string
 // +kubebuilder:printcolumn:JSONPath=".status.phase",name=Phase,type=string
+// +kubebuilder:printcolumn:JSONPath=".status.checkpoint.pathIndex",name=PathIndex,type=integer,priority=1
+// +kubebuilder:printcolumn:JSONPath=".status.checkpoint.nodeIndex",name=NodeIndex,type=integer,priority=1
+// +kubebuilder:printcolumn:JSONPath=".status.checkpoint.succeeded",name=Succeeded,type=integer
+// +kubebuilder:printcolumn:JSONPath=".status.checkpoint.failed",name=Failed,type=integer
+// +kubebuilder:printcolumn:JSONPath=".status.startTime",name=StartTime,type=date,priority=1
 // +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
 
 // Diagnosis is the Schema for the diagnoses API.
@@ -0,0 +1,58 @@
+/*
+Copyright 2022 The KubeDiag Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// TestDiagnosisStatusPrinterColumnFields verifies that the status serializes the fields
+// referenced by the kubectl printer columns under their expected json paths.
+func TestDiagnosisStatusPrinterColumnFields(t *testing.T) {
+	status := DiagnosisStatus{
+		Phase:     DiagnosisRunning,
+		StartTime: metav1.Now(),
+		Checkpoint: &Checkpoint{
+			PathIndex: 1,
+			NodeIndex: 2,
+			Desired:   3,
+			Active:    1,
+			Succeeded: 1,
+			Failed:    1,
+		},
+	}
+
+	raw, err := json.Marshal(status)
+	assert.NoError(t, err)
+	serialized := make(map[string]interface{})
+	err = json.Unmarshal(raw, &serialized)
+	assert.NoError(t, err)
+
+	assert.Equal(t, "Running", serialized["phase"])
+	assert.NotEmpty(t, serialized["startTime"])
+	checkpoint, ok := serialized["checkpoint"].(map[string]interface{})
+	if assert.True(t, ok) {
+		assert.Equal(t, float64(1), checkpoint["pathIndex"])
+		assert.Equal(t, float64(2), checkpoint["nodeIndex"])
+		assert.Equal(t, float64(1), checkpoint["succeeded"])
+		assert.Equal(t, float64(1), checkpoint["failed"])
+	}
+}
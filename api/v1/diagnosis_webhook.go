@@ -30,6 +30,26 @@ import (
 // diagnosislog is for logging of diagnosis webhook.
 var diagnosislog = logf.Log.WithName("diagnosis-webhook")
 
+// defaultOperationSet is the operation set to inject into source generated diagnoses without an
+// operation set. Defaulting is skipped if empty.
+var defaultOperationSet string
+
+// sourceGeneratedDiagnosisAnnotations contains annotations set by diagnosis sources such as the
+// alertmanager and the eventer. A diagnosis carrying any of these annotations is generated from
+// a source instead of being created by a user.
+var sourceGeneratedDiagnosisAnnotations = []string{
+	"diagnosis.kubediag.org/prometheus-alert",
+	"diagnosis.kubediag.org/kubernetes-event",
+	"diagnosis.kubediag.org/common-event",
+	"diagnosis.kubediag.org/cron",
+}
+
+// SetDefaultOperationSet configures the operation set to inject into source generated diagnoses
+// without an operation set.
+func SetDefaultOperationSet(name string) {
+	defaultOperationSet = name
+}
+
 // SetupWebhookWithManager setups the Diagnosis webhook.
 func (r *Diagnosis) SetupWebhookWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewWebhookManagedBy(mgr).
@@ -47,6 +67,16 @@ func (r *Diagnosis) Default() {
 		Name:      r.Name,
 		Namespace: r.Namespace,
 	})
+
+	// Inject the default operation set into a source generated diagnosis without an operation set.
+	if r.Spec.OperationSet == "" && defaultOperationSet != "" {
+		for _, annotation := range sourceGeneratedDiagnosisAnnotations {
+			if _, ok := r.Annotations[annotation]; ok {
+				r.Spec.OperationSet = defaultOperationSet
+				break
+			}
+		}
+	}
 }
 
 // +kubebuilder:webhook:verbs=create;update,path=/validate-diagnosis-kubediag-org-v1-diagnosis,mutating=false,failurePolicy=fail,groups=diagnosis.kubediag.org,resources=diagnoses,versions=v1,name=vdiagnosis.kb.io
@@ -91,6 +121,27 @@ func (r *Diagnosis) validateDiagnosis() error {
 		allErrs = append(allErrs, field.Invalid(field.NewPath("spec").Child("operationSet"),
 			r.Spec.OperationSet, "must not be empty"))
 	}
+	if r.Spec.TargetSelector != nil {
+		// The controller resolves selector types in priority order and ignores lower priority ones silently.
+		// Reject a target selector with more than one selector type to avoid surprising users.
+		specified := 0
+		if r.Spec.TargetSelector.NodeSelector != nil {
+			specified++
+		}
+		if len(r.Spec.TargetSelector.NodeNames) != 0 {
+			specified++
+		}
+		if r.Spec.TargetSelector.PodSelector != nil {
+			specified++
+		}
+		if len(r.Spec.TargetSelector.PodReferences) != 0 {
+			specified++
+		}
+		if specified > 1 {
+			allErrs = append(allErrs, field.Invalid(field.NewPath("spec").Child("targetSelector"),
+				r.Spec.TargetSelector, "only one of nodeSelector, nodeNames, podSelector and podReferences may be specified"))
+		}
+	}
 	if len(allErrs) == 0 {
 		return nil
 	}
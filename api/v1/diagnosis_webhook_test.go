@@ -0,0 +1,147 @@
+/*
+Copyright 2022 The KubeDiag Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestValidateDiagnosisTargetSelector(t *testing.T) {
+	tests := []struct {
+		targetSelector *TargetSelector
+		expected       bool
+		desc           string
+	}{
+		{
+			targetSelector: &TargetSelector{
+				NodeNames: []string{"node1"},
+			},
+			expected: true,
+			desc:     "single selector type, valid",
+		},
+		{
+			targetSelector: &TargetSelector{
+				NodeSelector: &metav1.LabelSelector{
+					MatchLabels: map[string]string{"key1": "value1"},
+				},
+			},
+			expected: true,
+			desc:     "node selector only, valid",
+		},
+		{
+			targetSelector: &TargetSelector{
+				NodeNames: []string{"node1"},
+				PodSelector: &metav1.LabelSelector{
+					MatchLabels: map[string]string{"key1": "value1"},
+				},
+			},
+			expected: false,
+			desc:     "node names and pod selector, invalid",
+		},
+		{
+			targetSelector: &TargetSelector{
+				NodeSelector: &metav1.LabelSelector{
+					MatchLabels: map[string]string{"key1": "value1"},
+				},
+				PodReferences: []PodReference{
+					{
+						NamespacedName: NamespacedName{
+							Namespace: "default",
+							Name:      "pod1",
+						},
+					},
+				},
+			},
+			expected: false,
+			desc:     "node selector and pod references, invalid",
+		},
+	}
+
+	for _, test := range tests {
+		diagnosis := Diagnosis{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "diagnosis1",
+				Namespace: "default",
+			},
+			Spec: DiagnosisSpec{
+				OperationSet:   "operationset1",
+				TargetSelector: test.targetSelector,
+			},
+		}
+		err := diagnosis.validateDiagnosis()
+		if test.expected {
+			assert.NoError(t, err, test.desc)
+		} else {
+			assert.Error(t, err, test.desc)
+		}
+	}
+}
+
+func TestDefaultOperationSetForSourceGeneratedDiagnosis(t *testing.T) {
+	SetDefaultOperationSet("default-operationset")
+	defer SetDefaultOperationSet("")
+
+	tests := []struct {
+		annotations  map[string]string
+		operationSet string
+		expected     string
+		desc         string
+	}{
+		{
+			annotations:  map[string]string{"diagnosis.kubediag.org/prometheus-alert": "alert1"},
+			operationSet: "",
+			expected:     "default-operationset",
+			desc:         "alert generated diagnosis without operation set, defaulted",
+		},
+		{
+			annotations:  map[string]string{"diagnosis.kubediag.org/kubernetes-event": "event1"},
+			operationSet: "",
+			expected:     "default-operationset",
+			desc:         "event generated diagnosis without operation set, defaulted",
+		},
+		{
+			annotations:  map[string]string{"diagnosis.kubediag.org/prometheus-alert": "alert1"},
+			operationSet: "operationset1",
+			expected:     "operationset1",
+			desc:         "alert generated diagnosis with operation set, not defaulted",
+		},
+		{
+			annotations:  nil,
+			operationSet: "",
+			expected:     "",
+			desc:         "user created diagnosis without operation set, not defaulted",
+		},
+	}
+
+	for _, test := range tests {
+		diagnosis := Diagnosis{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        "diagnosis1",
+				Namespace:   "default",
+				Annotations: test.annotations,
+			},
+			Spec: DiagnosisSpec{
+				OperationSet: test.operationSet,
+			},
+		}
+		diagnosis.Default()
+		assert.Equal(t, test.expected, diagnosis.Spec.OperationSet, test.desc)
+	}
+}
@@ -38,6 +38,81 @@ type OperationSpec struct {
 	// Operation results will not be stored if nil.
 	// +optional
 	Storage *Storage `json:"storage,omitempty"`
+	// SensitiveKeys contains keys in parameters or operation results whose values are redacted
+	// before being written to statuses. The real values are still sent to the processor.
+	// +optional
+	SensitiveKeys []string `json:"sensitiveKeys,omitempty"`
+	// ParameterTypes declares types of parameters by key. Parameter values remain strings on
+	// the wire; a value of a declared key must be parseable as the declared type. Parameters
+	// of undeclared keys are not validated.
+	// +optional
+	ParameterTypes map[string]ParameterType `json:"parameterTypes,omitempty"`
+	// ResultTTLSeconds is the number of seconds after which results of finished tasks of the
+	// operation are blanked out of task statuses while the task objects are kept. Results are
+	// retained until the task is garbage collected if not specified.
+	// +optional
+	ResultTTLSeconds *int32 `json:"resultTTLSeconds,omitempty"`
+	// ResultConditions maps operation result keys to diagnosis condition types. A condition of
+	// the declared type is set to true on the diagnosis if the corresponding result value is a
+	// true boolean string.
+	// +optional
+	ResultConditions []ResultCondition `json:"resultConditions,omitempty"`
+	// ResultKeyPattern is a regular expression which result keys declared in resultConditions,
+	// sensitiveKeys and httpServer.resultJSONPaths must match, e.g. ^collector\..+$.
+	// Result key naming is not validated if not specified.
+	// +optional
+	ResultKeyPattern *string `json:"resultKeyPattern,omitempty"`
+	// NodeTelemetry selects metadata of the task node to inject into the request data sent to
+	// the processor.
+	// +optional
+	NodeTelemetry *NodeTelemetry `json:"nodeTelemetry,omitempty"`
+	// Concurrency controls whether tasks of the operation may run concurrently on a node.
+	// A singleton operation runs at most one of its tasks at a time per node; additional
+	// tasks wait for the running one to finish.
+	// Defaults to allow.
+	// +optional
+	Concurrency ConcurrencyPolicy `json:"concurrency,omitempty"`
+}
+
+// ParameterType specifies the type a parameter value must be parseable as.
+type ParameterType string
+
+const (
+	// StringParameterType means the parameter value may be any string.
+	StringParameterType ParameterType = "string"
+	// IntegerParameterType means the parameter value must be parseable as an integer.
+	IntegerParameterType ParameterType = "integer"
+	// BooleanParameterType means the parameter value must be parseable as a boolean.
+	BooleanParameterType ParameterType = "boolean"
+)
+
+// ConcurrencyPolicy specifies whether tasks of an operation may run concurrently on a node.
+type ConcurrencyPolicy string
+
+const (
+	// AllowConcurrency means tasks of the operation may run concurrently.
+	AllowConcurrency ConcurrencyPolicy = "allow"
+	// SingletonConcurrency means at most one task of the operation runs at a time per node.
+	SingletonConcurrency ConcurrencyPolicy = "singleton"
+)
+
+// NodeTelemetry selects node metadata injected into processor request data.
+type NodeTelemetry struct {
+	// Labels are node label keys whose values are injected under node.label prefixed keys.
+	// +optional
+	Labels []string `json:"labels,omitempty"`
+	// Annotations are node annotation keys whose values are injected under node.annotation
+	// prefixed keys.
+	// +optional
+	Annotations []string `json:"annotations,omitempty"`
+}
+
+// ResultCondition maps an operation result key to a diagnosis condition type.
+type ResultCondition struct {
+	// Key is the key of the operation result to inspect.
+	Key string `json:"key"`
+	// Type is the type of the diagnosis condition to set if the result value is true.
+	Type DiagnosisConditionType `json:"type"`
 }
 
 // Processor describes how to register a operation processor into kubediag.
@@ -56,8 +131,24 @@ type Processor struct {
 	// Defaults to 30 seconds. Minimum value is 1.
 	// +optional
 	TimeoutSeconds *int32 `json:"timeoutSeconds,omitempty"`
+	// RunLocation indicates where tasks of the operation are executed. A master location
+	// operation is dispatched as a single task on the node of the master instead of being
+	// fanned out to every target node.
+	// Defaults to agent.
+	// +optional
+	RunLocation RunLocation `json:"runLocation,omitempty"`
 }
 
+// RunLocation specifies where tasks of an operation are executed.
+type RunLocation string
+
+const (
+	// MasterRunLocation means tasks of the operation are executed on the master.
+	MasterRunLocation RunLocation = "master"
+	// AgentRunLocation means tasks of the operation are executed on agent nodes.
+	AgentRunLocation RunLocation = "agent"
+)
+
 // Storage represents the type of storage for operation results.
 type Storage struct {
 	// HostPath represents a directory on the host.
@@ -82,12 +173,33 @@ type HTTPServer struct {
 	// Defaults to kubediag agent serving port if not specified.
 	// +optional
 	Port *int32 `json:"port,omitempty"`
-	// Path is the serving http path of processor.
+	// Path is the serving http path of processor. It may contain go template placeholders
+	// referencing parameters or telemetry of the task, e.g. /profile/{{ .pod.name }}, which
+	// are expanded before the request is sent.
 	// +optional
 	Path *string `json:"path,omitempty"`
 	// Scheme is the serving scheme of processor. It must be either http or https.
 	// +optional
 	Scheme *string `json:"scheme,omitempty"`
+	// AuthSecretRef is the reference to a secret containing the credential injected into the
+	// Authorization header of requests sent to the http server. The credential is a bearer
+	// token if the secret contains a "token" key, or basic authentication if the secret
+	// contains "username" and "password" keys.
+	// +optional
+	AuthSecretRef *NamespacedName `json:"authSecretRef,omitempty"`
+	// ResultJSONPaths is a map of result keys to kubernetes jsonpath templates, e.g. {.status.phase}.
+	// If set, result values are extracted from an arbitrary json response body according to the
+	// templates instead of interpreting the body as a flat string map.
+	// +optional
+	ResultJSONPaths map[string]string `json:"resultJSONPaths,omitempty"`
+	// SuccessStatusCodes is a list of http status codes treated as success besides 200.
+	// +optional
+	SuccessStatusCodes []int32 `json:"successStatusCodes,omitempty"`
+	// ResponseFormat specifies how the response body of processor is interpreted. It must be
+	// either Map or Raw. A Map response body is interpreted as a flat string map while a Raw
+	// response body is stored as is under the response.body result key. Defaults to Map.
+	// +optional
+	ResponseFormat ResponseFormat `json:"responseFormat,omitempty"`
 }
 
 // ScriptRunner contains the information to run a script.
@@ -95,7 +207,9 @@ type ScriptRunner struct {
 	// Script is the content of shell script.
 	Script string `json:"script"`
 	// ArgKeys contains a slice of keys in parameters or operationResults. The script arguments are generated
-	// from specified key value pairs.
+	// from specified key value pairs. An entry containing go template placeholders, e.g.
+	// pod-{{ .pod.name }}, is expanded with parameters and telemetry of the task and passed
+	// as a literal argument instead.
 	// No argument will be passed to the script if not specified.
 	// +optional
 	ArgKeys []string `json:"argKeys,omitempty"`
@@ -103,14 +217,63 @@ type ScriptRunner struct {
 	// Execution results will not be updated if not specified.
 	// +optional
 	OperationResultKey *string `json:"operationResultKey,omitempty"`
+	// Interpreter is the interpreter to run the script with.
+	// Defaults to /bin/sh if not specified.
+	// +optional
+	Interpreter *string `json:"interpreter,omitempty"`
+	// Files contains files to materialize into a per-task temporary directory before the script
+	// runs. Keys are file names and values are file contents. A value matching a key in parameters
+	// or operationResults is replaced with the corresponding value. The directory path is passed
+	// to the script via the KUBEDIAG_SCRIPT_FILES_DIR environment variable.
+	// +optional
+	Files map[string]string `json:"files,omitempty"`
+	// TargetPodNamespaces is the list of linux namespaces of the target pod container to enter
+	// via nsenter before running the script, e.g. net, mnt, pid, ipc or uts. The container is
+	// resolved from the pod telemetry of the task through the container runtime client.
+	// +optional
+	TargetPodNamespaces []ContainerNamespace `json:"targetPodNamespaces,omitempty"`
 }
 
+// ContainerNamespace is a linux namespace of a container that a script can enter.
+type ContainerNamespace string
+
+const (
+	// NetNamespace is the network namespace of a container.
+	NetNamespace ContainerNamespace = "net"
+	// MountNamespace is the mount namespace of a container.
+	MountNamespace ContainerNamespace = "mnt"
+	// PIDNamespace is the pid namespace of a container.
+	PIDNamespace ContainerNamespace = "pid"
+	// IPCNamespace is the ipc namespace of a container.
+	IPCNamespace ContainerNamespace = "ipc"
+	// UTSNamespace is the uts namespace of a container.
+	UTSNamespace ContainerNamespace = "uts"
+)
+
+// ResponseFormat is the format of a processor http response body.
+type ResponseFormat string
+
+const (
+	// MapResponseFormat interprets a response body as a flat string map.
+	MapResponseFormat ResponseFormat = "Map"
+	// RawResponseFormat stores a response body as is under the response.body result key.
+	RawResponseFormat ResponseFormat = "Raw"
+)
+
 // Function contains the details to run a function as an operation.
 type Function struct {
 	// CodeSource contains the code source files.
 	CodeSource map[string]string `json:"codeSource"`
 	// Runtime is the language to use for writing a function.
 	Runtime FunctionRuntime `json:"runtime"`
+	// SuccessStatusCodes is a list of http status codes treated as success besides 200.
+	// +optional
+	SuccessStatusCodes []int32 `json:"successStatusCodes,omitempty"`
+	// ResponseFormat specifies how the response body of the function is interpreted. It must be
+	// either Map or Raw. A Map response body is interpreted as a flat string map while a Raw
+	// response body is stored as is under the response.body result key. Defaults to Map.
+	// +optional
+	ResponseFormat ResponseFormat `json:"responseFormat,omitempty"`
 }
 
 // FunctionRuntime is a valid value for Function.Runtime.
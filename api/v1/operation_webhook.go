@@ -17,7 +17,10 @@ limitations under the License.
 package v1
 
 import (
+	"fmt"
 	"net"
+	"regexp"
+	"strings"
 
 	"github.com/asaskevich/govalidator"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
@@ -32,6 +35,9 @@ import (
 // operationlog is for logging of operation webhook.
 var operationlog = logf.Log.WithName("operation-webhook")
 
+// MaxScriptSizeBytes is the maximum size in bytes of a script runner script.
+const MaxScriptSizeBytes = 131072
+
 // SetupWebhookWithManager setups the Operation webhook.
 func (r *Operation) SetupWebhookWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewWebhookManagedBy(mgr).
@@ -119,6 +125,18 @@ func (r *Operation) validateOperation() error {
 					r.Spec.Processor.HTTPServer.Scheme, "must be either http or https"))
 			}
 		}
+	} else if r.Spec.Processor.ScriptRunner != nil {
+		script := r.Spec.Processor.ScriptRunner.Script
+		if script == "" {
+			allErrs = append(allErrs, field.Invalid(field.NewPath("spec").Child("processor").Child("scriptRunner").Child("script"),
+				script, "must not be empty"))
+		} else if len(script) > MaxScriptSizeBytes {
+			allErrs = append(allErrs, field.Invalid(field.NewPath("spec").Child("processor").Child("scriptRunner").Child("script"),
+				len(script), fmt.Sprintf("must not exceed %d bytes", MaxScriptSizeBytes)))
+		} else if !strings.HasPrefix(script, "#!") && r.Spec.Processor.ScriptRunner.Interpreter == nil {
+			allErrs = append(allErrs, field.Invalid(field.NewPath("spec").Child("processor").Child("scriptRunner").Child("script"),
+				script, "must start with a shebang if no interpreter is specified"))
+		}
 	} else if r.Spec.Processor.Function != nil {
 		if r.Spec.Processor.Function.Runtime != Python3FunctionRuntime && r.Spec.Processor.Function.Runtime != GolangFunctionRuntime {
 			allErrs = append(allErrs, field.Invalid(field.NewPath("spec").Child("processor").Child("function").Child("runtime"),
@@ -131,6 +149,25 @@ func (r *Operation) validateOperation() error {
 				r.Spec.Processor.TimeoutSeconds, "must be greater than 0"))
 		}
 	}
+	if r.Spec.Processor.RunLocation != "" && r.Spec.Processor.RunLocation != MasterRunLocation && r.Spec.Processor.RunLocation != AgentRunLocation {
+		allErrs = append(allErrs, field.Invalid(field.NewPath("spec").Child("processor").Child("runLocation"),
+			r.Spec.Processor.RunLocation, "must be either master or agent"))
+	}
+	if r.Spec.Processor.ScriptRunner != nil {
+		for i, namespace := range r.Spec.Processor.ScriptRunner.TargetPodNamespaces {
+			if namespace != NetNamespace && namespace != MountNamespace && namespace != PIDNamespace && namespace != IPCNamespace && namespace != UTSNamespace {
+				allErrs = append(allErrs, field.Invalid(field.NewPath("spec").Child("processor").Child("scriptRunner").Child("targetPodNamespaces").Index(i),
+					namespace, "must be one of net, mnt, pid, ipc or uts"))
+			}
+		}
+	}
+	for key, parameterType := range r.Spec.ParameterTypes {
+		if parameterType != StringParameterType && parameterType != IntegerParameterType && parameterType != BooleanParameterType {
+			allErrs = append(allErrs, field.Invalid(field.NewPath("spec").Child("parameterTypes").Key(key),
+				parameterType, "must be either string, integer or boolean"))
+		}
+	}
+	allErrs = append(allErrs, r.validateResultKeyNaming()...)
 	if len(allErrs) == 0 {
 		return nil
 	}
@@ -139,3 +176,42 @@ func (r *Operation) validateOperation() error {
 		schema.GroupKind{Group: "diagnosis.kubediag.org", Kind: "Operation"},
 		r.Name, allErrs)
 }
+
+// validateResultKeyNaming validates that declared result keys match the result key pattern of
+// the operation. Naming is not validated if no pattern is specified.
+func (r *Operation) validateResultKeyNaming() field.ErrorList {
+	var allErrs field.ErrorList
+	if r.Spec.ResultKeyPattern == nil {
+		return allErrs
+	}
+
+	pattern, err := regexp.Compile(*r.Spec.ResultKeyPattern)
+	if err != nil {
+		allErrs = append(allErrs, field.Invalid(field.NewPath("spec").Child("resultKeyPattern"),
+			*r.Spec.ResultKeyPattern, fmt.Sprintf("must be a valid regular expression: %v", err)))
+		return allErrs
+	}
+
+	for index, resultCondition := range r.Spec.ResultConditions {
+		if !pattern.MatchString(resultCondition.Key) {
+			allErrs = append(allErrs, field.Invalid(field.NewPath("spec").Child("resultConditions").Index(index).Child("key"),
+				resultCondition.Key, fmt.Sprintf("must match result key pattern %s", *r.Spec.ResultKeyPattern)))
+		}
+	}
+	for index, sensitiveKey := range r.Spec.SensitiveKeys {
+		if !pattern.MatchString(sensitiveKey) {
+			allErrs = append(allErrs, field.Invalid(field.NewPath("spec").Child("sensitiveKeys").Index(index),
+				sensitiveKey, fmt.Sprintf("must match result key pattern %s", *r.Spec.ResultKeyPattern)))
+		}
+	}
+	if r.Spec.Processor.HTTPServer != nil {
+		for key := range r.Spec.Processor.HTTPServer.ResultJSONPaths {
+			if !pattern.MatchString(key) {
+				allErrs = append(allErrs, field.Invalid(field.NewPath("spec").Child("processor").Child("httpServer").Child("resultJSONPaths").Key(key),
+					key, fmt.Sprintf("must match result key pattern %s", *r.Spec.ResultKeyPattern)))
+			}
+		}
+	}
+
+	return allErrs
+}
@@ -0,0 +1,243 @@
+/*
+Copyright 2022 The KubeDiag Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestValidateOperationScriptRunner(t *testing.T) {
+	interpreter := "/bin/bash"
+	tests := []struct {
+		scriptRunner ScriptRunner
+		errContains  string
+		desc         string
+	}{
+		{
+			scriptRunner: ScriptRunner{
+				Script: "#!/bin/sh\necho hello",
+			},
+			errContains: "",
+			desc:        "script with shebang",
+		},
+		{
+			scriptRunner: ScriptRunner{
+				Script:      "echo hello",
+				Interpreter: &interpreter,
+			},
+			errContains: "",
+			desc:        "script without shebang but with interpreter",
+		},
+		{
+			scriptRunner: ScriptRunner{
+				Script: "",
+			},
+			errContains: "must not be empty",
+			desc:        "empty script",
+		},
+		{
+			scriptRunner: ScriptRunner{
+				Script: "#!/bin/sh\n" + strings.Repeat("a", MaxScriptSizeBytes),
+			},
+			errContains: "must not exceed",
+			desc:        "script exceeding size cap",
+		},
+		{
+			scriptRunner: ScriptRunner{
+				Script: "echo hello",
+			},
+			errContains: "must start with a shebang",
+			desc:        "script without shebang or interpreter",
+		},
+		{
+			scriptRunner: ScriptRunner{
+				Script:              "#!/bin/sh\nip addr",
+				TargetPodNamespaces: []ContainerNamespace{NetNamespace, MountNamespace},
+			},
+			errContains: "",
+			desc:        "valid target pod namespaces",
+		},
+		{
+			scriptRunner: ScriptRunner{
+				Script:              "#!/bin/sh\nip addr",
+				TargetPodNamespaces: []ContainerNamespace{NetNamespace, "cgroup"},
+			},
+			errContains: "must be one of net, mnt, pid, ipc or uts",
+			desc:        "invalid target pod namespace",
+		},
+	}
+
+	for _, test := range tests {
+		operation := &Operation{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "operation1",
+			},
+			Spec: OperationSpec{
+				Processor: Processor{
+					ScriptRunner: test.scriptRunner.DeepCopy(),
+				},
+			},
+		}
+
+		err := operation.validateOperation()
+		if test.errContains == "" {
+			assert.NoError(t, err, test.desc)
+		} else {
+			assert.Error(t, err, test.desc)
+			assert.Contains(t, err.Error(), test.errContains, test.desc)
+		}
+	}
+}
+
+func TestValidateOperationRunLocation(t *testing.T) {
+	tests := []struct {
+		runLocation RunLocation
+		errContains string
+		desc        string
+	}{
+		{
+			runLocation: "",
+			errContains: "",
+			desc:        "run location not specified",
+		},
+		{
+			runLocation: MasterRunLocation,
+			errContains: "",
+			desc:        "master run location",
+		},
+		{
+			runLocation: AgentRunLocation,
+			errContains: "",
+			desc:        "agent run location",
+		},
+		{
+			runLocation: "node",
+			errContains: "must be either master or agent",
+			desc:        "unsupported run location",
+		},
+	}
+
+	for _, test := range tests {
+		operation := &Operation{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "operation1",
+			},
+			Spec: OperationSpec{
+				Processor: Processor{
+					ScriptRunner: &ScriptRunner{
+						Script: "#!/bin/sh\necho hello",
+					},
+					RunLocation: test.runLocation,
+				},
+			},
+		}
+
+		err := operation.validateOperation()
+		if test.errContains == "" {
+			assert.NoError(t, err, test.desc)
+		} else {
+			assert.Error(t, err, test.desc)
+			assert.Contains(t, err.Error(), test.errContains, test.desc)
+		}
+	}
+}
+
+func TestValidateOperationResultKeyNaming(t *testing.T) {
+	conformingPattern := `^collector\..+$`
+	invalidPattern := `^collector\.(`
+	tests := []struct {
+		resultKeyPattern *string
+		resultConditions []ResultCondition
+		sensitiveKeys    []string
+		resultJSONPaths  map[string]string
+		errContains      string
+		desc             string
+	}{
+		{
+			resultKeyPattern: nil,
+			resultConditions: []ResultCondition{{Key: "anything goes", Type: "HighMemory"}},
+			errContains:      "",
+			desc:             "naming is not validated without a pattern",
+		},
+		{
+			resultKeyPattern: &conformingPattern,
+			resultConditions: []ResultCondition{{Key: "collector.memory.high", Type: "HighMemory"}},
+			sensitiveKeys:    []string{"collector.credentials"},
+			resultJSONPaths:  map[string]string{"collector.phase": "{.status.phase}"},
+			errContains:      "",
+			desc:             "conforming result keys",
+		},
+		{
+			resultKeyPattern: &conformingPattern,
+			resultConditions: []ResultCondition{{Key: "high_memory", Type: "HighMemory"}},
+			errContains:      "must match result key pattern",
+			desc:             "non-conforming result condition key",
+		},
+		{
+			resultKeyPattern: &conformingPattern,
+			sensitiveKeys:    []string{"password"},
+			errContains:      "must match result key pattern",
+			desc:             "non-conforming sensitive key",
+		},
+		{
+			resultKeyPattern: &conformingPattern,
+			resultJSONPaths:  map[string]string{"phase": "{.status.phase}"},
+			errContains:      "must match result key pattern",
+			desc:             "non-conforming result jsonpath key",
+		},
+		{
+			resultKeyPattern: &invalidPattern,
+			errContains:      "must be a valid regular expression",
+			desc:             "invalid pattern",
+		},
+	}
+
+	for _, test := range tests {
+		path := "/processor"
+		scheme := "http"
+		operation := &Operation{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "operation1",
+			},
+			Spec: OperationSpec{
+				Processor: Processor{
+					HTTPServer: &HTTPServer{
+						Path:            &path,
+						Scheme:          &scheme,
+						ResultJSONPaths: test.resultJSONPaths,
+					},
+				},
+				ResultConditions: test.resultConditions,
+				SensitiveKeys:    test.sensitiveKeys,
+				ResultKeyPattern: test.resultKeyPattern,
+			},
+		}
+
+		err := operation.validateOperation()
+		if test.errContains == "" {
+			assert.NoError(t, err, test.desc)
+		} else {
+			if assert.Error(t, err, test.desc) {
+				assert.Contains(t, err.Error(), test.errContains, test.desc)
+			}
+		}
+	}
+}
@@ -17,16 +17,55 @@ limitations under the License.
 package v1
 
 import (
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
+const (
+	// TooManyPaths means the number of paths enumerated from the directed acyclic graph exceeds
+	// the maximum path limit of graph builder.
+	TooManyPaths OperationSetConditionType = "TooManyPaths"
+)
+
+// OperationSetConditionType is a valid value for OperationSetCondition.Type.
+type OperationSetConditionType string
+
 // OperationSetSpec defines the desired state of OperationSet.
 type OperationSetSpec struct {
 	// AdjacencyList contains all nodes in the directed acyclic graph. The first node in the list represents the
 	// start of a diagnosis.
 	AdjacencyList []Node `json:"adjacencyList"`
+	// PreOperations is the list of operations to be executed before the first operation of every path.
+	// +optional
+	PreOperations []string `json:"preOperations,omitempty"`
+	// PostOperations is the list of operations to be executed after the last operation of every path.
+	// +optional
+	PostOperations []string `json:"postOperations,omitempty"`
+	// Parameters is a set of default parameters to be passed to operations. The defaults are
+	// overridden by parameters of the same key in the diagnosis and in the adjacency node.
+	// +optional
+	Parameters map[string]string `json:"parameters,omitempty"`
+	// ExecutionMode specifies how operations of the operation set are scheduled. In Path mode
+	// linear paths enumerated from the directed acyclic graph are executed one after another.
+	// In DAG mode every node runs once after all its predecessors succeeded and independent
+	// nodes run in parallel.
+	// Defaults to Path.
+	// +optional
+	ExecutionMode ExecutionMode `json:"executionMode,omitempty"`
 }
 
+// ExecutionMode specifies how operations of an operation set are scheduled.
+type ExecutionMode string
+
+const (
+	// PathExecutionMode executes linear paths enumerated from the directed acyclic graph one
+	// after another.
+	PathExecutionMode ExecutionMode = "Path"
+	// DAGExecutionMode executes every node of the directed acyclic graph once after all its
+	// predecessors succeeded.
+	DAGExecutionMode ExecutionMode = "DAG"
+)
+
 // Node is a node in the directed acyclic graph. It contains details of the operation.
 type Node struct {
 	// ID is the unique identifier of the node.
@@ -43,6 +82,15 @@ type Node struct {
 	// Dependences is the list of depended node ids.
 	// +optional
 	Dependences NodeSet `json:"dependences,omitempty"`
+	// Parameters is a set of parameters to be passed to the operation running on this node only.
+	// It overrides parameters of the same key in the operation set and in the diagnosis.
+	// +optional
+	Parameters map[string]string `json:"parameters,omitempty"`
+	// AlwaysRun indicates that the operation on this node is executed even if an earlier
+	// operation in the path failed, e.g. for cleanup or final reporting. The path is still
+	// recorded as failed.
+	// +optional
+	AlwaysRun bool `json:"alwaysRun,omitempty"`
 }
 
 // NodeSet is the set of node ids.
@@ -53,10 +101,48 @@ type OperationSetStatus struct {
 	// Paths is the collection of all directed paths of the directed acyclic graph.
 	// +optional
 	Paths []Path `json:"paths,omitempty"`
+	// Conditions contains current service state of operation set.
+	// +optional
+	Conditions []OperationSetCondition `json:"conditions,omitempty"`
+	// Versions archives immutable snapshots of previous generations of the operation set.
+	// A running diagnosis pinned to an archived version keeps executing that version after
+	// the specification has been changed.
+	// +optional
+	Versions []OperationSetVersion `json:"versions,omitempty"`
 	// Specifies whether a valid directed acyclic graph can be generated via provided nodes.
 	Ready bool `json:"ready"`
 }
 
+// OperationSetVersion is an immutable snapshot of one generation of an operation set.
+type OperationSetVersion struct {
+	// Hash is the hash value calculated from the adjacency list of this version.
+	Hash string `json:"hash"`
+	// Spec is the specification of this version.
+	Spec OperationSetSpec `json:"spec"`
+	// Paths is the collection of all directed paths enumerated from this version.
+	// +optional
+	Paths []Path `json:"paths,omitempty"`
+}
+
+// OperationSetCondition contains details for the current condition of this operation set.
+type OperationSetCondition struct {
+	// Type is the type of the condition.
+	Type OperationSetConditionType `json:"type"`
+	// Status is the status of the condition.
+	// Can be True, False, Unknown.
+	Status corev1.ConditionStatus `json:"status"`
+	// LastTransitionTime specifies last time the condition transitioned from one status
+	// to another.
+	// +optional
+	LastTransitionTime metav1.Time `json:"lastTransitionTime,omitempty"`
+	// Reason is a unique, one-word, CamelCase reason for the condition's last transition.
+	// +optional
+	Reason string `json:"reason,omitempty"`
+	// Message is a human readable message indicating details about last transition.
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
 // Path represents a linear ordering of nodes along the direction of every directed edge.
 type Path []Node
 
@@ -17,11 +17,17 @@ limitations under the License.
 package v1
 
 import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/util/validation/field"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
 )
@@ -29,8 +35,14 @@ import (
 // operationsetlog is for logging in this package.
 var operationsetlog = logf.Log.WithName("operationset-webhook")
 
+// operationSetWebhookReader is used by the deletion webhook to list diagnoses referencing an
+// operation set. It is set on webhook setup.
+var operationSetWebhookReader client.Reader
+
 // SetupWebhookWithManager setups the OperationSet webhook.
 func (r *OperationSet) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	operationSetWebhookReader = mgr.GetClient()
+
 	return ctrl.NewWebhookManagedBy(mgr).
 		For(r).
 		Complete()
@@ -49,7 +61,7 @@ func (r *OperationSet) Default() {
 	}
 }
 
-// +kubebuilder:webhook:verbs=create;update,path=/validate-diagnosis-kubediag-org-v1-operationset,mutating=false,failurePolicy=fail,groups=diagnosis.kubediag.org,resources=operationsets,versions=v1,name=voperationset.kb.io
+// +kubebuilder:webhook:verbs=create;update;delete,path=/validate-diagnosis-kubediag-org-v1-operationset,mutating=false,failurePolicy=fail,groups=diagnosis.kubediag.org,resources=operationsets,versions=v1,name=voperationset.kb.io
 
 var _ webhook.Validator = &OperationSet{}
 
@@ -71,6 +83,32 @@ func (r *OperationSet) ValidateUpdate(old runtime.Object) error {
 func (r *OperationSet) ValidateDelete() error {
 	operationsetlog.Info("validating deletion of OperationSet", "operationset", r.Name)
 
+	// Deletion is not validated if no reader has been set up for the webhook.
+	if operationSetWebhookReader == nil {
+		return nil
+	}
+
+	var diagnosisList DiagnosisList
+	if err := operationSetWebhookReader.List(context.Background(), &diagnosisList); err != nil {
+		return fmt.Errorf("unable to list Diagnoses: %v", err)
+	}
+
+	// Block the deletion while a running diagnosis references the operation set. Deleting it
+	// mid-diagnosis would fail the diagnosis with an operation set not found condition.
+	referencers := make([]string, 0)
+	for _, diagnosis := range diagnosisList.Items {
+		if diagnosis.Spec.OperationSet == r.Name && diagnosis.Status.Phase == DiagnosisRunning {
+			referencers = append(referencers, fmt.Sprintf("%s/%s", diagnosis.Namespace, diagnosis.Name))
+		}
+	}
+	if len(referencers) != 0 {
+		sort.Strings(referencers)
+		return apierrors.NewForbidden(
+			schema.GroupResource{Group: "diagnosis.kubediag.org", Resource: "operationsets"},
+			r.Name,
+			fmt.Errorf("operation set is referenced by running diagnoses: %s", strings.Join(referencers, ", ")))
+	}
+
 	return nil
 }
 
@@ -86,6 +124,42 @@ func (r *OperationSet) validateOperationSet() error {
 			allErrs = append(allErrs, field.Invalid(field.NewPath("spec").Child("adjacencyList").Index(0),
 				r.Spec.AdjacencyList, "must not contains any operation or dependences"))
 		}
+
+		// Validate node ids are unique.
+		ids := make(map[int]struct{})
+		for i, node := range r.Spec.AdjacencyList {
+			if _, ok := ids[node.ID]; ok {
+				allErrs = append(allErrs, field.Invalid(field.NewPath("spec").Child("adjacencyList").Index(i),
+					node.ID, fmt.Sprintf("duplicate node id %d", node.ID)))
+				continue
+			}
+			ids[node.ID] = struct{}{}
+		}
+
+		// Validate all referenced to ids exist and count incoming edges.
+		indegrees := make(map[int]int)
+		for i, node := range r.Spec.AdjacencyList {
+			for _, to := range node.To {
+				if _, ok := ids[to]; !ok {
+					allErrs = append(allErrs, field.Invalid(field.NewPath("spec").Child("adjacencyList").Index(i).Child("to"),
+						node.To, fmt.Sprintf("node %d references nonexistent node %d", node.ID, to)))
+					continue
+				}
+				indegrees[to]++
+			}
+		}
+
+		// Validate there is exactly one root node without incoming edges.
+		roots := make([]int, 0)
+		for id := range ids {
+			if indegrees[id] == 0 {
+				roots = append(roots, id)
+			}
+		}
+		if len(roots) != 1 {
+			allErrs = append(allErrs, field.Invalid(field.NewPath("spec").Child("adjacencyList"),
+				r.Spec.AdjacencyList, fmt.Sprintf("must contain exactly one root node without incoming edges, found %d", len(roots))))
+		}
 	}
 	if len(allErrs) == 0 {
 		return nil
@@ -0,0 +1,184 @@
+/*
+Copyright 2022 The KubeDiag Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestValidateOperationSetAdjacencyList(t *testing.T) {
+	tests := []struct {
+		adjacencyList []Node
+		errContains   string
+		desc          string
+	}{
+		{
+			adjacencyList: []Node{
+				{
+					ID: 0,
+					To: NodeSet{1},
+				},
+				{
+					ID:        1,
+					Operation: "operation1",
+				},
+			},
+			errContains: "",
+			desc:        "valid adjacency list",
+		},
+		{
+			adjacencyList: []Node{
+				{
+					ID: 0,
+					To: NodeSet{1},
+				},
+				{
+					ID:        1,
+					Operation: "operation1",
+				},
+				{
+					ID:        1,
+					Operation: "operation2",
+				},
+			},
+			errContains: "duplicate node id 1",
+			desc:        "duplicate node ids",
+		},
+		{
+			adjacencyList: []Node{
+				{
+					ID: 0,
+					To: NodeSet{1, 2},
+				},
+				{
+					ID:        1,
+					Operation: "operation1",
+				},
+			},
+			errContains: "node 0 references nonexistent node 2",
+			desc:        "dangling to reference",
+		},
+		{
+			adjacencyList: []Node{
+				{
+					ID: 0,
+					To: NodeSet{2},
+				},
+				{
+					ID:        1,
+					Operation: "operation1",
+					To:        NodeSet{2},
+				},
+				{
+					ID:        2,
+					Operation: "operation2",
+				},
+			},
+			errContains: "must contain exactly one root node without incoming edges, found 2",
+			desc:        "multiple root nodes",
+		},
+	}
+
+	for _, test := range tests {
+		operationSet := &OperationSet{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "operationset1",
+			},
+			Spec: OperationSetSpec{
+				AdjacencyList: test.adjacencyList,
+			},
+		}
+
+		err := operationSet.validateOperationSet()
+		if test.errContains == "" {
+			assert.NoError(t, err, test.desc)
+		} else {
+			assert.Error(t, err, test.desc)
+			assert.Contains(t, err.Error(), test.errContains, test.desc)
+		}
+	}
+}
+
+func TestValidateOperationSetDelete(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = AddToScheme(scheme)
+
+	operationSet := &OperationSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "operationset1",
+		},
+	}
+	runningDiagnosis := &Diagnosis{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "diagnosis1",
+			Namespace: "default",
+		},
+		Spec: DiagnosisSpec{
+			OperationSet: "operationset1",
+		},
+		Status: DiagnosisStatus{
+			Phase: DiagnosisRunning,
+		},
+	}
+	succeededDiagnosis := &Diagnosis{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "diagnosis2",
+			Namespace: "default",
+		},
+		Spec: DiagnosisSpec{
+			OperationSet: "operationset1",
+		},
+		Status: DiagnosisStatus{
+			Phase: DiagnosisSucceeded,
+		},
+	}
+	otherRunningDiagnosis := &Diagnosis{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "diagnosis3",
+			Namespace: "default",
+		},
+		Spec: DiagnosisSpec{
+			OperationSet: "operationset2",
+		},
+		Status: DiagnosisStatus{
+			Phase: DiagnosisRunning,
+		},
+	}
+	defer func() {
+		operationSetWebhookReader = nil
+	}()
+
+	// Deletion is blocked while a running diagnosis references the operation set.
+	operationSetWebhookReader = fake.NewFakeClientWithScheme(scheme, runningDiagnosis, succeededDiagnosis, otherRunningDiagnosis)
+	err := operationSet.ValidateDelete()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "default/diagnosis1")
+	}
+
+	// Deletion is allowed once no running diagnosis references the operation set.
+	operationSetWebhookReader = fake.NewFakeClientWithScheme(scheme, succeededDiagnosis, otherRunningDiagnosis)
+	assert.NoError(t, operationSet.ValidateDelete())
+
+	// Deletion is not validated if no reader has been set up.
+	operationSetWebhookReader = nil
+	assert.NoError(t, operationSet.ValidateDelete())
+}
@@ -139,6 +139,7 @@ type TaskCondition struct {
 // +kubebuilder:printcolumn:JSONPath=".spec.podReference.name",name=PodName,type=string
 // +kubebuilder:printcolumn:JSONPath=".spec.podReference.container",name=PodContainer,type=string
 // +kubebuilder:printcolumn:JSONPath=".status.phase",name=Phase,type=string
+// +kubebuilder:printcolumn:JSONPath=".status.startTime",name=StartTime,type=date,priority=1
 // +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
 
 // Task is the Schema for the tasks API.
@@ -77,6 +77,12 @@ type PrometheusAlertTemplate struct {
 	// All label names and values will be set as key value pairs in ".spec.parameters" field.
 	// +optional
 	ParameterInjectionLabels []model.LabelName `json:"parameterInjectionLabels,omitempty"`
+	// DedupKeyLabels specifies the labels used to build the de-duplication key of generated
+	// diagnoses. Alerts with identical values of the labels map to the same diagnosis name, so
+	// repeated identical alerts are skipped instead of spawning duplicate diagnoses. Diagnosis
+	// names are unique per alert firing if not specified.
+	// +optional
+	DedupKeyLabels []model.LabelName `json:"dedupKeyLabels,omitempty"`
 }
 
 // PrometheusAlertTemplateRegexp is the regular expression for matching prometheus alert template.
@@ -163,6 +169,10 @@ type CronTemplate struct {
 	// Schedule is the schedule in cron format.
 	// See https://en.wikipedia.org/wiki/Cron for more details.
 	Schedule string `json:"schedule"`
+	// StartingDeadlineSeconds is the deadline in seconds for starting the diagnosis if it misses
+	// its scheduled time for any reason. Missed schedules beyond the deadline are skipped.
+	// +optional
+	StartingDeadlineSeconds *int64 `json:"startingDeadlineSeconds,omitempty"`
 }
 
 // TriggerStatus defines the observed state of Trigger.
@@ -35,6 +35,7 @@ func (in *Checkpoint) DeepCopyInto(out *Checkpoint) {
 		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
+	in.PathStartTime.DeepCopyInto(&out.PathStartTime)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Checkpoint.
@@ -181,6 +182,11 @@ func (in *CommonEventTemplateRegexp) DeepCopy() *CommonEventTemplateRegexp {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *CronTemplate) DeepCopyInto(out *CronTemplate) {
 	*out = *in
+	if in.StartingDeadlineSeconds != nil {
+		in, out := &in.StartingDeadlineSeconds, &out.StartingDeadlineSeconds
+		*out = new(int64)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CronTemplate.
@@ -332,6 +338,21 @@ func (in *DiagnosisSpec) DeepCopyInto(out *DiagnosisSpec) {
 		*out = new(TargetSelector)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.Parallelism != nil {
+		in, out := &in.Parallelism, &out.Parallelism
+		*out = new(int32)
+		**out = **in
+	}
+	if in.CompletionWebhook != nil {
+		in, out := &in.CompletionWebhook, &out.CompletionWebhook
+		*out = new(string)
+		**out = **in
+	}
+	if in.PathTimeoutSeconds != nil {
+		in, out := &in.PathTimeoutSeconds, &out.PathTimeoutSeconds
+		*out = new(int64)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DiagnosisSpec.
@@ -355,6 +376,7 @@ func (in *DiagnosisStatus) DeepCopyInto(out *DiagnosisStatus) {
 		}
 	}
 	in.StartTime.DeepCopyInto(&out.StartTime)
+	in.EndTime.DeepCopyInto(&out.EndTime)
 	if in.NodeNames != nil {
 		in, out := &in.NodeNames, &out.NodeNames
 		*out = make([]string, len(*in))
@@ -385,6 +407,13 @@ func (in *DiagnosisStatus) DeepCopyInto(out *DiagnosisStatus) {
 		*out = new(Checkpoint)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.ExecutedOperations != nil {
+		in, out := &in.ExecutedOperations, &out.ExecutedOperations
+		*out = make([]ExecutedOperation, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 	if in.Context != nil {
 		in, out := &in.Context, &out.Context
 		*out = new(DiagnosisContext)
@@ -402,6 +431,23 @@ func (in *DiagnosisStatus) DeepCopy() *DiagnosisStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ExecutedOperation) DeepCopyInto(out *ExecutedOperation) {
+	*out = *in
+	in.StartTime.DeepCopyInto(&out.StartTime)
+	in.EndTime.DeepCopyInto(&out.EndTime)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ExecutedOperation.
+func (in *ExecutedOperation) DeepCopy() *ExecutedOperation {
+	if in == nil {
+		return nil
+	}
+	out := new(ExecutedOperation)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Function) DeepCopyInto(out *Function) {
 	*out = *in
@@ -412,6 +458,11 @@ func (in *Function) DeepCopyInto(out *Function) {
 			(*out)[key] = val
 		}
 	}
+	if in.SuccessStatusCodes != nil {
+		in, out := &in.SuccessStatusCodes, &out.SuccessStatusCodes
+		*out = make([]int32, len(*in))
+		copy(*out, *in)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Function.
@@ -447,6 +498,23 @@ func (in *HTTPServer) DeepCopyInto(out *HTTPServer) {
 		*out = new(string)
 		**out = **in
 	}
+	if in.AuthSecretRef != nil {
+		in, out := &in.AuthSecretRef, &out.AuthSecretRef
+		*out = new(NamespacedName)
+		**out = **in
+	}
+	if in.ResultJSONPaths != nil {
+		in, out := &in.ResultJSONPaths, &out.ResultJSONPaths
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.SuccessStatusCodes != nil {
+		in, out := &in.SuccessStatusCodes, &out.SuccessStatusCodes
+		*out = make([]int32, len(*in))
+		copy(*out, *in)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HTTPServer.
@@ -534,6 +602,13 @@ func (in *Node) DeepCopyInto(out *Node) {
 		*out = make(NodeSet, len(*in))
 		copy(*out, *in)
 	}
+	if in.Parameters != nil {
+		in, out := &in.Parameters, &out.Parameters
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Node.
@@ -565,6 +640,31 @@ func (in NodeSet) DeepCopy() NodeSet {
 	return *out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NodeTelemetry) DeepCopyInto(out *NodeTelemetry) {
+	*out = *in
+	if in.Labels != nil {
+		in, out := &in.Labels, &out.Labels
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Annotations != nil {
+		in, out := &in.Annotations, &out.Annotations
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NodeTelemetry.
+func (in *NodeTelemetry) DeepCopy() *NodeTelemetry {
+	if in == nil {
+		return nil
+	}
+	out := new(NodeTelemetry)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Operation) DeepCopyInto(out *Operation) {
 	*out = *in
@@ -681,6 +781,22 @@ func (in *OperationSet) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OperationSetCondition) DeepCopyInto(out *OperationSetCondition) {
+	*out = *in
+	in.LastTransitionTime.DeepCopyInto(&out.LastTransitionTime)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OperationSetCondition.
+func (in *OperationSetCondition) DeepCopy() *OperationSetCondition {
+	if in == nil {
+		return nil
+	}
+	out := new(OperationSetCondition)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *OperationSetList) DeepCopyInto(out *OperationSetList) {
 	*out = *in
@@ -723,6 +839,23 @@ func (in *OperationSetSpec) DeepCopyInto(out *OperationSetSpec) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.PreOperations != nil {
+		in, out := &in.PreOperations, &out.PreOperations
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.PostOperations != nil {
+		in, out := &in.PostOperations, &out.PostOperations
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Parameters != nil {
+		in, out := &in.Parameters, &out.Parameters
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OperationSetSpec.
@@ -751,6 +884,20 @@ func (in *OperationSetStatus) DeepCopyInto(out *OperationSetStatus) {
 			}
 		}
 	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]OperationSetCondition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Versions != nil {
+		in, out := &in.Versions, &out.Versions
+		*out = make([]OperationSetVersion, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OperationSetStatus.
@@ -763,6 +910,35 @@ func (in *OperationSetStatus) DeepCopy() *OperationSetStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OperationSetVersion) DeepCopyInto(out *OperationSetVersion) {
+	*out = *in
+	in.Spec.DeepCopyInto(&out.Spec)
+	if in.Paths != nil {
+		in, out := &in.Paths, &out.Paths
+		*out = make([]Path, len(*in))
+		for i := range *in {
+			if (*in)[i] != nil {
+				in, out := &(*in)[i], &(*out)[i]
+				*out = make(Path, len(*in))
+				for i := range *in {
+					(*in)[i].DeepCopyInto(&(*out)[i])
+				}
+			}
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OperationSetVersion.
+func (in *OperationSetVersion) DeepCopy() *OperationSetVersion {
+	if in == nil {
+		return nil
+	}
+	out := new(OperationSetVersion)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *OperationSpec) DeepCopyInto(out *OperationSpec) {
 	*out = *in
@@ -777,6 +953,38 @@ func (in *OperationSpec) DeepCopyInto(out *OperationSpec) {
 		*out = new(Storage)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.SensitiveKeys != nil {
+		in, out := &in.SensitiveKeys, &out.SensitiveKeys
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.ParameterTypes != nil {
+		in, out := &in.ParameterTypes, &out.ParameterTypes
+		*out = make(map[string]ParameterType, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.ResultTTLSeconds != nil {
+		in, out := &in.ResultTTLSeconds, &out.ResultTTLSeconds
+		*out = new(int32)
+		**out = **in
+	}
+	if in.ResultConditions != nil {
+		in, out := &in.ResultConditions, &out.ResultConditions
+		*out = make([]ResultCondition, len(*in))
+		copy(*out, *in)
+	}
+	if in.ResultKeyPattern != nil {
+		in, out := &in.ResultKeyPattern, &out.ResultKeyPattern
+		*out = new(string)
+		**out = **in
+	}
+	if in.NodeTelemetry != nil {
+		in, out := &in.NodeTelemetry, &out.NodeTelemetry
+		*out = new(NodeTelemetry)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OperationSpec.
@@ -870,6 +1078,11 @@ func (in *PrometheusAlertTemplate) DeepCopyInto(out *PrometheusAlertTemplate) {
 		*out = make([]model.LabelName, len(*in))
 		copy(*out, *in)
 	}
+	if in.DedupKeyLabels != nil {
+		in, out := &in.DedupKeyLabels, &out.DedupKeyLabels
+		*out = make([]model.LabelName, len(*in))
+		copy(*out, *in)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PrometheusAlertTemplate.
@@ -911,6 +1124,21 @@ func (in *PrometheusAlertTemplateRegexp) DeepCopy() *PrometheusAlertTemplateRege
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ResultCondition) DeepCopyInto(out *ResultCondition) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ResultCondition.
+func (in *ResultCondition) DeepCopy() *ResultCondition {
+	if in == nil {
+		return nil
+	}
+	out := new(ResultCondition)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ScriptRunner) DeepCopyInto(out *ScriptRunner) {
 	*out = *in
@@ -924,6 +1152,23 @@ func (in *ScriptRunner) DeepCopyInto(out *ScriptRunner) {
 		*out = new(string)
 		**out = **in
 	}
+	if in.Interpreter != nil {
+		in, out := &in.Interpreter, &out.Interpreter
+		*out = new(string)
+		**out = **in
+	}
+	if in.Files != nil {
+		in, out := &in.Files, &out.Files
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.TargetPodNamespaces != nil {
+		in, out := &in.TargetPodNamespaces, &out.TargetPodNamespaces
+		*out = make([]ContainerNamespace, len(*in))
+		copy(*out, *in)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ScriptRunner.
@@ -957,7 +1202,7 @@ func (in *SourceTemplate) DeepCopyInto(out *SourceTemplate) {
 	if in.CronTemplate != nil {
 		in, out := &in.CronTemplate, &out.CronTemplate
 		*out = new(CronTemplate)
-		**out = **in
+		(*in).DeepCopyInto(*out)
 	}
 }
 
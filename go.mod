@@ -14,6 +14,7 @@ require (
 	github.com/elastic/go-elasticsearch/v7 v7.13.1
 	github.com/go-logr/logr v0.1.0
 	github.com/go-ole/go-ole v1.2.4 // indirect
+	github.com/google/uuid v1.1.1
 	github.com/gorilla/mux v1.7.4
 	github.com/jhoonb/archivex v0.0.0-20201016144719-6a343cdae81d
 	github.com/joewalnes/websocketd v0.4.1
@@ -25,6 +26,7 @@ require (
 	github.com/pkg/errors v0.9.1
 	github.com/prometheus/alertmanager v0.21.0
 	github.com/prometheus/client_golang v1.7.1
+	github.com/prometheus/client_model v0.2.0
 	github.com/prometheus/common v0.12.0
 	github.com/robfig/cron v1.2.0
 	github.com/segmentio/kafka-go v0.4.16
@@ -35,6 +37,7 @@ require (
 	github.com/vmware-tanzu/sonobuoy v0.18.4
 	golang.org/x/exp v0.0.0-20210220032938-85be41e4509f // indirect
 	golang.org/x/lint v0.0.0-20200302205851-738671d3881b // indirect
+	golang.org/x/time v0.0.0-20191024005414-555d28b269f0
 	golang.org/x/tools v0.1.0 // indirect
 	gonum.org/v1/gonum v0.9.0
 	gopkg.in/yaml.v2 v2.3.0
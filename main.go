@@ -19,6 +19,7 @@ package main
 import (
 	"context"
 	"fmt"
+	"io/ioutil"
 	"net/http"
 	"net/http/pprof"
 	"os"
@@ -41,17 +42,27 @@ import (
 
 	diagnosisv1 "github.com/kubediag/kubediag/api/v1"
 	"github.com/kubediag/kubediag/pkg/alertmanager"
+	"github.com/kubediag/kubediag/pkg/artifactserver"
+	"github.com/kubediag/kubediag/pkg/clusterhealthevaluator"
 	"github.com/kubediag/kubediag/pkg/commoneventer"
 	"github.com/kubediag/kubediag/pkg/controllers"
 	"github.com/kubediag/kubediag/pkg/cronscheduler"
+	"github.com/kubediag/kubediag/pkg/diagnosisbatcher"
+	"github.com/kubediag/kubediag/pkg/diagnosisstreamer"
 	"github.com/kubediag/kubediag/pkg/eventer"
 	"github.com/kubediag/kubediag/pkg/executor"
 	"github.com/kubediag/kubediag/pkg/features"
 	"github.com/kubediag/kubediag/pkg/garbagecollection"
 	"github.com/kubediag/kubediag/pkg/graphbuilder"
 	"github.com/kubediag/kubediag/pkg/kafka"
+	"github.com/kubediag/kubediag/pkg/localdiagnoser"
+	"github.com/kubediag/kubediag/pkg/nodehealthevaluator"
+	"github.com/kubediag/kubediag/pkg/nodesummarizer"
+	"github.com/kubediag/kubediag/pkg/nodewatcher"
 	"github.com/kubediag/kubediag/pkg/pagerdutyeventer"
+	"github.com/kubediag/kubediag/pkg/processors"
 	"github.com/kubediag/kubediag/pkg/processors/register"
+	"github.com/kubediag/kubediag/pkg/util"
 	// +kubebuilder:scaffold:imports
 )
 
@@ -83,6 +94,12 @@ type KubeDiagOptions struct {
 	Host string
 	// CertDir is the directory that contains the server key and certificate.
 	CertDir string
+	// ServeTLS indicates whether the kubediag http api is served over tls using the server key
+	// and certificate in CertDir. The api is served over plain http if not specified.
+	ServeTLS bool
+	// AuthTokenFile is the file containing a static bearer token required to access the agent
+	// http api. Authentication is disabled if empty. Health check paths are exempt.
+	AuthTokenFile string
 	// AlertmanagerRepeatInterval specifies how long to wait before sending a notification again if it has
 	// already been sent successfully for an alert.
 	AlertmanagerRepeatInterval time.Duration
@@ -100,13 +117,74 @@ type KubeDiagOptions struct {
 	MinimumTaskTTLDuration time.Duration
 	// MaximumTasksPerNode is maximum number of finished tasks to retain per node.
 	MaximumTasksPerNode int32
+	// TaskReaperInterval is the interval between garbage collection runs of the task reaper.
+	// A quarter of TaskTTL is used if it is zero.
+	TaskReaperInterval time.Duration
 	// CommonEventTTL is amount of time to retain common events.
 	CommonEventTTL time.Duration
+	// MaximumPathsPerOperationSet is maximum number of paths to enumerate from an operation set.
+	MaximumPathsPerOperationSet int
+	// GraphBuilderRequeueBaseDelay is the base delay for requeueing an operation set after a failed sync.
+	GraphBuilderRequeueBaseDelay time.Duration
+	// GraphBuilderRequeueMaxDelay is the maximum delay for requeueing an operation set after a failed sync.
+	GraphBuilderRequeueMaxDelay time.Duration
+	// GraphBuilderWorkers is the number of workers syncing operation sets concurrently.
+	GraphBuilderWorkers int
+	// DiagnosisLabelSelector is the label selector for filtering diagnoses to be reconciled by the master.
+	DiagnosisLabelSelector string
+	// OperationSetReadinessGracePeriod is the amount of time a diagnosis waits for its operation
+	// set to become ready before failing.
+	OperationSetReadinessGracePeriod time.Duration
+	// DefaultOperationSet is the operation set to inject into source generated diagnoses without
+	// an operation set.
+	DefaultOperationSet string
+	// SourceDiagnosisCreationQPS is the maximum sustained rate of diagnosis creations per source.
+	// All creations are allowed if not positive.
+	SourceDiagnosisCreationQPS float64
 	// FeatureGates is a map of feature names to bools that enable or disable features. This field modifies
 	// piecemeal the default values from "github.com/kubediag/kubediag/pkg/features/features.go".
 	FeatureGates map[string]bool
 	// DataRoot is root directory of persistent kubediag data.
 	DataRoot string
+	// FunctionNamespace is the namespace in which function pods are created.
+	FunctionNamespace string
+	// MaximumDataRootBytes is maximum total size in bytes of files retained under the data root.
+	MaximumDataRootBytes int64
+	// ResultCompressionThresholdBytes is the size in bytes above which result values are stored
+	// gzip compressed and base64 encoded in statuses.
+	ResultCompressionThresholdBytes int
+	// AllowedScriptCommands contains regular expressions matched against the base names of
+	// commands invoked by script runner scripts on the node. Scripts invoking commands not
+	// matched by any entry are rejected. Commands are not restricted if empty.
+	AllowedScriptCommands []string
+	// ExecutorDrainTimeout is the maximum amount of time to wait for running task syncs to
+	// complete on shutdown.
+	ExecutorDrainTimeout time.Duration
+	// ProfilerMinExpirationSeconds is the minimum expiration of profiler endpoints. Requested
+	// expirations below the bound are clamped to it.
+	ProfilerMinExpirationSeconds int
+	// ProfilerMaxExpirationSeconds is the maximum expiration of profiler endpoints. Requested
+	// expirations above the bound are clamped to it.
+	ProfilerMaxExpirationSeconds int
+	// MaxStatusConditions is the maximum number of conditions retained in a diagnosis or task
+	// status. The oldest non terminal conditions beyond the limit are pruned.
+	MaxStatusConditions int
+	// NodeConditionOperationSet is the name of referenced operation set in diagnoses generated
+	// by node watcher.
+	NodeConditionOperationSet string
+	// NodeConditionGracePeriod is the amount of time an unhealthy node condition must persist
+	// before a diagnosis is generated.
+	NodeConditionGracePeriod time.Duration
+	// ClusterHealthUpdateInterval is the interval at which cluster health is evaluated.
+	ClusterHealthUpdateInterval time.Duration
+	// ProcessorKeepAlives indicates whether http keep-alives are enabled for processor requests.
+	ProcessorKeepAlives bool
+	// ProcessorMaxIdleConnsPerHost is the maximum number of idle connections pooled per processor
+	// if keep-alives are enabled.
+	ProcessorMaxIdleConnsPerHost int
+	// ProcessorTLSInsecureSkipVerify indicates whether tls certificate verification is skipped
+	// for processor requests.
+	ProcessorTLSInsecureSkipVerify bool
 	// SinkEventToKafka enables the pagerduty handler to write message to kafka cluster.
 	SinkEventToKafka bool
 	// KafkaAddress is the addresses used to connect to the kafka cluster.
@@ -161,21 +239,35 @@ of an Diagnosis.`,
 // NewKubeDiagOptions creates a new KubeDiagOptions with a default config.
 func NewKubeDiagOptions() (*KubeDiagOptions, error) {
 	return &KubeDiagOptions{
-		Mode:                       "agent",
-		BindAddress:                "0.0.0.0",
-		Port:                       8090,
-		MetricsPort:                10357,
-		EnableLeaderElection:       false,
-		WebhookPort:                9443,
-		CertDir:                    defaultCertDir,
-		AlertmanagerRepeatInterval: 6 * time.Hour,
-		TaskTTL:                    240 * time.Hour,
-		MinimumTaskTTLDuration:     30 * time.Minute,
-		MaximumTasksPerNode:        50,
-		CommonEventTTL:             2400 * time.Hour,
-		DataRoot:                   defaultDataRoot,
-		SinkEventToKafka:           false,
-		SinkEventToWebhookReceiver: false,
+		Mode:                             "agent",
+		BindAddress:                      "0.0.0.0",
+		Port:                             8090,
+		MetricsPort:                      10357,
+		EnableLeaderElection:             false,
+		WebhookPort:                      9443,
+		CertDir:                          defaultCertDir,
+		AlertmanagerRepeatInterval:       6 * time.Hour,
+		TaskTTL:                          240 * time.Hour,
+		MinimumTaskTTLDuration:           30 * time.Minute,
+		NodeConditionGracePeriod:         5 * time.Minute,
+		ClusterHealthUpdateInterval:      10 * time.Minute,
+		ProcessorMaxIdleConnsPerHost:     16,
+		ProcessorTLSInsecureSkipVerify:   true,
+		MaximumTasksPerNode:              50,
+		CommonEventTTL:                   2400 * time.Hour,
+		MaximumPathsPerOperationSet:      1024,
+		GraphBuilderRequeueBaseDelay:     graphbuilder.DefaultRequeueBaseDelay,
+		GraphBuilderRequeueMaxDelay:      graphbuilder.DefaultRequeueMaxDelay,
+		GraphBuilderWorkers:              graphbuilder.DefaultWorkers,
+		OperationSetReadinessGracePeriod: 30 * time.Second,
+		DataRoot:                         defaultDataRoot,
+		FunctionNamespace:                executor.DefaultFunctionNamespace,
+		ExecutorDrainTimeout:             executor.DefaultDrainTimeout,
+		ProfilerMinExpirationSeconds:     processors.DefaultMinExpirationSeconds,
+		ProfilerMaxExpirationSeconds:     processors.DefaultMaxExpirationSeconds,
+		MaxStatusConditions:              util.DefaultMaxStatusConditions,
+		SinkEventToKafka:                 false,
+		SinkEventToWebhookReceiver:       false,
 	}, nil
 }
 
@@ -190,6 +282,8 @@ func (opts *KubeDiagOptions) Run() error {
 		return fmt.Errorf("unable to set feature gates: %v", err)
 	}
 
+	util.SetMaxStatusConditions(opts.MaxStatusConditions)
+
 	// Create a new docker client for Operation controller to run Function Processor.
 	dockerCli, err := dockerclient.NewClientWithOpts(dockerclient.WithHost(opts.DockerEndpoint))
 	if err != nil {
@@ -244,11 +338,18 @@ func (opts *KubeDiagOptions) Run() error {
 			mgr.GetScheme(),
 			mgr.GetCache(),
 			graphBuilderCh,
+			opts.GraphBuilderRequeueBaseDelay,
+			opts.GraphBuilderRequeueMaxDelay,
+			opts.MaximumPathsPerOperationSet,
+			opts.GraphBuilderWorkers,
 		)
 		go func(stopCh chan struct{}) {
 			graphbuilder.Run(stopCh)
 		}(stopCh)
 
+		// Create rate limiter for throttling diagnosis creations per source.
+		sourceRateLimiter := util.NewSourceRateLimiter(opts.SourceDiagnosisCreationQPS)
+
 		// Create alertmanager for managing prometheus alerts.
 		alertmanager := alertmanager.NewAlertmanager(
 			context.Background(),
@@ -256,6 +357,7 @@ func (opts *KubeDiagOptions) Run() error {
 			mgr.GetClient(),
 			mgr.GetCache(),
 			opts.AlertmanagerRepeatInterval,
+			sourceRateLimiter,
 			featureGate.Enabled(features.Alertmanager),
 		)
 
@@ -266,6 +368,7 @@ func (opts *KubeDiagOptions) Run() error {
 			mgr.GetClient(),
 			mgr.GetCache(),
 			eventChainCh,
+			sourceRateLimiter,
 			featureGate.Enabled(features.Eventer),
 		)
 		go func(stopCh chan struct{}) {
@@ -306,6 +409,49 @@ func (opts *KubeDiagOptions) Run() error {
 			cronscheduler.Run(stopCh)
 		}(stopCh)
 
+		// Create node watcher for managing node conditions.
+		nodeWatcher := nodewatcher.NewNodeWatcher(
+			context.Background(),
+			ctrl.Log.WithName("nodewatcher"),
+			mgr.GetClient(),
+			mgr.GetCache(),
+			opts.NodeConditionOperationSet,
+			opts.NodeConditionGracePeriod,
+			featureGate.Enabled(features.NodeConditionWatcher),
+		)
+		go func(stopCh chan struct{}) {
+			nodeWatcher.Run(stopCh)
+		}(stopCh)
+
+		// Create cluster health evaluator for evaluating cluster health.
+		clusterHealthEvaluator := clusterhealthevaluator.NewClusterHealthEvaluator(
+			context.Background(),
+			ctrl.Log.WithName("clusterhealthevaluator"),
+			mgr.GetClient(),
+			mgr.GetCache(),
+			opts.ClusterHealthUpdateInterval,
+			featureGate.Enabled(features.ClusterHealthEvaluator),
+		)
+		go func(stopCh chan struct{}) {
+			clusterHealthEvaluator.Run(stopCh)
+		}(stopCh)
+
+		// Setup diagnosis streamer for streaming live progress of running diagnoses.
+		diagnosisStreamer := diagnosisstreamer.NewDiagnosisStreamer(
+			context.Background(),
+			ctrl.Log.WithName("diagnosisstreamer"),
+			mgr.GetCache(),
+			diagnosisstreamer.DefaultPollInterval,
+		)
+
+		// Setup diagnosis batcher for creating diagnoses in bulk.
+		diagnosisBatcher := diagnosisbatcher.NewDiagnosisBatcher(
+			context.Background(),
+			ctrl.Log.WithName("diagnosisbatcher"),
+			mgr.GetClient(),
+			mgr.GetCache(),
+		)
+
 		// Create kafka consumer for managing kafka messages.
 		if len(opts.KafkaBrokers) != 0 && opts.KafkaTopic != "" {
 			kafkaConsumer, err := kafka.NewConsumer(
@@ -331,10 +477,14 @@ func (opts *KubeDiagOptions) Run() error {
 			r.HandleFunc("/api/v1/alerts", alertmanager.Handler)
 			r.HandleFunc("/pagerduty", pagerdutyEventer.Handler)
 			r.HandleFunc("/api/v1/commonevent", commonEventer.Handler)
+			r.HandleFunc("/api/v1/operationsets/{name}/paths", graphbuilder.Handler)
+			r.HandleFunc("/api/v1/cluster/health", clusterHealthEvaluator.Handler)
+			r.HandleFunc("/api/v1/diagnoses/batch", diagnosisBatcher.Handler)
+			r.HandleFunc("/api/v1/diagnoses/{namespace}/{name}/events", diagnosisStreamer.Handler)
 
 			// Start pprof server.
 			r.PathPrefix("/debug/pprof/").HandlerFunc(pprof.Index)
-			if err := http.ListenAndServe(fmt.Sprintf("%s:%d", opts.BindAddress, opts.Port), r); err != nil {
+			if err := util.ListenAndServe(fmt.Sprintf("%s:%d", opts.BindAddress, opts.Port), r, opts.ServeTLS, opts.CertDir); err != nil {
 				setupLog.Error(err, "unable to start http server")
 				close(stopCh)
 			}
@@ -348,6 +498,8 @@ func (opts *KubeDiagOptions) Run() error {
 			mgr.GetEventRecorderFor("kubediag/diagnosisreconciler"),
 			opts.NodeName,
 			diagnosisCh,
+			opts.DiagnosisLabelSelector,
+			opts.OperationSetReadinessGracePeriod,
 		)).SetupWithManager(mgr); err != nil {
 			setupLog.Error(err, "unable to create controller", "controller", "Diagnosis")
 			return fmt.Errorf("unable to create controller for Diagnosis: %v", err)
@@ -392,6 +544,7 @@ func (opts *KubeDiagOptions) Run() error {
 		}
 
 		// Setup webhooks for Diagnosis, Trigger and Operation.
+		diagnosisv1.SetDefaultOperationSet(opts.DefaultOperationSet)
 		if err = (&diagnosisv1.Diagnosis{}).SetupWebhookWithManager(mgr); err != nil {
 			setupLog.Error(err, "unable to create webhook", "webhook", "Diagnosis")
 			return fmt.Errorf("unable to create webhook for Diagnosis: %v", err)
@@ -455,6 +608,10 @@ func (opts *KubeDiagOptions) Run() error {
 			opts.BindAddress,
 			opts.Port,
 			opts.DataRoot,
+			opts.FunctionNamespace,
+			opts.ResultCompressionThresholdBytes,
+			opts.AllowedScriptCommands,
+			executor.NewProcessorTransport(opts.ProcessorKeepAlives, opts.ProcessorMaxIdleConnsPerHost, opts.ProcessorTLSInsecureSkipVerify),
 			taskCh,
 		)
 		go func(stopCh chan struct{}) {
@@ -472,23 +629,74 @@ func (opts *KubeDiagOptions) Run() error {
 			opts.TaskTTL,
 			opts.MinimumTaskTTLDuration,
 			opts.MaximumTasksPerNode,
+			opts.TaskReaperInterval,
 			opts.DataRoot,
 		)
 		go func(stopCh chan struct{}) {
 			taskReaper.Run(stopCh)
 		}(stopCh)
 
+		// Run data root janitor to enforce the data root quota.
+		dataRootJanitor := garbagecollection.NewDataRootJanitor(
+			context.Background(),
+			ctrl.Log.WithName("datarootjanitor"),
+			opts.DataRoot,
+			opts.MaximumDataRootBytes,
+		)
+		go func(stopCh chan struct{}) {
+			dataRootJanitor.Run(stopCh)
+		}(stopCh)
+
 		router := mux.NewRouter()
 		router.HandleFunc("/healthz", HealthCheckHandler)
+
+		// Setup local diagnoser for creating diagnoses targeting the local node.
+		localDiagnoser := localdiagnoser.NewLocalDiagnoser(
+			context.Background(),
+			ctrl.Log.WithName("localdiagnoser"),
+			mgr.GetClient(),
+			opts.NodeName,
+		)
+		router.HandleFunc("/api/v1/diagnoses/local", localDiagnoser.Handler)
+
+		// Setup node health evaluator for reporting the aggregated health of the local node.
+		nodeHealthEvaluator := nodehealthevaluator.NewNodeHealthEvaluator(
+			context.Background(),
+			ctrl.Log.WithName("nodehealthevaluator"),
+			mgr.GetCache(),
+			opts.NodeName,
+			opts.DataRoot,
+		)
+		router.HandleFunc("/api/v1/node/health", nodeHealthEvaluator.Handler)
+
+		// Setup node summarizer for listing diagnoses and tasks assigned to the node.
+		nodeSummarizer := nodesummarizer.NewNodeSummarizer(
+			context.Background(),
+			ctrl.Log.WithName("nodesummarizer"),
+			mgr.GetCache(),
+			opts.NodeName,
+		)
+		router.HandleFunc("/api/v1/node/summary", nodeSummarizer.Handler)
+
+		// Setup artifact server for serving operation result artifacts over the agent port.
+		artifactServer := artifactserver.NewArtifactServer(
+			context.Background(),
+			ctrl.Log.WithName("artifactserver"),
+		)
+		router.HandleFunc("/artifacts/{token}", artifactServer.Handler)
 		// Start pprof server.
 		router.PathPrefix("/debug/pprof/").HandlerFunc(pprof.Index)
 
 		// Setup operation processors.
 		registryOpt := &register.RegistryOption{
-			NodeName:       opts.NodeName,
-			DockerEndpoint: opts.DockerEndpoint,
-			DataRoot:       opts.DataRoot,
-			BindAddress:    opts.BindAddress,
+			NodeName:                     opts.NodeName,
+			DockerEndpoint:               opts.DockerEndpoint,
+			DataRoot:                     opts.DataRoot,
+			BindAddress:                  opts.BindAddress,
+			Port:                         opts.Port,
+			ArtifactServer:               artifactServer,
+			ProfilerMinExpirationSeconds: opts.ProfilerMinExpirationSeconds,
+			ProfilerMaxExpirationSeconds: opts.ProfilerMaxExpirationSeconds,
 		}
 		err = register.RegisterProcessors(mgr, registryOpt, featureGate, router, setupLog)
 		if err != nil {
@@ -496,9 +704,20 @@ func (opts *KubeDiagOptions) Run() error {
 			return fmt.Errorf("unable to register processors for Diagnosis: %v", err)
 		}
 
+		// Require a bearer token on the agent http api if an auth token file is specified.
+		var handler http.Handler = router
+		if opts.AuthTokenFile != "" {
+			token, err := ioutil.ReadFile(opts.AuthTokenFile)
+			if err != nil {
+				setupLog.Error(err, "unable to read auth token file")
+				return fmt.Errorf("unable to read auth token file: %v", err)
+			}
+			handler = util.NewBearerTokenAuthHandler(router, strings.TrimSpace(string(token)))
+		}
+
 		// Start http server.
 		go func(stopCh chan struct{}) {
-			if err := http.ListenAndServe(fmt.Sprintf("%s:%d", opts.BindAddress, opts.Port), router); err != nil {
+			if err := util.ListenAndServe(fmt.Sprintf("%s:%d", opts.BindAddress, opts.Port), handler, opts.ServeTLS, opts.CertDir); err != nil {
 				setupLog.Error(err, "unable to start http server")
 				close(stopCh)
 			}
@@ -532,6 +751,12 @@ func (opts *KubeDiagOptions) Run() error {
 			setupLog.Error(err, "problem running manager")
 			return fmt.Errorf("problem running manager: %v", err)
 		}
+
+		// Drain running task syncs before exiting so tasks are not abandoned mid-sync in an
+		// inconsistent Running state.
+		if err := executor.Shutdown(opts.ExecutorDrainTimeout); err != nil {
+			setupLog.Error(err, "unable to drain executor")
+		}
 	} else {
 		return fmt.Errorf("invalid kubediag mode: %s", opts.Mode)
 	}
@@ -551,15 +776,40 @@ func (opts *KubeDiagOptions) AddFlags(fs *pflag.FlagSet) {
 	fs.IntVar(&opts.WebhookPort, "webhook-port", opts.WebhookPort, "The port that the webhook server serves at.")
 	fs.StringVar(&opts.Host, "host", opts.Host, "The hostname that the webhook server binds to.")
 	fs.StringVar(&opts.CertDir, "cert-dir", opts.CertDir, "The directory that contains the server key and certificate.")
+	fs.BoolVar(&opts.ServeTLS, "serve-tls", opts.ServeTLS, "Serve the kubediag http api over tls using the server key and certificate in --cert-dir.")
+	fs.StringVar(&opts.AuthTokenFile, "auth-token-file", opts.AuthTokenFile, "The file containing a static bearer token required to access the agent http api. Authentication is disabled if empty.")
 	fs.DurationVar(&opts.AlertmanagerRepeatInterval, "repeat-interval", opts.AlertmanagerRepeatInterval, "How long to wait before sending a notification again if it has already been sent successfully for an alert.")
 	fs.StringSliceVar(&opts.KafkaBrokers, "kafka-brokers", opts.KafkaBrokers, "The list of broker addresses used to connect to the kafka cluster.")
 	fs.StringVar(&opts.KafkaTopic, "kafka-topic", opts.KafkaTopic, "The topic to read messages from.")
 	fs.DurationVar(&opts.TaskTTL, "task-ttl", opts.TaskTTL, "Amount of time to retain tasks.")
 	fs.DurationVar(&opts.MinimumTaskTTLDuration, "minimum-task-ttl-duration", opts.MinimumTaskTTLDuration, "Minimum age for a finished task before it is garbage collected.")
 	fs.DurationVar(&opts.CommonEventTTL, "common-event-ttl", opts.CommonEventTTL, "Amount of time to retain common events.")
+	fs.IntVar(&opts.MaximumPathsPerOperationSet, "maximum-paths-per-operation-set", opts.MaximumPathsPerOperationSet, "Maximum number of paths to enumerate from an operation set.")
+	fs.DurationVar(&opts.GraphBuilderRequeueBaseDelay, "graph-builder-requeue-base-delay", opts.GraphBuilderRequeueBaseDelay, "Base delay for requeueing an operation set after a failed sync.")
+	fs.DurationVar(&opts.GraphBuilderRequeueMaxDelay, "graph-builder-requeue-max-delay", opts.GraphBuilderRequeueMaxDelay, "Maximum delay for requeueing an operation set after a failed sync.")
+	fs.IntVar(&opts.GraphBuilderWorkers, "graph-builder-workers", opts.GraphBuilderWorkers, "Number of workers syncing operation sets concurrently.")
+	fs.StringVar(&opts.DiagnosisLabelSelector, "diagnosis-label-selector", opts.DiagnosisLabelSelector, "The label selector for filtering diagnoses to be reconciled. All diagnoses are reconciled if not specified.")
+	fs.DurationVar(&opts.OperationSetReadinessGracePeriod, "operation-set-readiness-grace-period", opts.OperationSetReadinessGracePeriod, "Amount of time a diagnosis waits for its operation set to become ready before failing. Diagnoses fail immediately if not positive.")
+	fs.StringVar(&opts.DefaultOperationSet, "default-operation-set", opts.DefaultOperationSet, "The operation set to inject into source generated diagnoses without an operation set.")
+	fs.Float64Var(&opts.SourceDiagnosisCreationQPS, "source-diagnosis-creation-qps", opts.SourceDiagnosisCreationQPS, "Maximum sustained rate of diagnosis creations per source. All creations are allowed if not positive.")
 	fs.Int32Var(&opts.MaximumTasksPerNode, "maximum-tasks-per-node", opts.MaximumTasksPerNode, "Maximum number of finished diagnoses to retain per node.")
+	fs.DurationVar(&opts.TaskReaperInterval, "task-reaper-interval", opts.TaskReaperInterval, "Interval between garbage collection runs of the task reaper. Defaults to a quarter of task-ttl if zero.")
 	fs.Var(flag.NewMapStringBool(&opts.FeatureGates), "feature-gates", "A map of feature names to bools that enable or disable features. Options are:\n"+strings.Join(features.NewFeatureGate().KnownFeatures(), "\n"))
 	fs.StringVar(&opts.DataRoot, "data-root", opts.DataRoot, "Root directory of persistent kubediag data.")
+	fs.StringVar(&opts.FunctionNamespace, "function-namespace", opts.FunctionNamespace, "The namespace in which function pods are created.")
+	fs.Int64Var(&opts.MaximumDataRootBytes, "maximum-data-root-bytes", opts.MaximumDataRootBytes, "Maximum total size in bytes of files retained under the data root. The quota is not enforced if not positive.")
+	fs.IntVar(&opts.ResultCompressionThresholdBytes, "result-compression-threshold-bytes", opts.ResultCompressionThresholdBytes, "Size in bytes above which result values are stored gzip compressed and base64 encoded in statuses. Compression is disabled if not positive.")
+	fs.StringSliceVar(&opts.AllowedScriptCommands, "allowed-script-commands", opts.AllowedScriptCommands, "Regular expressions matched against the base names of commands invoked by script runner scripts on the node. Scripts invoking commands not matched by any entry are rejected. Commands are not restricted if empty.")
+	fs.DurationVar(&opts.ExecutorDrainTimeout, "executor-drain-timeout", opts.ExecutorDrainTimeout, "Maximum amount of time to wait for running task syncs to complete on shutdown.")
+	fs.IntVar(&opts.ProfilerMinExpirationSeconds, "profiler-min-expiration-seconds", opts.ProfilerMinExpirationSeconds, "Minimum expiration in seconds of profiler endpoints. Requested expirations below the bound are clamped to it.")
+	fs.IntVar(&opts.ProfilerMaxExpirationSeconds, "profiler-max-expiration-seconds", opts.ProfilerMaxExpirationSeconds, "Maximum expiration in seconds of profiler endpoints. Requested expirations above the bound are clamped to it.")
+	fs.IntVar(&opts.MaxStatusConditions, "max-status-conditions", opts.MaxStatusConditions, "Maximum number of conditions retained in a diagnosis or task status. The oldest non terminal conditions beyond the limit are pruned.")
+	fs.StringVar(&opts.NodeConditionOperationSet, "node-condition-operation-set", opts.NodeConditionOperationSet, "Name of referenced operation set in diagnoses generated by node watcher. The node watcher is disabled if not specified.")
+	fs.DurationVar(&opts.NodeConditionGracePeriod, "node-condition-grace-period", opts.NodeConditionGracePeriod, "Amount of time an unhealthy node condition must persist before a diagnosis is generated.")
+	fs.DurationVar(&opts.ClusterHealthUpdateInterval, "cluster-health-update-interval", opts.ClusterHealthUpdateInterval, "Interval at which cluster health is evaluated.")
+	fs.BoolVar(&opts.ProcessorKeepAlives, "processor-keep-alives", opts.ProcessorKeepAlives, "Enable http keep-alives for processor requests to reuse connections under high diagnosis volume.")
+	fs.IntVar(&opts.ProcessorMaxIdleConnsPerHost, "processor-max-idle-conns-per-host", opts.ProcessorMaxIdleConnsPerHost, "Maximum number of idle connections pooled per processor if keep-alives are enabled.")
+	fs.BoolVar(&opts.ProcessorTLSInsecureSkipVerify, "processor-tls-insecure-skip-verify", opts.ProcessorTLSInsecureSkipVerify, "Skip tls certificate verification for processor requests.")
 	fs.BoolVar(&opts.SinkEventToKafka, "sink-event-to-kafka", opts.SinkEventToKafka, "Enables the pagerduty handler to write message to kafka cluster.")
 	fs.StringVar(&opts.KafkaAddress, "kafka-address", opts.KafkaAddress, "The addresses used to connect to the kafka cluster.")
 	fs.BoolVar(&opts.SinkEventToWebhookReceiver, "sink-event-to-webhook-receiver", opts.SinkEventToWebhookReceiver, "Enables the pagerduty handler to write message to a webhook receiver.")
@@ -46,6 +46,9 @@ var (
 	PrometheusAlertGeneratedDiagnosisPrefix = "prometheus-alert"
 	// PrometheusAlertAnnotation is the annotation used to store the prometheus alert that triggers a diagnosis.
 	PrometheusAlertAnnotation = util.KubeDiagPrefix + PrometheusAlertGeneratedDiagnosisPrefix
+	// DedupKeyAnnotation is the annotation used to store the de-duplication key of a diagnosis
+	// generated from an alert.
+	DedupKeyAnnotation = util.KubeDiagPrefix + "dedup-key"
 
 	invalidDNS1123Characters = regexp.MustCompile("[^-a-z0-9]+")
 )
@@ -93,6 +96,8 @@ type alertmanager struct {
 	repeatInterval time.Duration
 	// firingAlertSet contains all alerts fired by alertmanager.
 	firingAlertSet map[uint64]time.Time
+	// rateLimiter limits the rate of diagnosis creations per alert source.
+	rateLimiter *util.SourceRateLimiter
 	// alertmanagerEnabled indicates whether alertmanager is enabled.
 	alertmanagerEnabled bool
 }
@@ -104,6 +109,7 @@ func NewAlertmanager(
 	cli client.Client,
 	cache cache.Cache,
 	repeatInterval time.Duration,
+	rateLimiter *util.SourceRateLimiter,
 	alertmanagerEnabled bool,
 ) Alertmanager {
 	metrics.Registry.MustRegister(
@@ -121,6 +127,7 @@ func NewAlertmanager(
 		cache:               cache,
 		repeatInterval:      repeatInterval,
 		firingAlertSet:      firingAlertSet,
+		rateLimiter:         rateLimiter,
 		alertmanagerEnabled: alertmanagerEnabled,
 	}
 }
@@ -168,6 +175,16 @@ func (am *alertmanager) Handler(w http.ResponseWriter, r *http.Request) {
 				continue
 			}
 
+			// Skip the alert if the rate of diagnosis creations from its source is exceeded.
+			source := (*Alert)(alert).Source()
+			if source == "" {
+				source = strings.ToLower(alert.Name())
+			}
+			if !am.rateLimiter.Allow(source) {
+				am.Info("throttling diagnosis creation for alert", "alert", alert.Name(), "source", source)
+				continue
+			}
+
 			err := am.createCommonEvent(alert)
 			if err != nil {
 				am.Error(err, "failed to create common event from alert")
@@ -230,13 +247,22 @@ func (am *alertmanager) createDiagnosisFromPrometheusAlert(triggers []diagnosisv
 			if matched {
 				am.Info("creating Diagnosis from prometheus alert", "alert", alert.String())
 
-				// Create diagnosis according to the prometheus alert.
+				// Create diagnosis according to the prometheus alert. The diagnosis name is
+				// derived from the de-duplication key labels if specified, so repeated alerts
+				// with identical label values map to the same diagnosis.
 				alertName := sanitizeAlertName(alert.Name())
 				now := time.Now()
-				name := fmt.Sprintf("%s.%s.%s.%d", PrometheusAlertGeneratedDiagnosisPrefix, strings.ToLower(alertName), alert.Fingerprint().String()[:7], now.Unix())
 				namespace := util.DefautlNamespace
 				annotations := make(map[string]string)
 				annotations[PrometheusAlertAnnotation] = string(alert.String())
+				var name string
+				if len(sourceTemplate.PrometheusAlertTemplate.DedupKeyLabels) > 0 {
+					dedupKey := buildDedupKey(sourceTemplate.PrometheusAlertTemplate.DedupKeyLabels, alert)
+					name = fmt.Sprintf("%s.%s.%s", PrometheusAlertGeneratedDiagnosisPrefix, strings.ToLower(alertName), util.ComputeHash(dedupKey))
+					annotations[DedupKeyAnnotation] = dedupKey
+				} else {
+					name = fmt.Sprintf("%s.%s.%s.%d", PrometheusAlertGeneratedDiagnosisPrefix, strings.ToLower(alertName), alert.Fingerprint().String()[:7], now.Unix())
+				}
 				diagnosis := diagnosisv1.Diagnosis{
 					ObjectMeta: metav1.ObjectMeta{
 						Name:        name,
@@ -302,6 +328,16 @@ func (am *alertmanager) createDiagnosisFromPrometheusAlert(triggers []diagnosisv
 	return nil, nil
 }
 
+// buildDedupKey builds the de-duplication key of an alert from the values of the specified labels.
+func buildDedupKey(labels []model.LabelName, alert *types.Alert) string {
+	pairs := make([]string, 0, len(labels))
+	for _, label := range labels {
+		pairs = append(pairs, fmt.Sprintf("%s=%s", label, alert.Labels[label]))
+	}
+
+	return strings.Join(pairs, ",")
+}
+
 // createCommonEvent creates a common event according to the prometheus alert.
 func (am *alertmanager) createCommonEvent(alert *types.Alert) error {
 	var name string
@@ -17,6 +17,7 @@ limitations under the License.
 package alertmanager
 
 import (
+	"context"
 	"fmt"
 	"regexp"
 	"testing"
@@ -25,6 +26,11 @@ import (
 	"github.com/prometheus/alertmanager/types"
 	"github.com/prometheus/common/model"
 	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 
 	diagnosisv1 "github.com/kubediag/kubediag/api/v1"
 )
@@ -275,3 +281,81 @@ func TestMatchPrometheusAlert(t *testing.T) {
 		}
 	}
 }
+
+func TestCreateDiagnosisFromPrometheusAlertDedupKey(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = diagnosisv1.AddToScheme(scheme)
+
+	triggers := []diagnosisv1.Trigger{
+		{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "trigger1",
+			},
+			Spec: diagnosisv1.TriggerSpec{
+				OperationSet: "operationset1",
+				SourceTemplate: diagnosisv1.SourceTemplate{
+					PrometheusAlertTemplate: &diagnosisv1.PrometheusAlertTemplate{
+						Regexp: diagnosisv1.PrometheusAlertTemplateRegexp{
+							AlertName: "alert1",
+						},
+						NodeNameReferenceLabel: "node",
+						DedupKeyLabels:         []model.LabelName{"alertname", "node"},
+					},
+				},
+			},
+		},
+	}
+	newAlert := func(instance model.LabelValue) *types.Alert {
+		return &types.Alert{
+			Alert: model.Alert{
+				Labels: model.LabelSet{
+					"alertname": "alert1",
+					"node":      "node1",
+					"instance":  instance,
+				},
+			},
+		}
+	}
+
+	cli := fake.NewFakeClientWithScheme(scheme)
+	am := &alertmanager{
+		Context:             context.Background(),
+		Logger:              ctrl.Log.WithName("alertmanager"),
+		client:              cli,
+		alertmanagerEnabled: true,
+	}
+
+	// Two alerts with identical de-duplication key labels yield a single diagnosis even if
+	// other labels differ.
+	first, err := am.createDiagnosisFromPrometheusAlert(triggers, newAlert("instance1"))
+	assert.NoError(t, err)
+	assert.NotNil(t, first)
+	second, err := am.createDiagnosisFromPrometheusAlert(triggers, newAlert("instance2"))
+	assert.NoError(t, err)
+	assert.NotNil(t, second)
+	assert.Equal(t, first.Name, second.Name)
+
+	var diagnosisList diagnosisv1.DiagnosisList
+	err = cli.List(context.Background(), &diagnosisList)
+	assert.NoError(t, err)
+	if assert.Equal(t, 1, len(diagnosisList.Items)) {
+		diagnosis := diagnosisList.Items[0]
+		assert.Equal(t, "alertname=alert1,node=node1", diagnosis.Annotations[DedupKeyAnnotation])
+		assert.Equal(t, []string{"node1"}, diagnosis.Spec.TargetSelector.NodeNames)
+	}
+}
+
+func TestBuildDedupKey(t *testing.T) {
+	alert := &types.Alert{
+		Alert: model.Alert{
+			Labels: model.LabelSet{
+				"alertname": "alert1",
+				"node":      "node1",
+			},
+		},
+	}
+
+	// Labels missing on the alert yield empty values.
+	assert.Equal(t, "node=node1,severity=", buildDedupKey([]model.LabelName{"node", "severity"}, alert))
+}
@@ -0,0 +1,124 @@
+/*
+Copyright 2022 The KubeDiag Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package artifactserver
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// ArtifactServer serves operation result artifacts over the agent port. Processors register
+// files with an expiration instead of spinning up their own ephemeral http servers, so only
+// the agent port needs to be exposed.
+type ArtifactServer interface {
+	// Handler handles http requests.
+	Handler(http.ResponseWriter, *http.Request)
+	// Register registers a file to be served until the expiration elapses. It returns the
+	// token under which the artifact is served.
+	Register(path string, expiration time.Duration) string
+}
+
+// artifact is a registered file with its expiry.
+type artifact struct {
+	// path is the path of the file on the node.
+	path string
+	// expiresAt is the time after which the artifact is no longer served.
+	expiresAt time.Time
+}
+
+// artifactServer serves registered files under token paths until they expire.
+type artifactServer struct {
+	// Context carries values across API boundaries.
+	context.Context
+	// Logger represents the ability to log messages.
+	logr.Logger
+
+	// artifactsMutex protects artifacts.
+	artifactsMutex sync.Mutex
+	// artifacts maps tokens to registered artifacts.
+	artifacts map[string]artifact
+}
+
+// NewArtifactServer creates a new ArtifactServer.
+func NewArtifactServer(
+	ctx context.Context,
+	logger logr.Logger,
+) ArtifactServer {
+	return &artifactServer{
+		Context:   ctx,
+		Logger:    logger,
+		artifacts: make(map[string]artifact),
+	}
+}
+
+// Register registers a file to be served until the expiration elapses.
+func (as *artifactServer) Register(path string, expiration time.Duration) string {
+	as.artifactsMutex.Lock()
+	defer as.artifactsMutex.Unlock()
+
+	as.purgeExpired()
+	token := uuid.New().String()
+	as.artifacts[token] = artifact{
+		path:      path,
+		expiresAt: time.Now().Add(expiration),
+	}
+	as.Info("registered artifact", "token", token, "path", path, "expiration", expiration)
+
+	return token
+}
+
+// Handler handles http requests for registered artifacts.
+func (as *artifactServer) Handler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+		token := mux.Vars(r)["token"]
+		as.artifactsMutex.Lock()
+		as.purgeExpired()
+		artifact, ok := as.artifacts[token]
+		as.artifactsMutex.Unlock()
+		if !ok {
+			http.Error(w, fmt.Sprintf("artifact %s is not found or has expired", token), http.StatusNotFound)
+			return
+		}
+		if _, err := os.Stat(artifact.path); err != nil {
+			http.Error(w, fmt.Sprintf("artifact %s is not found or has expired", token), http.StatusNotFound)
+			return
+		}
+
+		http.ServeFile(w, r, artifact.path)
+	default:
+		http.Error(w, fmt.Sprintf("method %s is not supported", r.Method), http.StatusMethodNotAllowed)
+	}
+}
+
+// purgeExpired removes expired artifacts. The caller must hold artifactsMutex.
+func (as *artifactServer) purgeExpired() {
+	now := time.Now()
+	for token, artifact := range as.artifacts {
+		if now.After(artifact.expiresAt) {
+			delete(as.artifacts, token)
+		}
+	}
+}
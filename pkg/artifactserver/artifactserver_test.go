@@ -0,0 +1,82 @@
+/*
+Copyright 2022 The KubeDiag Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package artifactserver
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+func TestArtifactServer(t *testing.T) {
+	dir, err := ioutil.TempDir("", "artifactserver")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "artifact.txt")
+	err = ioutil.WriteFile(path, []byte("artifact content"), 0644)
+	assert.NoError(t, err)
+
+	as := NewArtifactServer(
+		context.Background(),
+		log.NullLogger{},
+	)
+
+	router := mux.NewRouter()
+	router.HandleFunc("/artifacts/{token}", as.Handler)
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	token := as.Register(path, 100*time.Millisecond)
+
+	// The artifact is served before the expiration elapses.
+	resp, err := http.Get(server.URL + "/artifacts/" + token)
+	assert.NoError(t, err)
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "artifact content", string(body))
+
+	// The artifact is not served after the expiration elapses.
+	time.Sleep(200 * time.Millisecond)
+	resp, err = http.Get(server.URL + "/artifacts/" + token)
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	// An unknown token is not served.
+	resp, err = http.Get(server.URL + "/artifacts/unknown")
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	// Only http get method is supported.
+	resp, err = http.Post(server.URL+"/artifacts/"+token, "application/json", nil)
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+}
@@ -0,0 +1,432 @@
+/*
+Copyright 2022 The KubeDiag Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clusterhealthevaluator
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/prometheus/client_golang/prometheus"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	"github.com/kubediag/kubediag/pkg/types"
+	"github.com/kubediag/kubediag/pkg/util"
+)
+
+var (
+	clusterHealthEvaluationCycleCount = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "cluster_health_evaluation_cycle_count",
+			Help: "Counter of cluster health evaluation cycles",
+		},
+	)
+	clusterHealthEvaluationErrorCount = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "cluster_health_evaluation_error_count",
+			Help: "Counter of erroneous cluster health evaluations",
+		},
+	)
+	clusterHealthScore = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "cluster_health_score",
+			Help: "Weighted score of kubernetes cluster health",
+		},
+	)
+)
+
+// ClusterHealthEvaluator evaluates the health of kubernetes cluster.
+type ClusterHealthEvaluator interface {
+	// Run runs the cluster health evaluator.
+	Run(<-chan struct{})
+	// Handler handles http requests.
+	Handler(http.ResponseWriter, *http.Request)
+}
+
+// clusterHealthEvaluator evaluates the health of kubernetes cluster periodically.
+type clusterHealthEvaluator struct {
+	// Context carries values across API boundaries.
+	context.Context
+	// Logger represents the ability to log messages.
+	logr.Logger
+
+	// client knows how to perform CRUD operations on Kubernetes objects.
+	client client.Client
+	// cache knows how to load Kubernetes objects.
+	cache cache.Cache
+	// updateInterval is the interval at which cluster health is evaluated.
+	updateInterval time.Duration
+	// clusterHealth is the last evaluated health of kubernetes cluster.
+	clusterHealth *types.ClusterHealth
+	// clusterHealthMutex protects clusterHealth.
+	clusterHealthMutex sync.RWMutex
+	// clusterHealthEvaluatorEnabled indicates whether clusterHealthEvaluator is enabled.
+	clusterHealthEvaluatorEnabled bool
+}
+
+// NewClusterHealthEvaluator creates a new ClusterHealthEvaluator.
+func NewClusterHealthEvaluator(
+	ctx context.Context,
+	logger logr.Logger,
+	cli client.Client,
+	cache cache.Cache,
+	updateInterval time.Duration,
+	clusterHealthEvaluatorEnabled bool,
+) ClusterHealthEvaluator {
+	metrics.Registry.MustRegister(
+		clusterHealthEvaluationCycleCount,
+		clusterHealthEvaluationErrorCount,
+		clusterHealthScore,
+	)
+
+	return &clusterHealthEvaluator{
+		Context:                       ctx,
+		Logger:                        logger,
+		client:                        cli,
+		cache:                         cache,
+		updateInterval:                updateInterval,
+		clusterHealth:                 new(types.ClusterHealth),
+		clusterHealthEvaluatorEnabled: clusterHealthEvaluatorEnabled,
+	}
+}
+
+// Run runs the cluster health evaluator.
+func (che *clusterHealthEvaluator) Run(stopCh <-chan struct{}) {
+	if !che.clusterHealthEvaluatorEnabled {
+		return
+	}
+
+	// Wait for all caches to sync before processing.
+	if !che.cache.WaitForCacheSync(stopCh) {
+		return
+	}
+
+	go wait.Until(func() {
+		clusterHealthEvaluationCycleCount.Inc()
+		if err := che.evaluate(); err != nil {
+			clusterHealthEvaluationErrorCount.Inc()
+			che.Error(err, "unable to evaluate cluster health")
+		}
+	}, che.updateInterval, stopCh)
+}
+
+// Handler handles http requests for cluster health.
+func (che *clusterHealthEvaluator) Handler(w http.ResponseWriter, r *http.Request) {
+	if !che.clusterHealthEvaluatorEnabled {
+		http.Error(w, fmt.Sprintf("cluster health evaluator is not enabled"), http.StatusUnprocessableEntity)
+		return
+	}
+
+	switch r.Method {
+	case "GET":
+		data, err := json.Marshal(che.getClusterHealth())
+		if err != nil {
+			http.Error(w, fmt.Sprintf("failed to marshal cluster health: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(data)
+	default:
+		http.Error(w, fmt.Sprintf("method %s is not supported", r.Method), http.StatusMethodNotAllowed)
+	}
+}
+
+// evaluate evaluates the health of kubernetes cluster and updates the evaluation result.
+func (che *clusterHealthEvaluator) evaluate() error {
+	var podList corev1.PodList
+	if err := che.cache.List(che, &podList); err != nil {
+		return fmt.Errorf("unable to list Pods: %s", err)
+	}
+	var deploymentList appsv1.DeploymentList
+	if err := che.cache.List(che, &deploymentList); err != nil {
+		return fmt.Errorf("unable to list Deployments: %s", err)
+	}
+	var statefulSetList appsv1.StatefulSetList
+	if err := che.cache.List(che, &statefulSetList); err != nil {
+		return fmt.Errorf("unable to list StatefulSets: %s", err)
+	}
+	var daemonSetList appsv1.DaemonSetList
+	if err := che.cache.List(che, &daemonSetList); err != nil {
+		return fmt.Errorf("unable to list DaemonSets: %s", err)
+	}
+	var nodeList corev1.NodeList
+	if err := che.cache.List(che, &nodeList); err != nil {
+		return fmt.Errorf("unable to list Nodes: %s", err)
+	}
+
+	workloadHealth := evaluateWorkloadHealth(podList.Items, deploymentList.Items, statefulSetList.Items, daemonSetList.Items)
+	nodeHealth := evaluateNodeHealth(nodeList.Items)
+	clusterHealth := types.ClusterHealth{
+		Score:          (workloadHealth.Score + nodeHealth.Score) / 2,
+		WorkloadHealth: workloadHealth,
+		NodeHealth:     nodeHealth,
+	}
+
+	che.setClusterHealth(clusterHealth)
+	clusterHealthScore.Set(float64(clusterHealth.Score))
+
+	che.Info("cluster health evaluated", "score", clusterHealth.Score)
+	return nil
+}
+
+// getClusterHealth retrieves the last evaluated health of kubernetes cluster.
+func (che *clusterHealthEvaluator) getClusterHealth() types.ClusterHealth {
+	che.clusterHealthMutex.RLock()
+	defer che.clusterHealthMutex.RUnlock()
+
+	return *che.clusterHealth
+}
+
+// setClusterHealth updates the evaluated health of kubernetes cluster.
+func (che *clusterHealthEvaluator) setClusterHealth(clusterHealth types.ClusterHealth) {
+	che.clusterHealthMutex.Lock()
+	defer che.clusterHealthMutex.Unlock()
+
+	*che.clusterHealth = clusterHealth
+}
+
+// evaluateWorkloadHealth evaluates the health of workloads in kubernetes cluster.
+func evaluateWorkloadHealth(pods []corev1.Pod, deployments []appsv1.Deployment, statefulSets []appsv1.StatefulSet, daemonSets []appsv1.DaemonSet) types.WorkloadHealth {
+	podHealth := evaluatePodHealth(pods)
+	deploymentHealth := evaluateDeploymentHealth(deployments)
+	statefulSetHealth := evaluateStatefulSetHealth(statefulSets)
+	daemonSetHealth := evaluateDaemonSetHealth(daemonSets)
+
+	return types.WorkloadHealth{
+		Score:             (podHealth.Score + deploymentHealth.Score + statefulSetHealth.Score + daemonSetHealth.Score) / 4,
+		PodHealth:         podHealth,
+		DeploymentHealth:  deploymentHealth,
+		StatefulSetHealth: statefulSetHealth,
+		DaemonSetHealth:   daemonSetHealth,
+	}
+}
+
+// evaluatePodHealth evaluates the health of pods in kubernetes cluster.
+func evaluatePodHealth(pods []corev1.Pod) types.PodHealth {
+	statistics := types.PodStatistics{
+		Total: len(pods),
+	}
+	statistics.Unhealthy.ContainerStateReasons = make(map[string]int)
+
+	for _, pod := range pods {
+		switch pod.Status.Phase {
+		case corev1.PodSucceeded:
+			statistics.Healthy.Succeeded++
+		case corev1.PodRunning:
+			if pod.DeletionTimestamp != nil {
+				statistics.Unhealthy.Terminating++
+			} else if allContainersReady(pod) {
+				statistics.Healthy.Ready++
+			} else {
+				statistics.Unhealthy.Unready++
+				if reason := firstContainerStateReason(pod); reason != "" {
+					statistics.Unhealthy.ContainerStateReasons[reason]++
+				}
+			}
+		case corev1.PodPending:
+			statistics.Unhealthy.Pending++
+			if reason := firstContainerStateReason(pod); reason != "" {
+				statistics.Unhealthy.ContainerStateReasons[reason]++
+			}
+		case corev1.PodFailed:
+			statistics.Unhealthy.Failed++
+			if reason := firstContainerStateReason(pod); reason != "" {
+				statistics.Unhealthy.ContainerStateReasons[reason]++
+			}
+		default:
+			statistics.Unhealthy.Unknown++
+		}
+	}
+
+	healthy := statistics.Healthy.Ready + statistics.Healthy.Succeeded
+	return types.PodHealth{
+		Score:      healthScore(healthy, statistics.Total),
+		Statistics: statistics,
+	}
+}
+
+// evaluateDeploymentHealth evaluates the health of deployments in kubernetes cluster.
+func evaluateDeploymentHealth(deployments []appsv1.Deployment) types.DeploymentHealth {
+	statistics := types.DeploymentStatistics{
+		Total: len(deployments),
+	}
+
+	for _, deployment := range deployments {
+		desired := int32(1)
+		if deployment.Spec.Replicas != nil {
+			desired = *deployment.Spec.Replicas
+		}
+		fraction := availableFraction(deployment.Status.AvailableReplicas, desired)
+		switch {
+		case fraction >= types.FourQuarters:
+			statistics.Healthy++
+		case fraction >= types.ThreeQuarters:
+			statistics.Unhealthy.FourQuartersAvailable++
+		case fraction >= types.TwoQuarters:
+			statistics.Unhealthy.ThreeQuartersAvailable++
+		case fraction >= types.OneQuarter:
+			statistics.Unhealthy.TwoQuartersAvailable++
+		default:
+			statistics.Unhealthy.OneQuarterAvailable++
+		}
+	}
+
+	return types.DeploymentHealth{
+		Score:      healthScore(statistics.Healthy, statistics.Total),
+		Statistics: statistics,
+	}
+}
+
+// evaluateStatefulSetHealth evaluates the health of statefulsets in kubernetes cluster.
+func evaluateStatefulSetHealth(statefulSets []appsv1.StatefulSet) types.StatefulSetHealth {
+	statistics := types.StatefulSetStatistics{
+		Total: len(statefulSets),
+	}
+
+	for _, statefulSet := range statefulSets {
+		desired := int32(1)
+		if statefulSet.Spec.Replicas != nil {
+			desired = *statefulSet.Spec.Replicas
+		}
+		fraction := availableFraction(statefulSet.Status.ReadyReplicas, desired)
+		switch {
+		case fraction >= types.FourQuarters:
+			statistics.Healthy++
+		case fraction >= types.ThreeQuarters:
+			statistics.Unhealthy.FourQuartersReady++
+		case fraction >= types.TwoQuarters:
+			statistics.Unhealthy.ThreeQuartersReady++
+		case fraction >= types.OneQuarter:
+			statistics.Unhealthy.TwoQuartersReady++
+		default:
+			statistics.Unhealthy.OneQuarterReady++
+		}
+	}
+
+	return types.StatefulSetHealth{
+		Score:      healthScore(statistics.Healthy, statistics.Total),
+		Statistics: statistics,
+	}
+}
+
+// evaluateDaemonSetHealth evaluates the health of daemonsets in kubernetes cluster.
+func evaluateDaemonSetHealth(daemonSets []appsv1.DaemonSet) types.DaemonSetHealth {
+	statistics := types.DaemonSetStatistics{
+		Total: len(daemonSets),
+	}
+
+	for _, daemonSet := range daemonSets {
+		available := daemonSet.Status.NumberAvailable
+		if daemonSet.Status.CurrentNumberScheduled < available {
+			available = daemonSet.Status.CurrentNumberScheduled
+		}
+		fraction := availableFraction(available, daemonSet.Status.DesiredNumberScheduled)
+		switch {
+		case fraction >= types.FourQuarters:
+			statistics.Healthy++
+		case fraction >= types.ThreeQuarters:
+			statistics.Unhealthy.FourQuartersAvailableAndScheduled++
+		case fraction >= types.TwoQuarters:
+			statistics.Unhealthy.ThreeQuartersAvailableAndScheduled++
+		case fraction >= types.OneQuarter:
+			statistics.Unhealthy.TwoQuartersAvailableAndScheduled++
+		default:
+			statistics.Unhealthy.OneQuarterAvailableAndScheduled++
+		}
+	}
+
+	return types.DaemonSetHealth{
+		Score:      healthScore(statistics.Healthy, statistics.Total),
+		Statistics: statistics,
+	}
+}
+
+// evaluateNodeHealth evaluates the health of nodes in kubernetes cluster.
+func evaluateNodeHealth(nodes []corev1.Node) types.NodeHealth {
+	statistics := types.NodeStatistics{
+		Total:     len(nodes),
+		Unhealthy: make(map[corev1.NodeConditionType]int),
+	}
+
+	for _, node := range nodes {
+		if util.IsNodeReady(node) {
+			statistics.Healthy++
+			continue
+		}
+		statistics.Unhealthy[util.GetNodeUnhealthyConditionType(node)]++
+	}
+
+	return types.NodeHealth{
+		Score:      healthScore(statistics.Healthy, statistics.Total),
+		Statistics: statistics,
+	}
+}
+
+// healthScore calculates a weighted score from the healthy count and the total count. The score
+// is the max health score if the total count is zero.
+func healthScore(healthy int, total int) int {
+	if total == 0 {
+		return types.MaxHealthScore
+	}
+
+	return healthy * types.MaxHealthScore / total
+}
+
+// availableFraction calculates the fraction of available replicas divided by desired replicas.
+func availableFraction(available int32, desired int32) float64 {
+	if desired == 0 {
+		return types.FourQuarters
+	}
+
+	return float64(available) / float64(desired)
+}
+
+// allContainersReady reports whether all containers in the pod are ready.
+func allContainersReady(pod corev1.Pod) bool {
+	for _, containerStatus := range pod.Status.ContainerStatuses {
+		if !containerStatus.Ready {
+			return false
+		}
+	}
+
+	return true
+}
+
+// firstContainerStateReason returns the reason of the first waiting or terminated container in
+// the pod.
+func firstContainerStateReason(pod corev1.Pod) string {
+	for _, containerStatus := range pod.Status.ContainerStatuses {
+		if containerStatus.State.Waiting != nil && containerStatus.State.Waiting.Reason != "" {
+			return containerStatus.State.Waiting.Reason
+		}
+		if containerStatus.State.Terminated != nil && containerStatus.State.Terminated.Reason != "" {
+			return containerStatus.State.Terminated.Reason
+		}
+	}
+
+	return ""
+}
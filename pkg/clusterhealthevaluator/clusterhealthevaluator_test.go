@@ -0,0 +1,187 @@
+/*
+Copyright 2022 The KubeDiag Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clusterhealthevaluator
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/kubediag/kubediag/pkg/types"
+)
+
+// fakeCache is a cache.Cache backed by a fake client for testing.
+type fakeCache struct {
+	client.Reader
+}
+
+func (f fakeCache) GetInformer(obj runtime.Object) (cache.Informer, error) {
+	return nil, nil
+}
+
+func (f fakeCache) GetInformerForKind(gvk schema.GroupVersionKind) (cache.Informer, error) {
+	return nil, nil
+}
+
+func (f fakeCache) Start(stopCh <-chan struct{}) error {
+	return nil
+}
+
+func (f fakeCache) WaitForCacheSync(stop <-chan struct{}) bool {
+	return true
+}
+
+func (f fakeCache) IndexField(obj runtime.Object, field string, extractValue client.IndexerFunc) error {
+	return nil
+}
+
+func TestClusterHealthEvaluatorHandler(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+
+	objects := []runtime.Object{
+		&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "pod1",
+				Namespace: "default",
+			},
+			Status: corev1.PodStatus{
+				Phase: corev1.PodRunning,
+				ContainerStatuses: []corev1.ContainerStatus{
+					{
+						Ready: true,
+					},
+				},
+			},
+		},
+		&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "pod2",
+				Namespace: "default",
+			},
+			Status: corev1.PodStatus{
+				Phase: corev1.PodPending,
+				ContainerStatuses: []corev1.ContainerStatus{
+					{
+						State: corev1.ContainerState{
+							Waiting: &corev1.ContainerStateWaiting{
+								Reason: "CrashLoopBackOff",
+							},
+						},
+					},
+				},
+			},
+		},
+		&corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "node1",
+			},
+			Status: corev1.NodeStatus{
+				Conditions: []corev1.NodeCondition{
+					{
+						Type:   corev1.NodeReady,
+						Status: corev1.ConditionTrue,
+					},
+				},
+			},
+		},
+	}
+
+	cli := fake.NewFakeClientWithScheme(scheme, objects...)
+	che := &clusterHealthEvaluator{
+		Context:                       context.Background(),
+		Logger:                        ctrl.Log.WithName("clusterhealthevaluator"),
+		client:                        cli,
+		cache:                         fakeCache{Reader: cli},
+		clusterHealth:                 new(types.ClusterHealth),
+		clusterHealthEvaluatorEnabled: true,
+	}
+
+	err := che.evaluate()
+	assert.NoError(t, err)
+
+	req := httptest.NewRequest("GET", "/api/v1/cluster/health", nil)
+	recorder := httptest.NewRecorder()
+	che.Handler(recorder, req)
+	assert.Equal(t, 200, recorder.Code)
+
+	var clusterHealth types.ClusterHealth
+	err = json.Unmarshal(recorder.Body.Bytes(), &clusterHealth)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, clusterHealth.WorkloadHealth.PodHealth.Statistics.Total)
+	assert.Equal(t, 1, clusterHealth.WorkloadHealth.PodHealth.Statistics.Healthy.Ready)
+	assert.Equal(t, 1, clusterHealth.WorkloadHealth.PodHealth.Statistics.Unhealthy.Pending)
+	assert.Equal(t, 1, clusterHealth.WorkloadHealth.PodHealth.Statistics.Unhealthy.ContainerStateReasons["CrashLoopBackOff"])
+	assert.Equal(t, 50, clusterHealth.WorkloadHealth.PodHealth.Score)
+	assert.Equal(t, 1, clusterHealth.NodeHealth.Statistics.Healthy)
+	assert.Equal(t, types.MaxHealthScore, clusterHealth.NodeHealth.Score)
+
+	// Only GET requests are supported.
+	req = httptest.NewRequest("POST", "/api/v1/cluster/health", nil)
+	recorder = httptest.NewRecorder()
+	che.Handler(recorder, req)
+	assert.Equal(t, 405, recorder.Code)
+}
+
+func TestHealthScore(t *testing.T) {
+	assert.Equal(t, types.MaxHealthScore, healthScore(0, 0))
+	assert.Equal(t, 50, healthScore(1, 2))
+	assert.Equal(t, 0, healthScore(0, 3))
+}
+
+func TestClusterHealthEvaluatorRunDisabled(t *testing.T) {
+	che := &clusterHealthEvaluator{
+		Context:                       context.Background(),
+		Logger:                        ctrl.Log.WithName("clusterhealthevaluator"),
+		clusterHealth:                 new(types.ClusterHealth),
+		clusterHealthEvaluatorEnabled: false,
+	}
+
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+
+	// Run returns immediately without touching the cache if the evaluator is not enabled.
+	done := make(chan struct{})
+	go func() {
+		che.Run(stopCh)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not return with the evaluator disabled")
+	}
+
+	// The handler rejects requests if the evaluator is not enabled.
+	req := httptest.NewRequest("GET", "/api/v1/cluster/health", nil)
+	recorder := httptest.NewRecorder()
+	che.Handler(recorder, req)
+	assert.Equal(t, 422, recorder.Code)
+}
@@ -17,25 +17,90 @@ limitations under the License.
 package controllers
 
 import (
+	"bytes"
 	"context"
+	"crypto/tls"
+	"encoding/json"
 	"fmt"
+	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/go-logr/logr"
 	"github.com/prometheus/client_golang/prometheus"
 	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	utilnet "k8s.io/apimachinery/pkg/util/net"
+	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/metrics"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	diagnosisv1 "github.com/kubediag/kubediag/api/v1"
 	"github.com/kubediag/kubediag/pkg/util"
 )
 
+// CompletionWebhookNotifiedAnnotation is the annotation set after a summary of the diagnosis has
+// been sent to the completion webhook, or after sending has been given up on. Its value is the
+// time of the notification on success and "failed" otherwise.
+const CompletionWebhookNotifiedAnnotation = util.KubeDiagPrefix + "completion-webhook-notified"
+
+// CompletionWebhookFailedAnnotationValue is the value of CompletionWebhookNotifiedAnnotation
+// recorded after all attempts to send a summary to the completion webhook have failed.
+const CompletionWebhookFailedAnnotationValue = "failed"
+
+// ResultsConfigMapNameSuffix is the suffix of the name of the config map mirroring the results
+// of a diagnosis.
+const ResultsConfigMapNameSuffix = ".results"
+
+// ParameterAnnotationPrefix is the prefix of diagnosis annotations which are merged into the task
+// parameter map with the prefix stripped.
+const ParameterAnnotationPrefix = "param." + util.KubeDiagPrefix
+
+// PodEventsRecordedAnnotation is the annotation set after events summarizing the diagnosis
+// outcome have been recorded on the target pods.
+const PodEventsRecordedAnnotation = util.KubeDiagPrefix + "pod-events-recorded"
+
+// completionWebhookBackoff is the recommended backoff for a failure when sending a summary to the
+// completion webhook.
+var completionWebhookBackoff = wait.Backoff{
+	Steps:    3,
+	Duration: 2 * time.Second,
+	Factor:   2.0,
+	Jitter:   0.1,
+}
+
+// diagnosisCompletion is the summary of a completed diagnosis sent to the completion webhook.
+type diagnosisCompletion struct {
+	// Name specifies the name of the diagnosis.
+	Name string `json:"name"`
+	// Namespace specifies the namespace of the diagnosis.
+	Namespace string `json:"namespace"`
+	// UID is the unique identifier of the diagnosis.
+	UID types.UID `json:"uid"`
+	// Phase is the terminal phase of the diagnosis.
+	Phase diagnosisv1.DiagnosisPhase `json:"phase"`
+	// StartTime is RFC 3339 date and time at which the diagnosis was acknowledged by the system.
+	StartTime metav1.Time `json:"startTime"`
+	// SucceededPath is the succeeded path in diagnosis pipeline.
+	// +optional
+	SucceededPath diagnosisv1.Path `json:"succeededPath,omitempty"`
+	// FailedPaths contains all failed paths in diagnosis pipeline.
+	// +optional
+	FailedPaths []diagnosisv1.Path `json:"failedPaths,omitempty"`
+}
+
 // Kubediag master metrics
 var (
 	diagnosisMasterSkipCount = prometheus.NewCounter(
@@ -69,6 +134,14 @@ var (
 		},
 		[]string{"name", "operationset", "phase"},
 	)
+	diagnosisDurationSeconds = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "diagnosis_duration_seconds",
+			Help:    "Histogram of end to end diagnosis duration in seconds",
+			Buckets: prometheus.ExponentialBuckets(1, 2, 12),
+		},
+		[]string{"phase"},
+	)
 )
 
 // Kubediag agent metrics
@@ -96,6 +169,15 @@ type DiagnosisReconciler struct {
 
 	nodeName   string
 	executorCh chan diagnosisv1.Diagnosis
+	// transport is the transport for sending http requests to completion webhooks.
+	transport *http.Transport
+	// diagnosisLabelSelector is the label selector for filtering diagnoses to be reconciled.
+	// All diagnoses are reconciled if empty.
+	diagnosisLabelSelector string
+	// operationSetReadinessGracePeriod is the amount of time a diagnosis waits for its
+	// operation set to become ready before failing. The diagnosis fails immediately if the
+	// grace period is not positive.
+	operationSetReadinessGracePeriod time.Duration
 }
 
 // NewDiagnosisReconciler creates a new DiagnosisReconciler.
@@ -106,6 +188,8 @@ func NewDiagnosisReconciler(
 	eventRecorder record.EventRecorder,
 	nodeName string,
 	executorCh chan diagnosisv1.Diagnosis,
+	diagnosisLabelSelector string,
+	operationSetReadinessGracePeriod time.Duration,
 ) *DiagnosisReconciler {
 	metrics.Registry.MustRegister(
 		diagnosisMasterSkipCount,
@@ -113,22 +197,34 @@ func NewDiagnosisReconciler(
 		diagnosisTotalSuccessCount,
 		diagnosisTotalFailCount,
 		diagnosisInfo,
+		diagnosisDurationSeconds,
 		diagnosisAgentSkipCount,
 		diagnosisAgentQueuedCount,
 	)
 
+	transport := utilnet.SetTransportDefaults(
+		&http.Transport{
+			TLSClientConfig:   &tls.Config{InsecureSkipVerify: true},
+			DisableKeepAlives: true,
+			Proxy:             http.ProxyURL(nil),
+		})
+
 	return &DiagnosisReconciler{
-		Client:        cli,
-		Log:           log,
-		Scheme:        scheme,
-		eventRecorder: eventRecorder,
-		nodeName:      nodeName,
-		executorCh:    executorCh,
+		Client:                           cli,
+		Log:                              log,
+		Scheme:                           scheme,
+		eventRecorder:                    eventRecorder,
+		nodeName:                         nodeName,
+		executorCh:                       executorCh,
+		transport:                        transport,
+		diagnosisLabelSelector:           diagnosisLabelSelector,
+		operationSetReadinessGracePeriod: operationSetReadinessGracePeriod,
 	}
 }
 
 // +kubebuilder:rbac:groups=diagnosis.kubediag.org,resources=diagnoses,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=diagnosis.kubediag.org,resources=diagnoses/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=diagnosis.kubediag.org,resources=operations,verbs=get;list;watch
 // +kubebuilder:rbac:groups=diagnosis.kubediag.org,resources=commonevents,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=diagnosis.kubediag.org,resources=commonevents/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=core,resources=pods,verbs=get;list;watch;delete
@@ -138,6 +234,7 @@ func NewDiagnosisReconciler(
 // +kubebuilder:rbac:groups=apps,resources=statefulsets,verbs=get;list;watch
 // +kubebuilder:rbac:groups=apps,resources=daemonsets,verbs=get;list;watch
 // +kubebuilder:rbac:groups=core,resources=secrets,verbs=get;list;watch
+// +kubebuilder:rbac:groups=core,resources=configmaps,verbs=get;list;watch;create;update
 
 // Reconcile synchronizes a Diagnosis object according to the phase.
 func (r *DiagnosisReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
@@ -171,6 +268,7 @@ func (r *DiagnosisReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 			diagnosisTotalCount.Inc()
 
 			diagnosis.Status.StartTime = metav1.Now()
+			diagnosis.Status.EndTime = metav1.Time{}
 			diagnosis.Status.Phase = diagnosisv1.DiagnosisFailed
 			if err := r.Status().Update(ctx, &diagnosis); err != nil {
 				log.Error(err, "target selector not found")
@@ -180,7 +278,9 @@ func (r *DiagnosisReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 			return ctrl.Result{}, nil
 		}
 
+		// Reset the end time of a previous run so the terminal phase of this run records it anew.
 		diagnosis.Status.StartTime = metav1.Now()
+		diagnosis.Status.EndTime = metav1.Time{}
 		diagnosis.Status.Phase = diagnosisv1.DiagnosisPending
 		if err := r.Status().Update(ctx, &diagnosis); err != nil {
 			log.Error(err, "unable to update Diagnosis")
@@ -210,7 +310,7 @@ func (r *DiagnosisReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 		} else if len(diagnosis.Spec.TargetSelector.NodeNames) != 0 {
 			nodeNames = append(nodeNames, diagnosis.Spec.TargetSelector.NodeNames...)
 		} else if diagnosis.Spec.TargetSelector.PodSelector != nil {
-			labelSelector, err := metav1.LabelSelectorAsSelector(diagnosis.Spec.TargetSelector.NodeSelector)
+			labelSelector, err := metav1.LabelSelectorAsSelector(diagnosis.Spec.TargetSelector.PodSelector)
 			if err != nil {
 				log.Error(err, "unable to get pod label selector")
 				return ctrl.Result{}, err
@@ -293,8 +393,19 @@ func (r *DiagnosisReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 			return ctrl.Result{}, err
 		}
 
-		// Validate the operation set is ready.
-		if !operationset.Status.Ready {
+		// Validate the operation set is ready. A diagnosis pinned to an archived version is
+		// not affected by a rebuild of the current specification.
+		if !operationset.Status.Ready && getArchivedOperationSetVersion(&operationset, diagnosis.GetLabels()[util.OperationSetUniqueLabelKey]) == nil {
+			// Wait for the graph to be built within the readiness grace period since the
+			// operation set may have been created just before the diagnosis.
+			if r.operationSetReadinessGracePeriod > 0 && time.Since(diagnosis.Status.StartTime.Time) < r.operationSetReadinessGracePeriod {
+				log.Info("waiting for operation set to become ready", "operationset", operationset.Name, "diagnosis", client.ObjectKey{
+					Name:      diagnosis.Name,
+					Namespace: diagnosis.Namespace,
+				})
+				return ctrl.Result{RequeueAfter: 5 * time.Second}, nil
+			}
+
 			log.Info("the graph has not been updated according to the latest specification")
 
 			r.eventRecorder.Eventf(&diagnosis, corev1.EventTypeWarning, "DiagnosisFailed", "Failed to run diagnosis %s/%s since operation set is not ready", diagnosis.Namespace, diagnosis.Name)
@@ -338,19 +449,28 @@ func (r *DiagnosisReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 		if operationSetAdjacencyListHash != diagnosisAdjacencyListHash {
 			log.Info("hash value caculated from adjacency list has been changed", "new", operationSetAdjacencyListHash, "old", diagnosisAdjacencyListHash)
 
-			r.eventRecorder.Eventf(&diagnosis, corev1.EventTypeWarning, "DiagnosisFailed", "Failed to run diagnosis %s/%s since operation set has been changed during execution", diagnosis.Namespace, diagnosis.Name)
-			diagnosis.Status.Phase = diagnosisv1.DiagnosisFailed
-			util.UpdateDiagnosisCondition(&diagnosis.Status, &diagnosisv1.DiagnosisCondition{
-				Type:    diagnosisv1.OperationSetChanged,
-				Status:  corev1.ConditionTrue,
-				Reason:  "OperationSetChanged",
-				Message: fmt.Sprintf("OperationSet %s specification has been changed during diagnosis execution", operationset.Name),
-			})
-			if err := r.Status().Update(ctx, &diagnosis); err != nil {
-				return ctrl.Result{}, fmt.Errorf("unable to update Diagnosis: %s", err)
+			// Resolve the version the diagnosis is pinned to from archived versions of the
+			// operation set and keep executing that version.
+			pinnedVersion := getArchivedOperationSetVersion(&operationset, diagnosisAdjacencyListHash)
+			if pinnedVersion == nil {
+				r.eventRecorder.Eventf(&diagnosis, corev1.EventTypeWarning, "DiagnosisFailed", "Failed to run diagnosis %s/%s since operation set has been changed during execution", diagnosis.Namespace, diagnosis.Name)
+				diagnosis.Status.Phase = diagnosisv1.DiagnosisFailed
+				util.UpdateDiagnosisCondition(&diagnosis.Status, &diagnosisv1.DiagnosisCondition{
+					Type:    diagnosisv1.OperationSetChanged,
+					Status:  corev1.ConditionTrue,
+					Reason:  "OperationSetChanged",
+					Message: fmt.Sprintf("OperationSet %s specification has been changed during diagnosis execution and version %s is not archived", operationset.Name, diagnosisAdjacencyListHash),
+				})
+				if err := r.Status().Update(ctx, &diagnosis); err != nil {
+					return ctrl.Result{}, fmt.Errorf("unable to update Diagnosis: %s", err)
+				}
+				diagnosisTotalFailCount.Inc()
+				return ctrl.Result{}, nil
 			}
-			diagnosisTotalFailCount.Inc()
-			return ctrl.Result{}, nil
+
+			log.Info("diagnosis is pinned to an archived version of operation set", "operationset", operationset.Name, "hash", diagnosisAdjacencyListHash)
+			operationset.Spec = pinnedVersion.Spec
+			operationset.Status.Paths = pinnedVersion.Paths
 		}
 
 		// Set initial checkpoint before operation execution.
@@ -371,6 +491,12 @@ func (r *DiagnosisReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 			return ctrl.Result{}, nil
 		}
 
+		// Schedule operations on the directed acyclic graph directly if the operation set is
+		// executed in DAG mode.
+		if operationset.Spec.ExecutionMode == diagnosisv1.DAGExecutionMode {
+			return r.syncDiagnosisDAG(ctx, log, &diagnosis, &operationset)
+		}
+
 		// Retrieve operation node information.
 		checkpoint := diagnosis.Status.Checkpoint
 		paths := operationset.Status.Paths
@@ -383,22 +509,126 @@ func (r *DiagnosisReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 		}
 		node := path[checkpoint.NodeIndex]
 
+		// Determine target node names of current operation.
+		targetNodeNames, err := r.targetNodeNames(ctx, &diagnosis, node.Operation)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+
+		// Record the start time of current path for enforcing the per path timeout. The start
+		// time is persisted by the status update of current reconcile pass.
+		if checkpoint.PathStartTime.IsZero() {
+			checkpoint.PathStartTime = metav1.Now()
+		}
+
+		// Abandon current path as failed and try the next path if current path exceeds the
+		// per path timeout.
+		var pathRequeueAfter time.Duration
+		if diagnosis.Spec.PathTimeoutSeconds != nil && *diagnosis.Spec.PathTimeoutSeconds > 0 {
+			pathDeadline := checkpoint.PathStartTime.Add(time.Duration(*diagnosis.Spec.PathTimeoutSeconds) * time.Second)
+			pathRequeueAfter = time.Until(pathDeadline)
+			if pathRequeueAfter <= 0 {
+				log.Info("path timed out", "diagnosis", client.ObjectKey{
+					Name:      diagnosis.Name,
+					Namespace: diagnosis.Namespace,
+				}, "operationset", operationset.Name, "path", path)
+				r.eventRecorder.Eventf(&diagnosis, corev1.EventTypeWarning, "PathTimedOut", "Path %d of diagnosis %s/%s exceeded timeout of %d seconds", checkpoint.PathIndex, diagnosis.Namespace, diagnosis.Name, *diagnosis.Spec.PathTimeoutSeconds)
+
+				// Set current path as failed path and clear succeeded path.
+				if diagnosis.Status.FailedPaths == nil {
+					diagnosis.Status.FailedPaths = make([]diagnosisv1.Path, 0, len(paths))
+				}
+				diagnosis.Status.FailedPaths = append(diagnosis.Status.FailedPaths, path)
+				diagnosis.Status.SucceededPath = nil
+
+				// Set phase to failed if all paths are failed or timed out.
+				if checkpoint.PathIndex == len(paths)-1 {
+					log.Info("failed to run diagnosis", "diagnosis", client.ObjectKey{
+						Name:      diagnosis.Name,
+						Namespace: diagnosis.Namespace,
+					})
+					r.eventRecorder.Eventf(&diagnosis, corev1.EventTypeWarning, "DiagnosisFailed", "Failed to run diagnosis %s/%s", diagnosis.Namespace, diagnosis.Name)
+					diagnosis.Status.Phase = diagnosisv1.DiagnosisFailed
+					if err := r.Status().Update(ctx, &diagnosis); err != nil {
+						return ctrl.Result{}, fmt.Errorf("unable to update Diagnosis: %s", err)
+					}
+					return ctrl.Result{}, nil
+				}
+
+				// Increment path index if paths has remaining paths to executed.
+				checkpoint.PathIndex++
+				checkpoint.NodeIndex = 0
+				checkpoint.Active = 0
+				checkpoint.Desired = 0
+				checkpoint.Succeeded = 0
+				checkpoint.Failed = 0
+				checkpoint.SynchronizedTasks = []string{}
+				checkpoint.PathStartTime = metav1.Now()
+				checkpoint.PathFailed = false
+				if err := r.Status().Update(ctx, &diagnosis); err != nil {
+					return ctrl.Result{}, fmt.Errorf("unable to update Diagnosis: %s", err)
+				}
+				return ctrl.Result{}, nil
+			}
+		}
+
+		// Tally the phases of tasks created for the current checkpoint so that succeeded and
+		// failed counts reflect tasks finished by the executor.
+		var taskList diagnosisv1.TaskList
+		if err := r.List(ctx, &taskList, client.InNamespace(diagnosis.Namespace), client.MatchingLabels{
+			"diagnosis-namespace": diagnosis.Namespace,
+			"diagnosis-name":      diagnosis.Name,
+		}); err != nil {
+			return ctrl.Result{}, fmt.Errorf("unable to list Tasks: %s", err)
+		}
+		taskNameSuffix := "." + strconv.Itoa(checkpoint.PathIndex) + "." + strconv.Itoa(checkpoint.NodeIndex) + "." + node.Operation
+		active := 0
+		succeeded := 0
+		failed := 0
+		var operationStartTime metav1.Time
+		for _, task := range taskList.Items {
+			if !strings.HasSuffix(task.Name, taskNameSuffix) {
+				continue
+			}
+			switch task.Status.Phase {
+			case diagnosisv1.TaskSucceeded:
+				succeeded++
+			case diagnosisv1.TaskFailed:
+				failed++
+			default:
+				active++
+			}
+			if !task.Status.StartTime.IsZero() && (operationStartTime.IsZero() || task.Status.StartTime.Before(&operationStartTime)) {
+				operationStartTime = task.Status.StartTime
+			}
+		}
+		diagnosis.Status.Checkpoint.Active = active
+		diagnosis.Status.Checkpoint.Succeeded = succeeded
+		diagnosis.Status.Checkpoint.Failed = failed
+
 		// Set desired number of tasks.
 		desired := diagnosis.Status.Checkpoint.Desired
-		active := diagnosis.Status.Checkpoint.Active
-		succeeded := diagnosis.Status.Checkpoint.Succeeded
-		failed := diagnosis.Status.Checkpoint.Failed
 		if diagnosis.Status.Checkpoint.Desired == 0 {
-			diagnosis.Status.Checkpoint.Desired = len(diagnosis.Status.NodeNames)
+			diagnosis.Status.Checkpoint.Desired = len(targetNodeNames)
 			if err := r.Status().Update(ctx, &diagnosis); err != nil {
 				return ctrl.Result{}, fmt.Errorf("unable to update Diagnosis: %s", err)
 			}
 			return ctrl.Result{}, nil
 		}
 
-		// Create tasks for current checkpoint.
+		// Create tasks for current checkpoint. Tasks are created in batches limited by spec parallelism
+		// to avoid flooding the apiserver on large node sets. The status update on active count triggers
+		// another reconcile pass which advances to the next batch.
 		if active+succeeded+failed != desired {
-			for _, nodeName := range diagnosis.Status.NodeNames {
+			created := active + succeeded + failed
+			if created > len(targetNodeNames) {
+				return ctrl.Result{}, fmt.Errorf("invalid checkpoint task count %d of %d node names", created, len(targetNodeNames))
+			}
+			batch := targetNodeNames[created:]
+			if diagnosis.Spec.Parallelism != nil && *diagnosis.Spec.Parallelism > 0 && len(batch) > int(*diagnosis.Spec.Parallelism) {
+				batch = batch[:*diagnosis.Spec.Parallelism]
+			}
+			for _, nodeName := range batch {
 				log.Info("creating task", "task", client.ObjectKey{
 					Name:      diagnosis.Name,
 					Namespace: diagnosis.Namespace,
@@ -423,9 +653,11 @@ func (r *DiagnosisReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 						OwnerReferences: owner,
 					},
 					Spec: diagnosisv1.TaskSpec{
-						Operation:  node.Operation,
-						NodeName:   nodeName,
-						Parameters: diagnosis.Spec.Parameters,
+						Operation: node.Operation,
+						NodeName:  nodeName,
+						// Parameters of the adjacency node override diagnosis parameters which in
+						// turn override annotation derived parameters and operation set defaults.
+						Parameters: util.MergeStringMaps(operationset.Spec.Parameters, parameterAnnotations(&diagnosis), diagnosis.Spec.Parameters, node.Parameters),
 					},
 				}
 
@@ -466,40 +698,86 @@ func (r *DiagnosisReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 
 			return ctrl.Result{}, nil
 		} else if succeeded+failed == desired && succeeded != 0 {
-			// Set current path as succeeded path if current operation is succeeded.
-			if diagnosis.Status.SucceededPath == nil {
-				diagnosis.Status.SucceededPath = make(diagnosisv1.Path, 0, len(path))
-			}
-			diagnosis.Status.SucceededPath = append(diagnosis.Status.SucceededPath, node)
+			diagnosis.Status.ExecutedOperations = append(diagnosis.Status.ExecutedOperations, diagnosisv1.ExecutedOperation{
+				Name:      node.Operation,
+				Node:      node.ID,
+				PathIndex: checkpoint.PathIndex,
+				NodeIndex: checkpoint.NodeIndex,
+				Phase:     diagnosisv1.DiagnosisSucceeded,
+				StartTime: operationStartTime,
+				EndTime:   metav1.Now(),
+			})
 
-			// Set phase to succeeded if current path has been finished and all operations are succeeded.
-			if checkpoint.NodeIndex == len(path)-1 {
-				log.Info("running diagnosis successfully", "diagnosis", client.ObjectKey{
-					Name:      diagnosis.Name,
-					Namespace: diagnosis.Namespace,
-				})
-				r.eventRecorder.Eventf(&diagnosis, corev1.EventTypeNormal, "DiagnosisSucceeded", "Running %s/%s diagnosis successfully", diagnosis.Namespace, diagnosis.Name)
+			if checkpoint.PathFailed {
+				// An earlier operation in current path has failed and only remaining nodes flagged
+				// as always run are executed before abandoning the path.
+				if next := nextAlwaysRunNodeIndex(path, checkpoint.NodeIndex); next >= 0 {
+					checkpoint.NodeIndex = next
+					checkpoint.Active = 0
+					checkpoint.Desired = 0
+					checkpoint.Succeeded = 0
+					checkpoint.Failed = 0
+					checkpoint.SynchronizedTasks = []string{}
+				} else if checkpoint.PathIndex == len(paths)-1 {
+					// Set phase to failed if all paths are failed.
+					log.Info("failed to run diagnosis", "diagnosis", client.ObjectKey{
+						Name:      diagnosis.Name,
+						Namespace: diagnosis.Namespace,
+					})
+					r.eventRecorder.Eventf(&diagnosis, corev1.EventTypeWarning, "DiagnosisFailed", "Failed to run diagnosis %s/%s", diagnosis.Namespace, diagnosis.Name)
+					diagnosis.Status.Phase = diagnosisv1.DiagnosisFailed
+					if err := r.Status().Update(ctx, &diagnosis); err != nil {
+						return ctrl.Result{}, fmt.Errorf("unable to update Diagnosis: %s", err)
+					}
+					return ctrl.Result{}, nil
+				} else {
+					// Increment path index if paths has remaining paths to executed.
+					checkpoint.PathIndex++
+					checkpoint.NodeIndex = 0
+					checkpoint.Active = 0
+					checkpoint.Desired = 0
+					checkpoint.Succeeded = 0
+					checkpoint.Failed = 0
+					checkpoint.SynchronizedTasks = []string{}
+					checkpoint.PathStartTime = metav1.Now()
+					checkpoint.PathFailed = false
+				}
+			} else {
+				// Set current path as succeeded path if current operation is succeeded.
+				if diagnosis.Status.SucceededPath == nil {
+					diagnosis.Status.SucceededPath = make(diagnosisv1.Path, 0, len(path))
+				}
+				diagnosis.Status.SucceededPath = append(diagnosis.Status.SucceededPath, node)
 
-				util.UpdateDiagnosisCondition(&diagnosis.Status, &diagnosisv1.DiagnosisCondition{
-					Type:    diagnosisv1.DiagnosisComplete,
-					Status:  corev1.ConditionTrue,
-					Reason:  "DiagnosisComplete",
-					Message: fmt.Sprintf("Diagnosis is completed"),
-				})
-				diagnosis.Status.Phase = diagnosisv1.DiagnosisSucceeded
-				if err := r.Status().Update(ctx, &diagnosis); err != nil {
-					return ctrl.Result{}, fmt.Errorf("unable to update Diagnosis: %s", err)
+				// Set phase to succeeded if current path has been finished and all operations are succeeded.
+				if checkpoint.NodeIndex == len(path)-1 {
+					log.Info("running diagnosis successfully", "diagnosis", client.ObjectKey{
+						Name:      diagnosis.Name,
+						Namespace: diagnosis.Namespace,
+					})
+					r.eventRecorder.Eventf(&diagnosis, corev1.EventTypeNormal, "DiagnosisSucceeded", "Running %s/%s diagnosis successfully", diagnosis.Namespace, diagnosis.Name)
+
+					util.UpdateDiagnosisCondition(&diagnosis.Status, &diagnosisv1.DiagnosisCondition{
+						Type:    diagnosisv1.DiagnosisComplete,
+						Status:  corev1.ConditionTrue,
+						Reason:  "DiagnosisComplete",
+						Message: fmt.Sprintf("Diagnosis is completed"),
+					})
+					diagnosis.Status.Phase = diagnosisv1.DiagnosisSucceeded
+					if err := r.Status().Update(ctx, &diagnosis); err != nil {
+						return ctrl.Result{}, fmt.Errorf("unable to update Diagnosis: %s", err)
+					}
+					return ctrl.Result{}, nil
 				}
-				return ctrl.Result{}, nil
-			}
 
-			// Increment node index if path has remaining operations to executed.
-			checkpoint.NodeIndex++
-			checkpoint.Active = 0
-			checkpoint.Desired = 0
-			checkpoint.Succeeded = 0
-			checkpoint.Failed = 0
-			checkpoint.SynchronizedTasks = []string{}
+				// Increment node index if path has remaining operations to executed.
+				checkpoint.NodeIndex++
+				checkpoint.Active = 0
+				checkpoint.Desired = 0
+				checkpoint.Succeeded = 0
+				checkpoint.Failed = 0
+				checkpoint.SynchronizedTasks = []string{}
+			}
 		} else if failed == desired {
 			log.Info("failed to execute operation", "diagnosis", client.ObjectKey{
 				Name:      diagnosis.Name,
@@ -508,14 +786,36 @@ func (r *DiagnosisReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 			r.eventRecorder.Eventf(&diagnosis, corev1.EventTypeWarning, "OperationFailed", "Failed to execute operation %s", node.Operation)
 
 			// Set current path as failed path and clear succeeded path if current operation is failed.
-			if diagnosis.Status.FailedPaths == nil {
-				diagnosis.Status.FailedPaths = make([]diagnosisv1.Path, 0, len(paths))
+			// The path is recorded as failed only once even if a subsequent always run node fails too.
+			if !checkpoint.PathFailed {
+				if diagnosis.Status.FailedPaths == nil {
+					diagnosis.Status.FailedPaths = make([]diagnosisv1.Path, 0, len(paths))
+				}
+				diagnosis.Status.FailedPaths = append(diagnosis.Status.FailedPaths, path)
+				diagnosis.Status.SucceededPath = nil
+				checkpoint.PathFailed = true
 			}
-			diagnosis.Status.FailedPaths = append(diagnosis.Status.FailedPaths, path)
-			diagnosis.Status.SucceededPath = nil
+			diagnosis.Status.ExecutedOperations = append(diagnosis.Status.ExecutedOperations, diagnosisv1.ExecutedOperation{
+				Name:      node.Operation,
+				Node:      node.ID,
+				PathIndex: checkpoint.PathIndex,
+				NodeIndex: checkpoint.NodeIndex,
+				Phase:     diagnosisv1.DiagnosisFailed,
+				StartTime: operationStartTime,
+				EndTime:   metav1.Now(),
+			})
 
-			// Set phase to failed if all paths are failed.
-			if checkpoint.PathIndex == len(paths)-1 {
+			// Execute remaining nodes flagged as always run, e.g. cleanup or final reporting,
+			// before advancing the path index.
+			if next := nextAlwaysRunNodeIndex(path, checkpoint.NodeIndex); next >= 0 {
+				checkpoint.NodeIndex = next
+				checkpoint.Active = 0
+				checkpoint.Desired = 0
+				checkpoint.Succeeded = 0
+				checkpoint.Failed = 0
+				checkpoint.SynchronizedTasks = []string{}
+			} else if checkpoint.PathIndex == len(paths)-1 {
+				// Set phase to failed if all paths are failed.
 				log.Info("failed to run diagnosis", "diagnosis", client.ObjectKey{
 					Name:      diagnosis.Name,
 					Namespace: diagnosis.Namespace,
@@ -526,37 +826,664 @@ func (r *DiagnosisReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 					return ctrl.Result{}, fmt.Errorf("unable to update Diagnosis: %s", err)
 				}
 				return ctrl.Result{}, nil
+			} else {
+				// Increment path index if paths has remaining paths to executed.
+				checkpoint.PathIndex++
+				checkpoint.NodeIndex = 0
+				checkpoint.Active = 0
+				checkpoint.Desired = 0
+				checkpoint.Succeeded = 0
+				checkpoint.Failed = 0
+				checkpoint.SynchronizedTasks = []string{}
+				checkpoint.PathStartTime = metav1.Now()
+				checkpoint.PathFailed = false
 			}
-
-			// Increment path index if paths has remaining paths to executed.
-			checkpoint.PathIndex++
-			checkpoint.NodeIndex = 0
-			checkpoint.Active = 0
-			checkpoint.Desired = 0
-			checkpoint.Succeeded = 0
-			checkpoint.Failed = 0
-			checkpoint.SynchronizedTasks = []string{}
 		}
 
 		if err := r.Status().Update(ctx, &diagnosis); err != nil {
 			return ctrl.Result{}, fmt.Errorf("unable to update Diagnosis: %s", err)
 		}
 
-		return ctrl.Result{}, nil
+		// Requeue before the path deadline so a hung path is abandoned even if no task event
+		// triggers another reconcile pass.
+		return ctrl.Result{RequeueAfter: pathRequeueAfter}, nil
 	case diagnosisv1.DiagnosisFailed:
 		diagnosisTotalFailCount.Inc()
+		if err := r.recordDiagnosisDuration(ctx, log, &diagnosis); err != nil {
+			return ctrl.Result{}, err
+		}
+		if err := r.notifyDiagnosisCompletion(ctx, log, &diagnosis); err != nil {
+			return ctrl.Result{}, err
+		}
+		if err := r.recordPodEvents(ctx, log, &diagnosis); err != nil {
+			return ctrl.Result{}, err
+		}
+		if err := r.mirrorResultsConfigMap(ctx, log, &diagnosis); err != nil {
+			return ctrl.Result{}, err
+		}
 	case diagnosisv1.DiagnosisSucceeded:
 		diagnosisTotalSuccessCount.Inc()
+		if err := r.recordDiagnosisDuration(ctx, log, &diagnosis); err != nil {
+			return ctrl.Result{}, err
+		}
+		if err := r.notifyDiagnosisCompletion(ctx, log, &diagnosis); err != nil {
+			return ctrl.Result{}, err
+		}
+		if err := r.recordPodEvents(ctx, log, &diagnosis); err != nil {
+			return ctrl.Result{}, err
+		}
+		if err := r.mirrorResultsConfigMap(ctx, log, &diagnosis); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	return ctrl.Result{}, nil
+}
+
+// recordDiagnosisDuration sets the end time of the diagnosis and observes the end to end
+// duration in the duration histogram labeled by the terminal phase. The duration is observed
+// only once for a diagnosis and skipped if the start time has not been recorded.
+func (r *DiagnosisReconciler) recordDiagnosisDuration(ctx context.Context, log logr.Logger, diagnosis *diagnosisv1.Diagnosis) error {
+	if !diagnosis.Status.EndTime.IsZero() || diagnosis.Status.StartTime.IsZero() {
+		return nil
+	}
+
+	diagnosis.Status.EndTime = metav1.Now()
+	diagnosisDurationSeconds.WithLabelValues(strings.ToLower(string(diagnosis.Status.Phase))).Observe(diagnosis.Status.EndTime.Sub(diagnosis.Status.StartTime.Time).Seconds())
+	if err := r.Status().Update(ctx, diagnosis); err != nil {
+		return fmt.Errorf("unable to update Diagnosis: %s", err)
+	}
+
+	log.Info("diagnosis duration recorded", "diagnosis", client.ObjectKey{
+		Name:      diagnosis.Name,
+		Namespace: diagnosis.Namespace,
+	}, "duration", diagnosis.Status.EndTime.Sub(diagnosis.Status.StartTime.Time))
+
+	return nil
+}
+
+// getArchivedOperationSetVersion returns the archived version of the operation set with the
+// provided hash, or nil if the version is not archived.
+func getArchivedOperationSetVersion(operationset *diagnosisv1.OperationSet, hash string) *diagnosisv1.OperationSetVersion {
+	if hash == "" {
+		return nil
+	}
+	for i := range operationset.Status.Versions {
+		if operationset.Status.Versions[i].Hash == hash {
+			return &operationset.Status.Versions[i]
+		}
+	}
+
+	return nil
+}
+
+// nextAlwaysRunNodeIndex returns the index of the first node after the provided index in the
+// path flagged as always run, or -1 if there is none.
+func nextAlwaysRunNodeIndex(path diagnosisv1.Path, index int) int {
+	for i := index + 1; i < len(path); i++ {
+		if path[i].AlwaysRun {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// targetNodeNames returns the node names tasks of an operation are created on. A master run
+// location operation is dispatched as a single task on the node of this controller instead of
+// being fanned out to every target node.
+func (r *DiagnosisReconciler) targetNodeNames(ctx context.Context, diagnosis *diagnosisv1.Diagnosis, operationName string) ([]string, error) {
+	var operation diagnosisv1.Operation
+	if err := r.Get(ctx, client.ObjectKey{
+		Name: operationName,
+	}, &operation); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return nil, err
+		}
+	} else if operation.Spec.Processor.RunLocation == diagnosisv1.MasterRunLocation {
+		return []string{r.nodeName}, nil
+	}
+
+	return diagnosis.Status.NodeNames, nil
+}
+
+// syncDiagnosisDAG schedules operations of a diagnosis in DAG execution mode. Every node of
+// the adjacency list runs once after all its predecessors succeeded and independent nodes run
+// in parallel. The diagnosis fails if any operation fails since its successors can never run.
+func (r *DiagnosisReconciler) syncDiagnosisDAG(ctx context.Context, log logr.Logger, diagnosis *diagnosisv1.Diagnosis, operationset *diagnosisv1.OperationSet) (ctrl.Result, error) {
+	nodes := operationset.Spec.AdjacencyList
+
+	// Derive the predecessors of every node from the adjacency list. Nodes without an
+	// operation, e.g. the start node, are not scheduled and do not gate their successors.
+	predecessors := make(map[int][]int)
+	for _, node := range nodes {
+		if node.Operation == "" {
+			continue
+		}
+		for _, to := range node.To {
+			predecessors[to] = append(predecessors[to], node.ID)
+		}
+	}
+
+	var taskList diagnosisv1.TaskList
+	if err := r.List(ctx, &taskList, client.InNamespace(diagnosis.Namespace), client.MatchingLabels{
+		"diagnosis-namespace": diagnosis.Namespace,
+		"diagnosis-name":      diagnosis.Name,
+	}); err != nil {
+		return ctrl.Result{}, fmt.Errorf("unable to list Tasks: %s", err)
+	}
+
+	// Tally task phases per node. Task names embed the node id so that tasks of different
+	// nodes running the same operation do not collide.
+	type nodeTally struct {
+		created   int
+		succeeded int
+		failed    int
+		startTime metav1.Time
+	}
+	tallies := make(map[int]*nodeTally)
+	targets := make(map[int][]string)
+	for _, node := range nodes {
+		if node.Operation == "" {
+			continue
+		}
+		tally := &nodeTally{}
+		taskNameSuffix := ".0." + strconv.Itoa(node.ID) + "." + node.Operation
+		for _, task := range taskList.Items {
+			if !strings.HasSuffix(task.Name, taskNameSuffix) {
+				continue
+			}
+			tally.created++
+			switch task.Status.Phase {
+			case diagnosisv1.TaskSucceeded:
+				tally.succeeded++
+			case diagnosisv1.TaskFailed:
+				tally.failed++
+			}
+			if !task.Status.StartTime.IsZero() && (tally.startTime.IsZero() || task.Status.StartTime.Before(&tally.startTime)) {
+				tally.startTime = task.Status.StartTime
+			}
+		}
+		tallies[node.ID] = tally
+
+		targetNodeNames, err := r.targetNodeNames(ctx, diagnosis, node.Operation)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		targets[node.ID] = targetNodeNames
+	}
+
+	// Record finished operations and determine whether the graph completed.
+	finished := make(map[int]bool)
+	succeeded := make(map[int]bool)
+	anyFailed := false
+	allSucceeded := true
+	for _, node := range nodes {
+		if node.Operation == "" {
+			continue
+		}
+		tally := tallies[node.ID]
+		if tally.created == len(targets[node.ID]) && tally.succeeded+tally.failed == tally.created {
+			finished[node.ID] = true
+			phase := diagnosisv1.DiagnosisSucceeded
+			if tally.failed > 0 {
+				phase = diagnosisv1.DiagnosisFailed
+				anyFailed = true
+			} else {
+				succeeded[node.ID] = true
+			}
+			recordExecutedOperation(diagnosis, node, phase, tally.startTime)
+		} else {
+			allSucceeded = false
+		}
+	}
+
+	if anyFailed {
+		log.Info("failed to run diagnosis", "diagnosis", client.ObjectKey{
+			Name:      diagnosis.Name,
+			Namespace: diagnosis.Namespace,
+		})
+		r.eventRecorder.Eventf(diagnosis, corev1.EventTypeWarning, "DiagnosisFailed", "Failed to run diagnosis %s/%s", diagnosis.Namespace, diagnosis.Name)
+		diagnosis.Status.Phase = diagnosisv1.DiagnosisFailed
+		if err := r.Status().Update(ctx, diagnosis); err != nil {
+			return ctrl.Result{}, fmt.Errorf("unable to update Diagnosis: %s", err)
+		}
+		return ctrl.Result{}, nil
+	}
+	if allSucceeded {
+		log.Info("running diagnosis successfully", "diagnosis", client.ObjectKey{
+			Name:      diagnosis.Name,
+			Namespace: diagnosis.Namespace,
+		})
+		r.eventRecorder.Eventf(diagnosis, corev1.EventTypeNormal, "DiagnosisSucceeded", "Running %s/%s diagnosis successfully", diagnosis.Namespace, diagnosis.Name)
+		util.UpdateDiagnosisCondition(&diagnosis.Status, &diagnosisv1.DiagnosisCondition{
+			Type:    diagnosisv1.DiagnosisComplete,
+			Status:  corev1.ConditionTrue,
+			Reason:  "DiagnosisComplete",
+			Message: fmt.Sprintf("Diagnosis is completed"),
+		})
+		diagnosis.Status.Phase = diagnosisv1.DiagnosisSucceeded
+		if err := r.Status().Update(ctx, diagnosis); err != nil {
+			return ctrl.Result{}, fmt.Errorf("unable to update Diagnosis: %s", err)
+		}
+		return ctrl.Result{}, nil
+	}
+
+	// Create tasks for nodes whose predecessors all succeeded. Creations are limited by spec
+	// parallelism per reconcile pass to avoid flooding the apiserver on large graphs.
+	budget := -1
+	if diagnosis.Spec.Parallelism != nil && *diagnosis.Spec.Parallelism > 0 {
+		budget = int(*diagnosis.Spec.Parallelism)
+	}
+	for _, node := range nodes {
+		if node.Operation == "" || finished[node.ID] {
+			continue
+		}
+		ready := true
+		for _, predecessor := range predecessors[node.ID] {
+			if !succeeded[predecessor] {
+				ready = false
+				break
+			}
+		}
+		if !ready {
+			continue
+		}
+
+		tally := tallies[node.ID]
+		for _, nodeName := range targets[node.ID][tally.created:] {
+			if budget == 0 {
+				break
+			}
+			if budget > 0 {
+				budget--
+			}
+
+			log.Info("creating task", "diagnosis", client.ObjectKey{
+				Name:      diagnosis.Name,
+				Namespace: diagnosis.Namespace,
+			}, "operationset", operationset.Name, "node", node)
+
+			task := diagnosisv1.Task{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      diagnosis.Name + "." + string(diagnosis.UID)[0:8] + "." + nodeName + ".0." + strconv.Itoa(node.ID) + "." + node.Operation,
+					Namespace: diagnosis.Namespace,
+					OwnerReferences: []metav1.OwnerReference{
+						{
+							APIVersion: diagnosis.APIVersion,
+							Kind:       diagnosis.Kind,
+							Name:       diagnosis.Name,
+							UID:        diagnosis.UID,
+						},
+					},
+					Labels: map[string]string{
+						"diagnosis-namespace": diagnosis.Namespace,
+						"diagnosis-name":      diagnosis.Name,
+					},
+				},
+				Spec: diagnosisv1.TaskSpec{
+					Operation: node.Operation,
+					NodeName:  nodeName,
+					// Parameters of the adjacency node override diagnosis parameters which in
+					// turn override annotation derived parameters and operation set defaults.
+					Parameters: util.MergeStringMaps(operationset.Spec.Parameters, parameterAnnotations(diagnosis), diagnosis.Spec.Parameters, node.Parameters),
+				},
+			}
+			if err := r.Create(ctx, &task); err != nil {
+				if apierrors.IsAlreadyExists(err) {
+					continue
+				}
+				log.Error(err, "unable to create Task")
+				return ctrl.Result{}, err
+			}
+			task.Status.StartTime = metav1.Now()
+			task.Status.Phase = diagnosisv1.TaskPending
+			if err := r.Status().Update(ctx, &task); err != nil {
+				log.Error(err, "unable to update Task")
+				return ctrl.Result{}, client.IgnoreNotFound(err)
+			}
+		}
+	}
+
+	if err := r.Status().Update(ctx, diagnosis); err != nil {
+		return ctrl.Result{}, fmt.Errorf("unable to update Diagnosis: %s", err)
 	}
 
 	return ctrl.Result{}, nil
 }
 
+// recordExecutedOperation appends an executed operation entry for a node of the directed
+// acyclic graph unless the node is already recorded.
+func recordExecutedOperation(diagnosis *diagnosisv1.Diagnosis, node diagnosisv1.Node, phase diagnosisv1.DiagnosisPhase, startTime metav1.Time) {
+	for _, executed := range diagnosis.Status.ExecutedOperations {
+		if executed.Node == node.ID && executed.Name == node.Operation {
+			return
+		}
+	}
+
+	diagnosis.Status.ExecutedOperations = append(diagnosis.Status.ExecutedOperations, diagnosisv1.ExecutedOperation{
+		Name:      node.Operation,
+		Node:      node.ID,
+		PathIndex: 0,
+		NodeIndex: node.ID,
+		Phase:     phase,
+		StartTime: startTime,
+		EndTime:   metav1.Now(),
+	})
+}
+
+// parameterAnnotations returns parameters derived from diagnosis annotations matching the
+// parameter annotation prefix with the prefix stripped.
+func parameterAnnotations(diagnosis *diagnosisv1.Diagnosis) map[string]string {
+	parameters := make(map[string]string)
+	for key, value := range diagnosis.Annotations {
+		if strings.HasPrefix(key, ParameterAnnotationPrefix) {
+			parameters[strings.TrimPrefix(key, ParameterAnnotationPrefix)] = value
+		}
+	}
+
+	return parameters
+}
+
+// notifyDiagnosisCompletion sends a summary of the diagnosis to the completion webhook with retries.
+// The notification is attempted only once for a diagnosis and skipped if the completion webhook is
+// not specified. A failure after the retries are exhausted is recorded on the diagnosis and not
+// retried on subsequent reconcile passes.
+func (r *DiagnosisReconciler) notifyDiagnosisCompletion(ctx context.Context, log logr.Logger, diagnosis *diagnosisv1.Diagnosis) error {
+	if diagnosis.Spec.CompletionWebhook == nil {
+		return nil
+	}
+	if _, ok := diagnosis.Annotations[CompletionWebhookNotifiedAnnotation]; ok {
+		return nil
+	}
+
+	body, err := json.Marshal(diagnosisCompletion{
+		Name:          diagnosis.Name,
+		Namespace:     diagnosis.Namespace,
+		UID:           diagnosis.UID,
+		Phase:         diagnosis.Status.Phase,
+		StartTime:     diagnosis.Status.StartTime,
+		SucceededPath: diagnosis.Status.SucceededPath,
+		FailedPaths:   diagnosis.Status.FailedPaths,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal diagnosis completion: %s", err)
+	}
+
+	cli := &http.Client{
+		Timeout:   30 * time.Second,
+		Transport: r.transport,
+	}
+	err = fmt.Errorf("timed out waiting for the condition")
+	backoff := completionWebhookBackoff
+	for backoff.Steps > 0 {
+		var res *http.Response
+		res, err = cli.Post(*diagnosis.Spec.CompletionWebhook, "application/json", bytes.NewBuffer(body))
+		if err == nil {
+			res.Body.Close()
+			if res.StatusCode == http.StatusOK {
+				break
+			}
+			err = fmt.Errorf("completion webhook responded with status %s", res.Status)
+		}
+		if backoff.Steps == 1 {
+			break
+		}
+		time.Sleep(backoff.Step())
+	}
+
+	annotationValue := metav1.Now().Format(time.RFC3339)
+	if err != nil {
+		log.Error(err, "failed to send diagnosis summary to completion webhook", "webhook", *diagnosis.Spec.CompletionWebhook)
+		r.eventRecorder.Eventf(diagnosis, corev1.EventTypeWarning, "CompletionWebhookFailed", "Failed to send summary of diagnosis %s/%s to completion webhook", diagnosis.Namespace, diagnosis.Name)
+		annotationValue = CompletionWebhookFailedAnnotationValue
+	} else {
+		log.Info("diagnosis summary sent to completion webhook", "diagnosis", client.ObjectKey{
+			Name:      diagnosis.Name,
+			Namespace: diagnosis.Namespace,
+		}, "webhook", *diagnosis.Spec.CompletionWebhook)
+	}
+
+	// Record the outcome either way so the blocking retry loop is not repeated on every
+	// subsequent reconcile of the terminal diagnosis.
+	annotations := diagnosis.GetAnnotations()
+	if annotations == nil {
+		annotations = make(map[string]string)
+	}
+	annotations[CompletionWebhookNotifiedAnnotation] = annotationValue
+	diagnosis.SetAnnotations(annotations)
+	if err := r.Update(ctx, diagnosis); err != nil {
+		return fmt.Errorf("unable to update Diagnosis: %s", err)
+	}
+
+	return nil
+}
+
+// mirrorResultsConfigMap writes a summary of the diagnosis results into a config map in the
+// diagnosis namespace. The config map is owner referenced to the diagnosis so it is garbage
+// collected with it. Mirroring is skipped if not specified.
+func (r *DiagnosisReconciler) mirrorResultsConfigMap(ctx context.Context, log logr.Logger, diagnosis *diagnosisv1.Diagnosis) error {
+	if !diagnosis.Spec.MirrorResultsToConfigMap {
+		return nil
+	}
+
+	data := map[string]string{
+		"phase": string(diagnosis.Status.Phase),
+	}
+	if !diagnosis.Status.StartTime.IsZero() {
+		data["startTime"] = diagnosis.Status.StartTime.Format(time.RFC3339)
+	}
+	if diagnosis.Status.SucceededPath != nil {
+		raw, err := json.Marshal(diagnosis.Status.SucceededPath)
+		if err != nil {
+			return fmt.Errorf("failed to marshal succeeded path: %s", err)
+		}
+		data["succeededPath"] = string(raw)
+	}
+	if diagnosis.Status.FailedPaths != nil {
+		raw, err := json.Marshal(diagnosis.Status.FailedPaths)
+		if err != nil {
+			return fmt.Errorf("failed to marshal failed paths: %s", err)
+		}
+		data["failedPaths"] = string(raw)
+	}
+	if diagnosis.Status.ExecutedOperations != nil {
+		raw, err := json.Marshal(diagnosis.Status.ExecutedOperations)
+		if err != nil {
+			return fmt.Errorf("failed to marshal executed operations: %s", err)
+		}
+		data["executedOperations"] = string(raw)
+	}
+	if diagnosis.Status.Context != nil {
+		raw, err := json.Marshal(diagnosis.Status.Context)
+		if err != nil {
+			return fmt.Errorf("failed to marshal context: %s", err)
+		}
+		data["context"] = string(raw)
+	}
+
+	configMap := corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      diagnosis.Name + ResultsConfigMapNameSuffix,
+			Namespace: diagnosis.Namespace,
+			OwnerReferences: []metav1.OwnerReference{
+				{
+					APIVersion: diagnosis.APIVersion,
+					Kind:       diagnosis.Kind,
+					Name:       diagnosis.Name,
+					UID:        diagnosis.UID,
+				},
+			},
+		},
+		Data: data,
+	}
+	if err := r.Create(ctx, &configMap); err != nil {
+		if !apierrors.IsAlreadyExists(err) {
+			return fmt.Errorf("unable to create ConfigMap: %s", err)
+		}
+		var existing corev1.ConfigMap
+		if err := r.Get(ctx, client.ObjectKey{
+			Name:      configMap.Name,
+			Namespace: configMap.Namespace,
+		}, &existing); err != nil {
+			return fmt.Errorf("unable to fetch ConfigMap: %s", err)
+		}
+		existing.Data = data
+		if err := r.Update(ctx, &existing); err != nil {
+			return fmt.Errorf("unable to update ConfigMap: %s", err)
+		}
+	}
+
+	log.Info("diagnosis results mirrored to config map", "diagnosis", client.ObjectKey{
+		Name:      diagnosis.Name,
+		Namespace: diagnosis.Namespace,
+	}, "configmap", client.ObjectKey{
+		Name:      configMap.Name,
+		Namespace: configMap.Namespace,
+	})
+
+	return nil
+}
+
+// recordPodEvents records an event summarizing the diagnosis outcome on pods referenced by the
+// target selector. The events are recorded only once for a diagnosis and skipped if recording
+// pod events is not specified.
+func (r *DiagnosisReconciler) recordPodEvents(ctx context.Context, log logr.Logger, diagnosis *diagnosisv1.Diagnosis) error {
+	if !diagnosis.Spec.RecordPodEvents || diagnosis.Spec.TargetSelector == nil {
+		return nil
+	}
+	if _, ok := diagnosis.Annotations[PodEventsRecordedAnnotation]; ok {
+		return nil
+	}
+
+	pods := make([]corev1.Pod, 0)
+	if diagnosis.Spec.TargetSelector.PodSelector != nil {
+		labelSelector, err := metav1.LabelSelectorAsSelector(diagnosis.Spec.TargetSelector.PodSelector)
+		if err != nil {
+			return fmt.Errorf("unable to get pod label selector: %s", err)
+		}
+		var podList corev1.PodList
+		if err := r.List(ctx, &podList, &client.ListOptions{LabelSelector: labelSelector}); err != nil {
+			return fmt.Errorf("unable to list Pods: %s", err)
+		}
+		pods = append(pods, podList.Items...)
+	}
+	for _, podReference := range diagnosis.Spec.TargetSelector.PodReferences {
+		var pod corev1.Pod
+		if err := r.Get(ctx, client.ObjectKey{
+			Name:      podReference.Name,
+			Namespace: podReference.Namespace,
+		}, &pod); err != nil {
+			if apierrors.IsNotFound(err) {
+				continue
+			}
+			return fmt.Errorf("unable to fetch Pod: %s", err)
+		}
+		pods = append(pods, pod)
+	}
+
+	eventType := corev1.EventTypeNormal
+	reason := "DiagnosisSucceeded"
+	if diagnosis.Status.Phase == diagnosisv1.DiagnosisFailed {
+		eventType = corev1.EventTypeWarning
+		reason = "DiagnosisFailed"
+	}
+	for i := range pods {
+		r.eventRecorder.Eventf(&pods[i], eventType, reason, "Diagnosis %s/%s targeting pod %s/%s completed with phase %s", diagnosis.Namespace, diagnosis.Name, pods[i].Namespace, pods[i].Name, diagnosis.Status.Phase)
+	}
+
+	log.Info("diagnosis outcome recorded on target pods", "diagnosis", client.ObjectKey{
+		Name:      diagnosis.Name,
+		Namespace: diagnosis.Namespace,
+	}, "pods", len(pods))
+
+	// Set the annotation to avoid recording the events again.
+	annotations := diagnosis.GetAnnotations()
+	if annotations == nil {
+		annotations = make(map[string]string)
+	}
+	annotations[PodEventsRecordedAnnotation] = metav1.Now().Format(time.RFC3339)
+	diagnosis.SetAnnotations(annotations)
+	if err := r.Update(ctx, diagnosis); err != nil {
+		return fmt.Errorf("unable to update Diagnosis: %s", err)
+	}
+
+	return nil
+}
+
 // SetupWithManager setups DiagnosisReconciler with the provided manager.
 func (r *DiagnosisReconciler) SetupWithManager(mgr ctrl.Manager) error {
-	return ctrl.NewControllerManagedBy(mgr).
+	builder := ctrl.NewControllerManagedBy(mgr).
 		For(&diagnosisv1.Diagnosis{}).
-		Complete(r)
+		// Enqueue the owning diagnosis on task events so a completing task triggers a reconcile
+		// without depending on the periodic resync.
+		Watches(&source.Kind{Type: &diagnosisv1.Task{}}, &handler.EnqueueRequestsFromMapFunc{
+			ToRequests: handler.ToRequestsFunc(taskToDiagnosisRequests),
+		})
+	if r.diagnosisLabelSelector != "" {
+		selector, err := labels.Parse(r.diagnosisLabelSelector)
+		if err != nil {
+			return fmt.Errorf("invalid diagnosis label selector %s: %s", r.diagnosisLabelSelector, err)
+		}
+		builder = builder.WithEventFilter(newLabelSelectorPredicate(selector))
+	}
+
+	return builder.Complete(r)
+}
+
+// taskToDiagnosisRequests maps a task event to a reconcile request of the owning diagnosis.
+// Tasks are labeled with the owning diagnosis on creation.
+func taskToDiagnosisRequests(object handler.MapObject) []reconcile.Request {
+	taskLabels := object.Meta.GetLabels()
+	name, ok := taskLabels["diagnosis-name"]
+	if !ok {
+		return nil
+	}
+	namespace, ok := taskLabels["diagnosis-namespace"]
+	if !ok {
+		return nil
+	}
+
+	return []reconcile.Request{
+		{
+			NamespacedName: types.NamespacedName{
+				Name:      name,
+				Namespace: namespace,
+			},
+		},
+	}
+}
+
+// newLabelSelectorPredicate returns a predicate which only accepts events of objects matching the
+// provided label selector. Task events are always accepted since the owning diagnoses have been
+// filtered on creation.
+func newLabelSelectorPredicate(selector labels.Selector) predicate.Predicate {
+	return predicate.Funcs{
+		CreateFunc: func(e event.CreateEvent) bool {
+			if _, ok := e.Object.(*diagnosisv1.Task); ok {
+				return true
+			}
+			return selector.Matches(labels.Set(e.Meta.GetLabels()))
+		},
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			if _, ok := e.ObjectNew.(*diagnosisv1.Task); ok {
+				return true
+			}
+			return selector.Matches(labels.Set(e.MetaNew.GetLabels()))
+		},
+		DeleteFunc: func(e event.DeleteEvent) bool {
+			if _, ok := e.Object.(*diagnosisv1.Task); ok {
+				return true
+			}
+			return selector.Matches(labels.Set(e.Meta.GetLabels()))
+		},
+		GenericFunc: func(e event.GenericEvent) bool {
+			if _, ok := e.Object.(*diagnosisv1.Task); ok {
+				return true
+			}
+			return selector.Matches(labels.Set(e.Meta.GetLabels()))
+		},
+	}
 }
 
 func (r *DiagnosisReconciler) collectDiagnosisMetricsWithPhase(ctx context.Context, log logr.Logger) {
@@ -0,0 +1,2077 @@
+/*
+Copyright 2022 The KubeDiag Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	utilnet "k8s.io/apimachinery/pkg/util/net"
+	"k8s.io/apimachinery/pkg/util/wait"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	diagnosisv1 "github.com/kubediag/kubediag/api/v1"
+	"github.com/kubediag/kubediag/pkg/util"
+)
+
+// newTestScheme returns a scheme with all types required by diagnosis controller tests registered.
+func newTestScheme() *runtime.Scheme {
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = diagnosisv1.AddToScheme(scheme)
+	_ = corev1.AddToScheme(scheme)
+	return scheme
+}
+
+func TestReconcileRunningDiagnosisWithParallelism(t *testing.T) {
+	scheme := newTestScheme()
+	adjacencyList := []diagnosisv1.Node{
+		{
+			ID: 0,
+			To: diagnosisv1.NodeSet{1},
+		},
+		{
+			ID:        1,
+			Operation: "operation1",
+		},
+	}
+	hash := util.ComputeHash(adjacencyList)
+	parallelism := int32(2)
+
+	operationset := &diagnosisv1.OperationSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "operationset1",
+			Labels: map[string]string{
+				util.OperationSetUniqueLabelKey: hash,
+			},
+		},
+		Spec: diagnosisv1.OperationSetSpec{
+			AdjacencyList: adjacencyList,
+			Parameters: map[string]string{
+				"param1": "default",
+				"param2": "default",
+				"param3": "default",
+			},
+		},
+		Status: diagnosisv1.OperationSetStatus{
+			Ready: true,
+			Paths: []diagnosisv1.Path{
+				{
+					{
+						ID:        1,
+						Operation: "operation1",
+						Parameters: map[string]string{
+							"param1": "node",
+						},
+					},
+				},
+			},
+		},
+	}
+	diagnosis := &diagnosisv1.Diagnosis{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "diagnosis1",
+			Namespace: "default",
+			UID:       types.UID("0123456789"),
+			Labels: map[string]string{
+				util.OperationSetUniqueLabelKey: hash,
+			},
+		},
+		Spec: diagnosisv1.DiagnosisSpec{
+			OperationSet: "operationset1",
+			Parallelism:  &parallelism,
+			Parameters: map[string]string{
+				"param1": "diagnosis",
+				"param2": "diagnosis",
+			},
+			TargetSelector: &diagnosisv1.TargetSelector{
+				NodeNames: []string{"node1", "node2", "node3", "node4", "node5"},
+			},
+		},
+		Status: diagnosisv1.DiagnosisStatus{
+			Phase:     diagnosisv1.DiagnosisRunning,
+			NodeNames: []string{"node1", "node2", "node3", "node4", "node5"},
+			Checkpoint: &diagnosisv1.Checkpoint{
+				PathIndex:         0,
+				NodeIndex:         0,
+				Desired:           5,
+				SynchronizedTasks: []string{},
+			},
+		},
+	}
+
+	cli := fake.NewFakeClientWithScheme(scheme, operationset, diagnosis)
+	reconciler := &DiagnosisReconciler{
+		Client:        cli,
+		Log:           ctrl.Log.WithName("controllers").WithName("Diagnosis"),
+		Scheme:        scheme,
+		eventRecorder: record.NewFakeRecorder(100),
+	}
+	req := ctrl.Request{
+		NamespacedName: types.NamespacedName{
+			Name:      "diagnosis1",
+			Namespace: "default",
+		},
+	}
+
+	// Tasks should be created in batches of parallelism size on each reconcile pass.
+	expectedTaskCounts := []int{2, 4, 5}
+	for _, expected := range expectedTaskCounts {
+		_, err := reconciler.Reconcile(req)
+		assert.NoError(t, err)
+
+		var taskList diagnosisv1.TaskList
+		err = cli.List(context.Background(), &taskList)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, len(taskList.Items), "unexpected task count after reconcile pass")
+	}
+
+	var updated diagnosisv1.Diagnosis
+	err := cli.Get(context.Background(), req.NamespacedName, &updated)
+	assert.NoError(t, err)
+	assert.Equal(t, 5, updated.Status.Checkpoint.Active)
+
+	// The effective task parameters are merged with precedence of node override over diagnosis
+	// parameter over operation set default.
+	var taskList diagnosisv1.TaskList
+	err = cli.List(context.Background(), &taskList)
+	assert.NoError(t, err)
+	for _, task := range taskList.Items {
+		assert.Equal(t, map[string]string{
+			"param1": "node",
+			"param2": "diagnosis",
+			"param3": "default",
+		}, task.Spec.Parameters)
+	}
+}
+
+func TestReconcileRunningDiagnosisTalliesTaskPhases(t *testing.T) {
+	scheme := newTestScheme()
+	adjacencyList := []diagnosisv1.Node{
+		{
+			ID: 0,
+			To: diagnosisv1.NodeSet{1},
+		},
+		{
+			ID:        1,
+			Operation: "operation1",
+			To:        diagnosisv1.NodeSet{2},
+		},
+		{
+			ID:        2,
+			Operation: "operation2",
+		},
+	}
+	hash := util.ComputeHash(adjacencyList)
+
+	operationset := &diagnosisv1.OperationSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "operationset1",
+			Labels: map[string]string{
+				util.OperationSetUniqueLabelKey: hash,
+			},
+		},
+		Spec: diagnosisv1.OperationSetSpec{
+			AdjacencyList: adjacencyList,
+		},
+		Status: diagnosisv1.OperationSetStatus{
+			Ready: true,
+			Paths: []diagnosisv1.Path{
+				{
+					{
+						ID:        1,
+						Operation: "operation1",
+					},
+					{
+						ID:        2,
+						Operation: "operation2",
+					},
+				},
+			},
+		},
+	}
+	diagnosis := &diagnosisv1.Diagnosis{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "diagnosis1",
+			Namespace: "default",
+			UID:       types.UID("0123456789"),
+			Labels: map[string]string{
+				util.OperationSetUniqueLabelKey: hash,
+			},
+		},
+		Spec: diagnosisv1.DiagnosisSpec{
+			OperationSet: "operationset1",
+			TargetSelector: &diagnosisv1.TargetSelector{
+				NodeNames: []string{"node1", "node2"},
+			},
+		},
+		Status: diagnosisv1.DiagnosisStatus{
+			Phase:     diagnosisv1.DiagnosisRunning,
+			NodeNames: []string{"node1", "node2"},
+			Checkpoint: &diagnosisv1.Checkpoint{
+				PathIndex:         0,
+				NodeIndex:         0,
+				Desired:           2,
+				SynchronizedTasks: []string{},
+			},
+		},
+	}
+	newTask := func(nodeName string, nodeIndex int, operation string, phase diagnosisv1.TaskPhase) *diagnosisv1.Task {
+		return &diagnosisv1.Task{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "diagnosis1.01234567." + nodeName + ".0." + strconv.Itoa(nodeIndex) + "." + operation,
+				Namespace: "default",
+				Labels: map[string]string{
+					"diagnosis-namespace": "default",
+					"diagnosis-name":      "diagnosis1",
+				},
+			},
+			Spec: diagnosisv1.TaskSpec{
+				Operation: operation,
+				NodeName:  nodeName,
+			},
+			Status: diagnosisv1.TaskStatus{
+				Phase:     phase,
+				StartTime: metav1.Now(),
+			},
+		}
+	}
+
+	cli := fake.NewFakeClientWithScheme(scheme, operationset, diagnosis,
+		newTask("node1", 0, "operation1", diagnosisv1.TaskSucceeded),
+		newTask("node2", 0, "operation1", diagnosisv1.TaskSucceeded),
+	)
+	reconciler := &DiagnosisReconciler{
+		Client:        cli,
+		Log:           ctrl.Log.WithName("controllers").WithName("Diagnosis"),
+		Scheme:        scheme,
+		eventRecorder: record.NewFakeRecorder(100),
+	}
+	req := ctrl.Request{
+		NamespacedName: types.NamespacedName{
+			Name:      "diagnosis1",
+			Namespace: "default",
+		},
+	}
+
+	// All tasks of the first node have succeeded, so the checkpoint should advance to the
+	// next node of the path.
+	_, err := reconciler.Reconcile(req)
+	assert.NoError(t, err)
+
+	var updated diagnosisv1.Diagnosis
+	err = cli.Get(context.Background(), req.NamespacedName, &updated)
+	assert.NoError(t, err)
+	assert.Equal(t, diagnosisv1.DiagnosisRunning, updated.Status.Phase)
+	assert.Equal(t, 1, updated.Status.Checkpoint.NodeIndex)
+	assert.Equal(t, 0, updated.Status.Checkpoint.Desired)
+	assert.Equal(t, 0, updated.Status.Checkpoint.Succeeded)
+
+	// The next reconcile pass sets the desired count for the new node.
+	_, err = reconciler.Reconcile(req)
+	assert.NoError(t, err)
+	err = cli.Get(context.Background(), req.NamespacedName, &updated)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, updated.Status.Checkpoint.Desired)
+
+	// Once all tasks of the last node succeed, the checkpoint reflects the succeeded count
+	// and the diagnosis completes.
+	err = cli.Create(context.Background(), newTask("node1", 1, "operation2", diagnosisv1.TaskSucceeded))
+	assert.NoError(t, err)
+	err = cli.Create(context.Background(), newTask("node2", 1, "operation2", diagnosisv1.TaskSucceeded))
+	assert.NoError(t, err)
+
+	_, err = reconciler.Reconcile(req)
+	assert.NoError(t, err)
+	err = cli.Get(context.Background(), req.NamespacedName, &updated)
+	assert.NoError(t, err)
+	assert.Equal(t, diagnosisv1.DiagnosisSucceeded, updated.Status.Phase)
+	assert.Equal(t, updated.Status.Checkpoint.Desired, updated.Status.Checkpoint.Succeeded)
+	assert.Equal(t, 0, updated.Status.Checkpoint.Active)
+
+	// Both executed operations are recorded in the execution trace with monotonic timestamps.
+	if assert.Equal(t, 2, len(updated.Status.ExecutedOperations)) {
+		first := updated.Status.ExecutedOperations[0]
+		second := updated.Status.ExecutedOperations[1]
+		assert.Equal(t, "operation1", first.Name)
+		assert.Equal(t, 1, first.Node)
+		assert.Equal(t, 0, first.NodeIndex)
+		assert.Equal(t, diagnosisv1.DiagnosisSucceeded, first.Phase)
+		assert.Equal(t, "operation2", second.Name)
+		assert.Equal(t, 2, second.Node)
+		assert.Equal(t, 1, second.NodeIndex)
+		assert.Equal(t, diagnosisv1.DiagnosisSucceeded, second.Phase)
+		for _, executedOperation := range updated.Status.ExecutedOperations {
+			assert.False(t, executedOperation.StartTime.IsZero())
+			assert.False(t, executedOperation.StartTime.Time.After(executedOperation.EndTime.Time))
+		}
+		assert.False(t, first.EndTime.Time.After(second.EndTime.Time))
+	}
+}
+
+func TestReconcilePendingDiagnosisWithPodSelector(t *testing.T) {
+	scheme := newTestScheme()
+	newPod := func(name string, nodeName string, labels map[string]string) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: "default",
+				Labels:    labels,
+			},
+			Spec: corev1.PodSpec{
+				NodeName: nodeName,
+			},
+		}
+	}
+	diagnosis := &diagnosisv1.Diagnosis{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "diagnosis1",
+			Namespace: "default",
+		},
+		Spec: diagnosisv1.DiagnosisSpec{
+			OperationSet: "operationset1",
+			TargetSelector: &diagnosisv1.TargetSelector{
+				PodSelector: &metav1.LabelSelector{
+					MatchLabels: map[string]string{"app": "app1"},
+				},
+			},
+		},
+		Status: diagnosisv1.DiagnosisStatus{
+			Phase: diagnosisv1.DiagnosisPending,
+		},
+	}
+
+	cli := fake.NewFakeClientWithScheme(scheme,
+		newPod("pod1", "node1", map[string]string{"app": "app1"}),
+		newPod("pod2", "node2", map[string]string{"app": "app1"}),
+		newPod("pod3", "node3", map[string]string{"app": "app2"}),
+		diagnosis,
+	)
+	reconciler := &DiagnosisReconciler{
+		Client:        cli,
+		Log:           ctrl.Log.WithName("controllers").WithName("Diagnosis"),
+		Scheme:        scheme,
+		eventRecorder: record.NewFakeRecorder(100),
+	}
+	req := ctrl.Request{
+		NamespacedName: types.NamespacedName{
+			Name:      "diagnosis1",
+			Namespace: "default",
+		},
+	}
+
+	_, err := reconciler.Reconcile(req)
+	assert.NoError(t, err)
+
+	var updated diagnosisv1.Diagnosis
+	err = cli.Get(context.Background(), req.NamespacedName, &updated)
+	assert.NoError(t, err)
+	assert.Equal(t, diagnosisv1.DiagnosisRunning, updated.Status.Phase)
+	assert.ElementsMatch(t, []string{"node1", "node2"}, updated.Status.NodeNames)
+}
+
+func TestReconcileCompletedDiagnosisWithCompletionWebhook(t *testing.T) {
+	tests := []struct {
+		phase diagnosisv1.DiagnosisPhase
+		name  string
+	}{
+		{
+			phase: diagnosisv1.DiagnosisSucceeded,
+			name:  "succeeded diagnosis",
+		},
+		{
+			phase: diagnosisv1.DiagnosisFailed,
+			name:  "failed diagnosis",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			scheme := newTestScheme()
+			completions := make([]diagnosisCompletion, 0)
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				var completion diagnosisCompletion
+				err := json.NewDecoder(r.Body).Decode(&completion)
+				assert.NoError(t, err)
+				completions = append(completions, completion)
+				w.WriteHeader(http.StatusOK)
+			}))
+			defer server.Close()
+
+			webhook := server.URL
+			diagnosis := &diagnosisv1.Diagnosis{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "diagnosis1",
+					Namespace: "default",
+					UID:       types.UID("0123456789"),
+				},
+				Spec: diagnosisv1.DiagnosisSpec{
+					OperationSet:      "operationset1",
+					CompletionWebhook: &webhook,
+				},
+				Status: diagnosisv1.DiagnosisStatus{
+					Phase: test.phase,
+				},
+			}
+
+			cli := fake.NewFakeClientWithScheme(scheme, diagnosis)
+			reconciler := &DiagnosisReconciler{
+				Client:        cli,
+				Log:           ctrl.Log.WithName("controllers").WithName("Diagnosis"),
+				Scheme:        scheme,
+				eventRecorder: record.NewFakeRecorder(100),
+				transport:     utilnet.SetTransportDefaults(&http.Transport{}),
+			}
+			req := ctrl.Request{
+				NamespacedName: types.NamespacedName{
+					Name:      "diagnosis1",
+					Namespace: "default",
+				},
+			}
+
+			_, err := reconciler.Reconcile(req)
+			assert.NoError(t, err)
+			assert.Equal(t, 1, len(completions))
+			assert.Equal(t, "diagnosis1", completions[0].Name)
+			assert.Equal(t, test.phase, completions[0].Phase)
+
+			var updated diagnosisv1.Diagnosis
+			err = cli.Get(context.Background(), req.NamespacedName, &updated)
+			assert.NoError(t, err)
+			assert.Contains(t, updated.Annotations, CompletionWebhookNotifiedAnnotation)
+
+			// The notification should not be sent again on subsequent reconcile passes.
+			_, err = reconciler.Reconcile(req)
+			assert.NoError(t, err)
+			assert.Equal(t, 1, len(completions))
+		})
+	}
+}
+
+func TestReconcileCompletedDiagnosisWithFailingCompletionWebhook(t *testing.T) {
+	oldBackoff := completionWebhookBackoff
+	completionWebhookBackoff = wait.Backoff{
+		Steps:    2,
+		Duration: time.Millisecond,
+		Factor:   1.0,
+	}
+	defer func() { completionWebhookBackoff = oldBackoff }()
+
+	scheme := newTestScheme()
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		http.Error(w, "internal error", http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	webhook := server.URL
+	diagnosis := &diagnosisv1.Diagnosis{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "diagnosis1",
+			Namespace: "default",
+		},
+		Spec: diagnosisv1.DiagnosisSpec{
+			OperationSet:      "operationset1",
+			CompletionWebhook: &webhook,
+		},
+		Status: diagnosisv1.DiagnosisStatus{
+			Phase: diagnosisv1.DiagnosisFailed,
+		},
+	}
+
+	cli := fake.NewFakeClientWithScheme(scheme, diagnosis)
+	reconciler := &DiagnosisReconciler{
+		Client:        cli,
+		Log:           ctrl.Log.WithName("controllers").WithName("Diagnosis"),
+		Scheme:        scheme,
+		eventRecorder: record.NewFakeRecorder(100),
+		transport:     utilnet.SetTransportDefaults(&http.Transport{}),
+	}
+	req := ctrl.Request{
+		NamespacedName: types.NamespacedName{
+			Name:      "diagnosis1",
+			Namespace: "default",
+		},
+	}
+
+	// The failure is recorded on the diagnosis after the retries are exhausted.
+	_, err := reconciler.Reconcile(req)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, requests)
+	var updated diagnosisv1.Diagnosis
+	err = cli.Get(context.Background(), req.NamespacedName, &updated)
+	assert.NoError(t, err)
+	assert.Equal(t, CompletionWebhookFailedAnnotationValue, updated.Annotations[CompletionWebhookNotifiedAnnotation])
+
+	// The retry loop should not be repeated on subsequent reconcile passes.
+	_, err = reconciler.Reconcile(req)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, requests)
+}
+
+func TestReconcileCompletedDiagnosisRecordsPodEvents(t *testing.T) {
+	scheme := newTestScheme()
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "pod1",
+			Namespace: "default",
+		},
+		Spec: corev1.PodSpec{
+			NodeName: "node1",
+		},
+	}
+	diagnosis := &diagnosisv1.Diagnosis{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "diagnosis1",
+			Namespace: "default",
+		},
+		Spec: diagnosisv1.DiagnosisSpec{
+			OperationSet:    "operationset1",
+			RecordPodEvents: true,
+			TargetSelector: &diagnosisv1.TargetSelector{
+				PodReferences: []diagnosisv1.PodReference{
+					{
+						NamespacedName: diagnosisv1.NamespacedName{
+							Name:      "pod1",
+							Namespace: "default",
+						},
+					},
+				},
+			},
+		},
+		Status: diagnosisv1.DiagnosisStatus{
+			Phase: diagnosisv1.DiagnosisSucceeded,
+		},
+	}
+
+	cli := fake.NewFakeClientWithScheme(scheme, pod, diagnosis)
+	eventRecorder := record.NewFakeRecorder(100)
+	reconciler := &DiagnosisReconciler{
+		Client:        cli,
+		Log:           ctrl.Log.WithName("controllers").WithName("Diagnosis"),
+		Scheme:        scheme,
+		eventRecorder: eventRecorder,
+	}
+	req := ctrl.Request{
+		NamespacedName: types.NamespacedName{
+			Name:      "diagnosis1",
+			Namespace: "default",
+		},
+	}
+
+	_, err := reconciler.Reconcile(req)
+	assert.NoError(t, err)
+	event := <-eventRecorder.Events
+	assert.Contains(t, event, "DiagnosisSucceeded")
+	assert.Contains(t, event, "diagnosis1")
+	assert.Contains(t, event, "pod1")
+
+	var updated diagnosisv1.Diagnosis
+	err = cli.Get(context.Background(), req.NamespacedName, &updated)
+	assert.NoError(t, err)
+	assert.Contains(t, updated.Annotations, PodEventsRecordedAnnotation)
+
+	// The events should not be recorded again on subsequent reconcile passes.
+	_, err = reconciler.Reconcile(req)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(eventRecorder.Events))
+}
+
+func TestDiagnosisLabelSelectorPredicate(t *testing.T) {
+	selector, err := labels.Parse("team=platform")
+	assert.NoError(t, err)
+	predicate := newLabelSelectorPredicate(selector)
+
+	newDiagnosis := func(labels map[string]string) *diagnosisv1.Diagnosis {
+		return &diagnosisv1.Diagnosis{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "diagnosis1",
+				Namespace: "default",
+				Labels:    labels,
+			},
+		}
+	}
+
+	tests := []struct {
+		labels   map[string]string
+		expected bool
+		name     string
+	}{
+		{
+			labels:   map[string]string{"team": "platform"},
+			expected: true,
+			name:     "matching label",
+		},
+		{
+			labels:   map[string]string{"team": "app"},
+			expected: false,
+			name:     "non matching label",
+		},
+		{
+			labels:   nil,
+			expected: false,
+			name:     "no labels",
+		},
+	}
+
+	for _, test := range tests {
+		diagnosis := newDiagnosis(test.labels)
+		assert.Equal(t, test.expected, predicate.Create(event.CreateEvent{
+			Meta:   diagnosis,
+			Object: diagnosis,
+		}), test.name)
+		assert.Equal(t, test.expected, predicate.Update(event.UpdateEvent{
+			MetaNew:   diagnosis,
+			ObjectNew: diagnosis,
+		}), test.name)
+	}
+
+	// Task events are always accepted since the owning diagnoses have been filtered on creation.
+	task := &diagnosisv1.Task{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "task1",
+			Namespace: "default",
+		},
+	}
+	assert.True(t, predicate.Create(event.CreateEvent{
+		Meta:   task,
+		Object: task,
+	}))
+	assert.True(t, predicate.Update(event.UpdateEvent{
+		MetaNew:   task,
+		ObjectNew: task,
+	}))
+}
+
+func TestTaskToDiagnosisRequests(t *testing.T) {
+	tests := []struct {
+		labels   map[string]string
+		expected []reconcile.Request
+		name     string
+	}{
+		{
+			labels: map[string]string{
+				"diagnosis-namespace": "default",
+				"diagnosis-name":      "diagnosis1",
+			},
+			expected: []reconcile.Request{
+				{
+					NamespacedName: types.NamespacedName{
+						Name:      "diagnosis1",
+						Namespace: "default",
+					},
+				},
+			},
+			name: "task labeled with owning diagnosis",
+		},
+		{
+			labels: map[string]string{
+				"diagnosis-name": "diagnosis1",
+			},
+			expected: nil,
+			name:     "diagnosis namespace label not found",
+		},
+		{
+			labels:   nil,
+			expected: nil,
+			name:     "no labels",
+		},
+	}
+
+	for _, test := range tests {
+		task := &diagnosisv1.Task{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "task1",
+				Namespace: "default",
+				Labels:    test.labels,
+			},
+			Status: diagnosisv1.TaskStatus{
+				Phase: diagnosisv1.TaskSucceeded,
+			},
+		}
+		requests := taskToDiagnosisRequests(handler.MapObject{
+			Meta:   task,
+			Object: task,
+		})
+		assert.Equal(t, test.expected, requests, test.name)
+	}
+}
+
+func TestReconcileRunningDiagnosisPathTimeout(t *testing.T) {
+	scheme := newTestScheme()
+	adjacencyList := []diagnosisv1.Node{
+		{
+			ID: 0,
+			To: diagnosisv1.NodeSet{1, 2},
+		},
+		{
+			ID:        1,
+			Operation: "operation1",
+		},
+		{
+			ID:        2,
+			Operation: "operation2",
+		},
+	}
+	hash := util.ComputeHash(adjacencyList)
+	pathTimeoutSeconds := int64(60)
+
+	operationset := &diagnosisv1.OperationSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "operationset1",
+			Labels: map[string]string{
+				util.OperationSetUniqueLabelKey: hash,
+			},
+		},
+		Spec: diagnosisv1.OperationSetSpec{
+			AdjacencyList: adjacencyList,
+		},
+		Status: diagnosisv1.OperationSetStatus{
+			Ready: true,
+			Paths: []diagnosisv1.Path{
+				{
+					{
+						ID:        1,
+						Operation: "operation1",
+					},
+				},
+				{
+					{
+						ID:        2,
+						Operation: "operation2",
+					},
+				},
+			},
+		},
+	}
+	diagnosis := &diagnosisv1.Diagnosis{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "diagnosis1",
+			Namespace: "default",
+			UID:       types.UID("0123456789"),
+			Labels: map[string]string{
+				util.OperationSetUniqueLabelKey: hash,
+			},
+		},
+		Spec: diagnosisv1.DiagnosisSpec{
+			OperationSet:       "operationset1",
+			PathTimeoutSeconds: &pathTimeoutSeconds,
+			TargetSelector: &diagnosisv1.TargetSelector{
+				NodeNames: []string{"node1"},
+			},
+		},
+		Status: diagnosisv1.DiagnosisStatus{
+			Phase:     diagnosisv1.DiagnosisRunning,
+			NodeNames: []string{"node1"},
+			Checkpoint: &diagnosisv1.Checkpoint{
+				PathIndex:         0,
+				NodeIndex:         0,
+				Desired:           1,
+				SynchronizedTasks: []string{},
+				PathStartTime:     metav1.NewTime(time.Now().Add(-2 * time.Minute)),
+			},
+		},
+	}
+
+	cli := fake.NewFakeClientWithScheme(scheme, operationset, diagnosis)
+	reconciler := &DiagnosisReconciler{
+		Client:        cli,
+		Log:           ctrl.Log.WithName("controllers").WithName("Diagnosis"),
+		Scheme:        scheme,
+		eventRecorder: record.NewFakeRecorder(100),
+	}
+	req := ctrl.Request{
+		NamespacedName: types.NamespacedName{
+			Name:      "diagnosis1",
+			Namespace: "default",
+		},
+	}
+
+	// The first path exceeds its timeout, so it is abandoned as failed and the checkpoint
+	// advances to the next path instead of failing the whole diagnosis.
+	_, err := reconciler.Reconcile(req)
+	assert.NoError(t, err)
+
+	var updated diagnosisv1.Diagnosis
+	err = cli.Get(context.Background(), req.NamespacedName, &updated)
+	assert.NoError(t, err)
+	assert.Equal(t, diagnosisv1.DiagnosisRunning, updated.Status.Phase)
+	assert.Equal(t, 1, updated.Status.Checkpoint.PathIndex)
+	assert.Equal(t, 0, updated.Status.Checkpoint.NodeIndex)
+	assert.Equal(t, 0, updated.Status.Checkpoint.Desired)
+	assert.Equal(t, 1, len(updated.Status.FailedPaths))
+	// The path start time is reset for the new path.
+	assert.False(t, updated.Status.Checkpoint.PathStartTime.IsZero())
+	assert.False(t, time.Since(updated.Status.Checkpoint.PathStartTime.Time) > time.Minute)
+
+	// Set the desired count and create the task for the new path, then wait on the active task.
+	// The waiting reconcile pass requeues before the path deadline so a hung path is abandoned
+	// even if no task event triggers another pass.
+	_, err = reconciler.Reconcile(req)
+	assert.NoError(t, err)
+	_, err = reconciler.Reconcile(req)
+	assert.NoError(t, err)
+	result, err := reconciler.Reconcile(req)
+	assert.NoError(t, err)
+	assert.True(t, result.RequeueAfter > 0)
+
+	err = cli.Get(context.Background(), req.NamespacedName, &updated)
+	assert.NoError(t, err)
+
+	// The diagnosis fails once the last path exceeds its timeout.
+	updated.Status.Checkpoint.PathStartTime = metav1.NewTime(time.Now().Add(-2 * time.Minute))
+	err = cli.Status().Update(context.Background(), &updated)
+	assert.NoError(t, err)
+
+	_, err = reconciler.Reconcile(req)
+	assert.NoError(t, err)
+	err = cli.Get(context.Background(), req.NamespacedName, &updated)
+	assert.NoError(t, err)
+	assert.Equal(t, diagnosisv1.DiagnosisFailed, updated.Status.Phase)
+	assert.Equal(t, 2, len(updated.Status.FailedPaths))
+}
+
+func TestReconcileRunningDiagnosisMasterRunLocation(t *testing.T) {
+	scheme := newTestScheme()
+	adjacencyList := []diagnosisv1.Node{
+		{
+			ID: 0,
+			To: diagnosisv1.NodeSet{1},
+		},
+		{
+			ID:        1,
+			Operation: "operation1",
+		},
+	}
+	hash := util.ComputeHash(adjacencyList)
+
+	operation := &diagnosisv1.Operation{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "operation1",
+		},
+		Spec: diagnosisv1.OperationSpec{
+			Processor: diagnosisv1.Processor{
+				RunLocation: diagnosisv1.MasterRunLocation,
+			},
+		},
+	}
+	operationset := &diagnosisv1.OperationSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "operationset1",
+			Labels: map[string]string{
+				util.OperationSetUniqueLabelKey: hash,
+			},
+		},
+		Spec: diagnosisv1.OperationSetSpec{
+			AdjacencyList: adjacencyList,
+		},
+		Status: diagnosisv1.OperationSetStatus{
+			Ready: true,
+			Paths: []diagnosisv1.Path{
+				{
+					{
+						ID:        1,
+						Operation: "operation1",
+					},
+				},
+			},
+		},
+	}
+	diagnosis := &diagnosisv1.Diagnosis{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "diagnosis1",
+			Namespace: "default",
+			UID:       types.UID("0123456789"),
+			Labels: map[string]string{
+				util.OperationSetUniqueLabelKey: hash,
+			},
+		},
+		Spec: diagnosisv1.DiagnosisSpec{
+			OperationSet: "operationset1",
+			TargetSelector: &diagnosisv1.TargetSelector{
+				NodeNames: []string{"node1", "node2", "node3"},
+			},
+		},
+		Status: diagnosisv1.DiagnosisStatus{
+			Phase:     diagnosisv1.DiagnosisRunning,
+			NodeNames: []string{"node1", "node2", "node3"},
+			Checkpoint: &diagnosisv1.Checkpoint{
+				PathIndex:         0,
+				NodeIndex:         0,
+				SynchronizedTasks: []string{},
+			},
+		},
+	}
+
+	cli := fake.NewFakeClientWithScheme(scheme, operation, operationset, diagnosis)
+	reconciler := &DiagnosisReconciler{
+		Client:        cli,
+		Log:           ctrl.Log.WithName("controllers").WithName("Diagnosis"),
+		Scheme:        scheme,
+		eventRecorder: record.NewFakeRecorder(100),
+		nodeName:      "master1",
+	}
+	req := ctrl.Request{
+		NamespacedName: types.NamespacedName{
+			Name:      "diagnosis1",
+			Namespace: "default",
+		},
+	}
+
+	// The desired count of a master run location operation is one regardless of the number of
+	// target nodes.
+	_, err := reconciler.Reconcile(req)
+	assert.NoError(t, err)
+
+	var updated diagnosisv1.Diagnosis
+	err = cli.Get(context.Background(), req.NamespacedName, &updated)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, updated.Status.Checkpoint.Desired)
+
+	// A single task is created on the node of the controller instead of one per target node.
+	_, err = reconciler.Reconcile(req)
+	assert.NoError(t, err)
+
+	var taskList diagnosisv1.TaskList
+	err = cli.List(context.Background(), &taskList)
+	assert.NoError(t, err)
+	if assert.Equal(t, 1, len(taskList.Items)) {
+		assert.Equal(t, "master1", taskList.Items[0].Spec.NodeName)
+	}
+}
+
+func TestReconcileRunningDiagnosisAlwaysRunNode(t *testing.T) {
+	scheme := newTestScheme()
+	adjacencyList := []diagnosisv1.Node{
+		{
+			ID: 0,
+			To: diagnosisv1.NodeSet{1},
+		},
+		{
+			ID:        1,
+			Operation: "operation1",
+			To:        diagnosisv1.NodeSet{2},
+		},
+		{
+			ID:        2,
+			Operation: "operation2",
+			To:        diagnosisv1.NodeSet{3},
+		},
+		{
+			ID:        3,
+			Operation: "cleanup",
+			AlwaysRun: true,
+		},
+	}
+	hash := util.ComputeHash(adjacencyList)
+
+	operationset := &diagnosisv1.OperationSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "operationset1",
+			Labels: map[string]string{
+				util.OperationSetUniqueLabelKey: hash,
+			},
+		},
+		Spec: diagnosisv1.OperationSetSpec{
+			AdjacencyList: adjacencyList,
+		},
+		Status: diagnosisv1.OperationSetStatus{
+			Ready: true,
+			Paths: []diagnosisv1.Path{
+				{
+					{
+						ID:        1,
+						Operation: "operation1",
+					},
+					{
+						ID:        2,
+						Operation: "operation2",
+					},
+					{
+						ID:        3,
+						Operation: "cleanup",
+						AlwaysRun: true,
+					},
+				},
+			},
+		},
+	}
+	diagnosis := &diagnosisv1.Diagnosis{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "diagnosis1",
+			Namespace: "default",
+			UID:       types.UID("0123456789"),
+			Labels: map[string]string{
+				util.OperationSetUniqueLabelKey: hash,
+			},
+		},
+		Spec: diagnosisv1.DiagnosisSpec{
+			OperationSet: "operationset1",
+			TargetSelector: &diagnosisv1.TargetSelector{
+				NodeNames: []string{"node1"},
+			},
+		},
+		Status: diagnosisv1.DiagnosisStatus{
+			Phase:     diagnosisv1.DiagnosisRunning,
+			NodeNames: []string{"node1"},
+			Checkpoint: &diagnosisv1.Checkpoint{
+				PathIndex:         0,
+				NodeIndex:         0,
+				Desired:           1,
+				SynchronizedTasks: []string{},
+			},
+		},
+	}
+	failedTask := &diagnosisv1.Task{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "diagnosis1.01234567.node1.0.0.operation1",
+			Namespace: "default",
+			Labels: map[string]string{
+				"diagnosis-namespace": "default",
+				"diagnosis-name":      "diagnosis1",
+			},
+		},
+		Spec: diagnosisv1.TaskSpec{
+			Operation: "operation1",
+			NodeName:  "node1",
+		},
+		Status: diagnosisv1.TaskStatus{
+			Phase:     diagnosisv1.TaskFailed,
+			StartTime: metav1.Now(),
+		},
+	}
+
+	cli := fake.NewFakeClientWithScheme(scheme, operationset, diagnosis, failedTask)
+	reconciler := &DiagnosisReconciler{
+		Client:        cli,
+		Log:           ctrl.Log.WithName("controllers").WithName("Diagnosis"),
+		Scheme:        scheme,
+		eventRecorder: record.NewFakeRecorder(100),
+	}
+	req := ctrl.Request{
+		NamespacedName: types.NamespacedName{
+			Name:      "diagnosis1",
+			Namespace: "default",
+		},
+	}
+
+	// The first operation failed, so the checkpoint should skip operation2 and jump to the
+	// always run cleanup node instead of abandoning the path immediately.
+	_, err := reconciler.Reconcile(req)
+	assert.NoError(t, err)
+
+	var updated diagnosisv1.Diagnosis
+	err = cli.Get(context.Background(), req.NamespacedName, &updated)
+	assert.NoError(t, err)
+	assert.Equal(t, diagnosisv1.DiagnosisRunning, updated.Status.Phase)
+	assert.Equal(t, 2, updated.Status.Checkpoint.NodeIndex)
+	assert.True(t, updated.Status.Checkpoint.PathFailed)
+	assert.Equal(t, 1, len(updated.Status.FailedPaths))
+
+	// Set the desired count and create the cleanup task.
+	_, err = reconciler.Reconcile(req)
+	assert.NoError(t, err)
+	_, err = reconciler.Reconcile(req)
+	assert.NoError(t, err)
+
+	var cleanupTask diagnosisv1.Task
+	err = cli.Get(context.Background(), types.NamespacedName{
+		Name:      "diagnosis1.01234567.node1.0.2.cleanup",
+		Namespace: "default",
+	}, &cleanupTask)
+	assert.NoError(t, err)
+
+	// No task should have been created for the skipped operation2.
+	var taskList diagnosisv1.TaskList
+	err = cli.List(context.Background(), &taskList)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(taskList.Items))
+
+	// Once the cleanup task succeeds, the diagnosis fails since the only path has failed.
+	cleanupTask.Status.Phase = diagnosisv1.TaskSucceeded
+	err = cli.Status().Update(context.Background(), &cleanupTask)
+	assert.NoError(t, err)
+
+	_, err = reconciler.Reconcile(req)
+	assert.NoError(t, err)
+	err = cli.Get(context.Background(), req.NamespacedName, &updated)
+	assert.NoError(t, err)
+	assert.Equal(t, diagnosisv1.DiagnosisFailed, updated.Status.Phase)
+	assert.Equal(t, 1, len(updated.Status.FailedPaths))
+	assert.Nil(t, updated.Status.SucceededPath)
+
+	// Both the failed operation and the cleanup operation are recorded in the execution trace.
+	if assert.Equal(t, 2, len(updated.Status.ExecutedOperations)) {
+		assert.Equal(t, "operation1", updated.Status.ExecutedOperations[0].Name)
+		assert.Equal(t, diagnosisv1.DiagnosisFailed, updated.Status.ExecutedOperations[0].Phase)
+		assert.Equal(t, "cleanup", updated.Status.ExecutedOperations[1].Name)
+		assert.Equal(t, diagnosisv1.DiagnosisSucceeded, updated.Status.ExecutedOperations[1].Phase)
+	}
+}
+
+func TestNextAlwaysRunNodeIndex(t *testing.T) {
+	path := diagnosisv1.Path{
+		{
+			ID:        1,
+			Operation: "operation1",
+		},
+		{
+			ID:        2,
+			Operation: "operation2",
+		},
+		{
+			ID:        3,
+			Operation: "cleanup",
+			AlwaysRun: true,
+		},
+	}
+
+	assert.Equal(t, 2, nextAlwaysRunNodeIndex(path, 0))
+	assert.Equal(t, 2, nextAlwaysRunNodeIndex(path, 1))
+	assert.Equal(t, -1, nextAlwaysRunNodeIndex(path, 2))
+	assert.Equal(t, -1, nextAlwaysRunNodeIndex(diagnosisv1.Path{}, 0))
+}
+
+func TestReconcileCompletedDiagnosisMirrorsResultsConfigMap(t *testing.T) {
+	scheme := newTestScheme()
+	diagnosis := &diagnosisv1.Diagnosis{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "diagnosis1",
+			Namespace: "default",
+			UID:       types.UID("0123456789"),
+		},
+		Spec: diagnosisv1.DiagnosisSpec{
+			OperationSet:             "operationset1",
+			MirrorResultsToConfigMap: true,
+		},
+		Status: diagnosisv1.DiagnosisStatus{
+			Phase:     diagnosisv1.DiagnosisSucceeded,
+			StartTime: metav1.Now(),
+			SucceededPath: diagnosisv1.Path{
+				{
+					ID:        1,
+					Operation: "operation1",
+				},
+			},
+			ExecutedOperations: []diagnosisv1.ExecutedOperation{
+				{
+					Name:  "operation1",
+					Node:  1,
+					Phase: diagnosisv1.DiagnosisSucceeded,
+				},
+			},
+			Context: &diagnosisv1.DiagnosisContext{
+				Operations: map[string]diagnosisv1.OperationContext{
+					"operation1": {
+						"task1": {
+							"key1": "value1",
+						},
+					},
+				},
+			},
+		},
+	}
+
+	cli := fake.NewFakeClientWithScheme(scheme, diagnosis)
+	reconciler := &DiagnosisReconciler{
+		Client:        cli,
+		Log:           ctrl.Log.WithName("controllers").WithName("Diagnosis"),
+		Scheme:        scheme,
+		eventRecorder: record.NewFakeRecorder(100),
+	}
+	req := ctrl.Request{
+		NamespacedName: types.NamespacedName{
+			Name:      "diagnosis1",
+			Namespace: "default",
+		},
+	}
+
+	_, err := reconciler.Reconcile(req)
+	assert.NoError(t, err)
+
+	var configMap corev1.ConfigMap
+	err = cli.Get(context.Background(), types.NamespacedName{
+		Name:      "diagnosis1" + ResultsConfigMapNameSuffix,
+		Namespace: "default",
+	}, &configMap)
+	assert.NoError(t, err)
+	assert.Equal(t, "Succeeded", configMap.Data["phase"])
+	assert.Contains(t, configMap.Data, "startTime")
+	assert.Contains(t, configMap.Data["succeededPath"], "operation1")
+	assert.Contains(t, configMap.Data["executedOperations"], "operation1")
+	assert.Contains(t, configMap.Data["context"], "value1")
+	assert.NotContains(t, configMap.Data, "failedPaths")
+	if assert.Equal(t, 1, len(configMap.OwnerReferences)) {
+		assert.Equal(t, "diagnosis1", configMap.OwnerReferences[0].Name)
+		assert.Equal(t, types.UID("0123456789"), configMap.OwnerReferences[0].UID)
+	}
+
+	// The config map is updated in place on subsequent reconcile passes.
+	_, err = reconciler.Reconcile(req)
+	assert.NoError(t, err)
+
+	var configMapList corev1.ConfigMapList
+	err = cli.List(context.Background(), &configMapList)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(configMapList.Items))
+}
+
+func TestReconcileCompletedDiagnosisRecordsDuration(t *testing.T) {
+	tests := []struct {
+		phase diagnosisv1.DiagnosisPhase
+		label string
+		name  string
+	}{
+		{
+			phase: diagnosisv1.DiagnosisSucceeded,
+			label: "succeeded",
+			name:  "succeeded diagnosis",
+		},
+		{
+			phase: diagnosisv1.DiagnosisFailed,
+			label: "failed",
+			name:  "failed diagnosis",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			scheme := newTestScheme()
+			diagnosis := &diagnosisv1.Diagnosis{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "diagnosis1",
+					Namespace: "default",
+					UID:       types.UID("0123456789"),
+				},
+				Spec: diagnosisv1.DiagnosisSpec{
+					OperationSet: "operationset1",
+				},
+				Status: diagnosisv1.DiagnosisStatus{
+					Phase:     test.phase,
+					StartTime: metav1.NewTime(time.Now().Add(-30 * time.Second)),
+				},
+			}
+
+			cli := fake.NewFakeClientWithScheme(scheme, diagnosis)
+			reconciler := &DiagnosisReconciler{
+				Client:        cli,
+				Log:           ctrl.Log.WithName("controllers").WithName("Diagnosis"),
+				Scheme:        scheme,
+				eventRecorder: record.NewFakeRecorder(100),
+			}
+			req := ctrl.Request{
+				NamespacedName: types.NamespacedName{
+					Name:      "diagnosis1",
+					Namespace: "default",
+				},
+			}
+
+			sampleCount := func() uint64 {
+				metric := &dto.Metric{}
+				err := diagnosisDurationSeconds.WithLabelValues(test.label).(prometheus.Histogram).Write(metric)
+				assert.NoError(t, err)
+				return metric.Histogram.GetSampleCount()
+			}
+			before := sampleCount()
+
+			// The end time is recorded and a duration sample is observed on reaching the terminal phase.
+			_, err := reconciler.Reconcile(req)
+			assert.NoError(t, err)
+			assert.Equal(t, before+1, sampleCount())
+
+			var updated diagnosisv1.Diagnosis
+			err = cli.Get(context.Background(), req.NamespacedName, &updated)
+			assert.NoError(t, err)
+			assert.False(t, updated.Status.EndTime.IsZero())
+			assert.False(t, updated.Status.EndTime.Time.Before(updated.Status.StartTime.Time))
+
+			// The duration should not be observed again on subsequent reconcile passes.
+			_, err = reconciler.Reconcile(req)
+			assert.NoError(t, err)
+			assert.Equal(t, before+1, sampleCount())
+		})
+	}
+}
+
+func TestReconcileAcceptedDiagnosisResetsEndTime(t *testing.T) {
+	scheme := newTestScheme()
+	// A diagnosis of a previous run with an empty phase is accepted anew, so the end time of
+	// the previous run is reset.
+	diagnosis := &diagnosisv1.Diagnosis{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "diagnosis1",
+			Namespace: "default",
+		},
+		Spec: diagnosisv1.DiagnosisSpec{
+			OperationSet: "operationset1",
+			TargetSelector: &diagnosisv1.TargetSelector{
+				NodeNames: []string{"node1"},
+			},
+		},
+		Status: diagnosisv1.DiagnosisStatus{
+			StartTime: metav1.NewTime(time.Now().Add(-time.Hour)),
+			EndTime:   metav1.NewTime(time.Now().Add(-30 * time.Minute)),
+		},
+	}
+
+	cli := fake.NewFakeClientWithScheme(scheme, diagnosis)
+	reconciler := &DiagnosisReconciler{
+		Client:        cli,
+		Log:           ctrl.Log.WithName("controllers").WithName("Diagnosis"),
+		Scheme:        scheme,
+		eventRecorder: record.NewFakeRecorder(100),
+	}
+	req := ctrl.Request{
+		NamespacedName: types.NamespacedName{
+			Name:      "diagnosis1",
+			Namespace: "default",
+		},
+	}
+
+	_, err := reconciler.Reconcile(req)
+	assert.NoError(t, err)
+
+	var updated diagnosisv1.Diagnosis
+	err = cli.Get(context.Background(), req.NamespacedName, &updated)
+	assert.NoError(t, err)
+	assert.Equal(t, diagnosisv1.DiagnosisPending, updated.Status.Phase)
+	assert.True(t, updated.Status.EndTime.IsZero())
+	assert.False(t, time.Since(updated.Status.StartTime.Time) > time.Minute)
+}
+
+func TestParameterAnnotations(t *testing.T) {
+	diagnosis := &diagnosisv1.Diagnosis{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "diagnosis1",
+			Namespace: "default",
+			Annotations: map[string]string{
+				ParameterAnnotationPrefix + "foo":  "bar",
+				ParameterAnnotationPrefix + "key1": "value1",
+				"diagnosis.kubediag.org/other":     "ignored",
+			},
+		},
+	}
+
+	assert.Equal(t, map[string]string{
+		"foo":  "bar",
+		"key1": "value1",
+	}, parameterAnnotations(diagnosis))
+
+	// A diagnosis without annotations yields no parameters.
+	assert.Equal(t, map[string]string{}, parameterAnnotations(&diagnosisv1.Diagnosis{}))
+}
+
+func TestReconcileRunningDiagnosisWithParameterAnnotations(t *testing.T) {
+	scheme := newTestScheme()
+	adjacencyList := []diagnosisv1.Node{
+		{
+			ID: 0,
+			To: diagnosisv1.NodeSet{1},
+		},
+		{
+			ID:        1,
+			Operation: "operation1",
+		},
+	}
+	hash := util.ComputeHash(adjacencyList)
+
+	operationset := &diagnosisv1.OperationSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "operationset1",
+			Labels: map[string]string{
+				util.OperationSetUniqueLabelKey: hash,
+			},
+		},
+		Spec: diagnosisv1.OperationSetSpec{
+			AdjacencyList: adjacencyList,
+		},
+		Status: diagnosisv1.OperationSetStatus{
+			Ready: true,
+			Paths: []diagnosisv1.Path{
+				{
+					{
+						ID:        1,
+						Operation: "operation1",
+					},
+				},
+			},
+		},
+	}
+	diagnosis := &diagnosisv1.Diagnosis{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "diagnosis1",
+			Namespace: "default",
+			UID:       types.UID("0123456789"),
+			Labels: map[string]string{
+				util.OperationSetUniqueLabelKey: hash,
+			},
+			Annotations: map[string]string{
+				ParameterAnnotationPrefix + "foo":  "bar",
+				ParameterAnnotationPrefix + "key1": "annotation",
+			},
+		},
+		Spec: diagnosisv1.DiagnosisSpec{
+			OperationSet: "operationset1",
+			Parameters: map[string]string{
+				"key1": "diagnosis",
+			},
+			TargetSelector: &diagnosisv1.TargetSelector{
+				NodeNames: []string{"node1"},
+			},
+		},
+		Status: diagnosisv1.DiagnosisStatus{
+			Phase:     diagnosisv1.DiagnosisRunning,
+			NodeNames: []string{"node1"},
+			Checkpoint: &diagnosisv1.Checkpoint{
+				PathIndex:         0,
+				NodeIndex:         0,
+				Desired:           1,
+				SynchronizedTasks: []string{},
+			},
+		},
+	}
+
+	cli := fake.NewFakeClientWithScheme(scheme, operationset, diagnosis)
+	reconciler := &DiagnosisReconciler{
+		Client:        cli,
+		Log:           ctrl.Log.WithName("controllers").WithName("Diagnosis"),
+		Scheme:        scheme,
+		eventRecorder: record.NewFakeRecorder(100),
+	}
+	req := ctrl.Request{
+		NamespacedName: types.NamespacedName{
+			Name:      "diagnosis1",
+			Namespace: "default",
+		},
+	}
+
+	_, err := reconciler.Reconcile(req)
+	assert.NoError(t, err)
+
+	// Annotation derived parameters surface in the task parameter map while explicit diagnosis
+	// parameters take precedence over them.
+	var taskList diagnosisv1.TaskList
+	err = cli.List(context.Background(), &taskList)
+	assert.NoError(t, err)
+	if assert.Equal(t, 1, len(taskList.Items)) {
+		assert.Equal(t, map[string]string{
+			"foo":  "bar",
+			"key1": "diagnosis",
+		}, taskList.Items[0].Spec.Parameters)
+	}
+}
+
+func TestReconcileRunningDiagnosisDAGExecutionMode(t *testing.T) {
+	scheme := newTestScheme()
+	// Diamond graph: operationA fans out to operationB and operationC which join on operationD.
+	adjacencyList := []diagnosisv1.Node{
+		{
+			ID: 0,
+			To: diagnosisv1.NodeSet{1},
+		},
+		{
+			ID:        1,
+			Operation: "operationA",
+			To:        diagnosisv1.NodeSet{2, 3},
+		},
+		{
+			ID:        2,
+			Operation: "operationB",
+			To:        diagnosisv1.NodeSet{4},
+		},
+		{
+			ID:        3,
+			Operation: "operationC",
+			To:        diagnosisv1.NodeSet{4},
+		},
+		{
+			ID:        4,
+			Operation: "operationD",
+		},
+	}
+	hash := util.ComputeHash(adjacencyList)
+
+	operationset := &diagnosisv1.OperationSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "operationset1",
+			Labels: map[string]string{
+				util.OperationSetUniqueLabelKey: hash,
+			},
+		},
+		Spec: diagnosisv1.OperationSetSpec{
+			AdjacencyList: adjacencyList,
+			ExecutionMode: diagnosisv1.DAGExecutionMode,
+		},
+		Status: diagnosisv1.OperationSetStatus{
+			Ready: true,
+		},
+	}
+	diagnosis := &diagnosisv1.Diagnosis{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "diagnosis1",
+			Namespace: "default",
+			UID:       types.UID("0123456789"),
+			Labels: map[string]string{
+				util.OperationSetUniqueLabelKey: hash,
+			},
+		},
+		Spec: diagnosisv1.DiagnosisSpec{
+			OperationSet: "operationset1",
+			TargetSelector: &diagnosisv1.TargetSelector{
+				NodeNames: []string{"node1"},
+			},
+		},
+		Status: diagnosisv1.DiagnosisStatus{
+			Phase:     diagnosisv1.DiagnosisRunning,
+			NodeNames: []string{"node1"},
+			Checkpoint: &diagnosisv1.Checkpoint{
+				SynchronizedTasks: []string{},
+			},
+		},
+	}
+
+	cli := fake.NewFakeClientWithScheme(scheme, operationset, diagnosis)
+	reconciler := &DiagnosisReconciler{
+		Client:        cli,
+		Log:           ctrl.Log.WithName("controllers").WithName("Diagnosis"),
+		Scheme:        scheme,
+		eventRecorder: record.NewFakeRecorder(100),
+	}
+	req := ctrl.Request{
+		NamespacedName: types.NamespacedName{
+			Name:      "diagnosis1",
+			Namespace: "default",
+		},
+	}
+	taskNames := func() []string {
+		var taskList diagnosisv1.TaskList
+		err := cli.List(context.Background(), &taskList)
+		assert.NoError(t, err)
+		names := make([]string, 0, len(taskList.Items))
+		for _, task := range taskList.Items {
+			names = append(names, task.Name)
+		}
+		sort.Strings(names)
+		return names
+	}
+	succeedTask := func(name string) {
+		var task diagnosisv1.Task
+		err := cli.Get(context.Background(), types.NamespacedName{Name: name, Namespace: "default"}, &task)
+		assert.NoError(t, err)
+		task.Status.Phase = diagnosisv1.TaskSucceeded
+		err = cli.Status().Update(context.Background(), &task)
+		assert.NoError(t, err)
+	}
+
+	// Only the root operation runs first.
+	_, err := reconciler.Reconcile(req)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"diagnosis1.01234567.node1.0.1.operationA"}, taskNames())
+
+	// Both independent successors run in parallel once the root succeeded while the join
+	// operation waits for its predecessors.
+	succeedTask("diagnosis1.01234567.node1.0.1.operationA")
+	_, err = reconciler.Reconcile(req)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"diagnosis1.01234567.node1.0.1.operationA",
+		"diagnosis1.01234567.node1.0.2.operationB",
+		"diagnosis1.01234567.node1.0.3.operationC",
+	}, taskNames())
+
+	// The join operation does not run while only one of its predecessors succeeded.
+	succeedTask("diagnosis1.01234567.node1.0.2.operationB")
+	_, err = reconciler.Reconcile(req)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, len(taskNames()))
+
+	// The join operation runs once after both predecessors succeeded.
+	succeedTask("diagnosis1.01234567.node1.0.3.operationC")
+	_, err = reconciler.Reconcile(req)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"diagnosis1.01234567.node1.0.1.operationA",
+		"diagnosis1.01234567.node1.0.2.operationB",
+		"diagnosis1.01234567.node1.0.3.operationC",
+		"diagnosis1.01234567.node1.0.4.operationD",
+	}, taskNames())
+
+	// The diagnosis succeeds once every operation succeeded.
+	succeedTask("diagnosis1.01234567.node1.0.4.operationD")
+	_, err = reconciler.Reconcile(req)
+	assert.NoError(t, err)
+
+	var updated diagnosisv1.Diagnosis
+	err = cli.Get(context.Background(), req.NamespacedName, &updated)
+	assert.NoError(t, err)
+	assert.Equal(t, diagnosisv1.DiagnosisSucceeded, updated.Status.Phase)
+	assert.Equal(t, 4, len(updated.Status.ExecutedOperations))
+	for _, executed := range updated.Status.ExecutedOperations {
+		assert.Equal(t, diagnosisv1.DiagnosisSucceeded, executed.Phase)
+	}
+}
+
+func TestReconcileRunningDiagnosisDAGExecutionModeFailure(t *testing.T) {
+	scheme := newTestScheme()
+	adjacencyList := []diagnosisv1.Node{
+		{
+			ID: 0,
+			To: diagnosisv1.NodeSet{1},
+		},
+		{
+			ID:        1,
+			Operation: "operationA",
+			To:        diagnosisv1.NodeSet{2},
+		},
+		{
+			ID:        2,
+			Operation: "operationB",
+		},
+	}
+	hash := util.ComputeHash(adjacencyList)
+
+	operationset := &diagnosisv1.OperationSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "operationset1",
+			Labels: map[string]string{
+				util.OperationSetUniqueLabelKey: hash,
+			},
+		},
+		Spec: diagnosisv1.OperationSetSpec{
+			AdjacencyList: adjacencyList,
+			ExecutionMode: diagnosisv1.DAGExecutionMode,
+		},
+		Status: diagnosisv1.OperationSetStatus{
+			Ready: true,
+		},
+	}
+	diagnosis := &diagnosisv1.Diagnosis{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "diagnosis1",
+			Namespace: "default",
+			UID:       types.UID("0123456789"),
+			Labels: map[string]string{
+				util.OperationSetUniqueLabelKey: hash,
+			},
+		},
+		Spec: diagnosisv1.DiagnosisSpec{
+			OperationSet: "operationset1",
+			TargetSelector: &diagnosisv1.TargetSelector{
+				NodeNames: []string{"node1"},
+			},
+		},
+		Status: diagnosisv1.DiagnosisStatus{
+			Phase:     diagnosisv1.DiagnosisRunning,
+			NodeNames: []string{"node1"},
+			Checkpoint: &diagnosisv1.Checkpoint{
+				SynchronizedTasks: []string{},
+			},
+		},
+	}
+
+	cli := fake.NewFakeClientWithScheme(scheme, operationset, diagnosis)
+	reconciler := &DiagnosisReconciler{
+		Client:        cli,
+		Log:           ctrl.Log.WithName("controllers").WithName("Diagnosis"),
+		Scheme:        scheme,
+		eventRecorder: record.NewFakeRecorder(100),
+	}
+	req := ctrl.Request{
+		NamespacedName: types.NamespacedName{
+			Name:      "diagnosis1",
+			Namespace: "default",
+		},
+	}
+
+	_, err := reconciler.Reconcile(req)
+	assert.NoError(t, err)
+
+	var task diagnosisv1.Task
+	err = cli.Get(context.Background(), types.NamespacedName{Name: "diagnosis1.01234567.node1.0.1.operationA", Namespace: "default"}, &task)
+	assert.NoError(t, err)
+	task.Status.Phase = diagnosisv1.TaskFailed
+	err = cli.Status().Update(context.Background(), &task)
+	assert.NoError(t, err)
+
+	// The diagnosis fails since the successors of a failed operation can never run.
+	_, err = reconciler.Reconcile(req)
+	assert.NoError(t, err)
+
+	var updated diagnosisv1.Diagnosis
+	err = cli.Get(context.Background(), req.NamespacedName, &updated)
+	assert.NoError(t, err)
+	assert.Equal(t, diagnosisv1.DiagnosisFailed, updated.Status.Phase)
+	var taskList diagnosisv1.TaskList
+	err = cli.List(context.Background(), &taskList)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(taskList.Items))
+	assert.Equal(t, 1, len(updated.Status.ExecutedOperations))
+	assert.Equal(t, diagnosisv1.DiagnosisFailed, updated.Status.ExecutedOperations[0].Phase)
+}
+
+func TestReconcileRunningDiagnosisWaitsForOperationSetReadiness(t *testing.T) {
+	scheme := newTestScheme()
+	adjacencyList := []diagnosisv1.Node{
+		{
+			ID: 0,
+			To: diagnosisv1.NodeSet{1},
+		},
+		{
+			ID:        1,
+			Operation: "operation1",
+		},
+	}
+	hash := util.ComputeHash(adjacencyList)
+
+	operationset := &diagnosisv1.OperationSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "operationset1",
+			Labels: map[string]string{
+				util.OperationSetUniqueLabelKey: hash,
+			},
+		},
+		Spec: diagnosisv1.OperationSetSpec{
+			AdjacencyList: adjacencyList,
+		},
+		Status: diagnosisv1.OperationSetStatus{
+			Ready: false,
+		},
+	}
+	diagnosis := &diagnosisv1.Diagnosis{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "diagnosis1",
+			Namespace: "default",
+			UID:       types.UID("0123456789"),
+			Labels: map[string]string{
+				util.OperationSetUniqueLabelKey: hash,
+			},
+		},
+		Spec: diagnosisv1.DiagnosisSpec{
+			OperationSet: "operationset1",
+			TargetSelector: &diagnosisv1.TargetSelector{
+				NodeNames: []string{"node1"},
+			},
+		},
+		Status: diagnosisv1.DiagnosisStatus{
+			Phase:     diagnosisv1.DiagnosisRunning,
+			StartTime: metav1.Now(),
+			NodeNames: []string{"node1"},
+		},
+	}
+
+	cli := fake.NewFakeClientWithScheme(scheme, operationset, diagnosis)
+	reconciler := &DiagnosisReconciler{
+		Client:                           cli,
+		Log:                              ctrl.Log.WithName("controllers").WithName("Diagnosis"),
+		Scheme:                           scheme,
+		eventRecorder:                    record.NewFakeRecorder(100),
+		operationSetReadinessGracePeriod: 30 * time.Second,
+	}
+	req := ctrl.Request{
+		NamespacedName: types.NamespacedName{
+			Name:      "diagnosis1",
+			Namespace: "default",
+		},
+	}
+
+	// The diagnosis waits for the graph to be built instead of failing immediately.
+	result, err := reconciler.Reconcile(req)
+	assert.NoError(t, err)
+	assert.True(t, result.RequeueAfter > 0)
+
+	var updated diagnosisv1.Diagnosis
+	err = cli.Get(context.Background(), req.NamespacedName, &updated)
+	assert.NoError(t, err)
+	assert.Equal(t, diagnosisv1.DiagnosisRunning, updated.Status.Phase)
+
+	// The diagnosis proceeds once the graph is built.
+	operationset.Status.Ready = true
+	operationset.Status.Paths = []diagnosisv1.Path{
+		{
+			{
+				ID:        1,
+				Operation: "operation1",
+			},
+		},
+	}
+	err = cli.Status().Update(context.Background(), operationset)
+	assert.NoError(t, err)
+
+	// The first pass initializes the checkpoint and the second pass creates a task.
+	_, err = reconciler.Reconcile(req)
+	assert.NoError(t, err)
+	_, err = reconciler.Reconcile(req)
+	assert.NoError(t, err)
+	_, err = reconciler.Reconcile(req)
+	assert.NoError(t, err)
+
+	var taskList diagnosisv1.TaskList
+	err = cli.List(context.Background(), &taskList)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(taskList.Items))
+
+	// The diagnosis fails once the grace period expired without the graph becoming ready.
+	operationset.Status.Ready = false
+	err = cli.Status().Update(context.Background(), operationset)
+	assert.NoError(t, err)
+	updated.Status.StartTime = metav1.Time{Time: time.Now().Add(-time.Minute)}
+	updated.Status.Checkpoint = nil
+	err = cli.Status().Update(context.Background(), &updated)
+	assert.NoError(t, err)
+
+	_, err = reconciler.Reconcile(req)
+	assert.NoError(t, err)
+	err = cli.Get(context.Background(), req.NamespacedName, &updated)
+	assert.NoError(t, err)
+	assert.Equal(t, diagnosisv1.DiagnosisFailed, updated.Status.Phase)
+	_, condition := util.GetDiagnosisCondition(&updated.Status, diagnosisv1.OperationSetNotReady)
+	assert.NotNil(t, condition)
+}
+
+func TestReconcileRunningDiagnosisPinnedOperationSetVersion(t *testing.T) {
+	scheme := newTestScheme()
+	oldAdjacencyList := []diagnosisv1.Node{
+		{
+			ID: 0,
+			To: diagnosisv1.NodeSet{1},
+		},
+		{
+			ID:        1,
+			Operation: "operation1",
+		},
+	}
+	newAdjacencyList := []diagnosisv1.Node{
+		{
+			ID: 0,
+			To: diagnosisv1.NodeSet{1},
+		},
+		{
+			ID:        1,
+			Operation: "operation2",
+		},
+	}
+	oldHash := util.ComputeHash(oldAdjacencyList)
+	newHash := util.ComputeHash(newAdjacencyList)
+
+	// The operation set has been edited while the diagnosis is running. The prior version
+	// the diagnosis is pinned to is archived in the status.
+	operationset := &diagnosisv1.OperationSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "operationset1",
+			Labels: map[string]string{
+				util.OperationSetUniqueLabelKey: newHash,
+			},
+		},
+		Spec: diagnosisv1.OperationSetSpec{
+			AdjacencyList: newAdjacencyList,
+		},
+		Status: diagnosisv1.OperationSetStatus{
+			Ready: true,
+			Paths: []diagnosisv1.Path{
+				{
+					{
+						ID:        1,
+						Operation: "operation2",
+					},
+				},
+			},
+			Versions: []diagnosisv1.OperationSetVersion{
+				{
+					Hash: oldHash,
+					Spec: diagnosisv1.OperationSetSpec{
+						AdjacencyList: oldAdjacencyList,
+					},
+					Paths: []diagnosisv1.Path{
+						{
+							{
+								ID:        1,
+								Operation: "operation1",
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	diagnosis := &diagnosisv1.Diagnosis{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "diagnosis1",
+			Namespace: "default",
+			UID:       types.UID("0123456789"),
+			Labels: map[string]string{
+				util.OperationSetUniqueLabelKey: oldHash,
+			},
+		},
+		Spec: diagnosisv1.DiagnosisSpec{
+			OperationSet: "operationset1",
+			TargetSelector: &diagnosisv1.TargetSelector{
+				NodeNames: []string{"node1"},
+			},
+		},
+		Status: diagnosisv1.DiagnosisStatus{
+			Phase:     diagnosisv1.DiagnosisRunning,
+			StartTime: metav1.Now(),
+			NodeNames: []string{"node1"},
+			Checkpoint: &diagnosisv1.Checkpoint{
+				PathIndex:         0,
+				NodeIndex:         0,
+				Desired:           1,
+				SynchronizedTasks: []string{},
+			},
+		},
+	}
+
+	cli := fake.NewFakeClientWithScheme(scheme, operationset, diagnosis)
+	reconciler := &DiagnosisReconciler{
+		Client:        cli,
+		Log:           ctrl.Log.WithName("controllers").WithName("Diagnosis"),
+		Scheme:        scheme,
+		eventRecorder: record.NewFakeRecorder(100),
+	}
+	req := ctrl.Request{
+		NamespacedName: types.NamespacedName{
+			Name:      "diagnosis1",
+			Namespace: "default",
+		},
+	}
+
+	// The diagnosis keeps executing the archived version instead of failing.
+	_, err := reconciler.Reconcile(req)
+	assert.NoError(t, err)
+
+	var updated diagnosisv1.Diagnosis
+	err = cli.Get(context.Background(), req.NamespacedName, &updated)
+	assert.NoError(t, err)
+	assert.Equal(t, diagnosisv1.DiagnosisRunning, updated.Status.Phase)
+
+	var taskList diagnosisv1.TaskList
+	err = cli.List(context.Background(), &taskList)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(taskList.Items))
+	assert.Equal(t, "operation1", taskList.Items[0].Spec.Operation)
+}
+
+func TestReconcileRunningDiagnosisUnarchivedOperationSetVersion(t *testing.T) {
+	scheme := newTestScheme()
+	adjacencyList := []diagnosisv1.Node{
+		{
+			ID: 0,
+			To: diagnosisv1.NodeSet{1},
+		},
+		{
+			ID:        1,
+			Operation: "operation1",
+		},
+	}
+	hash := util.ComputeHash(adjacencyList)
+
+	// The operation set has been edited and the version the diagnosis is pinned to is not
+	// archived.
+	operationset := &diagnosisv1.OperationSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "operationset1",
+			Labels: map[string]string{
+				util.OperationSetUniqueLabelKey: hash,
+			},
+		},
+		Spec: diagnosisv1.OperationSetSpec{
+			AdjacencyList: adjacencyList,
+		},
+		Status: diagnosisv1.OperationSetStatus{
+			Ready: true,
+			Paths: []diagnosisv1.Path{
+				{
+					{
+						ID:        1,
+						Operation: "operation1",
+					},
+				},
+			},
+		},
+	}
+	diagnosis := &diagnosisv1.Diagnosis{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "diagnosis1",
+			Namespace: "default",
+			UID:       types.UID("0123456789"),
+			Labels: map[string]string{
+				util.OperationSetUniqueLabelKey: "unarchived",
+			},
+		},
+		Spec: diagnosisv1.DiagnosisSpec{
+			OperationSet: "operationset1",
+			TargetSelector: &diagnosisv1.TargetSelector{
+				NodeNames: []string{"node1"},
+			},
+		},
+		Status: diagnosisv1.DiagnosisStatus{
+			Phase:     diagnosisv1.DiagnosisRunning,
+			StartTime: metav1.Now(),
+			NodeNames: []string{"node1"},
+			Checkpoint: &diagnosisv1.Checkpoint{
+				PathIndex:         0,
+				NodeIndex:         0,
+				SynchronizedTasks: []string{},
+			},
+		},
+	}
+
+	cli := fake.NewFakeClientWithScheme(scheme, operationset, diagnosis)
+	reconciler := &DiagnosisReconciler{
+		Client:        cli,
+		Log:           ctrl.Log.WithName("controllers").WithName("Diagnosis"),
+		Scheme:        scheme,
+		eventRecorder: record.NewFakeRecorder(100),
+	}
+	req := ctrl.Request{
+		NamespacedName: types.NamespacedName{
+			Name:      "diagnosis1",
+			Namespace: "default",
+		},
+	}
+
+	_, err := reconciler.Reconcile(req)
+	assert.NoError(t, err)
+
+	var updated diagnosisv1.Diagnosis
+	err = cli.Get(context.Background(), req.NamespacedName, &updated)
+	assert.NoError(t, err)
+	assert.Equal(t, diagnosisv1.DiagnosisFailed, updated.Status.Phase)
+	_, condition := util.GetDiagnosisCondition(&updated.Status, diagnosisv1.OperationSetChanged)
+	assert.NotNil(t, condition)
+}
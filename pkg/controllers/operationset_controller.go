@@ -116,6 +116,31 @@ func (r *OperationSetReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error
 		if err != nil {
 			log.Error(err, "failed to send operationSet to graph builder queue")
 		}
+
+		return ctrl.Result{}, nil
+	}
+
+	// Archive the current generation once the graph is built. A running diagnosis pinned to
+	// the archived version keeps executing it after the specification has been changed.
+	archived := false
+	for _, version := range operationSet.Status.Versions {
+		if version.Hash == newAdjacencyListHash {
+			archived = true
+			break
+		}
+	}
+	if !archived {
+		operationSet.Status.Versions = append(operationSet.Status.Versions, diagnosisv1.OperationSetVersion{
+			Hash:  newAdjacencyListHash,
+			Spec:  operationSet.Spec,
+			Paths: operationSet.Status.Paths,
+		})
+		if err := r.Status().Update(ctx, &operationSet); err != nil {
+			log.Error(err, "unable to update OperationSet")
+			return ctrl.Result{}, err
+		}
+
+		log.Info("archived operation set version", "hash", newAdjacencyListHash)
 	}
 
 	return ctrl.Result{}, nil
@@ -0,0 +1,118 @@
+/*
+Copyright 2022 The KubeDiag Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	diagnosisv1 "github.com/kubediag/kubediag/api/v1"
+	"github.com/kubediag/kubediag/pkg/util"
+)
+
+func TestReconcileOperationSetArchivesVersion(t *testing.T) {
+	scheme := newTestScheme()
+	adjacencyList := []diagnosisv1.Node{
+		{
+			ID: 0,
+			To: diagnosisv1.NodeSet{1},
+		},
+		{
+			ID:        1,
+			Operation: "operation1",
+		},
+	}
+	hash := util.ComputeHash(adjacencyList)
+
+	operationset := &diagnosisv1.OperationSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "operationset1",
+			Labels: map[string]string{
+				util.OperationSetUniqueLabelKey: hash,
+			},
+		},
+		Spec: diagnosisv1.OperationSetSpec{
+			AdjacencyList: adjacencyList,
+		},
+		Status: diagnosisv1.OperationSetStatus{
+			Ready: true,
+			Paths: []diagnosisv1.Path{
+				{
+					{
+						ID:        1,
+						Operation: "operation1",
+					},
+				},
+			},
+		},
+	}
+
+	cli := fake.NewFakeClientWithScheme(scheme, operationset)
+	reconciler := &OperationSetReconciler{
+		Client:         cli,
+		Log:            ctrl.Log.WithName("controllers").WithName("OperationSet"),
+		Scheme:         scheme,
+		graphBuilderCh: make(chan diagnosisv1.OperationSet, 10),
+	}
+	req := ctrl.Request{
+		NamespacedName: types.NamespacedName{
+			Name: "operationset1",
+		},
+	}
+
+	// The current generation is archived once the graph is built.
+	_, err := reconciler.Reconcile(req)
+	assert.NoError(t, err)
+
+	var updated diagnosisv1.OperationSet
+	err = cli.Get(context.Background(), req.NamespacedName, &updated)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(updated.Status.Versions))
+	assert.Equal(t, hash, updated.Status.Versions[0].Hash)
+	assert.Equal(t, adjacencyList, updated.Status.Versions[0].Spec.AdjacencyList)
+	assert.Equal(t, updated.Status.Paths, updated.Status.Versions[0].Paths)
+
+	// Archiving is idempotent.
+	_, err = reconciler.Reconcile(req)
+	assert.NoError(t, err)
+	err = cli.Get(context.Background(), req.NamespacedName, &updated)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(updated.Status.Versions))
+
+	// The archived version survives a specification change.
+	updated.Spec.AdjacencyList = append(updated.Spec.AdjacencyList, diagnosisv1.Node{
+		ID:        2,
+		Operation: "operation2",
+	})
+	err = cli.Update(context.Background(), &updated)
+	assert.NoError(t, err)
+
+	_, err = reconciler.Reconcile(req)
+	assert.NoError(t, err)
+	err = cli.Get(context.Background(), req.NamespacedName, &updated)
+	assert.NoError(t, err)
+	assert.False(t, updated.Status.Ready)
+	assert.NotEqual(t, hash, updated.Labels[util.OperationSetUniqueLabelKey])
+	assert.Equal(t, 1, len(updated.Status.Versions))
+	assert.Equal(t, hash, updated.Status.Versions[0].Hash)
+}
@@ -24,6 +24,7 @@ import (
 
 	"github.com/go-logr/logr"
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	ctrl "sigs.k8s.io/controller-runtime"
@@ -147,6 +148,15 @@ func (r *TaskReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 		}
 		diagnosis.Status.Context.Operations[operationKey] = operationValue
 
+		var operation diagnosisv1.Operation
+		if err := r.Get(ctx, client.ObjectKey{Name: task.Spec.Operation}, &operation); err != nil {
+			if !apierrors.IsNotFound(err) {
+				return ctrl.Result{}, err
+			}
+		} else {
+			setResultConditions(&diagnosis.Status, operation, task)
+		}
+
 		if err := r.Status().Update(ctx, &diagnosis); err != nil {
 			return ctrl.Result{}, fmt.Errorf("unable to update Diagnosis: %s", err)
 		}
@@ -181,6 +191,29 @@ func (r *TaskReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 	return ctrl.Result{}, nil
 }
 
+// setResultConditions sets conditions on the diagnosis status according to the result condition
+// mappings declared by the operation. A condition is set to true if the task result under the
+// mapped key is a true boolean string.
+func setResultConditions(status *diagnosisv1.DiagnosisStatus, operation diagnosisv1.Operation, task diagnosisv1.Task) {
+	for _, resultCondition := range operation.Spec.ResultConditions {
+		value, ok := task.Status.Results[resultCondition.Key]
+		if !ok {
+			continue
+		}
+		matched, err := strconv.ParseBool(value)
+		if err != nil || !matched {
+			continue
+		}
+
+		util.UpdateDiagnosisCondition(status, &diagnosisv1.DiagnosisCondition{
+			Type:    resultCondition.Type,
+			Status:  corev1.ConditionTrue,
+			Reason:  "OperationResult",
+			Message: fmt.Sprintf("Operation %s reported result %s=%s", operation.Name, resultCondition.Key, value),
+		})
+	}
+}
+
 // SetupWithManager sets up the controller with the Manager.
 func (r *TaskReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
@@ -0,0 +1,189 @@
+/*
+Copyright 2022 The KubeDiag Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	diagnosisv1 "github.com/kubediag/kubediag/api/v1"
+	"github.com/kubediag/kubediag/pkg/util"
+)
+
+func TestReconcileSucceededTaskSetsResultConditions(t *testing.T) {
+	scheme := newTestScheme()
+	operation := &diagnosisv1.Operation{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "operation1",
+		},
+		Spec: diagnosisv1.OperationSpec{
+			ResultConditions: []diagnosisv1.ResultCondition{
+				{
+					Key:  "high_memory",
+					Type: "HighMemory",
+				},
+				{
+					Key:  "disk_pressure",
+					Type: "DiskPressure",
+				},
+			},
+		},
+	}
+	diagnosis := &diagnosisv1.Diagnosis{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "diagnosis1",
+			Namespace: "default",
+		},
+		Status: diagnosisv1.DiagnosisStatus{
+			Phase: diagnosisv1.DiagnosisRunning,
+			Checkpoint: &diagnosisv1.Checkpoint{
+				Active:  1,
+				Desired: 1,
+			},
+		},
+	}
+	task := &diagnosisv1.Task{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "diagnosis1.01234567.node1.0.0.operation1",
+			Namespace: "default",
+		},
+		Spec: diagnosisv1.TaskSpec{
+			Operation: "operation1",
+			NodeName:  "master1",
+		},
+		Status: diagnosisv1.TaskStatus{
+			Phase: diagnosisv1.TaskSucceeded,
+			Results: map[string]string{
+				"high_memory":   "true",
+				"disk_pressure": "false",
+			},
+		},
+	}
+
+	cli := fake.NewFakeClientWithScheme(scheme, operation, diagnosis, task)
+	reconciler := &TaskReconciler{
+		Client:   cli,
+		Log:      ctrl.Log.WithName("controllers").WithName("Task"),
+		Scheme:   scheme,
+		nodeName: "master1",
+	}
+	req := ctrl.Request{
+		NamespacedName: types.NamespacedName{
+			Name:      task.Name,
+			Namespace: "default",
+		},
+	}
+
+	_, err := reconciler.Reconcile(req)
+	assert.NoError(t, err)
+
+	var updated diagnosisv1.Diagnosis
+	err = cli.Get(context.Background(), types.NamespacedName{
+		Name:      "diagnosis1",
+		Namespace: "default",
+	}, &updated)
+	assert.NoError(t, err)
+
+	// The condition mapped from the true result value is set while the false one is not.
+	_, condition := util.GetDiagnosisCondition(&updated.Status, "HighMemory")
+	if assert.NotNil(t, condition) {
+		assert.Equal(t, corev1.ConditionTrue, condition.Status)
+		assert.Equal(t, "OperationResult", condition.Reason)
+		assert.Contains(t, condition.Message, "high_memory=true")
+	}
+	_, condition = util.GetDiagnosisCondition(&updated.Status, "DiskPressure")
+	assert.Nil(t, condition)
+
+	// The task results are recorded in the diagnosis context.
+	assert.Equal(t, 0, updated.Status.Checkpoint.Active)
+	assert.Equal(t, 1, updated.Status.Checkpoint.Succeeded)
+	operationValue, ok := updated.Status.Context.Operations["0.0.operation1"]
+	if assert.True(t, ok) {
+		assert.Equal(t, diagnosisv1.TaskContext(task.Status.Results), operationValue[task.Name])
+	}
+}
+
+func TestSetResultConditions(t *testing.T) {
+	operation := diagnosisv1.Operation{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "operation1",
+		},
+		Spec: diagnosisv1.OperationSpec{
+			ResultConditions: []diagnosisv1.ResultCondition{
+				{
+					Key:  "high_memory",
+					Type: "HighMemory",
+				},
+			},
+		},
+	}
+	tests := []struct {
+		results  map[string]string
+		expected bool
+		desc     string
+	}{
+		{
+			results:  map[string]string{"high_memory": "true"},
+			expected: true,
+			desc:     "true result value sets condition",
+		},
+		{
+			results:  map[string]string{"high_memory": "1"},
+			expected: true,
+			desc:     "boolean string result value sets condition",
+		},
+		{
+			results:  map[string]string{"high_memory": "false"},
+			expected: false,
+			desc:     "false result value does not set condition",
+		},
+		{
+			results:  map[string]string{"high_memory": "85%"},
+			expected: false,
+			desc:     "non boolean result value does not set condition",
+		},
+		{
+			results:  map[string]string{"other": "true"},
+			expected: false,
+			desc:     "missing result key does not set condition",
+		},
+	}
+
+	for _, test := range tests {
+		status := diagnosisv1.DiagnosisStatus{}
+		task := diagnosisv1.Task{
+			Status: diagnosisv1.TaskStatus{
+				Results: test.results,
+			},
+		}
+
+		setResultConditions(&status, operation, task)
+		_, condition := util.GetDiagnosisCondition(&status, "HighMemory")
+		if test.expected {
+			assert.NotNil(t, condition, test.desc)
+		} else {
+			assert.Nil(t, condition, test.desc)
+		}
+	}
+}
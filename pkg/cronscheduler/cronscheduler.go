@@ -263,6 +263,13 @@ func (cs *cronScheduler) getRecentUnmetScheduleTimes(trigger diagnosisv1.Trigger
 		// In any case, use the creation time of the trigger as last known start time.
 		earliestTime = trigger.ObjectMeta.CreationTimestamp.Time
 	}
+	if trigger.Spec.SourceTemplate.CronTemplate.StartingDeadlineSeconds != nil {
+		// Controller is not going to schedule anything below this point.
+		schedulingDeadline := now.Add(-time.Duration(*trigger.Spec.SourceTemplate.CronTemplate.StartingDeadlineSeconds) * time.Second)
+		if schedulingDeadline.After(earliestTime) {
+			earliestTime = schedulingDeadline
+		}
+	}
 	if earliestTime.After(now) {
 		return []time.Time{}, nil
 	}
@@ -0,0 +1,94 @@
+/*
+Copyright 2022 The KubeDiag Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cronscheduler
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	diagnosisv1 "github.com/kubediag/kubediag/api/v1"
+)
+
+func TestGetRecentUnmetScheduleTimes(t *testing.T) {
+	now := time.Date(2022, 1, 1, 12, 0, 0, 0, time.UTC)
+	startingDeadlineSeconds := int64(3600)
+	shortStartingDeadlineSeconds := int64(600)
+
+	cs := &cronScheduler{
+		Context: context.Background(),
+		Logger:  ctrl.Log.WithName("cronscheduler"),
+	}
+
+	tests := []struct {
+		trigger  diagnosisv1.Trigger
+		expected []time.Time
+		desc     string
+	}{
+		{
+			trigger: newCronTrigger("0 * * * *", now.Add(-90*time.Minute), nil),
+			expected: []time.Time{
+				now.Add(-60 * time.Minute),
+				now,
+			},
+			desc: "missed schedules without starting deadline",
+		},
+		{
+			trigger: newCronTrigger("0 * * * *", now.Add(-150*time.Minute), &startingDeadlineSeconds),
+			expected: []time.Time{
+				now,
+			},
+			desc: "missed schedules beyond starting deadline are skipped",
+		},
+		{
+			trigger:  newCronTrigger("30 * * * *", now.Add(-90*time.Minute), &shortStartingDeadlineSeconds),
+			expected: []time.Time{},
+			desc:     "all missed schedules beyond starting deadline",
+		},
+	}
+
+	for _, test := range tests {
+		times, err := cs.getRecentUnmetScheduleTimes(test.trigger, now)
+		assert.NoError(t, err, test.desc)
+		assert.Equal(t, test.expected, times, test.desc)
+	}
+}
+
+// newCronTrigger creates a Trigger with a cron template for testing.
+func newCronTrigger(schedule string, lastScheduleTime time.Time, startingDeadlineSeconds *int64) diagnosisv1.Trigger {
+	return diagnosisv1.Trigger{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "cron-trigger",
+		},
+		Spec: diagnosisv1.TriggerSpec{
+			OperationSet: "operationset",
+			SourceTemplate: diagnosisv1.SourceTemplate{
+				CronTemplate: &diagnosisv1.CronTemplate{
+					Schedule:                schedule,
+					StartingDeadlineSeconds: startingDeadlineSeconds,
+				},
+			},
+		},
+		Status: diagnosisv1.TriggerStatus{
+			LastScheduleTime: &metav1.Time{Time: lastScheduleTime},
+		},
+	}
+}
@@ -0,0 +1,249 @@
+/*
+Copyright 2022 The KubeDiag Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package diagnosisbatcher
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	diagnosisv1 "github.com/kubediag/kubediag/api/v1"
+)
+
+const (
+	// BatchGeneratedDiagnosisPrefix is the name prefix for diagnoses generated by batch requests.
+	BatchGeneratedDiagnosisPrefix = "batch"
+	// defaultNamespace is the namespace for created diagnoses if not specified in the request.
+	defaultNamespace = "default"
+)
+
+// DiagnosisBatcher creates diagnoses in bulk for fleet wide checks.
+type DiagnosisBatcher interface {
+	// Handler handles http requests.
+	Handler(http.ResponseWriter, *http.Request)
+}
+
+// BatchRequest is the payload for creating diagnoses in bulk.
+type BatchRequest struct {
+	// Namespace is the namespace to create diagnoses in. Defaults to default if not specified.
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+	// OperationSet is the name of operation set which represents diagnosis pipeline to be executed.
+	OperationSet string `json:"operationSet"`
+	// NodeNames specifies nodes to run the diagnosis pipeline on.
+	// One of NodeNames and NodeSelector must be specified.
+	// +optional
+	NodeNames []string `json:"nodeNames,omitempty"`
+	// NodeSelector queries over a set of nodes. The diagnosis pipeline runs on matched nodes.
+	// +optional
+	NodeSelector *metav1.LabelSelector `json:"nodeSelector,omitempty"`
+	// Parameters is a set of the parameters to be passed to operations of every created diagnosis.
+	// +optional
+	Parameters map[string]string `json:"parameters,omitempty"`
+	// SingleDiagnosis indicates whether one diagnosis targeting all matched nodes is created
+	// instead of one diagnosis per matched node.
+	// +optional
+	SingleDiagnosis bool `json:"singleDiagnosis,omitempty"`
+}
+
+// BatchResponse contains the names of created diagnoses.
+type BatchResponse struct {
+	// Diagnoses are the names of created diagnoses.
+	Diagnoses []string `json:"diagnoses"`
+}
+
+// diagnosisBatcher creates one diagnosis per matched node, or a single multi node diagnosis,
+// from an operation set and a node selector.
+type diagnosisBatcher struct {
+	// Context carries values across API boundaries.
+	context.Context
+	// Logger represents the ability to log messages.
+	logr.Logger
+
+	// client knows how to perform CRUD operations on Kubernetes objects.
+	client client.Client
+	// cache knows how to load Kubernetes objects.
+	cache cache.Cache
+}
+
+// NewDiagnosisBatcher creates a new DiagnosisBatcher.
+func NewDiagnosisBatcher(
+	ctx context.Context,
+	logger logr.Logger,
+	cli client.Client,
+	cache cache.Cache,
+) DiagnosisBatcher {
+	return &diagnosisBatcher{
+		Context: ctx,
+		Logger:  logger,
+		client:  cli,
+		cache:   cache,
+	}
+}
+
+// Handler handles http requests for creating diagnoses in bulk.
+func (db *diagnosisBatcher) Handler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "POST":
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("unable to read request body: %v", err), http.StatusBadRequest)
+			return
+		}
+		defer r.Body.Close()
+
+		var request BatchRequest
+		if err := json.Unmarshal(body, &request); err != nil {
+			http.Error(w, fmt.Sprintf("failed to unmarshal request body: %v", err), http.StatusBadRequest)
+			return
+		}
+		if request.OperationSet == "" {
+			http.Error(w, "operation set must be specified", http.StatusBadRequest)
+			return
+		}
+		if request.Namespace == "" {
+			request.Namespace = defaultNamespace
+		}
+
+		var operationset diagnosisv1.OperationSet
+		if err := db.cache.Get(db, client.ObjectKey{Name: request.OperationSet}, &operationset); err != nil {
+			if apierrors.IsNotFound(err) {
+				http.Error(w, fmt.Sprintf("operation set %s not found", request.OperationSet), http.StatusNotFound)
+				return
+			}
+			db.Error(err, "unable to get OperationSet", "operationset", request.OperationSet)
+			http.Error(w, fmt.Sprintf("unable to get operation set %s: %v", request.OperationSet, err), http.StatusInternalServerError)
+			return
+		}
+
+		nodeNames, err := db.resolveNodeNames(request)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		names, err := db.createDiagnoses(request, nodeNames)
+		if err != nil {
+			db.Error(err, "unable to create diagnoses in bulk", "operationset", request.OperationSet)
+			http.Error(w, fmt.Sprintf("unable to create diagnoses: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		data, err := json.Marshal(BatchResponse{Diagnoses: names})
+		if err != nil {
+			http.Error(w, fmt.Sprintf("failed to marshal response: %v", err), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(data)
+	default:
+		http.Error(w, fmt.Sprintf("method %s is not supported", r.Method), http.StatusMethodNotAllowed)
+	}
+}
+
+// resolveNodeNames resolves the target node names of a batch request from its node names or
+// node selector.
+func (db *diagnosisBatcher) resolveNodeNames(request BatchRequest) ([]string, error) {
+	if len(request.NodeNames) != 0 {
+		return request.NodeNames, nil
+	}
+	if request.NodeSelector == nil {
+		return nil, fmt.Errorf("node names or node selector must be specified")
+	}
+
+	labelSelector, err := metav1.LabelSelectorAsSelector(request.NodeSelector)
+	if err != nil {
+		return nil, fmt.Errorf("invalid node selector: %v", err)
+	}
+	var nodeList corev1.NodeList
+	if err := db.cache.List(db, &nodeList, &client.ListOptions{LabelSelector: labelSelector}); err != nil {
+		return nil, fmt.Errorf("unable to list nodes: %v", err)
+	}
+	if len(nodeList.Items) == 0 {
+		return nil, fmt.Errorf("no nodes matched the node selector")
+	}
+
+	nodeNames := make([]string, 0, len(nodeList.Items))
+	for _, node := range nodeList.Items {
+		nodeNames = append(nodeNames, node.Name)
+	}
+	sort.Strings(nodeNames)
+
+	return nodeNames, nil
+}
+
+// createDiagnoses creates one diagnosis per node name, or a single diagnosis targeting all
+// node names, and returns the names of created diagnoses.
+func (db *diagnosisBatcher) createDiagnoses(request BatchRequest, nodeNames []string) ([]string, error) {
+	now := time.Now().Unix()
+	names := make([]string, 0, len(nodeNames))
+
+	if request.SingleDiagnosis {
+		name := fmt.Sprintf("%s.%s.%d", BatchGeneratedDiagnosisPrefix, strings.ToLower(request.OperationSet), now)
+		if err := db.createDiagnosis(request, name, nodeNames); err != nil {
+			return nil, err
+		}
+		return []string{name}, nil
+	}
+
+	for _, nodeName := range nodeNames {
+		name := fmt.Sprintf("%s.%s.%s.%d", BatchGeneratedDiagnosisPrefix, strings.ToLower(request.OperationSet), strings.ToLower(nodeName), now)
+		if err := db.createDiagnosis(request, name, []string{nodeName}); err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+
+	return names, nil
+}
+
+// createDiagnosis creates one diagnosis targeting the provided node names. A diagnosis which
+// already exists is treated as created.
+func (db *diagnosisBatcher) createDiagnosis(request BatchRequest, name string, nodeNames []string) error {
+	diagnosis := diagnosisv1.Diagnosis{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: request.Namespace,
+		},
+		Spec: diagnosisv1.DiagnosisSpec{
+			OperationSet: request.OperationSet,
+			Parameters:   request.Parameters,
+			TargetSelector: &diagnosisv1.TargetSelector{
+				NodeNames: nodeNames,
+			},
+		},
+	}
+	if err := db.client.Create(db, &diagnosis); err != nil {
+		if !apierrors.IsAlreadyExists(err) {
+			return err
+		}
+	}
+
+	return nil
+}
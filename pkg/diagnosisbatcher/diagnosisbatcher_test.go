@@ -0,0 +1,223 @@
+/*
+Copyright 2022 The KubeDiag Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package diagnosisbatcher
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	diagnosisv1 "github.com/kubediag/kubediag/api/v1"
+)
+
+// fakeCache is a cache.Cache backed by a fake client for testing.
+type fakeCache struct {
+	client.Reader
+}
+
+func (f fakeCache) GetInformer(obj runtime.Object) (cache.Informer, error) {
+	return nil, nil
+}
+
+func (f fakeCache) GetInformerForKind(gvk schema.GroupVersionKind) (cache.Informer, error) {
+	return nil, nil
+}
+
+func (f fakeCache) Start(stopCh <-chan struct{}) error {
+	return nil
+}
+
+func (f fakeCache) WaitForCacheSync(stop <-chan struct{}) bool {
+	return true
+}
+
+func (f fakeCache) IndexField(obj runtime.Object, field string, extractValue client.IndexerFunc) error {
+	return nil
+}
+
+func newTestDiagnosisBatcher() (*diagnosisBatcher, client.Client) {
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = diagnosisv1.AddToScheme(scheme)
+
+	cli := fake.NewFakeClientWithScheme(scheme,
+		&diagnosisv1.OperationSet{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "operationset1",
+			},
+		},
+		&corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   "node1",
+				Labels: map[string]string{"role": "worker"},
+			},
+		},
+		&corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   "node2",
+				Labels: map[string]string{"role": "worker"},
+			},
+		},
+		&corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   "node3",
+				Labels: map[string]string{"role": "worker"},
+			},
+		},
+		&corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   "master1",
+				Labels: map[string]string{"role": "master"},
+			},
+		},
+	)
+	db := &diagnosisBatcher{
+		Context: context.Background(),
+		Logger:  ctrl.Log.WithName("diagnosisbatcher"),
+		client:  cli,
+		cache:   fakeCache{Reader: cli},
+	}
+
+	return db, cli
+}
+
+func TestDiagnosisBatcherHandlerPerNodeDiagnoses(t *testing.T) {
+	db, cli := newTestDiagnosisBatcher()
+
+	body, err := json.Marshal(BatchRequest{
+		OperationSet: "operationset1",
+		NodeSelector: &metav1.LabelSelector{
+			MatchLabels: map[string]string{"role": "worker"},
+		},
+		Parameters: map[string]string{"param1": "value1"},
+	})
+	assert.NoError(t, err)
+	recorder := httptest.NewRecorder()
+	db.Handler(recorder, httptest.NewRequest("POST", "/api/v1/diagnoses/batch", bytes.NewReader(body)))
+	assert.Equal(t, 200, recorder.Code)
+
+	var response BatchResponse
+	err = json.Unmarshal(recorder.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, len(response.Diagnoses))
+
+	// One diagnosis targeting each matched node is created in the default namespace.
+	var diagnosisList diagnosisv1.DiagnosisList
+	err = cli.List(context.Background(), &diagnosisList)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, len(diagnosisList.Items))
+	targetedNodes := make(map[string]bool)
+	for _, diagnosis := range diagnosisList.Items {
+		assert.Equal(t, "default", diagnosis.Namespace)
+		assert.Equal(t, "operationset1", diagnosis.Spec.OperationSet)
+		assert.Equal(t, map[string]string{"param1": "value1"}, diagnosis.Spec.Parameters)
+		if assert.Equal(t, 1, len(diagnosis.Spec.TargetSelector.NodeNames)) {
+			targetedNodes[diagnosis.Spec.TargetSelector.NodeNames[0]] = true
+		}
+	}
+	assert.Equal(t, map[string]bool{"node1": true, "node2": true, "node3": true}, targetedNodes)
+}
+
+func TestDiagnosisBatcherHandlerSingleDiagnosis(t *testing.T) {
+	db, cli := newTestDiagnosisBatcher()
+
+	body, err := json.Marshal(BatchRequest{
+		Namespace:    "kubediag",
+		OperationSet: "operationset1",
+		NodeSelector: &metav1.LabelSelector{
+			MatchLabels: map[string]string{"role": "worker"},
+		},
+		SingleDiagnosis: true,
+	})
+	assert.NoError(t, err)
+	recorder := httptest.NewRecorder()
+	db.Handler(recorder, httptest.NewRequest("POST", "/api/v1/diagnoses/batch", bytes.NewReader(body)))
+	assert.Equal(t, 200, recorder.Code)
+
+	var response BatchResponse
+	err = json.Unmarshal(recorder.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(response.Diagnoses))
+
+	// A single diagnosis targeting all matched nodes is created.
+	var diagnosisList diagnosisv1.DiagnosisList
+	err = cli.List(context.Background(), &diagnosisList)
+	assert.NoError(t, err)
+	if assert.Equal(t, 1, len(diagnosisList.Items)) {
+		diagnosis := diagnosisList.Items[0]
+		assert.Equal(t, "kubediag", diagnosis.Namespace)
+		assert.Equal(t, []string{"node1", "node2", "node3"}, diagnosis.Spec.TargetSelector.NodeNames)
+	}
+}
+
+func TestDiagnosisBatcherHandlerInvalidRequests(t *testing.T) {
+	db, _ := newTestDiagnosisBatcher()
+
+	// The operation set is required.
+	recorder := httptest.NewRecorder()
+	db.Handler(recorder, httptest.NewRequest("POST", "/api/v1/diagnoses/batch", bytes.NewReader([]byte(`{}`))))
+	assert.Equal(t, 400, recorder.Code)
+
+	// The operation set must exist.
+	body, err := json.Marshal(BatchRequest{
+		OperationSet: "operationset2",
+		NodeNames:    []string{"node1"},
+	})
+	assert.NoError(t, err)
+	recorder = httptest.NewRecorder()
+	db.Handler(recorder, httptest.NewRequest("POST", "/api/v1/diagnoses/batch", bytes.NewReader(body)))
+	assert.Equal(t, 404, recorder.Code)
+
+	// Node names or a node selector must be specified.
+	body, err = json.Marshal(BatchRequest{
+		OperationSet: "operationset1",
+	})
+	assert.NoError(t, err)
+	recorder = httptest.NewRecorder()
+	db.Handler(recorder, httptest.NewRequest("POST", "/api/v1/diagnoses/batch", bytes.NewReader(body)))
+	assert.Equal(t, 400, recorder.Code)
+
+	// A selector matching no nodes is an error.
+	body, err = json.Marshal(BatchRequest{
+		OperationSet: "operationset1",
+		NodeSelector: &metav1.LabelSelector{
+			MatchLabels: map[string]string{"role": "etcd"},
+		},
+	})
+	assert.NoError(t, err)
+	recorder = httptest.NewRecorder()
+	db.Handler(recorder, httptest.NewRequest("POST", "/api/v1/diagnoses/batch", bytes.NewReader(body)))
+	assert.Equal(t, 400, recorder.Code)
+
+	// Only POST requests are supported.
+	recorder = httptest.NewRecorder()
+	db.Handler(recorder, httptest.NewRequest("GET", "/api/v1/diagnoses/batch", nil))
+	assert.Equal(t, 405, recorder.Code)
+}
@@ -0,0 +1,184 @@
+/*
+Copyright 2022 The KubeDiag Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package diagnosisstreamer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/gorilla/mux"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	diagnosisv1 "github.com/kubediag/kubediag/api/v1"
+)
+
+// DefaultPollInterval is the default interval for polling progress of a watched diagnosis.
+const DefaultPollInterval = time.Second
+
+// DiagnosisStreamer streams live progress of running diagnoses as server sent events.
+type DiagnosisStreamer interface {
+	// Handler handles http requests.
+	Handler(http.ResponseWriter, *http.Request)
+}
+
+// diagnosisStreamer streams phase, condition and checkpoint changes of a diagnosis.
+type diagnosisStreamer struct {
+	// Context carries values across API boundaries.
+	context.Context
+	// Logger represents the ability to log messages.
+	logr.Logger
+
+	// cache knows how to load Kubernetes objects.
+	cache cache.Cache
+	// pollInterval specifies how often the watched diagnosis is polled for progress changes.
+	pollInterval time.Duration
+}
+
+// diagnosisProgress is the payload of a server sent event emitted on a progress change.
+type diagnosisProgress struct {
+	// Phase is the current phase of the diagnosis.
+	Phase diagnosisv1.DiagnosisPhase `json:"phase"`
+	// Conditions contains current service state of diagnosis.
+	Conditions []diagnosisv1.DiagnosisCondition `json:"conditions,omitempty"`
+	// Checkpoint is the current checkpoint of the diagnosis.
+	Checkpoint *diagnosisv1.Checkpoint `json:"checkpoint,omitempty"`
+}
+
+// NewDiagnosisStreamer creates a new DiagnosisStreamer.
+func NewDiagnosisStreamer(
+	ctx context.Context,
+	logger logr.Logger,
+	cache cache.Cache,
+	pollInterval time.Duration,
+) DiagnosisStreamer {
+	if pollInterval <= 0 {
+		pollInterval = DefaultPollInterval
+	}
+
+	return &diagnosisStreamer{
+		Context:      ctx,
+		Logger:       logger,
+		cache:        cache,
+		pollInterval: pollInterval,
+	}
+}
+
+// Handler handles http requests for streaming progress of a diagnosis as server sent events.
+// The stream is closed when the diagnosis reaches a terminal phase or the client disconnects.
+func (ds *diagnosisStreamer) Handler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+		vars := mux.Vars(r)
+		key := client.ObjectKey{
+			Namespace: vars["namespace"],
+			Name:      vars["name"],
+		}
+
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			http.Error(w, "streaming is not supported", http.StatusInternalServerError)
+			return
+		}
+
+		var diagnosis diagnosisv1.Diagnosis
+		if err := ds.cache.Get(ds, key, &diagnosis); err != nil {
+			if apierrors.IsNotFound(err) {
+				http.Error(w, fmt.Sprintf("diagnosis %s/%s not found", key.Namespace, key.Name), http.StatusNotFound)
+				return
+			}
+			ds.Error(err, "unable to get Diagnosis", "diagnosis", key)
+			http.Error(w, fmt.Sprintf("unable to get diagnosis %s/%s: %v", key.Namespace, key.Name, err), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+
+		// Emit the current progress as the first event.
+		lastProgress, err := marshalProgress(diagnosis)
+		if err != nil {
+			ds.Error(err, "unable to marshal progress of Diagnosis", "diagnosis", key)
+			http.Error(w, fmt.Sprintf("unable to marshal progress of diagnosis %s/%s: %v", key.Namespace, key.Name, err), http.StatusInternalServerError)
+			return
+		}
+		writeEvent(w, lastProgress)
+		flusher.Flush()
+		if isTerminalPhase(diagnosis.Status.Phase) {
+			return
+		}
+
+		ticker := time.NewTicker(ds.pollInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case <-ticker.C:
+				if err := ds.cache.Get(ds, key, &diagnosis); err != nil {
+					if apierrors.IsNotFound(err) {
+						return
+					}
+					ds.Error(err, "unable to get Diagnosis", "diagnosis", key)
+					continue
+				}
+
+				progress, err := marshalProgress(diagnosis)
+				if err != nil {
+					ds.Error(err, "unable to marshal progress of Diagnosis", "diagnosis", key)
+					continue
+				}
+				if !bytes.Equal(progress, lastProgress) {
+					writeEvent(w, progress)
+					flusher.Flush()
+					lastProgress = progress
+				}
+				if isTerminalPhase(diagnosis.Status.Phase) {
+					return
+				}
+			}
+		}
+	default:
+		http.Error(w, fmt.Sprintf("method %s is not supported", r.Method), http.StatusMethodNotAllowed)
+	}
+}
+
+// marshalProgress marshals the progress of a diagnosis into a server sent event payload.
+func marshalProgress(diagnosis diagnosisv1.Diagnosis) ([]byte, error) {
+	return json.Marshal(diagnosisProgress{
+		Phase:      diagnosis.Status.Phase,
+		Conditions: diagnosis.Status.Conditions,
+		Checkpoint: diagnosis.Status.Checkpoint,
+	})
+}
+
+// writeEvent writes a server sent event with the provided payload.
+func writeEvent(w http.ResponseWriter, data []byte) {
+	fmt.Fprintf(w, "data: %s\n\n", data)
+}
+
+// isTerminalPhase reports whether the diagnosis phase is terminal.
+func isTerminalPhase(phase diagnosisv1.DiagnosisPhase) bool {
+	return phase == diagnosisv1.DiagnosisSucceeded || phase == diagnosisv1.DiagnosisFailed
+}
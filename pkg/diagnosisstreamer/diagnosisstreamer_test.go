@@ -0,0 +1,150 @@
+/*
+Copyright 2022 The KubeDiag Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package diagnosisstreamer
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	diagnosisv1 "github.com/kubediag/kubediag/api/v1"
+)
+
+// fakeCache is a cache.Cache backed by a fake client for testing.
+type fakeCache struct {
+	client.Reader
+}
+
+func (f fakeCache) GetInformer(obj runtime.Object) (cache.Informer, error) {
+	return nil, nil
+}
+
+func (f fakeCache) GetInformerForKind(gvk schema.GroupVersionKind) (cache.Informer, error) {
+	return nil, nil
+}
+
+func (f fakeCache) Start(stopCh <-chan struct{}) error {
+	return nil
+}
+
+func (f fakeCache) WaitForCacheSync(stop <-chan struct{}) bool {
+	return true
+}
+
+func (f fakeCache) IndexField(obj runtime.Object, field string, extractValue client.IndexerFunc) error {
+	return nil
+}
+
+func TestDiagnosisStreamerHandler(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = diagnosisv1.AddToScheme(scheme)
+
+	diagnosis := &diagnosisv1.Diagnosis{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "diagnosis1",
+			Namespace: "default",
+		},
+		Status: diagnosisv1.DiagnosisStatus{
+			Phase: diagnosisv1.DiagnosisRunning,
+		},
+	}
+
+	cli := fake.NewFakeClientWithScheme(scheme, diagnosis)
+	ds := &diagnosisStreamer{
+		Context:      context.Background(),
+		Logger:       ctrl.Log.WithName("diagnosisstreamer"),
+		cache:        fakeCache{Reader: cli},
+		pollInterval: 10 * time.Millisecond,
+	}
+
+	req := httptest.NewRequest("GET", "/api/v1/diagnoses/default/diagnosis1/events", nil)
+	req = mux.SetURLVars(req, map[string]string{
+		"namespace": "default",
+		"name":      "diagnosis1",
+	})
+	recorder := httptest.NewRecorder()
+
+	done := make(chan struct{})
+	go func() {
+		ds.Handler(recorder, req)
+		close(done)
+	}()
+
+	// The stream emits an event and closes once the diagnosis transitions to a terminal phase.
+	time.Sleep(50 * time.Millisecond)
+	updated := diagnosis.DeepCopy()
+	updated.Status.Phase = diagnosisv1.DiagnosisSucceeded
+	err := cli.Status().Update(context.Background(), updated)
+	assert.NoError(t, err)
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("stream did not close after the diagnosis reached a terminal phase")
+	}
+
+	assert.Equal(t, 200, recorder.Code)
+	assert.Equal(t, "text/event-stream", recorder.Header().Get("Content-Type"))
+	events := strings.Split(strings.TrimSpace(recorder.Body.String()), "\n\n")
+	if assert.Equal(t, 2, len(events)) {
+		assert.Contains(t, events[0], `"phase":"Running"`)
+		assert.Contains(t, events[1], `"phase":"Succeeded"`)
+	}
+}
+
+func TestDiagnosisStreamerHandlerNotFound(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = diagnosisv1.AddToScheme(scheme)
+
+	cli := fake.NewFakeClientWithScheme(scheme)
+	ds := &diagnosisStreamer{
+		Context:      context.Background(),
+		Logger:       ctrl.Log.WithName("diagnosisstreamer"),
+		cache:        fakeCache{Reader: cli},
+		pollInterval: 10 * time.Millisecond,
+	}
+
+	req := httptest.NewRequest("GET", "/api/v1/diagnoses/default/diagnosis1/events", nil)
+	req = mux.SetURLVars(req, map[string]string{
+		"namespace": "default",
+		"name":      "diagnosis1",
+	})
+	recorder := httptest.NewRecorder()
+	ds.Handler(recorder, req)
+	assert.Equal(t, 404, recorder.Code)
+
+	// Only GET requests are supported.
+	req = httptest.NewRequest("POST", "/api/v1/diagnoses/default/diagnosis1/events", nil)
+	recorder = httptest.NewRecorder()
+	ds.Handler(recorder, req)
+	assert.Equal(t, 405, recorder.Code)
+}
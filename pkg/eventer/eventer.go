@@ -82,6 +82,8 @@ type eventer struct {
 	cache cache.Cache
 	// eventChainCh is a channel for queuing Events to be processed by eventer.
 	eventChainCh chan corev1.Event
+	// rateLimiter limits the rate of diagnosis creations per event source.
+	rateLimiter *util.SourceRateLimiter
 	// eventerEnabled indicates whether eventer is enabled.
 	eventerEnabled bool
 }
@@ -93,6 +95,7 @@ func NewEventer(
 	cli client.Client,
 	cache cache.Cache,
 	eventChainCh chan corev1.Event,
+	rateLimiter *util.SourceRateLimiter,
 	eventerEnabled bool,
 ) Eventer {
 	metrics.Registry.MustRegister(
@@ -105,6 +108,7 @@ func NewEventer(
 		Context:        ctx,
 		Logger:         logger,
 		eventChainCh:   eventChainCh,
+		rateLimiter:    rateLimiter,
 		eventerEnabled: eventerEnabled,
 	}
 }
@@ -124,8 +128,22 @@ func (ev *eventer) Run(stopCh <-chan struct{}) {
 		select {
 		// Process events queuing in event channel.
 		case event := <-ev.eventChainCh:
+			util.UpdateEventChannelLength(ev.eventChainCh)
 			eventReceivedCount.Inc()
 
+			// Skip the event if the rate of diagnosis creations from its source is exceeded.
+			source := KubernetesEventGeneratedDiagnosisPrefix
+			if event.Source.Component != "" {
+				source = fmt.Sprintf("%s/%s", source, event.Source.Component)
+			}
+			if !ev.rateLimiter.Allow(source) {
+				ev.Info("throttling diagnosis creation for event", "event", client.ObjectKey{
+					Name:      event.Name,
+					Namespace: event.Namespace,
+				}, "source", source)
+				continue
+			}
+
 			triggers, err := ev.listTriggers()
 			if err != nil {
 				ev.Error(err, "failed to list Triggers")
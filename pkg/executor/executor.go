@@ -20,16 +20,25 @@ import (
 	"bytes"
 	"context"
 	"crypto/tls"
+	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io/ioutil"
+	"net"
 	"net/http"
+	"os"
+	"os/exec"
 	"path/filepath"
+	"regexp"
 	"strconv"
 	"strings"
+	"sync"
+	"syscall"
+	"text/template"
 	"time"
 
+	dockertypes "github.com/docker/docker/api/types"
 	dockerclient "github.com/docker/docker/client"
 	"github.com/go-logr/logr"
 	"github.com/prometheus/client_golang/prometheus"
@@ -41,6 +50,8 @@ import (
 	utilnet "k8s.io/apimachinery/pkg/util/net"
 	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/jsonpath"
+	"k8s.io/client-go/util/workqueue"
 	"sigs.k8s.io/controller-runtime/pkg/cache"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/metrics"
@@ -76,9 +87,24 @@ const (
 	ContainerTelemetryKey = "container"
 	// NodeTelemetryKey is the telemetry key of node.
 	NodeTelemetryKey = "node"
+	// NodeLabelTelemetryKeyPrefix is the telemetry key prefix of injected node labels.
+	NodeLabelTelemetryKeyPrefix = "node.label."
+	// NodeAnnotationTelemetryKeyPrefix is the telemetry key prefix of injected node annotations.
+	NodeAnnotationTelemetryKeyPrefix = "node.annotation."
 
 	// DefaultFunctionNamepace is the dafault namespace for k8s object created by function processor.
 	DefaultFunctionNamespace = "kubediag"
+
+	// DefaultDrainTimeout is the default maximum time to wait for running task syncs to
+	// complete on shutdown.
+	DefaultDrainTimeout = 30 * time.Second
+
+	// sensitiveValueMask is the replacement for values of sensitive keys in statuses.
+	sensitiveValueMask = "***"
+
+	// ScriptFilesDirectoryEnv is the environment variable to pass the directory of materialized
+	// script files to a script runner.
+	ScriptFilesDirectoryEnv = "KUBEDIAG_SCRIPT_FILES_DIR"
 )
 
 var (
@@ -106,26 +132,106 @@ var (
 			Help: "Counter of erroneous diagnosis syncs by executor",
 		},
 	)
-	executorOperationErrorCounter = prometheus.NewCounter(
+	// The operation counters are labeled by operation name and processor type. Label cardinality
+	// is bounded by the number of operations in the cluster.
+	executorOperationErrorCounter = prometheus.NewCounterVec(
 		prometheus.CounterOpts{
 			Name: "executor_operation_error_counter",
 			Help: "Counter of erroneous diagnosis syncs by operation",
 		},
+		[]string{"operation", "processor"},
 	)
-	executorOperationSuccessCounter = prometheus.NewCounter(
+	executorOperationSuccessCounter = prometheus.NewCounterVec(
 		prometheus.CounterOpts{
 			Name: "executor_operation_success_counter",
 			Help: "Counter of success diagnosis syncs by operation",
 		},
+		[]string{"operation", "processor"},
 	)
-	executorOperationFailCounter = prometheus.NewCounter(
+	executorOperationFailCounter = prometheus.NewCounterVec(
 		prometheus.CounterOpts{
 			Name: "executor_operation_fail_counter",
 			Help: "Counter of fail diagnosis syncs by operation",
 		},
+		[]string{"operation", "processor"},
 	)
 )
 
+// Reasons classifying processor failures recorded as task conditions.
+const (
+	// TaskFailureReasonTimeout means the processor did not respond within the operation timeout.
+	TaskFailureReasonTimeout = "Timeout"
+	// TaskFailureReasonUnreachable means a connection to the processor could not be established.
+	TaskFailureReasonUnreachable = "Unreachable"
+	// TaskFailureReasonBadResponse means the processor responded with an erroneous status or body.
+	TaskFailureReasonBadResponse = "BadResponse"
+	// TaskFailureReasonNonZeroExit means the script exited with a non zero exit code.
+	TaskFailureReasonNonZeroExit = "NonZeroExit"
+	// TaskFailureReasonCircuitOpen means the task was short-circuited without a processor
+	// attempt since the processor of the operation failed repeatedly.
+	TaskFailureReasonCircuitOpen = "CircuitOpen"
+	// TaskFailureReasonInvalidParameter means a task parameter did not conform to the type
+	// declared by the operation.
+	TaskFailureReasonInvalidParameter = "InvalidParameter"
+
+	// ResponseBodyResultKey is the result key under which a raw response body is stored.
+	ResponseBodyResultKey = "response.body"
+
+	// ReservedKeyNamespacePrefix is the prefix applied to reserved telemetry keys returned by a
+	// processor before the result is written to status.
+	ReservedKeyNamespacePrefix = "result."
+)
+
+// reservedTelemetryKeys contains the keys injected into request data by updateTaskContext.
+// A processor response containing a reserved key is namespaced under the result. prefix so
+// that the telemetry values are not clobbered.
+var reservedTelemetryKeys = []string{
+	TaskUIDTelemetryKey,
+	TaskNamespaceTelemetryKey,
+	TaskNameTelemetryKey,
+	DiagnosisUIDTelemetryKey,
+	DiagnosisNamespaceTelemetryKey,
+	DiagnosisNameTelemetryKey,
+	PodNamespaceTelemetryKey,
+	PodNameTelemetryKey,
+	ContainerTelemetryKey,
+	NodeTelemetryKey,
+}
+
+// errImageBuildInProgress indicates that the function image of an operation is still being built.
+var errImageBuildInProgress = errors.New("function image build in progress")
+
+// taskCancellationCheckInterval is the interval at which the parent diagnosis of an in-flight
+// task is checked to abort the processor call of an abandoned task.
+const taskCancellationCheckInterval = 10 * time.Second
+
+// functionImageBuildDeadline is the maximum time to wait for a function image build before the
+// task is failed.
+const functionImageBuildDeadline = 10 * time.Minute
+
+// Circuit breaker parameters for operations whose processor fails repeatedly.
+const (
+	// circuitBreakerFailureThreshold is the number of consecutive processor failures within
+	// the failure window after which the circuit opens.
+	circuitBreakerFailureThreshold = 5
+	// circuitBreakerFailureWindow is the maximum gap between failures counted as consecutive.
+	circuitBreakerFailureWindow = 5 * time.Minute
+	// circuitBreakerCooldown is the period during which tasks of an operation with an open
+	// circuit fail fast without a processor attempt.
+	circuitBreakerCooldown = time.Minute
+)
+
+// circuitBreaker tracks processor failures of an operation.
+type circuitBreaker struct {
+	// consecutiveFailures is the number of consecutive processor failures.
+	consecutiveFailures int
+	// lastFailureTime is the time of the latest processor failure.
+	lastFailureTime time.Time
+	// openUntil is the time until which tasks of the operation fail fast. It is zero if the
+	// circuit is closed.
+	openUntil time.Time
+}
+
 // TaskBackoff is the recommended backoff for a failure when syncing diagnosis.
 var TaskBackoff = wait.Backoff{
 	Steps:    4,
@@ -142,6 +248,9 @@ type Executor interface {
 	logr.Logger
 	// Run runs the Executor.
 	Run(<-chan struct{})
+	// Shutdown stops the Executor from accepting new tasks and waits up to the timeout for
+	// running task syncs to complete.
+	Shutdown(time.Duration) error
 }
 
 // executor runs the diagnosis pipeline by executing operations defined in diagnosis.
@@ -171,8 +280,61 @@ type executor struct {
 	port int
 	// dataRoot is root directory of persistent kubediag data.
 	dataRoot string
+	// functionNamespace is the namespace in which function pods are created.
+	functionNamespace string
+	// resultCompressionThresholdBytes is the size in bytes above which result values are stored
+	// gzip compressed and base64 encoded in statuses. Compression is disabled if not positive.
+	resultCompressionThresholdBytes int
 	// taskCh is a channel for queuing Tasks to be processed by executor.
 	taskCh chan diagnosisv1.Task
+	// queue rate limits and coalesces tasks to be synced. Failed syncs are retried with
+	// increasing delay.
+	queue workqueue.RateLimitingInterface
+	// drainWaitGroup tracks the worker so a graceful shutdown can wait for an in-flight task
+	// sync to complete.
+	drainWaitGroup sync.WaitGroup
+
+	// circuitBreakersMutex protects circuitBreakers.
+	circuitBreakersMutex sync.Mutex
+	// circuitBreakers tracks consecutive processor failures per operation so tasks of an
+	// operation whose processor is down fail fast instead of burning through retries.
+	circuitBreakers map[string]*circuitBreaker
+
+	// allowedScriptCommands contains regular expressions matched against the base names of
+	// commands invoked by script runner scripts. Scripts invoking commands not matched by any
+	// entry are rejected. Commands are not restricted if empty.
+	allowedScriptCommands []string
+
+	// singletonLocksMutex protects singletonLocks.
+	singletonLocksMutex sync.Mutex
+	// singletonLocks holds per operation locks serializing task syncs of singleton operations
+	// on the node.
+	singletonLocks map[string]*sync.Mutex
+
+	// imageBuildsMutex protects imageBuilds.
+	imageBuildsMutex sync.Mutex
+	// buildFunctionImage builds a docker image for a function processor.
+	buildFunctionImage func(dockerclient.Client, *diagnosisv1.Operation, string, *bytes.Buffer) error
+	// imageBuilds tracks start times of function image builds in progress to avoid concurrent
+	// builds of the same image.
+	imageBuilds map[string]time.Time
+}
+
+// NewProcessorTransport constructs the transport for sending http requests to processors. Keep-alives
+// are disabled unless keepAlives is set, in which case up to maxIdleConnsPerHost idle connections
+// are pooled per processor.
+func NewProcessorTransport(keepAlives bool, maxIdleConnsPerHost int, insecureSkipVerify bool) *http.Transport {
+	transport := utilnet.SetTransportDefaults(
+		&http.Transport{
+			TLSClientConfig:   &tls.Config{InsecureSkipVerify: insecureSkipVerify},
+			DisableKeepAlives: !keepAlives,
+			Proxy:             http.ProxyURL(nil),
+		})
+	if keepAlives && maxIdleConnsPerHost > 0 {
+		transport.MaxIdleConnsPerHost = maxIdleConnsPerHost
+	}
+
+	return transport
 }
 
 // NewExecutor creates a new executor.
@@ -188,8 +350,16 @@ func NewExecutor(
 	bindAddress string,
 	port int,
 	dataRoot string,
+	functionNamespace string,
+	resultCompressionThresholdBytes int,
+	allowedScriptCommands []string,
+	transport *http.Transport,
 	taskCh chan diagnosisv1.Task,
 ) Executor {
+	if functionNamespace == "" {
+		functionNamespace = DefaultFunctionNamespace
+	}
+
 	metrics.Registry.MustRegister(
 		executorSyncSuccessCount,
 		executorSyncSkipCount,
@@ -200,30 +370,33 @@ func NewExecutor(
 		executorOperationFailCounter,
 	)
 
-	transport := utilnet.SetTransportDefaults(
-		&http.Transport{
-			TLSClientConfig:   &tls.Config{InsecureSkipVerify: true},
-			DisableKeepAlives: true,
-			Proxy:             http.ProxyURL(nil),
-		})
-
 	return &executor{
-		Context:       ctx,
-		Logger:        logger,
-		client:        cli,
-		dockerClient:  dockerClient,
-		eventRecorder: eventRecorder,
-		scheme:        scheme,
-		cache:         cache,
-		nodeName:      nodeName,
-		transport:     transport,
-		bindAddress:   bindAddress,
-		port:          port,
-		dataRoot:      dataRoot,
-		taskCh:        taskCh,
+		Context:                         ctx,
+		Logger:                          logger,
+		client:                          cli,
+		dockerClient:                    dockerClient,
+		buildFunctionImage:              function.BuildFunctionImage,
+		eventRecorder:                   eventRecorder,
+		scheme:                          scheme,
+		cache:                           cache,
+		nodeName:                        nodeName,
+		transport:                       transport,
+		bindAddress:                     bindAddress,
+		port:                            port,
+		dataRoot:                        dataRoot,
+		functionNamespace:               functionNamespace,
+		resultCompressionThresholdBytes: resultCompressionThresholdBytes,
+		allowedScriptCommands:           allowedScriptCommands,
+		taskCh:                          taskCh,
+		queue:                           workqueue.NewRateLimitingQueue(newTaskRateLimiter()),
 	}
 }
 
+// newTaskRateLimiter returns the rate limiter for retrying failed task syncs.
+func newTaskRateLimiter() workqueue.RateLimiter {
+	return workqueue.NewItemExponentialFailureRateLimiter(TaskBackoff.Duration, 8*time.Minute)
+}
+
 // Run runs the executor.
 func (ex *executor) Run(stopCh <-chan struct{}) {
 	// Wait for all caches to sync before processing.
@@ -231,80 +404,132 @@ func (ex *executor) Run(stopCh <-chan struct{}) {
 		return
 	}
 
-	for {
-		select {
-		// Process diagnoses queuing in executor channel.
-		case task := <-ex.taskCh:
-			err := ex.client.Get(ex, client.ObjectKey{
-				Name:      task.Name,
-				Namespace: task.Namespace,
-			}, &task)
-			if err != nil {
-				if apierrors.IsNotFound(err) {
-					continue
-				}
-				ex.addTaskToExecutorQueue(task)
-				continue
+	// Requeue tasks which were in progress before the executor started. Tasks in Running phase are
+	// not delivered on task channel again after an agent restart.
+	ex.resumeTasks()
+
+	// Shut down the work queue on the stop signal to unblock the worker.
+	go func() {
+		<-stopCh
+		ex.queue.ShutDown()
+	}()
+
+	// Pump tasks from the task channel into the rate limiting work queue. Duplicate enqueues
+	// of the same task coalesce into a single queue entry.
+	go func() {
+		for {
+			select {
+			case task := <-ex.taskCh:
+				util.UpdateTaskChannelLength(ex.taskCh)
+				ex.queue.Add(client.ObjectKey{
+					Name:      task.Name,
+					Namespace: task.Namespace,
+				})
+			case <-stopCh:
+				return
 			}
+		}
+	}()
 
-			// Only process task in DiagnosisRunning phase.
-			if task.Status.Phase != diagnosisv1.TaskRunning {
-				continue
-			}
+	ex.runWorker()
+}
 
-			// Only process diagnosis on designated node.
-			if util.IsTaskNodeNameMatched(task, ex.nodeName) {
-				go func() {
-					task, err := ex.SyncTaskWithRetry(TaskBackoff, task)
-					if err != nil {
-						ex.Error(err, "failed to sync Task", "task", client.ObjectKey{
-							Name:      task.Name,
-							Namespace: task.Namespace,
-						})
-						executorSyncErrorCount.Inc()
-						return
-					}
-
-					ex.Info("syncing Task successfully", "task", client.ObjectKey{
-						Name:      task.Name,
-						Namespace: task.Namespace,
-					})
-				}()
-			}
-		// Stop executor on stop signal.
-		case <-stopCh:
-			return
-		}
+// runWorker processes items on the work queue until it is shut down.
+func (ex *executor) runWorker() {
+	ex.drainWaitGroup.Add(1)
+	defer ex.drainWaitGroup.Done()
+
+	for ex.processNextWorkItem() {
 	}
 }
 
-// SyncTaskWithRetry syncs diagnoses with backoff.
-func (ex *executor) SyncTaskWithRetry(backoff wait.Backoff, task diagnosisv1.Task) (diagnosisv1.Task, error) {
-	err := errors.New("timed out waiting for the condition")
-	for backoff.Steps > 0 {
-		if task, err = ex.syncTask(task); err == nil {
-			return task, nil
+// Shutdown stops the executor from accepting new tasks and waits up to the timeout for running
+// task syncs to complete. It returns an error if the timeout expires before the drain finishes.
+func (ex *executor) Shutdown(timeout time.Duration) error {
+	ex.queue.ShutDown()
+
+	done := make(chan struct{})
+	go func() {
+		ex.drainWaitGroup.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-time.After(timeout):
+		return fmt.Errorf("timed out waiting %s for running task syncs to complete", timeout)
+	}
+}
+
+// processNextWorkItem syncs the next task on the work queue. A failed sync is retried with
+// increasing delay until the backoff steps are exhausted, then the task is marked as failed.
+// It returns false when the work queue has been shut down.
+func (ex *executor) processNextWorkItem() bool {
+	item, shutdown := ex.queue.Get()
+	if shutdown {
+		return false
+	}
+	defer ex.queue.Done(item)
+
+	key := item.(client.ObjectKey)
+	var task diagnosisv1.Task
+	if err := ex.client.Get(ex, key, &task); err != nil {
+		if apierrors.IsNotFound(err) {
+			ex.queue.Forget(item)
+			return true
 		}
-		if backoff.Steps == 1 {
-			break
+		ex.queue.AddRateLimited(item)
+		return true
+	}
+
+	// Only process task in TaskRunning phase on the designated node.
+	if task.Status.Phase != diagnosisv1.TaskRunning || !util.IsTaskNodeNameMatched(task, ex.nodeName) {
+		ex.queue.Forget(item)
+		return true
+	}
+
+	task, err := ex.syncTask(task)
+	if err != nil {
+		if ex.queue.NumRequeues(item) < TaskBackoff.Steps-1 {
+			ex.queue.AddRateLimited(item)
+			return true
 		}
-		time.Sleep(backoff.Step())
+
+		ex.queue.Forget(item)
+		ex.failTask(task, err)
+		ex.Error(err, "failed to sync Task", "task", key)
+		executorSyncErrorCount.Inc()
+		return true
 	}
 
-	// Set phase to failed.
+	ex.queue.Forget(item)
+	ex.Info("syncing Task successfully", "task", key)
+	return true
+}
+
+// failTask sets the phase of a task to failed after all sync retries have been exhausted.
+func (ex *executor) failTask(task diagnosisv1.Task, err error) {
 	ex.eventRecorder.Eventf(&task, corev1.EventTypeWarning, "DiagnosisFailed", "Failed to run task %s/%s since sync task failed", task.Namespace, task.Name)
 	task.Status.Phase = diagnosisv1.TaskFailed
+	reason := classifyProcessorError(err)
+	if reason == "" {
+		reason = "SyncTaskFailed"
+	}
 	util.UpdateTaskCondition(&task.Status, &diagnosisv1.TaskCondition{
 		Type:    diagnosisv1.TaskAccepted,
 		Status:  corev1.ConditionTrue,
-		Reason:  "SyncTaskFailed",
+		Reason:  reason,
 		Message: err.Error(),
 	})
 	if err := ex.client.Status().Update(ex, &task); err != nil {
-		return task, fmt.Errorf("unable to update Diagnosis: %s", err)
+		ex.Error(err, "unable to update Task", "task", client.ObjectKey{
+			Name:      task.Name,
+			Namespace: task.Namespace,
+		})
+		return
 	}
 	executorSyncFailCount.Inc()
-	return task, err
 }
 
 // syncTask syncs tasks.
@@ -345,6 +570,54 @@ func (ex *executor) syncTask(task diagnosisv1.Task) (diagnosisv1.Task, error) {
 		return task, err
 	}
 
+	// Fail the task fast without a processor attempt if the circuit of the operation is open
+	// due to repeated processor failures.
+	if ex.circuitOpen(operation.Name) {
+		ex.Info("short-circuiting task since processor of operation failed repeatedly", "task", client.ObjectKey{
+			Name:      task.Name,
+			Namespace: task.Namespace,
+		}, "operation", operation.Name)
+		ex.eventRecorder.Eventf(&task, corev1.EventTypeWarning, "CircuitOpen", "Short-circuiting task %s/%s since processor of operation %s failed repeatedly", task.Namespace, task.Name, operation.Name)
+		util.UpdateTaskCondition(&task.Status, &diagnosisv1.TaskCondition{
+			Type:    diagnosisv1.TaskIncomplete,
+			Status:  corev1.ConditionTrue,
+			Reason:  TaskFailureReasonCircuitOpen,
+			Message: fmt.Sprintf("Processor of operation %s failed repeatedly, backing off until cooldown elapses", operation.Name),
+		})
+		task.Status.Phase = diagnosisv1.TaskFailed
+		if err := ex.client.Status().Update(ex, &task); err != nil {
+			return task, fmt.Errorf("unable to update Task: %s", err)
+		}
+		executorSyncFailCount.Inc()
+		return task, nil
+	}
+
+	// Fail the task without a processor attempt if a parameter does not conform to the type
+	// declared by the operation.
+	if err := validateParameterTypes(task.Spec.Parameters, operation.Spec.ParameterTypes); err != nil {
+		ex.eventRecorder.Eventf(&task, corev1.EventTypeWarning, "InvalidParameter", "Failed to run task %s/%s: %v", task.Namespace, task.Name, err)
+		util.UpdateTaskCondition(&task.Status, &diagnosisv1.TaskCondition{
+			Type:    diagnosisv1.TaskIncomplete,
+			Status:  corev1.ConditionTrue,
+			Reason:  TaskFailureReasonInvalidParameter,
+			Message: err.Error(),
+		})
+		task.Status.Phase = diagnosisv1.TaskFailed
+		if err := ex.client.Status().Update(ex, &task); err != nil {
+			return task, fmt.Errorf("unable to update Task: %s", err)
+		}
+		executorSyncFailCount.Inc()
+		return task, nil
+	}
+
+	// Serialize task syncs of a singleton operation with a node-local lock so only one of its
+	// tasks runs at a time on the node.
+	if operation.Spec.Concurrency == diagnosisv1.SingletonConcurrency {
+		lock := ex.singletonLock(operation.Name)
+		lock.Lock()
+		defer lock.Unlock()
+	}
+
 	// Construct request data for current operation by adding contexts and operation results.
 	// The request data is a map[string]string which contains key value pairs.
 	data := make(map[string]string)
@@ -353,33 +626,63 @@ func (ex *executor) syncTask(task diagnosisv1.Task) (diagnosisv1.Task, error) {
 	}
 	updateTaskContext(data, task)
 
+	// Inject selected node labels and annotations into the request data.
+	if err := ex.injectNodeTelemetry(data, operation, task); err != nil {
+		return task, err
+	}
+
 	ex.Info("running operation", "task", client.ObjectKey{
 		Name:      task.Name,
 		Namespace: task.Namespace,
 	}, "operation", operation.Name)
 
+	// Cancel the processor call once the task is abandoned, e.g. when the parent diagnosis
+	// fails on a path timeout or is deleted.
+	ctx, cancel := context.WithCancel(ex)
+	defer cancel()
+	go ex.watchParentDiagnosis(ctx, cancel, task)
+
 	// Execute the operation by sending http request to the processor or running predefined script.
+	processor := processorType(operation)
 	var succeeded bool
 	var result map[string]string
 	if operation.Spec.Processor.HTTPServer != nil {
-		succeeded, result, err = ex.doHTTPRequestWithContext(operation, data)
+		succeeded, result, err = ex.doHTTPRequestWithContext(ctx, operation, data)
 		if err != nil {
-			executorOperationErrorCounter.Inc()
+			ex.recordProcessorFailure(operation.Name)
+			executorOperationErrorCounter.WithLabelValues(operation.Name, processor).Inc()
 			return task, err
 		}
 	} else if operation.Spec.Processor.ScriptRunner != nil {
-		succeeded, result, err = ex.runScriptWithContext(operation, data)
+		succeeded, result, err = ex.runScriptWithContext(ctx, operation, data)
 		if err != nil {
-			executorOperationErrorCounter.Inc()
+			ex.recordProcessorFailure(operation.Name)
+			executorOperationErrorCounter.WithLabelValues(operation.Name, processor).Inc()
 			return task, err
 		}
 	} else if operation.Spec.Processor.Function != nil {
-		succeeded, result, err = ex.runFunctionWithContext(operation, data)
+		succeeded, result, err = ex.runFunctionWithContext(ctx, operation, data)
 		if err != nil {
-			executorOperationErrorCounter.Inc()
+			// Leave the task pending while the function image is building. The task is accepted
+			// and queued again by the task controller instead of being failed.
+			if errors.Is(err, errImageBuildInProgress) {
+				ex.Info("function image build in progress, leaving task pending", "task", client.ObjectKey{
+					Name:      task.Name,
+					Namespace: task.Namespace,
+				}, "operation", operation.Name)
+				ex.eventRecorder.Eventf(&task, corev1.EventTypeNormal, "WaitingForImageBuild", "Waiting for function image build of operation %s", operation.Name)
+				task.Status.Phase = diagnosisv1.TaskPending
+				if err := ex.client.Status().Update(ex, &task); err != nil {
+					return task, fmt.Errorf("unable to update Task: %s", err)
+				}
+				return task, nil
+			}
+			ex.recordProcessorFailure(operation.Name)
+			executorOperationErrorCounter.WithLabelValues(operation.Name, processor).Inc()
 			return task, err
 		}
 	}
+	ex.recordProcessorSuccess(operation.Name)
 
 	// Update the operation result into task status.
 	if succeeded {
@@ -388,13 +691,19 @@ func (ex *executor) syncTask(task diagnosisv1.Task) (diagnosisv1.Task, error) {
 			Namespace: task.Namespace,
 		}, "operation", operation.Name)
 		ex.eventRecorder.Eventf(&task, corev1.EventTypeNormal, "OperationSucceeded", "Operation %s executed successfully", operation.Name)
-		executorOperationSuccessCounter.Inc()
+		executorOperationSuccessCounter.WithLabelValues(operation.Name, processor).Inc()
 
-		// Set operation result according to response from operaton processor.
+		// Set operation result according to response from operaton processor. Values of sensitive
+		// keys are redacted and large values are compressed before being written to status.
 		if task.Status.Results == nil {
 			task.Status.Results = make(map[string]string)
 		}
-		for key, value := range result {
+		result = ex.namespaceReservedTelemetryKeys(result, operation)
+		results, err := util.CompressResult(redactSensitiveKeys(result, operation.Spec.SensitiveKeys), ex.resultCompressionThresholdBytes)
+		if err != nil {
+			return task, fmt.Errorf("unable to compress results: %s", err)
+		}
+		for key, value := range results {
 			task.Status.Results[key] = value
 		}
 
@@ -410,8 +719,20 @@ func (ex *executor) syncTask(task diagnosisv1.Task) (diagnosisv1.Task, error) {
 			Namespace: task.Namespace,
 		}, "operation", operation.Name)
 		ex.eventRecorder.Eventf(&task, corev1.EventTypeWarning, "OperationFailed", "Failed to execute operation %s", operation.Name)
-		executorOperationFailCounter.Inc()
+		executorOperationFailCounter.WithLabelValues(operation.Name, processor).Inc()
 
+		// A failure without an execution error means the processor responded with an erroneous
+		// status or body, or the script exited with a non zero exit code.
+		reason := TaskFailureReasonBadResponse
+		if operation.Spec.Processor.ScriptRunner != nil {
+			reason = TaskFailureReasonNonZeroExit
+		}
+		util.UpdateTaskCondition(&task.Status, &diagnosisv1.TaskCondition{
+			Type:    diagnosisv1.TaskIncomplete,
+			Status:  corev1.ConditionTrue,
+			Reason:  reason,
+			Message: fmt.Sprintf("Operation %s failed to execute", operation.Name),
+		})
 		task.Status.Phase = diagnosisv1.TaskFailed
 		if err := ex.client.Status().Update(ex, &task); err != nil {
 			return task, fmt.Errorf("unable to update Diagnosis: %s", err)
@@ -421,9 +742,358 @@ func (ex *executor) syncTask(task diagnosisv1.Task) (diagnosisv1.Task, error) {
 	}
 }
 
+// namespaceReservedTelemetryKeys moves reserved telemetry keys in a processor result under the
+// result. prefix so the telemetry values injected by the executor are preserved.
+func (ex *executor) namespaceReservedTelemetryKeys(result map[string]string, operation diagnosisv1.Operation) map[string]string {
+	for _, key := range reservedTelemetryKeys {
+		value, ok := result[key]
+		if !ok {
+			continue
+		}
+		ex.Info("namespacing reserved telemetry key in processor result", "operation", operation.Name, "key", key)
+		delete(result, key)
+		result[ReservedKeyNamespacePrefix+key] = value
+	}
+
+	return result
+}
+
+// imageBuildStartTime returns the start time of a function image build in progress.
+func (ex *executor) imageBuildStartTime(image string) (time.Time, bool) {
+	ex.imageBuildsMutex.Lock()
+	defer ex.imageBuildsMutex.Unlock()
+
+	started, building := ex.imageBuilds[image]
+	return started, building
+}
+
+// startImageBuild marks a function image build as in progress. It returns false if a build of
+// the image has already been started.
+func (ex *executor) startImageBuild(image string) bool {
+	ex.imageBuildsMutex.Lock()
+	defer ex.imageBuildsMutex.Unlock()
+
+	if _, building := ex.imageBuilds[image]; building {
+		return false
+	}
+	if ex.imageBuilds == nil {
+		ex.imageBuilds = make(map[string]time.Time)
+	}
+	ex.imageBuilds[image] = time.Now()
+	return true
+}
+
+// launchImageBuild builds a function image asynchronously. It returns false without starting
+// a build if a build of the image is already in progress, so only one build proceeds per image.
+func (ex *executor) launchImageBuild(operation diagnosisv1.Operation, image string) bool {
+	if !ex.startImageBuild(image) {
+		return false
+	}
+	go func() {
+		defer ex.finishImageBuild(image)
+		// imageBuildMessage stores information returned by docker server after building an image.
+		imageBuildMessage := new(bytes.Buffer)
+		err := ex.buildFunctionImage(ex.dockerClient, &operation, string(operation.Spec.Processor.Function.Runtime), imageBuildMessage)
+		if err != nil {
+			ex.Error(err, "failed to build docker image for function processor")
+			return
+		}
+		ex.Info(imageBuildMessage.String())
+	}()
+	return true
+}
+
+// shellKeywords contains shell keywords and builtins skipped by the static script command
+// check.
+var shellKeywords = map[string]struct{}{
+	"if": {}, "then": {}, "elif": {}, "else": {}, "fi": {}, "for": {}, "in": {}, "do": {},
+	"done": {}, "while": {}, "until": {}, "case": {}, "esac": {}, "function": {}, "exit": {},
+	"return": {}, "break": {}, "continue": {}, "cd": {}, "export": {}, "echo": {}, "printf": {},
+	"set": {}, "shift": {}, "read": {}, "local": {}, "true": {}, "false": {}, "test": {},
+}
+
+// commandSegmentSeparators splits shell command lines at pipes, command separators and command
+// substitutions so each segment starts at a command position.
+var commandSegmentSeparators = regexp.MustCompile("[;&|]+|\\$\\(|`")
+
+// disallowedScriptCommands returns base names of commands invoked by the script which are not
+// matched by any of the allowed command patterns. The analysis is a best-effort tokenization
+// of the script source: the first token of each command segment is checked against the
+// patterns, skipping shell keywords, builtins and variable assignments. No commands are
+// returned if the allowlist is empty.
+func disallowedScriptCommands(script string, allowedCommands []string) []string {
+	if len(allowedCommands) == 0 {
+		return nil
+	}
+
+	patterns := make([]*regexp.Regexp, 0, len(allowedCommands))
+	for _, allowedCommand := range allowedCommands {
+		// An entry which is not a valid regular expression allows nothing.
+		pattern, err := regexp.Compile("^(" + allowedCommand + ")$")
+		if err != nil {
+			continue
+		}
+		patterns = append(patterns, pattern)
+	}
+
+	disallowed := make([]string, 0)
+	seen := make(map[string]struct{})
+	for _, line := range strings.Split(script, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		for _, segment := range commandSegmentSeparators.Split(line, -1) {
+			command := ""
+			for _, field := range strings.Fields(segment) {
+				// Skip environment variable assignments preceding the command.
+				if strings.Contains(field, "=") {
+					continue
+				}
+				command = field
+				break
+			}
+			if command == "" {
+				continue
+			}
+			base := filepath.Base(command)
+			// Skip shell keywords, builtins and tokens which are not command names.
+			if _, ok := shellKeywords[base]; ok {
+				continue
+			}
+			if strings.HasPrefix(base, "$") || strings.HasPrefix(base, "\"") || strings.HasPrefix(base, "'") ||
+				strings.HasPrefix(base, "[") || strings.HasPrefix(base, "(") || strings.HasPrefix(base, ")") {
+				continue
+			}
+			allowed := false
+			for _, pattern := range patterns {
+				if pattern.MatchString(base) {
+					allowed = true
+					break
+				}
+			}
+			if !allowed {
+				if _, ok := seen[base]; !ok {
+					seen[base] = struct{}{}
+					disallowed = append(disallowed, base)
+				}
+			}
+		}
+	}
+
+	return disallowed
+}
+
+// watchParentDiagnosis cancels the processor call of the task once its parent diagnosis has
+// failed, e.g. on a path timeout, or has been deleted. It returns when the context is done.
+func (ex *executor) watchParentDiagnosis(ctx context.Context, cancel context.CancelFunc, task diagnosisv1.Task) {
+	var diagnosisName string
+	for _, ownerReference := range task.OwnerReferences {
+		if ownerReference.Kind == "Diagnosis" {
+			diagnosisName = ownerReference.Name
+		}
+	}
+	if diagnosisName == "" {
+		return
+	}
+
+	ticker := time.NewTicker(taskCancellationCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			var diagnosis diagnosisv1.Diagnosis
+			if err := ex.client.Get(ex, client.ObjectKey{Namespace: task.Namespace, Name: diagnosisName}, &diagnosis); err != nil {
+				if apierrors.IsNotFound(err) {
+					cancel()
+					return
+				}
+				continue
+			}
+			if diagnosis.Status.Phase == diagnosisv1.DiagnosisFailed {
+				cancel()
+				return
+			}
+		}
+	}
+}
+
+// validateParameterTypes validates parameter values against the types declared by the
+// operation. Parameters of undeclared keys and declared keys absent from the parameters are
+// not validated.
+func validateParameterTypes(parameters map[string]string, parameterTypes map[string]diagnosisv1.ParameterType) error {
+	for key, parameterType := range parameterTypes {
+		value, ok := parameters[key]
+		if !ok {
+			continue
+		}
+		switch parameterType {
+		case diagnosisv1.IntegerParameterType:
+			if _, err := strconv.Atoi(value); err != nil {
+				return fmt.Errorf("parameter %s must be an integer, got %q", key, value)
+			}
+		case diagnosisv1.BooleanParameterType:
+			if _, err := strconv.ParseBool(value); err != nil {
+				return fmt.Errorf("parameter %s must be a boolean, got %q", key, value)
+			}
+		}
+	}
+
+	return nil
+}
+
+// singletonLock returns the node-local lock of a singleton operation, creating it on first use.
+func (ex *executor) singletonLock(operationName string) *sync.Mutex {
+	ex.singletonLocksMutex.Lock()
+	defer ex.singletonLocksMutex.Unlock()
+
+	if ex.singletonLocks == nil {
+		ex.singletonLocks = make(map[string]*sync.Mutex)
+	}
+	lock, ok := ex.singletonLocks[operationName]
+	if !ok {
+		lock = new(sync.Mutex)
+		ex.singletonLocks[operationName] = lock
+	}
+	return lock
+}
+
+// circuitOpen returns whether the circuit of the operation is open, i.e. tasks of the
+// operation fail fast without a processor attempt until the cooldown elapses.
+func (ex *executor) circuitOpen(operationName string) bool {
+	ex.circuitBreakersMutex.Lock()
+	defer ex.circuitBreakersMutex.Unlock()
+
+	breaker, ok := ex.circuitBreakers[operationName]
+	if !ok {
+		return false
+	}
+	return time.Now().Before(breaker.openUntil)
+}
+
+// recordProcessorFailure counts a processor failure of the operation. The circuit opens for
+// the cooldown period once the failure threshold is reached within the failure window. The
+// failure count is not reset on opening so another failure after the cooldown reopens the
+// circuit immediately.
+func (ex *executor) recordProcessorFailure(operationName string) {
+	ex.circuitBreakersMutex.Lock()
+	defer ex.circuitBreakersMutex.Unlock()
+
+	if ex.circuitBreakers == nil {
+		ex.circuitBreakers = make(map[string]*circuitBreaker)
+	}
+	breaker, ok := ex.circuitBreakers[operationName]
+	if !ok {
+		breaker = new(circuitBreaker)
+		ex.circuitBreakers[operationName] = breaker
+	}
+
+	now := time.Now()
+	if now.Sub(breaker.lastFailureTime) > circuitBreakerFailureWindow {
+		breaker.consecutiveFailures = 0
+	}
+	breaker.consecutiveFailures++
+	breaker.lastFailureTime = now
+	if breaker.consecutiveFailures >= circuitBreakerFailureThreshold {
+		breaker.openUntil = now.Add(circuitBreakerCooldown)
+	}
+}
+
+// recordProcessorSuccess closes the circuit of the operation after a completed processor call.
+func (ex *executor) recordProcessorSuccess(operationName string) {
+	ex.circuitBreakersMutex.Lock()
+	defer ex.circuitBreakersMutex.Unlock()
+
+	delete(ex.circuitBreakers, operationName)
+}
+
+// finishImageBuild marks a function image build as finished.
+func (ex *executor) finishImageBuild(image string) {
+	ex.imageBuildsMutex.Lock()
+	defer ex.imageBuildsMutex.Unlock()
+
+	delete(ex.imageBuilds, image)
+}
+
+// classifyProcessorError classifies an error returned by the HTTP or script execution path.
+// It returns an empty string if the error does not match any known failure class.
+func classifyProcessorError(err error) string {
+	if err == nil {
+		return ""
+	}
+
+	var exitError *exec.ExitError
+	if errors.As(err, &exitError) {
+		return TaskFailureReasonNonZeroExit
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return TaskFailureReasonTimeout
+	}
+	var netError net.Error
+	if errors.As(err, &netError) && netError.Timeout() {
+		return TaskFailureReasonTimeout
+	}
+	if errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.EHOSTUNREACH) || errors.Is(err, syscall.ENETUNREACH) {
+		return TaskFailureReasonUnreachable
+	}
+	var dnsError *net.DNSError
+	if errors.As(err, &dnsError) {
+		return TaskFailureReasonUnreachable
+	}
+	var opError *net.OpError
+	if errors.As(err, &opError) {
+		return TaskFailureReasonUnreachable
+	}
+
+	// Errors wrapped with fmt.Errorf lose their type and are classified by message.
+	message := err.Error()
+	if strings.Contains(message, "context deadline exceeded") || strings.Contains(message, "Client.Timeout exceeded") {
+		return TaskFailureReasonTimeout
+	}
+	if strings.Contains(message, "connection refused") {
+		return TaskFailureReasonUnreachable
+	}
+
+	return ""
+}
+
+// processorType returns the type of the processor specified in the operation.
+func processorType(operation diagnosisv1.Operation) string {
+	if operation.Spec.Processor.HTTPServer != nil {
+		return "httpServer"
+	} else if operation.Spec.Processor.ScriptRunner != nil {
+		return "scriptRunner"
+	} else if operation.Spec.Processor.Function != nil {
+		return "function"
+	}
+
+	return "unknown"
+}
+
+// redactSensitiveKeys returns a copy of the result with values of sensitive keys replaced by a mask.
+func redactSensitiveKeys(result map[string]string, sensitiveKeys []string) map[string]string {
+	if len(sensitiveKeys) == 0 {
+		return result
+	}
+
+	redacted := make(map[string]string, len(result))
+	for key, value := range result {
+		redacted[key] = value
+	}
+	for _, key := range sensitiveKeys {
+		if _, ok := redacted[key]; ok {
+			redacted[key] = sensitiveValueMask
+		}
+	}
+
+	return redacted
+}
+
 // doHTTPRequestWithContext sends a http request to the operation processor with payload.
 // It returns a bool, a map and an error as results.
-func (ex *executor) doHTTPRequestWithContext(operation diagnosisv1.Operation, data map[string]string) (bool, map[string]string, error) {
+func (ex *executor) doHTTPRequestWithContext(ctx context.Context, operation diagnosisv1.Operation, data map[string]string) (bool, map[string]string, error) {
 	if operation.Spec.Processor.HTTPServer == nil {
 		return false, nil, fmt.Errorf("http server not specified")
 	}
@@ -442,7 +1112,10 @@ func (ex *executor) doHTTPRequestWithContext(operation diagnosisv1.Operation, da
 	} else {
 		port = int32(ex.port)
 	}
-	path := *operation.Spec.Processor.HTTPServer.Path
+	path, err := expandFieldTemplate(*operation.Spec.Processor.HTTPServer.Path, data)
+	if err != nil {
+		return false, nil, err
+	}
 	scheme := strings.ToLower(string(*operation.Spec.Processor.HTTPServer.Scheme))
 	url := util.FormatURL(scheme, host, strconv.Itoa(int(port)), path)
 	timeout := time.Duration(*operation.Spec.Processor.TimeoutSeconds) * time.Second
@@ -460,6 +1133,16 @@ func (ex *executor) doHTTPRequestWithContext(operation diagnosisv1.Operation, da
 	if err != nil {
 		return false, nil, err
 	}
+	req = req.WithContext(ctx)
+
+	// Inject the credential from the referenced secret into the authorization header.
+	if operation.Spec.Processor.HTTPServer.AuthSecretRef != nil {
+		authorization, err := ex.resolveAuthorization(*operation.Spec.Processor.HTTPServer.AuthSecretRef)
+		if err != nil {
+			return false, nil, err
+		}
+		req.Header.Set("Authorization", authorization)
+	}
 
 	// Send the http request to operation processor.
 	res, err := cli.Do(req)
@@ -478,41 +1161,238 @@ func (ex *executor) doHTTPRequestWithContext(operation diagnosisv1.Operation, da
 		return false, nil, fmt.Errorf("response body size %d exceeds max data size %d", len(body), MaxDataSize)
 	}
 
-	if res.StatusCode != http.StatusOK {
+	if !isSuccessStatusCode(res.StatusCode, operation.Spec.Processor.HTTPServer.SuccessStatusCodes) {
 		ex.Info("http response with erroneous status", "status", res.Status, "response", string(body))
 		return false, nil, nil
 	}
 
+	// Extract result values from an arbitrary json response if jsonpath templates are specified.
+	if len(operation.Spec.Processor.HTTPServer.ResultJSONPaths) > 0 {
+		result, err := extractJSONPathResults(operation.Spec.Processor.HTTPServer.ResultJSONPaths, body)
+		if err != nil {
+			ex.Error(err, "failed to extract jsonpath results", "response", string(body))
+			// If response is successful but results could not be extracted, we think this processor is finished but failed and will not return error
+			return false, nil, nil
+		}
+		return true, result, nil
+	}
+
+	return ex.parseProcessorResponse(body, operation.Spec.Processor.HTTPServer.ResponseFormat)
+}
+
+// isSuccessStatusCode reports whether the http status code of a processor response indicates
+// success. Status code 200 is always treated as success.
+func isSuccessStatusCode(statusCode int, successStatusCodes []int32) bool {
+	if statusCode == http.StatusOK {
+		return true
+	}
+	for _, code := range successStatusCodes {
+		if statusCode == int(code) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// parseProcessorResponse parses a successful processor response body according to the response
+// format.
+func (ex *executor) parseProcessorResponse(body []byte, format diagnosisv1.ResponseFormat) (bool, map[string]string, error) {
+	if format == diagnosisv1.RawResponseFormat {
+		return true, map[string]string{ResponseBodyResultKey: string(body)}, nil
+	}
+
 	var result map[string]string
-	err = json.Unmarshal(body, &result)
-	if err != nil {
+	if err := json.Unmarshal(body, &result); err != nil {
 		ex.Error(err, "failed to unmarshal response body", "response", string(body))
-		// If response code is 200 but body is not a string-map, we think this processor is finished but failed and will not return error
+		// If response is successful but body is not a string-map, we think this processor is finished but failed and will not return error
 		return false, nil, nil
 	}
 
 	return true, result, nil
 }
 
+// extractJSONPathResults extracts values from a json response body into a flat result map
+// according to the provided result key to jsonpath template mapping.
+func extractJSONPathResults(paths map[string]string, body []byte) (map[string]string, error) {
+	var document interface{}
+	if err := json.Unmarshal(body, &document); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal response body: %s", err)
+	}
+
+	result := make(map[string]string)
+	for key, path := range paths {
+		parser := jsonpath.New(key)
+		if err := parser.Parse(path); err != nil {
+			return nil, fmt.Errorf("invalid jsonpath %s of result key %s: %s", path, key, err)
+		}
+		buffer := new(bytes.Buffer)
+		if err := parser.Execute(buffer, document); err != nil {
+			return nil, fmt.Errorf("failed to execute jsonpath %s of result key %s: %s", path, key, err)
+		}
+		result[key] = buffer.String()
+	}
+
+	return result, nil
+}
+
+// expandFieldTemplate expands go template placeholders in a processor field with the request
+// data, e.g. a http path of /profile/{{ .pod.name }}. Dotted data keys are exposed as nested
+// fields. The field is returned unchanged if it contains no placeholders, and expansion fails
+// if a placeholder references a missing key.
+func expandFieldTemplate(field string, data map[string]string) (string, error) {
+	if !strings.Contains(field, "{{") {
+		return field, nil
+	}
+
+	parsed, err := template.New("field").Option("missingkey=error").Parse(field)
+	if err != nil {
+		return "", fmt.Errorf("invalid template in processor field %s: %s", field, err)
+	}
+	buffer := new(bytes.Buffer)
+	if err := parsed.Execute(buffer, nestedTemplateData(data)); err != nil {
+		return "", fmt.Errorf("failed to expand template in processor field %s: %s", field, err)
+	}
+
+	return buffer.String(), nil
+}
+
+// nestedTemplateData converts flat dotted request data keys into nested maps so that templates
+// can reference them as fields, e.g. the pod.name key as {{ .pod.name }}. A nested map takes
+// precedence over a plain value on key conflicts.
+func nestedTemplateData(data map[string]string) map[string]interface{} {
+	root := make(map[string]interface{})
+	for key, value := range data {
+		parts := strings.Split(key, ".")
+		node := root
+		for _, part := range parts[:len(parts)-1] {
+			child, ok := node[part].(map[string]interface{})
+			if !ok {
+				child = make(map[string]interface{})
+				node[part] = child
+			}
+			node = child
+		}
+		if _, ok := node[parts[len(parts)-1]].(map[string]interface{}); !ok {
+			node[parts[len(parts)-1]] = value
+		}
+	}
+
+	return root
+}
+
+// resolveAuthorization resolves the authorization header value from the referenced secret.
+func (ex *executor) resolveAuthorization(secretRef diagnosisv1.NamespacedName) (string, error) {
+	var secret corev1.Secret
+	if err := ex.cache.Get(ex, client.ObjectKey{
+		Name:      secretRef.Name,
+		Namespace: secretRef.Namespace,
+	}, &secret); err != nil {
+		return "", fmt.Errorf("unable to fetch Secret: %s", err)
+	}
+
+	if token, ok := secret.Data["token"]; ok {
+		return "Bearer " + string(token), nil
+	}
+	username, usernameOK := secret.Data["username"]
+	password, passwordOK := secret.Data["password"]
+	if usernameOK && passwordOK {
+		return "Basic " + base64.StdEncoding.EncodeToString([]byte(string(username)+":"+string(password))), nil
+	}
+
+	return "", fmt.Errorf("secret %s/%s must contain a token key or username and password keys", secretRef.Namespace, secretRef.Name)
+}
+
 // runScriptWithContext runs a script with the arguments provided by context.
 // It returns a bool, a map and an error as results.
-func (ex *executor) runScriptWithContext(operation diagnosisv1.Operation, data map[string]string) (bool, map[string]string, error) {
+func (ex *executor) runScriptWithContext(ctx context.Context, operation diagnosisv1.Operation, data map[string]string) (bool, map[string]string, error) {
 	if operation.Spec.Processor.ScriptRunner == nil {
 		return false, nil, fmt.Errorf("script runner not specified")
 	}
 
+	// Reject the script if it invokes a command not on the allowlist of the node. The check is
+	// a best-effort static analysis of the script source and does not replace runtime
+	// sandboxing such as a seccomp profile on the agent.
+	if disallowed := disallowedScriptCommands(operation.Spec.Processor.ScriptRunner.Script, ex.allowedScriptCommands); len(disallowed) != 0 {
+		return false, nil, fmt.Errorf("script invokes disallowed commands: %s", strings.Join(disallowed, ", "))
+	}
+
 	// Generate all argument according to script runner definition and execute the script with timeout.
 	var args []string
 	for _, key := range operation.Spec.Processor.ScriptRunner.ArgKeys {
+		// An entry containing template placeholders is expanded with the request data and
+		// passed as a literal argument instead of being looked up as a key.
+		if strings.Contains(key, "{{") {
+			arg, err := expandFieldTemplate(key, data)
+			if err != nil {
+				return false, nil, err
+			}
+			args = append(args, arg)
+			continue
+		}
 		if value, ok := data[key]; ok {
 			args = append(args, value)
 		}
 	}
 	scriptFilePath := filepath.Join(ex.dataRoot, controllers.ScriptSubDirectory, operation.Name)
-	command := append([]string{"/bin/sh", scriptFilePath}, args...)
-	output, err := util.BlockingRunCommandWithTimeout(command, *operation.Spec.Processor.TimeoutSeconds)
+	interpreter := "/bin/sh"
+	if operation.Spec.Processor.ScriptRunner.Interpreter != nil {
+		interpreter = *operation.Spec.Processor.ScriptRunner.Interpreter
+	}
+	command := append([]string{interpreter, scriptFilePath}, args...)
+
+	// Enter the listed namespaces of the target pod container before running the script.
+	if len(operation.Spec.Processor.ScriptRunner.TargetPodNamespaces) != 0 {
+		pid, err := ex.resolveContainerPID(data[PodNamespaceTelemetryKey], data[PodNameTelemetryKey], data[ContainerTelemetryKey])
+		if err != nil {
+			return false, nil, err
+		}
+		command, err = nsenterCommand(pid, operation.Spec.Processor.ScriptRunner.TargetPodNamespaces, command)
+		if err != nil {
+			return false, nil, err
+		}
+	}
+
+	// Materialize files into a per-task temporary directory available to the script. A file
+	// content matching a key in the request data is replaced with the corresponding value.
+	var env []string
+	if len(operation.Spec.Processor.ScriptRunner.Files) != 0 {
+		filesDirectory, err := ioutil.TempDir("", "kubediag-script-files")
+		if err != nil {
+			return false, nil, err
+		}
+		defer os.RemoveAll(filesDirectory)
+		for filename, content := range operation.Spec.Processor.ScriptRunner.Files {
+			if value, ok := data[content]; ok {
+				content = value
+			}
+			if err := ioutil.WriteFile(filepath.Join(filesDirectory, filename), []byte(content), 0644); err != nil {
+				return false, nil, err
+			}
+		}
+		env = append(env, fmt.Sprintf("%s=%s", ScriptFilesDirectoryEnv, filesDirectory))
+	}
+
+	startTime := time.Now()
+	output, err := util.BlockingRunCommandWithContextEnv(ctx, command, env, *operation.Spec.Processor.TimeoutSeconds)
+	duration := time.Since(startTime)
+
+	// Surface the abort of a cancelled task instead of recording the killed run as a result.
+	if err != nil && ctx.Err() != nil {
+		return false, nil, err
+	}
+
+	// Extract the exit code of the script from the execution error.
+	exitCode := 0
+	if err != nil {
+		if exitError, ok := err.(*exec.ExitError); ok {
+			exitCode = exitError.ExitCode()
+		} else {
+			exitCode = -1
+		}
+	}
 
-	// Update script execution result with output and error.
+	// Update script execution result with output, error, exit code and duration.
 	result := make(map[string]string)
 	if operation.Spec.Processor.ScriptRunner.OperationResultKey != nil {
 		if output != nil {
@@ -523,30 +1403,98 @@ func (ex *executor) runScriptWithContext(operation diagnosisv1.Operation, data m
 			key := strings.Join([]string{"operation", *operation.Spec.Processor.ScriptRunner.OperationResultKey, "error"}, ".")
 			result[key] = err.Error()
 		}
+		exitCodeKey := strings.Join([]string{"operation", *operation.Spec.Processor.ScriptRunner.OperationResultKey, "exit_code"}, ".")
+		result[exitCodeKey] = strconv.Itoa(exitCode)
+		durationKey := strings.Join([]string{"operation", *operation.Spec.Processor.ScriptRunner.OperationResultKey, "duration_ms"}, ".")
+		result[durationKey] = strconv.FormatInt(duration.Milliseconds(), 10)
 	}
 
 	return true, result, nil
 }
 
+// nsenterNamespaceFlags maps container namespaces to nsenter flags.
+var nsenterNamespaceFlags = map[diagnosisv1.ContainerNamespace]string{
+	diagnosisv1.NetNamespace:   "--net",
+	diagnosisv1.MountNamespace: "--mount",
+	diagnosisv1.PIDNamespace:   "--pid",
+	diagnosisv1.IPCNamespace:   "--ipc",
+	diagnosisv1.UTSNamespace:   "--uts",
+}
+
+// nsenterCommand wraps a command with nsenter to run it in the listed namespaces of the
+// process with the provided pid.
+func nsenterCommand(pid int, namespaces []diagnosisv1.ContainerNamespace, command []string) ([]string, error) {
+	wrapped := []string{"nsenter", "--target", strconv.Itoa(pid)}
+	for _, namespace := range namespaces {
+		flag, ok := nsenterNamespaceFlags[namespace]
+		if !ok {
+			return nil, fmt.Errorf("unsupported container namespace: %s", namespace)
+		}
+		wrapped = append(wrapped, flag)
+	}
+	wrapped = append(wrapped, "--")
+
+	return append(wrapped, command...), nil
+}
+
+// resolveContainerPID resolves the pid of the running container of a pod through the docker
+// client. All running containers of the pod are matched if the container name is empty and
+// the first match is inspected.
+func (ex *executor) resolveContainerPID(namespace string, name string, container string) (int, error) {
+	if namespace == "" || name == "" {
+		return 0, fmt.Errorf("pod namespace and name not found in task telemetry")
+	}
+
+	containers, err := ex.dockerClient.ContainerList(ex, dockertypes.ContainerListOptions{})
+	if err != nil {
+		return 0, fmt.Errorf("list container failed, %s", err.Error())
+	}
+	for _, cont := range containers {
+		if cont.Labels["io.kubernetes.pod.namespace"] != namespace ||
+			cont.Labels["io.kubernetes.pod.name"] != name ||
+			cont.State != "running" {
+			continue
+		}
+		if container != "" && cont.Labels["io.kubernetes.container.name"] != container {
+			continue
+		}
+		inspected, err := ex.dockerClient.ContainerInspect(ex, cont.ID)
+		if err != nil {
+			return 0, fmt.Errorf("inspect container failed, %s", err.Error())
+		}
+		if inspected.State == nil || inspected.State.Pid == 0 {
+			return 0, fmt.Errorf("container %s of pod %s/%s has no pid", cont.Labels["io.kubernetes.container.name"], namespace, name)
+		}
+		return inspected.State.Pid, nil
+	}
+
+	return 0, fmt.Errorf("failed to get a matched running container of pod %s/%s", namespace, name)
+}
+
 // runFunctionWithContext runs a function with provided context.
 // It returns a bool, a map and an error as results.
-func (ex *executor) runFunctionWithContext(operation diagnosisv1.Operation, data map[string]string) (bool, map[string]string, error) {
+func (ex *executor) runFunctionWithContext(ctx context.Context, operation diagnosisv1.Operation, data map[string]string) (bool, map[string]string, error) {
 	if operation.Spec.Processor.Function == nil {
 		return false, nil, fmt.Errorf("function not specified")
 	}
 
 	imageName, tag := function.GetImageNameAndTag(&operation)
+	image := imageName + ":" + tag
+
+	// Wait if a build of the image is already in progress to avoid concurrent builds of the
+	// same image.
+	if started, building := ex.imageBuildStartTime(image); building {
+		if time.Since(started) > functionImageBuildDeadline {
+			return false, nil, fmt.Errorf("build of function image %s has not finished within %s", image, functionImageBuildDeadline)
+		}
+		return false, nil, errImageBuildInProgress
+	}
+
 	// Check if exist the image in local host.
 	if !function.ImageExists(ex.dockerClient, imageName, tag) {
-		ex.Info("image does not exist, try to build image", "image", imageName+":"+tag)
-		// imageBuildMessage stores information returned by docker server after building an image.
-		imageBuildMessage := new(bytes.Buffer)
-		err := function.BuildFunctionImage(ex.dockerClient, &operation, string(operation.Spec.Processor.Function.Runtime), imageBuildMessage)
-		if err != nil {
-			ex.Error(err, "failed to build docker image for function processor")
-			return false, nil, err
-		}
-		ex.Info(imageBuildMessage.String())
+		ex.Info("image does not exist, try to build image", "image", image)
+		ex.launchImageBuild(operation, image)
+		return false, nil, errImageBuildInProgress
 	}
 
 	namespacedName, err := ex.EnsureK8sResource(&operation)
@@ -582,6 +1530,7 @@ func (ex *executor) runFunctionWithContext(operation diagnosisv1.Operation, data
 	if err != nil {
 		return false, nil, err
 	}
+	req = req.WithContext(ctx)
 
 	// Send the http request.
 	res, err := cli.Do(req)
@@ -600,26 +1549,18 @@ func (ex *executor) runFunctionWithContext(operation diagnosisv1.Operation, data
 		return false, nil, fmt.Errorf("response body size %d exceeds max data size %d", len(body), MaxDataSize)
 	}
 
-	if res.StatusCode != http.StatusOK {
+	if !isSuccessStatusCode(res.StatusCode, operation.Spec.Processor.Function.SuccessStatusCodes) {
 		ex.Info("http response with erroneous status", "status", res.Status, "response", string(body))
 		return false, nil, nil
 	}
 
-	var result map[string]string
-	err = json.Unmarshal(body, &result)
-	if err != nil {
-		ex.Error(err, "failed to unmarshal response body", "response", string(body))
-		// If response code is 200 but body is not a string-map, we think this processor is finished but failed and will not return error
-		return false, nil, nil
-	}
-
-	return true, result, nil
+	return ex.parseProcessorResponse(body, operation.Spec.Processor.Function.ResponseFormat)
 }
 
 // ensureK8sResource creates/updates k8s object (pod) for the operation.
 func (ex *executor) EnsureK8sResource(operation *diagnosisv1.Operation) (namespacedName types.NamespacedName, err error) {
 	namespacedName = types.NamespacedName{
-		Namespace: DefaultFunctionNamespace,
+		Namespace: ex.functionNamespace,
 		Name:      operation.Name,
 	}
 
@@ -635,7 +1576,7 @@ func (ex *executor) EnsureK8sResource(operation *diagnosisv1.Operation) (namespa
 	pod := corev1.Pod{
 		ObjectMeta: v1.ObjectMeta{
 			Name:            operation.Name,
-			Namespace:       DefaultFunctionNamespace,
+			Namespace:       ex.functionNamespace,
 			OwnerReferences: or,
 			Labels:          labels,
 		},
@@ -701,6 +1642,33 @@ func hasDefaultLabel(labels map[string]string) bool {
 	return true
 }
 
+// resumeTasks requeues tasks on the node in Pending or Running phase to the queue processed by executor.
+// Tasks which have already completed their operations are skipped to avoid re-running non-idempotent
+// operations.
+func (ex *executor) resumeTasks() {
+	var taskList diagnosisv1.TaskList
+	if err := ex.client.List(ex, &taskList); err != nil {
+		ex.Error(err, "unable to list Tasks")
+		return
+	}
+
+	for _, task := range util.RetrieveTasksOnNode(taskList.Items, ex.nodeName) {
+		if task.Status.Phase != diagnosisv1.TaskPending && task.Status.Phase != diagnosisv1.TaskRunning {
+			continue
+		}
+		// Skip the task if its operation has been completed before the restart.
+		if _, condition := util.GetTaskCondition(&task.Status, diagnosisv1.TaskComplete); condition != nil && condition.Status == corev1.ConditionTrue {
+			continue
+		}
+
+		ex.Info("resuming Task", "task", client.ObjectKey{
+			Name:      task.Name,
+			Namespace: task.Namespace,
+		})
+		ex.addTaskToExecutorQueue(task)
+	}
+}
+
 // addTaskToExecutorQueue adds Task to the queue processed by executor.
 func (ex *executor) addTaskToExecutorQueue(task diagnosisv1.Task) {
 	err := util.QueueTask(ex, ex.taskCh, task)
@@ -712,6 +1680,36 @@ func (ex *executor) addTaskToExecutorQueue(task diagnosisv1.Task) {
 	}
 }
 
+// injectNodeTelemetry adds selected labels and annotations of the task node into the request
+// data under node.label and node.annotation prefixed keys. Keys not present on the node are
+// skipped.
+func (ex *executor) injectNodeTelemetry(data map[string]string, operation diagnosisv1.Operation, task diagnosisv1.Task) error {
+	if operation.Spec.NodeTelemetry == nil || task.Spec.NodeName == "" {
+		return nil
+	}
+
+	var node corev1.Node
+	if err := ex.client.Get(ex, client.ObjectKey{Name: task.Spec.NodeName}, &node); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil
+		}
+		return fmt.Errorf("unable to fetch Node %s: %s", task.Spec.NodeName, err)
+	}
+
+	for _, key := range operation.Spec.NodeTelemetry.Labels {
+		if value, ok := node.Labels[key]; ok {
+			data[NodeLabelTelemetryKeyPrefix+key] = value
+		}
+	}
+	for _, key := range operation.Spec.NodeTelemetry.Annotations {
+		if value, ok := node.Annotations[key]; ok {
+			data[NodeAnnotationTelemetryKeyPrefix+key] = value
+		}
+	}
+
+	return nil
+}
+
 // updateTaskContext updates data with task contexts.
 func updateTaskContext(data map[string]string, task diagnosisv1.Task) {
 	data[TaskNamespaceTelemetryKey] = task.Namespace
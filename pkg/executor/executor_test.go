@@ -0,0 +1,1931 @@
+/*
+Copyright 2022 The KubeDiag Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package executor
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	dockerclient "github.com/docker/docker/client"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	utilnet "k8s.io/apimachinery/pkg/util/net"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/workqueue"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	diagnosisv1 "github.com/kubediag/kubediag/api/v1"
+	"github.com/kubediag/kubediag/pkg/controllers"
+	"github.com/kubediag/kubediag/pkg/function"
+	"github.com/kubediag/kubediag/pkg/util"
+)
+
+func TestResumeTasks(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = diagnosisv1.AddToScheme(scheme)
+
+	newTask := func(name string, nodeName string, phase diagnosisv1.TaskPhase, conditions []diagnosisv1.TaskCondition) *diagnosisv1.Task {
+		return &diagnosisv1.Task{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: "default",
+			},
+			Spec: diagnosisv1.TaskSpec{
+				Operation: "operation1",
+				NodeName:  nodeName,
+			},
+			Status: diagnosisv1.TaskStatus{
+				Phase:      phase,
+				Conditions: conditions,
+			},
+		}
+	}
+
+	cli := fake.NewFakeClientWithScheme(scheme,
+		newTask("task1", "node1", diagnosisv1.TaskRunning, nil),
+		newTask("task2", "node1", diagnosisv1.TaskSucceeded, nil),
+		newTask("task3", "node2", diagnosisv1.TaskRunning, nil),
+		newTask("task4", "node1", diagnosisv1.TaskRunning, []diagnosisv1.TaskCondition{
+			{
+				Type:   diagnosisv1.TaskComplete,
+				Status: corev1.ConditionTrue,
+			},
+		}),
+	)
+	taskCh := make(chan diagnosisv1.Task, 10)
+	ex := &executor{
+		Context:  context.Background(),
+		Logger:   ctrl.Log.WithName("executor"),
+		client:   cli,
+		nodeName: "node1",
+		taskCh:   taskCh,
+	}
+
+	ex.resumeTasks()
+
+	// Only the running task on the node without a completion marker should be requeued.
+	assert.Equal(t, 1, len(taskCh))
+	task := <-taskCh
+	assert.Equal(t, "task1", task.Name)
+}
+
+func TestRunScriptWithContextInterpreter(t *testing.T) {
+	interpreter, err := exec.LookPath("python3")
+	if err != nil {
+		t.Skip("python3 not found in PATH")
+	}
+
+	dataRoot, err := ioutil.TempDir("", "executor")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dataRoot)
+	scriptDirectory := filepath.Join(dataRoot, controllers.ScriptSubDirectory)
+	err = os.MkdirAll(scriptDirectory, os.ModePerm)
+	assert.NoError(t, err)
+
+	script := "import sys\nprint(sys.argv[1])\n"
+	err = ioutil.WriteFile(filepath.Join(scriptDirectory, "operation1"), []byte(script), 0755)
+	assert.NoError(t, err)
+
+	timeoutSeconds := int32(30)
+	operationResultKey := "script"
+	operation := diagnosisv1.Operation{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "operation1",
+		},
+		Spec: diagnosisv1.OperationSpec{
+			Processor: diagnosisv1.Processor{
+				ScriptRunner: &diagnosisv1.ScriptRunner{
+					Script:             script,
+					ArgKeys:            []string{"param1"},
+					OperationResultKey: &operationResultKey,
+					Interpreter:        &interpreter,
+				},
+				TimeoutSeconds: &timeoutSeconds,
+			},
+		},
+	}
+	ex := &executor{
+		Context:  context.Background(),
+		Logger:   ctrl.Log.WithName("executor"),
+		dataRoot: dataRoot,
+	}
+
+	succeeded, result, err := ex.runScriptWithContext(context.Background(), operation, map[string]string{"param1": "value1"})
+	assert.NoError(t, err)
+	assert.True(t, succeeded)
+	assert.Equal(t, "value1\n", result["operation.script.output"])
+	assert.Equal(t, "0", result["operation.script.exit_code"])
+}
+
+func TestRunScriptWithContextExitCodeAndDuration(t *testing.T) {
+	dataRoot, err := ioutil.TempDir("", "executor")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dataRoot)
+	scriptDirectory := filepath.Join(dataRoot, controllers.ScriptSubDirectory)
+	err = os.MkdirAll(scriptDirectory, os.ModePerm)
+	assert.NoError(t, err)
+
+	script := "sleep 0.1\nexit 3\n"
+	err = ioutil.WriteFile(filepath.Join(scriptDirectory, "operation1"), []byte(script), 0755)
+	assert.NoError(t, err)
+
+	timeoutSeconds := int32(30)
+	operationResultKey := "script"
+	operation := diagnosisv1.Operation{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "operation1",
+		},
+		Spec: diagnosisv1.OperationSpec{
+			Processor: diagnosisv1.Processor{
+				ScriptRunner: &diagnosisv1.ScriptRunner{
+					Script:             script,
+					OperationResultKey: &operationResultKey,
+				},
+				TimeoutSeconds: &timeoutSeconds,
+			},
+		},
+	}
+	ex := &executor{
+		Context:  context.Background(),
+		Logger:   ctrl.Log.WithName("executor"),
+		dataRoot: dataRoot,
+	}
+
+	succeeded, result, err := ex.runScriptWithContext(context.Background(), operation, nil)
+	assert.NoError(t, err)
+	assert.True(t, succeeded)
+	assert.Equal(t, "3", result["operation.script.exit_code"])
+	durationMilliseconds, err := strconv.ParseInt(result["operation.script.duration_ms"], 10, 64)
+	assert.NoError(t, err)
+	assert.True(t, durationMilliseconds > 0)
+}
+
+func TestSyncTaskRedactsSensitiveKeys(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = diagnosisv1.AddToScheme(scheme)
+
+	// The processor echoes the received token back in the result.
+	var receivedToken string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var data map[string]string
+		err := json.NewDecoder(r.Body).Decode(&data)
+		assert.NoError(t, err)
+		receivedToken = data["param.token"]
+		result, err := json.Marshal(map[string]string{
+			"param.token":      data["param.token"],
+			"operation.result": "value1",
+		})
+		assert.NoError(t, err)
+		w.Write(result)
+	}))
+	defer server.Close()
+
+	serverURL, err := url.Parse(server.URL)
+	assert.NoError(t, err)
+	address := serverURL.Hostname()
+	portInt, err := strconv.Atoi(serverURL.Port())
+	assert.NoError(t, err)
+	port := int32(portInt)
+	path := "/processor"
+	httpScheme := "http"
+	timeoutSeconds := int32(30)
+	operation := &diagnosisv1.Operation{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "operation1",
+		},
+		Spec: diagnosisv1.OperationSpec{
+			Processor: diagnosisv1.Processor{
+				HTTPServer: &diagnosisv1.HTTPServer{
+					Address: &address,
+					Port:    &port,
+					Path:    &path,
+					Scheme:  &httpScheme,
+				},
+				TimeoutSeconds: &timeoutSeconds,
+			},
+			SensitiveKeys: []string{"param.token"},
+		},
+	}
+	task := &diagnosisv1.Task{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "task1",
+			Namespace: "default",
+		},
+		Spec: diagnosisv1.TaskSpec{
+			Operation:  "operation1",
+			NodeName:   "node1",
+			Parameters: map[string]string{"param.token": "secret"},
+		},
+		Status: diagnosisv1.TaskStatus{
+			Phase: diagnosisv1.TaskRunning,
+		},
+	}
+
+	cli := fake.NewFakeClientWithScheme(scheme, operation, task)
+	ex := &executor{
+		Context:       context.Background(),
+		Logger:        ctrl.Log.WithName("executor"),
+		client:        cli,
+		eventRecorder: record.NewFakeRecorder(100),
+		nodeName:      "node1",
+		transport:     utilnet.SetTransportDefaults(&http.Transport{}),
+	}
+
+	synced, err := ex.syncTask(*task)
+	assert.NoError(t, err)
+	assert.Equal(t, diagnosisv1.TaskSucceeded, synced.Status.Phase)
+
+	// The real value is delivered to the processor while the status is masked.
+	assert.Equal(t, "secret", receivedToken)
+	assert.Equal(t, "***", synced.Status.Results["param.token"])
+	assert.Equal(t, "value1", synced.Status.Results["operation.result"])
+}
+
+func TestSyncTaskOperationMetricsLabels(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = diagnosisv1.AddToScheme(scheme)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		result, err := json.Marshal(map[string]string{"operation.result": "value1"})
+		assert.NoError(t, err)
+		w.Write(result)
+	}))
+	defer server.Close()
+
+	serverURL, err := url.Parse(server.URL)
+	assert.NoError(t, err)
+	address := serverURL.Hostname()
+	portInt, err := strconv.Atoi(serverURL.Port())
+	assert.NoError(t, err)
+	port := int32(portInt)
+	path := "/processor"
+	httpScheme := "http"
+	timeoutSeconds := int32(30)
+	operation := &diagnosisv1.Operation{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "foo",
+		},
+		Spec: diagnosisv1.OperationSpec{
+			Processor: diagnosisv1.Processor{
+				HTTPServer: &diagnosisv1.HTTPServer{
+					Address: &address,
+					Port:    &port,
+					Path:    &path,
+					Scheme:  &httpScheme,
+				},
+				TimeoutSeconds: &timeoutSeconds,
+			},
+		},
+	}
+	task := &diagnosisv1.Task{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "task1",
+			Namespace: "default",
+		},
+		Spec: diagnosisv1.TaskSpec{
+			Operation: "foo",
+			NodeName:  "node1",
+		},
+		Status: diagnosisv1.TaskStatus{
+			Phase: diagnosisv1.TaskRunning,
+		},
+	}
+
+	cli := fake.NewFakeClientWithScheme(scheme, operation, task)
+	ex := &executor{
+		Context:       context.Background(),
+		Logger:        ctrl.Log.WithName("executor"),
+		client:        cli,
+		eventRecorder: record.NewFakeRecorder(100),
+		nodeName:      "node1",
+		transport:     utilnet.SetTransportDefaults(&http.Transport{}),
+	}
+
+	synced, err := ex.syncTask(*task)
+	assert.NoError(t, err)
+	assert.Equal(t, diagnosisv1.TaskSucceeded, synced.Status.Phase)
+
+	// The success counter is incremented with the operation name and processor type as labels.
+	assert.Equal(t, float64(1), testutil.ToFloat64(executorOperationSuccessCounter.WithLabelValues("foo", "httpServer")))
+}
+
+func TestNewTaskRateLimiter(t *testing.T) {
+	rateLimiter := newTaskRateLimiter()
+	key := client.ObjectKey{
+		Name:      "task1",
+		Namespace: "default",
+	}
+
+	// The delay doubles on each failure and is capped.
+	assert.Equal(t, 30*time.Second, rateLimiter.When(key))
+	assert.Equal(t, 60*time.Second, rateLimiter.When(key))
+	assert.Equal(t, 120*time.Second, rateLimiter.When(key))
+	for i := 0; i < 10; i++ {
+		rateLimiter.When(key)
+	}
+	assert.Equal(t, 8*time.Minute, rateLimiter.When(key))
+
+	rateLimiter.Forget(key)
+	assert.Equal(t, 30*time.Second, rateLimiter.When(key))
+}
+
+func TestProcessNextWorkItemRetriesFailingTask(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = diagnosisv1.AddToScheme(scheme)
+
+	// The processor is unreachable since the server is closed before any request is sent.
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	serverURL, err := url.Parse(server.URL)
+	assert.NoError(t, err)
+	server.Close()
+	address := serverURL.Hostname()
+	portInt, err := strconv.Atoi(serverURL.Port())
+	assert.NoError(t, err)
+	port := int32(portInt)
+	path := "/processor"
+	httpScheme := "http"
+	timeoutSeconds := int32(30)
+	operation := &diagnosisv1.Operation{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "operation1",
+		},
+		Spec: diagnosisv1.OperationSpec{
+			Processor: diagnosisv1.Processor{
+				HTTPServer: &diagnosisv1.HTTPServer{
+					Address: &address,
+					Port:    &port,
+					Path:    &path,
+					Scheme:  &httpScheme,
+				},
+				TimeoutSeconds: &timeoutSeconds,
+			},
+		},
+	}
+	task := &diagnosisv1.Task{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "task1",
+			Namespace: "default",
+		},
+		Spec: diagnosisv1.TaskSpec{
+			Operation: "operation1",
+			NodeName:  "node1",
+		},
+		Status: diagnosisv1.TaskStatus{
+			Phase: diagnosisv1.TaskRunning,
+		},
+	}
+
+	cli := fake.NewFakeClientWithScheme(scheme, operation, task)
+	// Use a fast rate limiter to keep the test short. The delay progression of the production
+	// rate limiter is covered by TestNewTaskRateLimiter.
+	queue := workqueue.NewRateLimitingQueue(workqueue.NewItemExponentialFailureRateLimiter(time.Millisecond, 50*time.Millisecond))
+	ex := &executor{
+		Context:       context.Background(),
+		Logger:        ctrl.Log.WithName("executor"),
+		client:        cli,
+		eventRecorder: record.NewFakeRecorder(100),
+		nodeName:      "node1",
+		transport:     utilnet.SetTransportDefaults(&http.Transport{}),
+		queue:         queue,
+	}
+
+	key := client.ObjectKey{
+		Name:      "task1",
+		Namespace: "default",
+	}
+	queue.Add(key)
+
+	// The first sync failures requeue the task with a rate limited delay.
+	for i := 0; i < TaskBackoff.Steps-1; i++ {
+		assert.True(t, ex.processNextWorkItem())
+		assert.Equal(t, i+1, queue.NumRequeues(key))
+	}
+
+	// The task is marked as failed once the backoff steps are exhausted.
+	assert.True(t, ex.processNextWorkItem())
+	assert.Equal(t, 0, queue.NumRequeues(key))
+
+	var failed diagnosisv1.Task
+	err = cli.Get(context.Background(), key, &failed)
+	assert.NoError(t, err)
+	assert.Equal(t, diagnosisv1.TaskFailed, failed.Status.Phase)
+	_, condition := util.GetTaskCondition(&failed.Status, diagnosisv1.TaskAccepted)
+	if assert.NotNil(t, condition) {
+		assert.Equal(t, TaskFailureReasonUnreachable, condition.Reason)
+	}
+
+	// The work queue unblocks the worker on shutdown.
+	queue.ShutDown()
+	assert.False(t, ex.processNextWorkItem())
+}
+
+func TestDoHTTPRequestWithContextResultJSONPaths(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"status":{"phase":"Degraded","replicas":3},"items":["a","b"]}`))
+	}))
+	defer server.Close()
+
+	serverURL, err := url.Parse(server.URL)
+	assert.NoError(t, err)
+	address := serverURL.Hostname()
+	portInt, err := strconv.Atoi(serverURL.Port())
+	assert.NoError(t, err)
+	port := int32(portInt)
+	path := "/processor"
+	httpScheme := "http"
+	timeoutSeconds := int32(30)
+	operation := diagnosisv1.Operation{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "operation1",
+		},
+		Spec: diagnosisv1.OperationSpec{
+			Processor: diagnosisv1.Processor{
+				HTTPServer: &diagnosisv1.HTTPServer{
+					Address: &address,
+					Port:    &port,
+					Path:    &path,
+					Scheme:  &httpScheme,
+					ResultJSONPaths: map[string]string{
+						"collector.phase":    "{.status.phase}",
+						"collector.replicas": "{.status.replicas}",
+					},
+				},
+				TimeoutSeconds: &timeoutSeconds,
+			},
+		},
+	}
+
+	ex := &executor{
+		Context:   context.Background(),
+		Logger:    ctrl.Log.WithName("executor"),
+		transport: utilnet.SetTransportDefaults(&http.Transport{}),
+	}
+
+	// Nested fields are extracted into flat result keys according to the jsonpath templates.
+	succeeded, result, err := ex.doHTTPRequestWithContext(context.Background(), operation, map[string]string{})
+	assert.NoError(t, err)
+	assert.True(t, succeeded)
+	assert.Equal(t, map[string]string{
+		"collector.phase":    "Degraded",
+		"collector.replicas": "3",
+	}, result)
+
+	// An invalid jsonpath marks the processor as finished but failed.
+	operation.Spec.Processor.HTTPServer.ResultJSONPaths = map[string]string{
+		"collector.missing": "{.status.missing}",
+	}
+	succeeded, result, err = ex.doHTTPRequestWithContext(context.Background(), operation, map[string]string{})
+	assert.NoError(t, err)
+	assert.False(t, succeeded)
+	assert.Nil(t, result)
+}
+
+func TestEnsureK8sResourceCreatesPodInFunctionNamespace(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = diagnosisv1.AddToScheme(scheme)
+
+	operation := &diagnosisv1.Operation{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "operation1",
+			UID:  "0123456789",
+		},
+		Spec: diagnosisv1.OperationSpec{
+			Processor: diagnosisv1.Processor{
+				Function: &diagnosisv1.Function{
+					CodeSource: map[string]string{"main.py": "def handler(): pass"},
+					Runtime:    diagnosisv1.Python3FunctionRuntime,
+				},
+			},
+		},
+	}
+
+	cli := fake.NewFakeClientWithScheme(scheme, operation)
+	ex := &executor{
+		Context:           context.Background(),
+		Logger:            ctrl.Log.WithName("executor"),
+		client:            cli,
+		nodeName:          "node1",
+		functionNamespace: "kubediag-functions",
+	}
+
+	namespacedName, err := ex.EnsureK8sResource(operation)
+	assert.NoError(t, err)
+	assert.Equal(t, "kubediag-functions", namespacedName.Namespace)
+
+	// The function pod is created in the configured namespace with an owner reference to
+	// the operation.
+	var pod corev1.Pod
+	err = cli.Get(context.Background(), client.ObjectKey{
+		Name:      "operation1",
+		Namespace: "kubediag-functions",
+	}, &pod)
+	assert.NoError(t, err)
+	assert.Equal(t, "node1", pod.Spec.NodeName)
+	if assert.Equal(t, 1, len(pod.OwnerReferences)) {
+		assert.Equal(t, "operation1", pod.OwnerReferences[0].Name)
+	}
+}
+
+func TestIsSuccessStatusCode(t *testing.T) {
+	// Status code 200 is always a success.
+	assert.True(t, isSuccessStatusCode(http.StatusOK, nil))
+	assert.False(t, isSuccessStatusCode(http.StatusAccepted, nil))
+	assert.True(t, isSuccessStatusCode(http.StatusAccepted, []int32{202, 204}))
+	assert.False(t, isSuccessStatusCode(http.StatusInternalServerError, []int32{202, 204}))
+}
+
+func TestDoHTTPRequestWithContextSuccessStatusCodesAndRawResponse(t *testing.T) {
+	// The processor responds like an openfaas function returning 202 with a raw body.
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+		w.Write([]byte("function output"))
+	}))
+	defer server.Close()
+
+	serverURL, err := url.Parse(server.URL)
+	assert.NoError(t, err)
+	address := serverURL.Hostname()
+	portInt, err := strconv.Atoi(serverURL.Port())
+	assert.NoError(t, err)
+	port := int32(portInt)
+	path := "/"
+	httpScheme := "http"
+	timeoutSeconds := int32(30)
+	operation := diagnosisv1.Operation{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "operation1",
+		},
+		Spec: diagnosisv1.OperationSpec{
+			Processor: diagnosisv1.Processor{
+				HTTPServer: &diagnosisv1.HTTPServer{
+					Address:            &address,
+					Port:               &port,
+					Path:               &path,
+					Scheme:             &httpScheme,
+					SuccessStatusCodes: []int32{202},
+					ResponseFormat:     diagnosisv1.RawResponseFormat,
+				},
+				TimeoutSeconds: &timeoutSeconds,
+			},
+		},
+	}
+
+	ex := &executor{
+		Context:   context.Background(),
+		Logger:    ctrl.Log.WithName("executor"),
+		transport: utilnet.SetTransportDefaults(&http.Transport{}),
+	}
+
+	succeeded, result, err := ex.doHTTPRequestWithContext(context.Background(), operation, map[string]string{})
+	assert.NoError(t, err)
+	assert.True(t, succeeded)
+	assert.Equal(t, map[string]string{ResponseBodyResultKey: "function output"}, result)
+
+	// A 202 response is erroneous without the status code listed as success.
+	operation.Spec.Processor.HTTPServer.SuccessStatusCodes = nil
+	succeeded, result, err = ex.doHTTPRequestWithContext(context.Background(), operation, map[string]string{})
+	assert.NoError(t, err)
+	assert.False(t, succeeded)
+	assert.Nil(t, result)
+}
+
+func TestParseProcessorResponse(t *testing.T) {
+	ex := &executor{
+		Context: context.Background(),
+		Logger:  ctrl.Log.WithName("executor"),
+	}
+
+	// A raw response body is stored as is under the response.body result key.
+	succeeded, result, err := ex.parseProcessorResponse([]byte("raw output"), diagnosisv1.RawResponseFormat)
+	assert.NoError(t, err)
+	assert.True(t, succeeded)
+	assert.Equal(t, map[string]string{ResponseBodyResultKey: "raw output"}, result)
+
+	// A map response body which is not a string map marks the processor as finished but failed.
+	succeeded, result, err = ex.parseProcessorResponse([]byte("raw output"), diagnosisv1.MapResponseFormat)
+	assert.NoError(t, err)
+	assert.False(t, succeeded)
+	assert.Nil(t, result)
+}
+
+func TestSyncTaskNamespacesReservedTelemetryKeys(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = diagnosisv1.AddToScheme(scheme)
+
+	// The processor echoes the telemetry node value back under the reserved key.
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		result, err := json.Marshal(map[string]string{
+			NodeTelemetryKey:   "node2",
+			"operation.result": "value1",
+		})
+		assert.NoError(t, err)
+		w.Write(result)
+	}))
+	defer server.Close()
+
+	serverURL, err := url.Parse(server.URL)
+	assert.NoError(t, err)
+	address := serverURL.Hostname()
+	portInt, err := strconv.Atoi(serverURL.Port())
+	assert.NoError(t, err)
+	port := int32(portInt)
+	path := "/processor"
+	httpScheme := "http"
+	timeoutSeconds := int32(30)
+	operation := &diagnosisv1.Operation{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "operation1",
+		},
+		Spec: diagnosisv1.OperationSpec{
+			Processor: diagnosisv1.Processor{
+				HTTPServer: &diagnosisv1.HTTPServer{
+					Address: &address,
+					Port:    &port,
+					Path:    &path,
+					Scheme:  &httpScheme,
+				},
+				TimeoutSeconds: &timeoutSeconds,
+			},
+		},
+	}
+	task := &diagnosisv1.Task{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "task1",
+			Namespace: "default",
+		},
+		Spec: diagnosisv1.TaskSpec{
+			Operation: "operation1",
+			NodeName:  "node1",
+		},
+		Status: diagnosisv1.TaskStatus{
+			Phase: diagnosisv1.TaskRunning,
+		},
+	}
+
+	cli := fake.NewFakeClientWithScheme(scheme, operation, task)
+	ex := &executor{
+		Context:       context.Background(),
+		Logger:        ctrl.Log.WithName("executor"),
+		client:        cli,
+		eventRecorder: record.NewFakeRecorder(100),
+		nodeName:      "node1",
+		transport:     utilnet.SetTransportDefaults(&http.Transport{}),
+	}
+
+	synced, err := ex.syncTask(*task)
+	assert.NoError(t, err)
+	assert.Equal(t, diagnosisv1.TaskSucceeded, synced.Status.Phase)
+
+	// The reserved node key is namespaced under the result. prefix instead of clobbering the
+	// telemetry value.
+	assert.NotContains(t, synced.Status.Results, NodeTelemetryKey)
+	assert.Equal(t, "node2", synced.Status.Results[ReservedKeyNamespacePrefix+NodeTelemetryKey])
+	assert.Equal(t, "value1", synced.Status.Results["operation.result"])
+}
+
+func TestRunScriptWithContextFiles(t *testing.T) {
+	dataRoot, err := ioutil.TempDir("", "executor")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dataRoot)
+	scriptDirectory := filepath.Join(dataRoot, controllers.ScriptSubDirectory)
+	err = os.MkdirAll(scriptDirectory, os.ModePerm)
+	assert.NoError(t, err)
+
+	// The script prints the files directory and the content of the materialized file.
+	script := "echo $KUBEDIAG_SCRIPT_FILES_DIR\ncat $KUBEDIAG_SCRIPT_FILES_DIR/config\n"
+	err = ioutil.WriteFile(filepath.Join(scriptDirectory, "operation1"), []byte(script), 0755)
+	assert.NoError(t, err)
+
+	timeoutSeconds := int32(30)
+	operationResultKey := "script"
+	operation := diagnosisv1.Operation{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "operation1",
+		},
+		Spec: diagnosisv1.OperationSpec{
+			Processor: diagnosisv1.Processor{
+				ScriptRunner: &diagnosisv1.ScriptRunner{
+					Script:             script,
+					OperationResultKey: &operationResultKey,
+					Files: map[string]string{
+						"config": "param.config",
+					},
+				},
+				TimeoutSeconds: &timeoutSeconds,
+			},
+		},
+	}
+	ex := &executor{
+		Context:  context.Background(),
+		Logger:   ctrl.Log.WithName("executor"),
+		dataRoot: dataRoot,
+	}
+
+	succeeded, result, err := ex.runScriptWithContext(context.Background(), operation, map[string]string{"param.config": "key1=value1"})
+	assert.NoError(t, err)
+	assert.True(t, succeeded)
+	lines := strings.SplitN(result["operation.script.output"], "\n", 2)
+	assert.Equal(t, 2, len(lines))
+	assert.Equal(t, "key1=value1", strings.TrimSpace(lines[1]))
+
+	// The temporary files directory is removed after execution.
+	_, err = os.Stat(lines[0])
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestNewProcessorTransport(t *testing.T) {
+	// Keep-alives are enabled with a tuned connection pool per processor.
+	transport := NewProcessorTransport(true, 16, true)
+	assert.False(t, transport.DisableKeepAlives)
+	assert.Equal(t, 16, transport.MaxIdleConnsPerHost)
+	assert.True(t, transport.TLSClientConfig.InsecureSkipVerify)
+
+	// Keep-alives are disabled by default and certificate verification is configurable.
+	transport = NewProcessorTransport(false, 16, false)
+	assert.True(t, transport.DisableKeepAlives)
+	assert.False(t, transport.TLSClientConfig.InsecureSkipVerify)
+}
+
+func BenchmarkDoHTTPRequestWithContext(b *testing.B) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"operation.result":"value1"}`))
+	}))
+	defer server.Close()
+
+	serverURL, _ := url.Parse(server.URL)
+	address := serverURL.Hostname()
+	portInt, _ := strconv.Atoi(serverURL.Port())
+	port := int32(portInt)
+	path := "/processor"
+	httpScheme := "http"
+	timeoutSeconds := int32(30)
+	operation := diagnosisv1.Operation{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "operation1",
+		},
+		Spec: diagnosisv1.OperationSpec{
+			Processor: diagnosisv1.Processor{
+				HTTPServer: &diagnosisv1.HTTPServer{
+					Address: &address,
+					Port:    &port,
+					Path:    &path,
+					Scheme:  &httpScheme,
+				},
+				TimeoutSeconds: &timeoutSeconds,
+			},
+		},
+	}
+
+	for _, benchmark := range []struct {
+		name       string
+		keepAlives bool
+	}{
+		{"KeepAlivesDisabled", false},
+		{"KeepAlivesEnabled", true},
+	} {
+		b.Run(benchmark.name, func(b *testing.B) {
+			ex := &executor{
+				Context:   context.Background(),
+				Logger:    ctrl.Log.WithName("executor"),
+				transport: NewProcessorTransport(benchmark.keepAlives, 16, true),
+			}
+			for i := 0; i < b.N; i++ {
+				succeeded, _, err := ex.doHTTPRequestWithContext(context.Background(), operation, map[string]string{})
+				if err != nil || !succeeded {
+					b.Fatalf("processor request failed: %v", err)
+				}
+			}
+		})
+	}
+}
+
+func TestSyncTaskWaitsForFunctionImageBuild(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = diagnosisv1.AddToScheme(scheme)
+
+	timeoutSeconds := int32(30)
+	operation := &diagnosisv1.Operation{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "operation1",
+		},
+		Spec: diagnosisv1.OperationSpec{
+			Processor: diagnosisv1.Processor{
+				Function: &diagnosisv1.Function{
+					CodeSource: map[string]string{"handler.py": "def handler(): pass"},
+					Runtime:    diagnosisv1.Python3FunctionRuntime,
+				},
+				TimeoutSeconds: &timeoutSeconds,
+			},
+		},
+	}
+	task := &diagnosisv1.Task{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "task1",
+			Namespace: "default",
+		},
+		Spec: diagnosisv1.TaskSpec{
+			Operation: "operation1",
+			NodeName:  "node1",
+		},
+		Status: diagnosisv1.TaskStatus{
+			Phase: diagnosisv1.TaskRunning,
+		},
+	}
+
+	cli := fake.NewFakeClientWithScheme(scheme, operation, task)
+	ex := &executor{
+		Context:       context.Background(),
+		Logger:        ctrl.Log.WithName("executor"),
+		client:        cli,
+		eventRecorder: record.NewFakeRecorder(100),
+		nodeName:      "node1",
+	}
+
+	imageName, tag := function.GetImageNameAndTag(operation)
+	image := imageName + ":" + tag
+
+	// The task is left pending while a build of the function image is in progress.
+	assert.True(t, ex.startImageBuild(image))
+	synced, err := ex.syncTask(*task)
+	assert.NoError(t, err)
+	assert.Equal(t, diagnosisv1.TaskPending, synced.Status.Phase)
+
+	// A build of the same image is not started concurrently.
+	assert.False(t, ex.startImageBuild(image))
+
+	// The task is failed if the build exceeds the deadline.
+	ex.imageBuilds[image] = time.Now().Add(-2 * functionImageBuildDeadline)
+	_, err = ex.syncTask(*task)
+	assert.Error(t, err)
+
+	// A new build can be started once the previous build has finished.
+	ex.finishImageBuild(image)
+	assert.True(t, ex.startImageBuild(image))
+}
+
+func TestLaunchImageBuild(t *testing.T) {
+	operation := diagnosisv1.Operation{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "operation1",
+		},
+		Spec: diagnosisv1.OperationSpec{
+			Processor: diagnosisv1.Processor{
+				Function: &diagnosisv1.Function{
+					CodeSource: map[string]string{"handler.py": "def handler(): pass"},
+					Runtime:    diagnosisv1.Python3FunctionRuntime,
+				},
+			},
+		},
+	}
+	imageName, tag := function.GetImageNameAndTag(&operation)
+	image := imageName + ":" + tag
+
+	var builds int32
+	done := make(chan struct{})
+	ex := &executor{
+		Context: context.Background(),
+		Logger:  ctrl.Log.WithName("executor"),
+		buildFunctionImage: func(cli dockerclient.Client, operation *diagnosisv1.Operation, lang string, imageBuildMessage *bytes.Buffer) error {
+			atomic.AddInt32(&builds, 1)
+			<-done
+			return nil
+		},
+	}
+
+	// Only one build is started for concurrent executions of the same function.
+	var launched int32
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if ex.launchImageBuild(operation, image) {
+				atomic.AddInt32(&launched, 1)
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(1), atomic.LoadInt32(&launched))
+
+	close(done)
+	assert.Eventually(t, func() bool {
+		_, building := ex.imageBuildStartTime(image)
+		return !building && atomic.LoadInt32(&builds) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestClassifyProcessorError(t *testing.T) {
+	exitError := exec.Command("/bin/sh", "-c", "exit 1").Run()
+	assert.IsType(t, &exec.ExitError{}, exitError)
+
+	tests := []struct {
+		err      error
+		expected string
+		desc     string
+	}{
+		{
+			err:      nil,
+			expected: "",
+			desc:     "no error",
+		},
+		{
+			err:      context.DeadlineExceeded,
+			expected: TaskFailureReasonTimeout,
+			desc:     "context deadline exceeded",
+		},
+		{
+			err:      &url.Error{Op: "Post", URL: "http://127.0.0.1:1/", Err: context.DeadlineExceeded},
+			expected: TaskFailureReasonTimeout,
+			desc:     "wrapped context deadline exceeded",
+		},
+		{
+			err:      &url.Error{Op: "Post", URL: "http://127.0.0.1:1/", Err: &net.OpError{Op: "dial", Net: "tcp", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}},
+			expected: TaskFailureReasonUnreachable,
+			desc:     "connection refused",
+		},
+		{
+			err:      &url.Error{Op: "Post", URL: "http://invalid/", Err: &net.DNSError{Err: "no such host", Name: "invalid"}},
+			expected: TaskFailureReasonUnreachable,
+			desc:     "dns resolution failure",
+		},
+		{
+			err:      exitError,
+			expected: TaskFailureReasonNonZeroExit,
+			desc:     "non zero exit",
+		},
+		{
+			err:      fmt.Errorf("unable to update Task"),
+			expected: "",
+			desc:     "unclassified error",
+		},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.expected, classifyProcessorError(test.err), test.desc)
+	}
+}
+
+// fakeCache is a cache.Cache backed by a fake client for testing.
+type fakeCache struct {
+	client.Reader
+}
+
+func (f fakeCache) GetInformer(obj runtime.Object) (cache.Informer, error) {
+	return nil, nil
+}
+
+func (f fakeCache) GetInformerForKind(gvk schema.GroupVersionKind) (cache.Informer, error) {
+	return nil, nil
+}
+
+func (f fakeCache) Start(stopCh <-chan struct{}) error {
+	return nil
+}
+
+func (f fakeCache) WaitForCacheSync(stop <-chan struct{}) bool {
+	return true
+}
+
+func (f fakeCache) IndexField(obj runtime.Object, field string, extractValue client.IndexerFunc) error {
+	return nil
+}
+
+func TestDoHTTPRequestWithContextAuthSecretRef(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = diagnosisv1.AddToScheme(scheme)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		result := map[string]string{
+			"authorization": r.Header.Get("Authorization"),
+		}
+		data, err := json.Marshal(result)
+		assert.NoError(t, err)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(data)
+	}))
+	defer server.Close()
+	serverURL, err := url.Parse(server.URL)
+	assert.NoError(t, err)
+	host := serverURL.Hostname()
+	port, err := strconv.Atoi(serverURL.Port())
+	assert.NoError(t, err)
+
+	tests := []struct {
+		secretData map[string][]byte
+		expected   string
+		desc       string
+	}{
+		{
+			secretData: map[string][]byte{
+				"token": []byte("secret-token"),
+			},
+			expected: "Bearer secret-token",
+			desc:     "bearer token",
+		},
+		{
+			secretData: map[string][]byte{
+				"username": []byte("user"),
+				"password": []byte("pass"),
+			},
+			expected: "Basic " + base64.StdEncoding.EncodeToString([]byte("user:pass")),
+			desc:     "basic authentication",
+		},
+	}
+
+	for _, test := range tests {
+		secret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "credential",
+				Namespace: "default",
+			},
+			Data: test.secretData,
+		}
+		cli := fake.NewFakeClientWithScheme(scheme, secret)
+		ex := &executor{
+			Context:   context.Background(),
+			Logger:    ctrl.Log.WithName("executor"),
+			client:    cli,
+			cache:     fakeCache{Reader: cli},
+			transport: NewProcessorTransport(false, 0, true),
+		}
+
+		address := host
+		processorPort := int32(port)
+		path := "/"
+		httpScheme := "http"
+		timeoutSeconds := int32(30)
+		operation := diagnosisv1.Operation{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "operation1",
+			},
+			Spec: diagnosisv1.OperationSpec{
+				Processor: diagnosisv1.Processor{
+					HTTPServer: &diagnosisv1.HTTPServer{
+						Address: &address,
+						Port:    &processorPort,
+						Path:    &path,
+						Scheme:  &httpScheme,
+						AuthSecretRef: &diagnosisv1.NamespacedName{
+							Name:      "credential",
+							Namespace: "default",
+						},
+					},
+					TimeoutSeconds: &timeoutSeconds,
+				},
+			},
+		}
+
+		succeeded, result, err := ex.doHTTPRequestWithContext(context.Background(), operation, map[string]string{})
+		assert.NoError(t, err, test.desc)
+		assert.True(t, succeeded, test.desc)
+		assert.Equal(t, test.expected, result["authorization"], test.desc)
+	}
+
+	// The task fails if the referenced secret does not exist.
+	cli := fake.NewFakeClientWithScheme(scheme)
+	ex := &executor{
+		Context: context.Background(),
+		Logger:  ctrl.Log.WithName("executor"),
+		client:  cli,
+		cache:   fakeCache{Reader: cli},
+	}
+	_, err = ex.resolveAuthorization(diagnosisv1.NamespacedName{Name: "missing", Namespace: "default"})
+	assert.Error(t, err)
+}
+
+func TestShutdownDrainsRunningTaskSync(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = diagnosisv1.AddToScheme(scheme)
+
+	// The processor signals when a sync is in flight and blocks until released.
+	syncStarted := make(chan struct{})
+	releaseSync := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(syncStarted)
+		<-releaseSync
+		result, err := json.Marshal(map[string]string{"operation.result": "value1"})
+		assert.NoError(t, err)
+		w.Write(result)
+	}))
+	defer server.Close()
+
+	serverURL, err := url.Parse(server.URL)
+	assert.NoError(t, err)
+	address := serverURL.Hostname()
+	portInt, err := strconv.Atoi(serverURL.Port())
+	assert.NoError(t, err)
+	port := int32(portInt)
+	path := "/processor"
+	httpScheme := "http"
+	timeoutSeconds := int32(30)
+	operation := &diagnosisv1.Operation{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "operation1",
+		},
+		Spec: diagnosisv1.OperationSpec{
+			Processor: diagnosisv1.Processor{
+				HTTPServer: &diagnosisv1.HTTPServer{
+					Address: &address,
+					Port:    &port,
+					Path:    &path,
+					Scheme:  &httpScheme,
+				},
+				TimeoutSeconds: &timeoutSeconds,
+			},
+		},
+	}
+	task := &diagnosisv1.Task{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "task1",
+			Namespace: "default",
+		},
+		Spec: diagnosisv1.TaskSpec{
+			Operation: "operation1",
+			NodeName:  "node1",
+		},
+		Status: diagnosisv1.TaskStatus{
+			Phase: diagnosisv1.TaskRunning,
+		},
+	}
+
+	cli := fake.NewFakeClientWithScheme(scheme, operation, task)
+	ex := &executor{
+		Context:       context.Background(),
+		Logger:        ctrl.Log.WithName("executor"),
+		client:        cli,
+		eventRecorder: record.NewFakeRecorder(100),
+		nodeName:      "node1",
+		transport:     utilnet.SetTransportDefaults(&http.Transport{}),
+		queue:         workqueue.NewRateLimitingQueue(newTaskRateLimiter()),
+	}
+
+	go ex.runWorker()
+
+	key := client.ObjectKey{
+		Name:      "task1",
+		Namespace: "default",
+	}
+	ex.queue.Add(key)
+	<-syncStarted
+
+	// A shutdown with an expired timeout reports that the running sync has not completed.
+	err = ex.Shutdown(10 * time.Millisecond)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "timed out")
+
+	// The running sync completes before a shutdown within the drain timeout returns.
+	close(releaseSync)
+	err = ex.Shutdown(5 * time.Second)
+	assert.NoError(t, err)
+
+	var synced diagnosisv1.Task
+	err = cli.Get(context.Background(), key, &synced)
+	assert.NoError(t, err)
+	assert.Equal(t, diagnosisv1.TaskSucceeded, synced.Status.Phase)
+	assert.Equal(t, "value1", synced.Status.Results["operation.result"])
+}
+
+func TestSyncTaskCircuitBreaker(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = diagnosisv1.AddToScheme(scheme)
+
+	// The processor counts attempts and closes every connection without responding so each
+	// attempt fails with an execution error.
+	var attempts int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&attempts, 1)
+		conn, _, err := w.(http.Hijacker).Hijack()
+		assert.NoError(t, err)
+		conn.Close()
+	}))
+	defer server.Close()
+
+	serverURL, err := url.Parse(server.URL)
+	assert.NoError(t, err)
+	address := serverURL.Hostname()
+	portInt, err := strconv.Atoi(serverURL.Port())
+	assert.NoError(t, err)
+	port := int32(portInt)
+	path := "/processor"
+	httpScheme := "http"
+	timeoutSeconds := int32(30)
+	operation := &diagnosisv1.Operation{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "operation1",
+		},
+		Spec: diagnosisv1.OperationSpec{
+			Processor: diagnosisv1.Processor{
+				HTTPServer: &diagnosisv1.HTTPServer{
+					Address: &address,
+					Port:    &port,
+					Path:    &path,
+					Scheme:  &httpScheme,
+				},
+				TimeoutSeconds: &timeoutSeconds,
+			},
+		},
+	}
+	task := &diagnosisv1.Task{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "task1",
+			Namespace: "default",
+		},
+		Spec: diagnosisv1.TaskSpec{
+			Operation: "operation1",
+			NodeName:  "node1",
+		},
+		Status: diagnosisv1.TaskStatus{
+			Phase: diagnosisv1.TaskRunning,
+		},
+	}
+
+	cli := fake.NewFakeClientWithScheme(scheme, operation, task)
+	ex := &executor{
+		Context:       context.Background(),
+		Logger:        ctrl.Log.WithName("executor"),
+		client:        cli,
+		eventRecorder: record.NewFakeRecorder(100),
+		nodeName:      "node1",
+		transport:     utilnet.SetTransportDefaults(&http.Transport{}),
+	}
+
+	// The circuit opens after the failure threshold is reached.
+	for i := 0; i < circuitBreakerFailureThreshold; i++ {
+		_, err := ex.syncTask(*task)
+		assert.Error(t, err)
+	}
+	assert.Equal(t, int32(circuitBreakerFailureThreshold), atomic.LoadInt32(&attempts))
+	assert.True(t, ex.circuitOpen("operation1"))
+
+	// A task of the operation fails fast without a processor attempt while the circuit is open.
+	synced, err := ex.syncTask(*task)
+	assert.NoError(t, err)
+	assert.Equal(t, int32(circuitBreakerFailureThreshold), atomic.LoadInt32(&attempts))
+	assert.Equal(t, diagnosisv1.TaskFailed, synced.Status.Phase)
+	_, condition := util.GetTaskCondition(&synced.Status, diagnosisv1.TaskIncomplete)
+	if assert.NotNil(t, condition) {
+		assert.Equal(t, TaskFailureReasonCircuitOpen, condition.Reason)
+	}
+
+	// The processor is attempted again once the cooldown elapsed and another failure reopens
+	// the circuit immediately.
+	ex.circuitBreakersMutex.Lock()
+	ex.circuitBreakers["operation1"].openUntil = time.Now().Add(-time.Second)
+	ex.circuitBreakersMutex.Unlock()
+	_, err = ex.syncTask(*task)
+	assert.Error(t, err)
+	assert.Equal(t, int32(circuitBreakerFailureThreshold+1), atomic.LoadInt32(&attempts))
+	assert.True(t, ex.circuitOpen("operation1"))
+
+	// A completed processor call closes the circuit.
+	ex.recordProcessorSuccess("operation1")
+	assert.False(t, ex.circuitOpen("operation1"))
+}
+
+func TestDoHTTPRequestWithContextTemplatedPath(t *testing.T) {
+	var requestedPath string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestedPath = r.URL.Path
+		w.Write([]byte(`{"profiler.endpoint":"ok"}`))
+	}))
+	defer server.Close()
+
+	serverURL, err := url.Parse(server.URL)
+	assert.NoError(t, err)
+	address := serverURL.Hostname()
+	portInt, err := strconv.Atoi(serverURL.Port())
+	assert.NoError(t, err)
+	port := int32(portInt)
+	path := "/profile/{{ .pod.namespace }}/{{ .pod.name }}"
+	httpScheme := "http"
+	timeoutSeconds := int32(30)
+	operation := diagnosisv1.Operation{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "operation1",
+		},
+		Spec: diagnosisv1.OperationSpec{
+			Processor: diagnosisv1.Processor{
+				HTTPServer: &diagnosisv1.HTTPServer{
+					Address: &address,
+					Port:    &port,
+					Path:    &path,
+					Scheme:  &httpScheme,
+				},
+				TimeoutSeconds: &timeoutSeconds,
+			},
+		},
+	}
+
+	ex := &executor{
+		Context:   context.Background(),
+		Logger:    ctrl.Log.WithName("executor"),
+		transport: utilnet.SetTransportDefaults(&http.Transport{}),
+	}
+
+	// Placeholders in the path are expanded with the request data before the request is sent.
+	data := map[string]string{
+		"pod.namespace": "default",
+		"pod.name":      "pod1",
+	}
+	succeeded, result, err := ex.doHTTPRequestWithContext(context.Background(), operation, data)
+	assert.NoError(t, err)
+	assert.True(t, succeeded)
+	assert.Equal(t, "/profile/default/pod1", requestedPath)
+	assert.Equal(t, map[string]string{"profiler.endpoint": "ok"}, result)
+
+	// A placeholder referencing a missing key fails the request.
+	_, _, err = ex.doHTTPRequestWithContext(context.Background(), operation, map[string]string{"pod.namespace": "default"})
+	assert.Error(t, err)
+}
+
+func TestExpandFieldTemplate(t *testing.T) {
+	data := map[string]string{
+		"pod.namespace":       "default",
+		"pod.name":            "pod1",
+		"node":                "node1",
+		"param.profiler.port": "6060",
+	}
+
+	tests := []struct {
+		field     string
+		expected  string
+		expectErr bool
+		message   string
+	}{
+		{
+			field:    "/processor/podCollector",
+			expected: "/processor/podCollector",
+			message:  "field without placeholders is returned unchanged",
+		},
+		{
+			field:    "/profile/{{ .pod.name }}",
+			expected: "/profile/pod1",
+			message:  "dotted telemetry key is expanded",
+		},
+		{
+			field:    "{{ .node }}:{{ .param.profiler.port }}",
+			expected: "node1:6060",
+			message:  "plain and parameter keys are expanded",
+		},
+		{
+			field:     "/profile/{{ .pod.uid }}",
+			expectErr: true,
+			message:   "missing key is an error",
+		},
+		{
+			field:     "/profile/{{ .pod.name",
+			expectErr: true,
+			message:   "invalid template is an error",
+		},
+	}
+
+	for _, test := range tests {
+		expanded, err := expandFieldTemplate(test.field, data)
+		if test.expectErr {
+			assert.Error(t, err, test.message)
+			continue
+		}
+		assert.NoError(t, err, test.message)
+		assert.Equal(t, test.expected, expanded, test.message)
+	}
+}
+
+func TestRunScriptWithContextTemplatedArgs(t *testing.T) {
+	dataRoot, err := ioutil.TempDir("", "executor-templated-args")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dataRoot)
+	scriptDir := filepath.Join(dataRoot, controllers.ScriptSubDirectory)
+	err = os.MkdirAll(scriptDir, 0755)
+	assert.NoError(t, err)
+	err = ioutil.WriteFile(filepath.Join(scriptDir, "operation1"), []byte("echo -n \"$1 $2\"\n"), 0755)
+	assert.NoError(t, err)
+
+	timeoutSeconds := int32(30)
+	operationResultKey := "script"
+	operation := diagnosisv1.Operation{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "operation1",
+		},
+		Spec: diagnosisv1.OperationSpec{
+			Processor: diagnosisv1.Processor{
+				ScriptRunner: &diagnosisv1.ScriptRunner{
+					Script:             "echo -n \"$1 $2\"",
+					ArgKeys:            []string{"node", "pod-{{ .pod.name }}"},
+					OperationResultKey: &operationResultKey,
+				},
+				TimeoutSeconds: &timeoutSeconds,
+			},
+		},
+	}
+
+	ex := &executor{
+		Context:  context.Background(),
+		Logger:   ctrl.Log.WithName("executor"),
+		dataRoot: dataRoot,
+	}
+
+	// A templated entry is expanded and passed as a literal argument while plain entries are
+	// looked up as keys.
+	succeeded, result, err := ex.runScriptWithContext(context.Background(), operation, map[string]string{
+		"node":     "node1",
+		"pod.name": "pod1",
+	})
+	assert.NoError(t, err)
+	assert.True(t, succeeded)
+	assert.Equal(t, "node1 pod-pod1", result["operation.script.output"])
+}
+
+func TestSyncTaskInjectsNodeTelemetry(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = diagnosisv1.AddToScheme(scheme)
+
+	// The processor echoes the received request data back in the result.
+	var receivedData map[string]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		err := json.NewDecoder(r.Body).Decode(&receivedData)
+		assert.NoError(t, err)
+		w.Write([]byte(`{"operation.result":"value1"}`))
+	}))
+	defer server.Close()
+
+	serverURL, err := url.Parse(server.URL)
+	assert.NoError(t, err)
+	address := serverURL.Hostname()
+	portInt, err := strconv.Atoi(serverURL.Port())
+	assert.NoError(t, err)
+	port := int32(portInt)
+	path := "/processor"
+	httpScheme := "http"
+	timeoutSeconds := int32(30)
+	operation := &diagnosisv1.Operation{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "operation1",
+		},
+		Spec: diagnosisv1.OperationSpec{
+			Processor: diagnosisv1.Processor{
+				HTTPServer: &diagnosisv1.HTTPServer{
+					Address: &address,
+					Port:    &port,
+					Path:    &path,
+					Scheme:  &httpScheme,
+				},
+				TimeoutSeconds: &timeoutSeconds,
+			},
+			NodeTelemetry: &diagnosisv1.NodeTelemetry{
+				Labels:      []string{"topology.kubernetes.io/region", "missing-label"},
+				Annotations: []string{"cluster-autoscaler.kubernetes.io/scale-down-disabled"},
+			},
+		},
+	}
+	node := &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "node1",
+			Labels: map[string]string{
+				"topology.kubernetes.io/region":    "us-west-1",
+				"node.kubernetes.io/instance-type": "m5.large",
+			},
+			Annotations: map[string]string{
+				"cluster-autoscaler.kubernetes.io/scale-down-disabled": "true",
+			},
+		},
+	}
+	task := &diagnosisv1.Task{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "task1",
+			Namespace: "default",
+		},
+		Spec: diagnosisv1.TaskSpec{
+			Operation: "operation1",
+			NodeName:  "node1",
+		},
+		Status: diagnosisv1.TaskStatus{
+			Phase: diagnosisv1.TaskRunning,
+		},
+	}
+
+	cli := fake.NewFakeClientWithScheme(scheme, operation, node, task)
+	ex := &executor{
+		Context:       context.Background(),
+		Logger:        ctrl.Log.WithName("executor"),
+		client:        cli,
+		eventRecorder: record.NewFakeRecorder(100),
+		nodeName:      "node1",
+		transport:     utilnet.SetTransportDefaults(&http.Transport{}),
+	}
+
+	synced, err := ex.syncTask(*task)
+	assert.NoError(t, err)
+	assert.Equal(t, diagnosisv1.TaskSucceeded, synced.Status.Phase)
+
+	// Only selected keys present on the node are injected into the request data.
+	assert.Equal(t, "us-west-1", receivedData["node.label.topology.kubernetes.io/region"])
+	assert.Equal(t, "true", receivedData["node.annotation.cluster-autoscaler.kubernetes.io/scale-down-disabled"])
+	_, ok := receivedData["node.label.missing-label"]
+	assert.False(t, ok)
+	_, ok = receivedData["node.label.node.kubernetes.io/instance-type"]
+	assert.False(t, ok)
+}
+
+func TestSyncTaskSingletonOperationSerializes(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = diagnosisv1.AddToScheme(scheme)
+
+	// The processor tracks the number of in-flight requests to detect concurrent executions.
+	var inFlight, maxInFlight int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		current := atomic.AddInt32(&inFlight, 1)
+		for {
+			max := atomic.LoadInt32(&maxInFlight)
+			if current <= max || atomic.CompareAndSwapInt32(&maxInFlight, max, current) {
+				break
+			}
+		}
+		time.Sleep(100 * time.Millisecond)
+		atomic.AddInt32(&inFlight, -1)
+		w.Write([]byte(`{"operation.result":"value1"}`))
+	}))
+	defer server.Close()
+
+	serverURL, err := url.Parse(server.URL)
+	assert.NoError(t, err)
+	address := serverURL.Hostname()
+	portInt, err := strconv.Atoi(serverURL.Port())
+	assert.NoError(t, err)
+	port := int32(portInt)
+	path := "/processor"
+	httpScheme := "http"
+	timeoutSeconds := int32(30)
+	operation := &diagnosisv1.Operation{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "operation1",
+		},
+		Spec: diagnosisv1.OperationSpec{
+			Processor: diagnosisv1.Processor{
+				HTTPServer: &diagnosisv1.HTTPServer{
+					Address: &address,
+					Port:    &port,
+					Path:    &path,
+					Scheme:  &httpScheme,
+				},
+				TimeoutSeconds: &timeoutSeconds,
+			},
+			Concurrency: diagnosisv1.SingletonConcurrency,
+		},
+	}
+	tasks := []*diagnosisv1.Task{}
+	for _, name := range []string{"task1", "task2"} {
+		tasks = append(tasks, &diagnosisv1.Task{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: "default",
+			},
+			Spec: diagnosisv1.TaskSpec{
+				Operation: "operation1",
+				NodeName:  "node1",
+			},
+			Status: diagnosisv1.TaskStatus{
+				Phase: diagnosisv1.TaskRunning,
+			},
+		})
+	}
+
+	cli := fake.NewFakeClientWithScheme(scheme, operation, tasks[0], tasks[1])
+	ex := &executor{
+		Context:       context.Background(),
+		Logger:        ctrl.Log.WithName("executor"),
+		client:        cli,
+		eventRecorder: record.NewFakeRecorder(100),
+		nodeName:      "node1",
+		transport:     utilnet.SetTransportDefaults(&http.Transport{}),
+	}
+
+	var waitGroup sync.WaitGroup
+	for _, task := range tasks {
+		waitGroup.Add(1)
+		go func(task diagnosisv1.Task) {
+			defer waitGroup.Done()
+			synced, err := ex.syncTask(task)
+			assert.NoError(t, err)
+			assert.Equal(t, diagnosisv1.TaskSucceeded, synced.Status.Phase)
+		}(*task)
+	}
+	waitGroup.Wait()
+
+	// The node-local lock of the singleton operation keeps the processor from seeing
+	// concurrent requests.
+	assert.Equal(t, int32(1), atomic.LoadInt32(&maxInFlight))
+}
+
+func TestValidateParameterTypes(t *testing.T) {
+	parameterTypes := map[string]diagnosisv1.ParameterType{
+		"param.name":    diagnosisv1.StringParameterType,
+		"param.count":   diagnosisv1.IntegerParameterType,
+		"param.enabled": diagnosisv1.BooleanParameterType,
+	}
+
+	tests := []struct {
+		parameters  map[string]string
+		errContains string
+		desc        string
+	}{
+		{
+			parameters: map[string]string{
+				"param.name":    "name1",
+				"param.count":   "3",
+				"param.enabled": "true",
+			},
+			errContains: "",
+			desc:        "all parameters conform to declared types",
+		},
+		{
+			parameters: map[string]string{
+				"param.undeclared": "value1",
+			},
+			errContains: "",
+			desc:        "undeclared and absent parameters are not validated",
+		},
+		{
+			parameters: map[string]string{
+				"param.count": "three",
+			},
+			errContains: "param.count must be an integer",
+			desc:        "non-integer value for an integer parameter",
+		},
+		{
+			parameters: map[string]string{
+				"param.enabled": "yes",
+			},
+			errContains: "param.enabled must be a boolean",
+			desc:        "non-boolean value for a boolean parameter",
+		},
+	}
+
+	for _, test := range tests {
+		err := validateParameterTypes(test.parameters, parameterTypes)
+		if test.errContains == "" {
+			assert.NoError(t, err, test.desc)
+		} else if assert.Error(t, err, test.desc) {
+			assert.Contains(t, err.Error(), test.errContains, test.desc)
+		}
+	}
+}
+
+func TestSyncTaskInvalidParameterFailsTask(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = diagnosisv1.AddToScheme(scheme)
+
+	operation := &diagnosisv1.Operation{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "operation1",
+		},
+		Spec: diagnosisv1.OperationSpec{
+			Processor: diagnosisv1.Processor{
+				ScriptRunner: &diagnosisv1.ScriptRunner{
+					Script: "#!/bin/sh\necho ok",
+				},
+			},
+			ParameterTypes: map[string]diagnosisv1.ParameterType{
+				"param.enabled": diagnosisv1.BooleanParameterType,
+			},
+		},
+	}
+	task := &diagnosisv1.Task{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "task1",
+			Namespace: "default",
+		},
+		Spec: diagnosisv1.TaskSpec{
+			Operation: "operation1",
+			NodeName:  "node1",
+			Parameters: map[string]string{
+				"param.enabled": "yes",
+			},
+		},
+		Status: diagnosisv1.TaskStatus{
+			Phase: diagnosisv1.TaskRunning,
+		},
+	}
+
+	cli := fake.NewFakeClientWithScheme(scheme, operation, task)
+	ex := &executor{
+		Context:       context.Background(),
+		Logger:        ctrl.Log.WithName("executor"),
+		client:        cli,
+		eventRecorder: record.NewFakeRecorder(100),
+		nodeName:      "node1",
+	}
+
+	synced, err := ex.syncTask(*task)
+	assert.NoError(t, err)
+	assert.Equal(t, diagnosisv1.TaskFailed, synced.Status.Phase)
+	_, condition := util.GetTaskCondition(&synced.Status, diagnosisv1.TaskIncomplete)
+	if assert.NotNil(t, condition) {
+		assert.Equal(t, TaskFailureReasonInvalidParameter, condition.Reason)
+	}
+}
+
+func TestRunScriptWithContextCancellation(t *testing.T) {
+	dataRoot, err := ioutil.TempDir("", "executor-cancel")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dataRoot)
+	scriptDirectory := filepath.Join(dataRoot, controllers.ScriptSubDirectory)
+	err = os.MkdirAll(scriptDirectory, 0755)
+	assert.NoError(t, err)
+	err = ioutil.WriteFile(filepath.Join(scriptDirectory, "operation1"), []byte("#!/bin/sh\nsleep 30\n"), 0755)
+	assert.NoError(t, err)
+
+	timeoutSeconds := int32(60)
+	operation := diagnosisv1.Operation{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "operation1",
+		},
+		Spec: diagnosisv1.OperationSpec{
+			Processor: diagnosisv1.Processor{
+				ScriptRunner: &diagnosisv1.ScriptRunner{
+					Script: "#!/bin/sh\nsleep 30\n",
+				},
+				TimeoutSeconds: &timeoutSeconds,
+			},
+		},
+	}
+	ex := &executor{
+		Context:  context.Background(),
+		Logger:   ctrl.Log.WithName("executor"),
+		dataRoot: dataRoot,
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		cancel()
+	}()
+
+	startTime := time.Now()
+	_, _, err = ex.runScriptWithContext(ctx, operation, nil)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "command aborted")
+	}
+	// The long-running script is killed well before its timeout once the context is cancelled.
+	assert.Less(t, int64(time.Since(startTime)), int64(10*time.Second))
+}
+
+func TestDisallowedScriptCommands(t *testing.T) {
+	tests := []struct {
+		script          string
+		allowedCommands []string
+		expected        []string
+		desc            string
+	}{
+		{
+			script:          "#!/bin/sh\ncurl http://example.com\n",
+			allowedCommands: nil,
+			expected:        []string{},
+			desc:            "empty allowlist does not restrict commands",
+		},
+		{
+			script:          "#!/bin/sh\nls /var/log\ngrep error /var/log/messages\n",
+			allowedCommands: []string{"ls", "grep"},
+			expected:        []string{},
+			desc:            "all invoked commands are allowed",
+		},
+		{
+			script:          "#!/bin/sh\nls /var/log\ncurl http://example.com\n",
+			allowedCommands: []string{"ls"},
+			expected:        []string{"curl"},
+			desc:            "disallowed command is reported",
+		},
+		{
+			script:          "#!/bin/sh\ncat /proc/meminfo | grep MemFree && rm -rf /tmp/data\n",
+			allowedCommands: []string{"cat", "grep"},
+			expected:        []string{"rm"},
+			desc:            "commands after pipes and separators are checked",
+		},
+		{
+			script:          "#!/bin/sh\nresult=$(curl http://example.com)\necho $result\n",
+			allowedCommands: []string{"ls"},
+			expected:        []string{"curl"},
+			desc:            "commands in command substitutions are checked",
+		},
+		{
+			script:          "#!/bin/sh\nif true; then\n  FOO=1 /usr/bin/jq .field /tmp/data.json\nfi\n",
+			allowedCommands: []string{"jq"},
+			expected:        []string{},
+			desc:            "keywords and assignments are skipped and base names are matched",
+		},
+		{
+			script:          "#!/bin/sh\nkubectl get pods\nkubectl-debug node1\n",
+			allowedCommands: []string{"kubectl.*"},
+			expected:        []string{},
+			desc:            "allowlist entries are matched as regular expressions",
+		},
+	}
+
+	for _, test := range tests {
+		disallowed := disallowedScriptCommands(test.script, test.allowedCommands)
+		if len(test.expected) == 0 {
+			assert.Empty(t, disallowed, test.desc)
+		} else {
+			assert.Equal(t, test.expected, disallowed, test.desc)
+		}
+	}
+}
+
+func TestRunScriptWithContextDisallowedCommand(t *testing.T) {
+	timeoutSeconds := int32(30)
+	operation := diagnosisv1.Operation{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "operation1",
+		},
+		Spec: diagnosisv1.OperationSpec{
+			Processor: diagnosisv1.Processor{
+				ScriptRunner: &diagnosisv1.ScriptRunner{
+					Script: "#!/bin/sh\ncurl http://example.com\n",
+				},
+				TimeoutSeconds: &timeoutSeconds,
+			},
+		},
+	}
+	ex := &executor{
+		Context:               context.Background(),
+		Logger:                ctrl.Log.WithName("executor"),
+		allowedScriptCommands: []string{"ls", "grep"},
+	}
+
+	_, _, err := ex.runScriptWithContext(context.Background(), operation, nil)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "disallowed commands: curl")
+	}
+}
+
+func TestNsenterCommand(t *testing.T) {
+	command, err := nsenterCommand(1234, []diagnosisv1.ContainerNamespace{
+		diagnosisv1.NetNamespace,
+		diagnosisv1.MountNamespace,
+		diagnosisv1.PIDNamespace,
+		diagnosisv1.IPCNamespace,
+		diagnosisv1.UTSNamespace,
+	}, []string{"/bin/sh", "script.sh", "arg1"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"nsenter", "--target", "1234", "--net", "--mount", "--pid", "--ipc", "--uts", "--", "/bin/sh", "script.sh", "arg1"}, command)
+
+	command, err = nsenterCommand(1, []diagnosisv1.ContainerNamespace{diagnosisv1.NetNamespace}, []string{"/bin/sh", "script.sh"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"nsenter", "--target", "1", "--net", "--", "/bin/sh", "script.sh"}, command)
+
+	_, err = nsenterCommand(1234, []diagnosisv1.ContainerNamespace{"cgroup"}, []string{"/bin/sh", "script.sh"})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "unsupported container namespace")
+}
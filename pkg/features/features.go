@@ -28,6 +28,11 @@ import (
 )
 
 const (
+	// ClusterHealthEvaluator evaluates the health of kubernetes cluster.
+	//
+	// Mode: master
+	// Alpha: 0.2.3
+	ClusterHealthEvaluator featuregate.Feature = "ClusterHealthEvaluator"
 	// Alertmanager can handle valid post alerts requests.
 	//
 	// Mode: master
@@ -58,6 +63,11 @@ const (
 	// Owner: @huangjiuyuan
 	// Alpha: 0.2.1
 	CronScheduler featuregate.Feature = "CronScheduler"
+	// NodeConditionWatcher creates diagnoses for nodes with persisting unhealthy conditions.
+	//
+	// Mode: master
+	// Alpha: 0.2.3
+	NodeConditionWatcher featuregate.Feature = "NodeConditionWatcher"
 	// KafkaConsumer can processs valid kafka messages.
 	//
 	// Mode: master
@@ -131,6 +141,47 @@ const (
 	// Owner: @April-Q
 	// Alpha: 0.2.0
 	GoProfiler featuregate.Feature = "GoProfiler"
+	// KubeletLogCollector collects the kubelet log of a node.
+	//
+	// Mode: agent
+	// Alpha: 0.2.3
+	KubeletLogCollector featuregate.Feature = "KubeletLogCollector"
+	// PodVolumeCollector enumerates pod volumes under the kubelet pod directory.
+	//
+	// Mode: agent
+	// Alpha: 0.2.3
+	PodVolumeCollector featuregate.Feature = "PodVolumeCollector"
+	// ContainerLogCollector fetches container logs through the kubernetes api.
+	//
+	// Mode: agent
+	// Alpha: 0.2.3
+	ContainerLogCollector featuregate.Feature = "ContainerLogCollector"
+	// DmesgCollector collects the kernel ring buffer of a node.
+	//
+	// Mode: agent
+	// Alpha: 0.2.3
+	DmesgCollector featuregate.Feature = "DmesgCollector"
+	// IptablesCollector dumps iptables or nftables rules of a node. It requires privileges to
+	// read the packet filter tables.
+	//
+	// Mode: agent
+	// Alpha: 0.2.3
+	IptablesCollector featuregate.Feature = "IptablesCollector"
+	// SysctlCollector collects sysctls and kernel parameters of a node.
+	//
+	// Mode: agent
+	// Alpha: 0.2.3
+	SysctlCollector featuregate.Feature = "SysctlCollector"
+	// NodeDrain cordons a node and optionally evicts the pods running on it.
+	//
+	// Mode: agent
+	// Alpha: 0.2.3
+	NodeDrain featuregate.Feature = "NodeDrain"
+	// PodDelete deletes a pod so that its controller recreates it.
+	//
+	// Mode: agent
+	// Alpha: 0.2.3
+	PodDelete featuregate.Feature = "PodDelete"
 	// MountInfoCollector manages mount info on node
 	//
 	// Mode: agent
@@ -150,6 +201,16 @@ const (
 	// Owner: @April-Q
 	// Alpha: 0.2.0
 	ElasticsearchCollector featuregate.Feature = "ElasticsearchCollector"
+	// PrometheusQueryDiagnoser runs a promql query against a prometheus endpoint.
+	//
+	// Mode: agent
+	// Alpha: 0.2.3
+	PrometheusQueryDiagnoser featuregate.Feature = "PrometheusQueryDiagnoser"
+	// CertExpiryDiagnoser checks certificate expiry of serving endpoints over tls.
+	//
+	// Mode: agent
+	// Alpha: 0.2.3
+	CertExpiryDiagnoser featuregate.Feature = "CertExpiryDiagnoser"
 	// SonobuoyResultDiagnoser analyzes failures in the result of a sonobuoy e2e test run.
 	//
 	// Mode: agent
@@ -174,6 +235,48 @@ const (
 	// Owner: yanwenhao
 	// Alpha: 0.2.3
 	TcpdumpProfiler featuregate.Feature = "TcpdumpProfiler"
+	// PodEventDiagnoser summarizes kubernetes events involving a pod.
+	//
+	// Mode: agent
+	// Alpha: 0.2.3
+	PodEventDiagnoser featuregate.Feature = "PodEventDiagnoser"
+	// PodSchedulingDiagnoser analyzes scheduling failures of a pending pod.
+	//
+	// Mode: agent
+	// Alpha: 0.2.3
+	PodSchedulingDiagnoser featuregate.Feature = "PodSchedulingDiagnoser"
+	// OOMKilledDiagnoser reports containers on the node terminated by the out of memory killer.
+	//
+	// Mode: agent
+	// Alpha: 0.2.3
+	OOMKilledDiagnoser featuregate.Feature = "OOMKilledDiagnoser"
+	// EtcdHealthDiagnoser snapshots etcd member health of a self managed control plane.
+	// It requires etcd client credentials if the cluster serves etcd over tls.
+	//
+	// Mode: agent
+	// Alpha: 0.2.3
+	EtcdHealthDiagnoser featuregate.Feature = "EtcdHealthDiagnoser"
+	// WorkloadReplicaDiagnoser reports workloads whose available replicas lag desired replicas.
+	//
+	// Mode: agent
+	// Alpha: 0.2.3
+	WorkloadReplicaDiagnoser featuregate.Feature = "WorkloadReplicaDiagnoser"
+	// PodLogDiagnoser greps container log files of a pod under the kubelet log directory
+	// on the node.
+	//
+	// Mode: agent
+	// Alpha: 0.2.3
+	PodLogDiagnoser featuregate.Feature = "PodLogDiagnoser"
+	// PSICollector collects pressure stall information of the node and pod cgroups.
+	//
+	// Mode: agent
+	// Alpha: 0.2.3
+	PSICollector featuregate.Feature = "PSICollector"
+	// SelfProfiler captures pprof profiles of the kubediag agent itself.
+	//
+	// Mode: agent
+	// Alpha: 0.2.3
+	SelfProfiler featuregate.Feature = "SelfProfiler"
 )
 
 var (
@@ -187,11 +290,13 @@ var (
 )
 
 var defaultKubeDiagFeatureGates = map[featuregate.Feature]featuregate.FeatureSpec{
+	ClusterHealthEvaluator:       {Default: true, PreRelease: featuregate.Alpha},
 	Alertmanager:                 {Default: true, PreRelease: featuregate.Alpha},
 	Eventer:                      {Default: false, PreRelease: featuregate.Alpha},
 	PagerDutyEventer:             {Default: true, PreRelease: featuregate.Alpha},
 	CommonEventer:                {Default: true, PreRelease: featuregate.Alpha},
 	CronScheduler:                {Default: true, PreRelease: featuregate.Alpha},
+	NodeConditionWatcher:         {Default: true, PreRelease: featuregate.Alpha},
 	KafkaConsumer:                {Default: true, PreRelease: featuregate.Alpha},
 	PodCollector:                 {Default: true, PreRelease: featuregate.Alpha},
 	ContainerCollector:           {Default: true, PreRelease: featuregate.Alpha},
@@ -204,13 +309,31 @@ var defaultKubeDiagFeatureGates = map[featuregate.Feature]featuregate.FeatureSpe
 	ContainerdGoroutineCollector: {Default: true, PreRelease: featuregate.Alpha},
 	NodeCordon:                   {Default: true, PreRelease: featuregate.Alpha},
 	GoProfiler:                   {Default: true, PreRelease: featuregate.Alpha},
+	KubeletLogCollector:          {Default: true, PreRelease: featuregate.Alpha},
+	PodVolumeCollector:           {Default: true, PreRelease: featuregate.Alpha},
+	ContainerLogCollector:        {Default: true, PreRelease: featuregate.Alpha},
+	DmesgCollector:               {Default: true, PreRelease: featuregate.Alpha},
+	IptablesCollector:            {Default: true, PreRelease: featuregate.Alpha},
+	SysctlCollector:              {Default: true, PreRelease: featuregate.Alpha},
+	NodeDrain:                    {Default: true, PreRelease: featuregate.Alpha},
+	PodDelete:                    {Default: true, PreRelease: featuregate.Alpha},
 	MountInfoCollector:           {Default: true, PreRelease: featuregate.Alpha},
 	SubpathRemountDiagnoser:      {Default: true, PreRelease: featuregate.Alpha},
 	ElasticsearchCollector:       {Default: true, PreRelease: featuregate.Alpha},
+	PrometheusQueryDiagnoser:     {Default: true, PreRelease: featuregate.Alpha},
+	CertExpiryDiagnoser:          {Default: true, PreRelease: featuregate.Alpha},
+	PodSchedulingDiagnoser:       {Default: true, PreRelease: featuregate.Alpha},
+	OOMKilledDiagnoser:           {Default: true, PreRelease: featuregate.Alpha},
+	EtcdHealthDiagnoser:          {Default: true, PreRelease: featuregate.Alpha},
 	SonobuoyResultDiagnoser:      {Default: true, PreRelease: featuregate.Alpha},
 	StatefulSetDetailCollector:   {Default: true, PreRelease: featuregate.Alpha},
 	StatefulSetStuck:             {Default: true, PreRelease: featuregate.Alpha},
 	TcpdumpProfiler:              {Default: true, PreRelease: featuregate.Alpha},
+	PodEventDiagnoser:            {Default: true, PreRelease: featuregate.Alpha},
+	WorkloadReplicaDiagnoser:     {Default: true, PreRelease: featuregate.Alpha},
+	PodLogDiagnoser:              {Default: true, PreRelease: featuregate.Alpha},
+	PSICollector:                 {Default: true, PreRelease: featuregate.Alpha},
+	SelfProfiler:                 {Default: true, PreRelease: featuregate.Alpha},
 }
 
 // KubeDiagFeatureGate indicates whether a given feature is enabled or not and stores flag gates for known features.
@@ -0,0 +1,178 @@
+/*
+Copyright 2022 The KubeDiag Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package garbagecollection
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	"github.com/kubediag/kubediag/pkg/util"
+)
+
+// dataRootHousekeepingInterval is the interval between data root quota enforcements.
+const dataRootHousekeepingInterval = 30 * time.Minute
+
+var (
+	dataRootGarbageCollectionCycleCount = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "data_root_garbage_collection_cycle_count",
+			Help: "Counter of data root garbage collection cycle",
+		},
+	)
+	dataRootGarbageCollectionErrorCount = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "data_root_garbage_collection_error_count",
+			Help: "Counter of erroneous data root garbage collections",
+		},
+	)
+	dataRootReclaimedBytes = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "data_root_reclaimed_bytes",
+			Help: "Counter of bytes reclaimed by data root garbage collections",
+		},
+	)
+)
+
+// DataRootJanitor enforces a quota on the total size of files under the kubediag data root.
+type DataRootJanitor struct {
+	// Context carries values across API boundaries.
+	context.Context
+	// Logger represents the ability to log messages.
+	logr.Logger
+
+	// dataRoot is root directory of persistent kubediag data.
+	dataRoot string
+	// maximumDataRootBytes is maximum total size in bytes of files retained under the data root.
+	maximumDataRootBytes int64
+}
+
+// NewDataRootJanitor creates a new DataRootJanitor.
+func NewDataRootJanitor(
+	ctx context.Context,
+	logger logr.Logger,
+	dataRoot string,
+	maximumDataRootBytes int64,
+) *DataRootJanitor {
+	metrics.Registry.MustRegister(
+		dataRootGarbageCollectionCycleCount,
+		dataRootGarbageCollectionErrorCount,
+		dataRootReclaimedBytes,
+	)
+
+	return &DataRootJanitor{
+		Context:              ctx,
+		Logger:               logger,
+		dataRoot:             dataRoot,
+		maximumDataRootBytes: maximumDataRootBytes,
+	}
+}
+
+// Run runs the data root janitor.
+func (dj *DataRootJanitor) Run(stopCh <-chan struct{}) {
+	// The quota is not enforced if maximumDataRootBytes is not positive.
+	if dj.maximumDataRootBytes <= 0 {
+		return
+	}
+
+	go wait.Until(func() {
+		dataRootGarbageCollectionCycleCount.Inc()
+
+		reclaimed, err := EnforceDataRootQuota(dj.dataRoot, dj.maximumDataRootBytes, dj)
+		if err != nil {
+			dataRootGarbageCollectionErrorCount.Inc()
+			dj.Error(err, "failed to enforce data root quota")
+			return
+		}
+
+		if reclaimed > 0 {
+			dataRootReclaimedBytes.Add(float64(reclaimed))
+			dj.Info("data root quota enforced", "reclaimedBytes", reclaimed)
+		}
+	}, dataRootHousekeepingInterval, stopCh)
+}
+
+// EnforceDataRootQuota deletes the oldest files under path until the total size of all files is
+// within maximumBytes. It returns the number of bytes reclaimed.
+func EnforceDataRootQuota(path string, maximumBytes int64, log logr.Logger) (int64, error) {
+	// Return if the data root directory does not exist.
+	_, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+
+	type fileDetail struct {
+		path    string
+		size    int64
+		modTime time.Time
+	}
+
+	var files []fileDetail
+	var totalBytes int64
+	err = filepath.Walk(path, func(filePath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+
+		files = append(files, fileDetail{
+			path:    filePath,
+			size:    info.Size(),
+			modTime: info.ModTime(),
+		})
+		totalBytes += info.Size()
+
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	if totalBytes <= maximumBytes {
+		return 0, nil
+	}
+
+	// Delete the oldest files first until the total size is within the quota.
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].modTime.Before(files[j].modTime)
+	})
+
+	var reclaimedBytes int64
+	for _, file := range files {
+		if totalBytes <= maximumBytes {
+			break
+		}
+
+		if err := util.RemoveFile(file.path); err != nil {
+			log.Error(err, "unable to remove file", "file", file.path)
+			continue
+		}
+		totalBytes -= file.size
+		reclaimedBytes += file.size
+	}
+
+	return reclaimedBytes, nil
+}
@@ -0,0 +1,82 @@
+/*
+Copyright 2022 The KubeDiag Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package garbagecollection
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+func TestEnforceDataRootQuota(t *testing.T) {
+	dataRoot, err := ioutil.TempDir("", "datarootjanitor")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dataRoot)
+
+	err = os.MkdirAll(filepath.Join(dataRoot, "profilers"), 0755)
+	assert.NoError(t, err)
+
+	// Create three files of 100 bytes each with distinct modification times.
+	now := time.Now()
+	files := []struct {
+		path    string
+		modTime time.Time
+	}{
+		{filepath.Join(dataRoot, "profilers", "oldest"), now.Add(-3 * time.Hour)},
+		{filepath.Join(dataRoot, "older"), now.Add(-2 * time.Hour)},
+		{filepath.Join(dataRoot, "newest"), now.Add(-1 * time.Hour)},
+	}
+	for _, file := range files {
+		err := ioutil.WriteFile(file.path, bytes.Repeat([]byte("a"), 100), 0644)
+		assert.NoError(t, err)
+		err = os.Chtimes(file.path, file.modTime, file.modTime)
+		assert.NoError(t, err)
+	}
+
+	// The two oldest files are deleted to bring the total size within the quota.
+	reclaimed, err := EnforceDataRootQuota(dataRoot, 100, ctrl.Log.WithName("datarootjanitor"))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(200), reclaimed)
+	_, err = os.Stat(files[0].path)
+	assert.True(t, os.IsNotExist(err))
+	_, err = os.Stat(files[1].path)
+	assert.True(t, os.IsNotExist(err))
+	_, err = os.Stat(files[2].path)
+	assert.NoError(t, err)
+}
+
+func TestEnforceDataRootQuotaWithinLimit(t *testing.T) {
+	dataRoot, err := ioutil.TempDir("", "datarootjanitor")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dataRoot)
+
+	err = ioutil.WriteFile(filepath.Join(dataRoot, "file"), bytes.Repeat([]byte("a"), 100), 0644)
+	assert.NoError(t, err)
+
+	// No file is deleted if the total size is within the quota.
+	reclaimed, err := EnforceDataRootQuota(dataRoot, 1000, ctrl.Log.WithName("datarootjanitor"))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), reclaimed)
+	_, err = os.Stat(filepath.Join(dataRoot, "file"))
+	assert.NoError(t, err)
+}
@@ -79,6 +79,9 @@ type TaskReaper struct {
 	minimumTaskTTLDuration time.Duration
 	// maximumTasksPerNode is maximum number of finished tasks to retain per node.
 	maximumTasksPerNode int32
+	// housekeepingInterval is the interval between garbage collection runs. A quarter of
+	// taskTTL is used if it is not positive.
+	housekeepingInterval time.Duration
 	// dataRoot is root directory of persistent kubediag data.
 	dataRoot string
 }
@@ -94,6 +97,7 @@ func NewTaskReaper(
 	taskTTL time.Duration,
 	minimumTaskTTLDuration time.Duration,
 	maximumTasksPerNode int32,
+	housekeepingInterval time.Duration,
 	dataRoot string,
 ) *TaskReaper {
 	metrics.Registry.MustRegister(
@@ -112,6 +116,7 @@ func NewTaskReaper(
 		taskTTL:                taskTTL,
 		minimumTaskTTLDuration: minimumTaskTTLDuration,
 		maximumTasksPerNode:    maximumTasksPerNode,
+		housekeepingInterval:   housekeepingInterval,
 		dataRoot:               dataRoot,
 	}
 }
@@ -123,8 +128,12 @@ func (tr *TaskReaper) Run(stopCh <-chan struct{}) {
 		return
 	}
 
-	// The housekeeping interval of garbage collections is a quarter of taskTTL.
-	housekeepingInterval := tr.taskTTL / 4
+	// The housekeeping interval of garbage collections defaults to a quarter of taskTTL if
+	// not specified.
+	housekeepingInterval := tr.housekeepingInterval
+	if housekeepingInterval <= 0 {
+		housekeepingInterval = tr.taskTTL / 4
+	}
 	go wait.Until(func() {
 		tr.Info("running garbage collection")
 		taskGarbageCollectionCycleCount.Inc()
@@ -137,51 +146,27 @@ func (tr *TaskReaper) Run(stopCh <-chan struct{}) {
 			return
 		}
 
-		reapedTasks := make([]diagnosisv1.Task, 0)
-		retainedTasks := make([]diagnosisv1.Task, 0)
-		if len(tasks) != 0 {
-			for _, task := range tasks {
-				// Garbage collect the task if it is under any of the following conditions:
-				//
-				// Its age is longer than taskTTL.
-				// Its age is longer than minimumTaskTTLDuration and its phase is Failed or Succeeded.
-				if time.Now().Sub(task.Status.StartTime.Time) > tr.taskTTL {
-					reapedTasks = append(reapedTasks, task)
-				} else if task.Status.Phase == diagnosisv1.TaskFailed || task.Status.Phase == diagnosisv1.TaskSucceeded {
-					if time.Now().Sub(task.Status.StartTime.Time) > tr.minimumTaskTTLDuration {
-						reapedTasks = append(reapedTasks, task)
-					}
-				}
-
-				retainedTasks = append(retainedTasks, task)
-			}
+		// Blank out expired results of finished tasks before reaping. The tasks themselves
+		// are kept until their own TTL elapses.
+		tr.expireTaskResults(tasks)
 
-			// Garbage collect old tasks if count of retained tasks is greater than maximumTasksPerNode.
-			if len(retainedTasks) > int(tr.maximumTasksPerNode) {
-				sorted := types.SortedTaskListByStartTime(retainedTasks)
-				sort.Sort(sorted)
-				for i := 0; i < len(retainedTasks)-int(tr.maximumTasksPerNode); i++ {
-					reapedTasks = append(reapedTasks, sorted[i])
+		reapedTasks := tr.selectReapedTasks(tasks)
+		if len(reapedTasks) > 0 {
+			for _, task := range reapedTasks {
+				err := tr.client.Delete(tr, &task)
+				if err != nil {
+					taskGarbageCollectionErrorCount.Inc()
+					tr.Error(err, "failed to delete task", "task", client.ObjectKey{
+						Name:      task.Name,
+						Namespace: task.Namespace,
+					})
+					continue
+				} else {
+					taskGarbageCollectionSuccessCount.Inc()
 				}
 			}
 
-			if len(reapedTasks) > 0 {
-				for _, task := range reapedTasks {
-					err := tr.client.Delete(tr, &task)
-					if err != nil {
-						taskGarbageCollectionErrorCount.Inc()
-						tr.Error(err, "failed to delete task", "task", client.ObjectKey{
-							Name:      task.Name,
-							Namespace: task.Namespace,
-						})
-						continue
-					} else {
-						taskGarbageCollectionSuccessCount.Inc()
-					}
-				}
-
-				tr.Info("tasks has been garbage collected", "time", time.Now(), "count", len(reapedTasks))
-			}
+			tr.Info("tasks has been garbage collected", "time", time.Now(), "count", len(reapedTasks))
 		}
 
 		// Garbage collect java profiler data on node.
@@ -208,6 +193,88 @@ func (tr *TaskReaper) Run(stopCh <-chan struct{}) {
 	}, housekeepingInterval, stopCh)
 }
 
+// selectReapedTasks selects the tasks to garbage collect on one pass. A task is reaped if it
+// is under any of the following conditions:
+//
+// Its age is longer than taskTTL.
+// Its age is longer than minimumTaskTTLDuration and its phase is Failed or Succeeded.
+// It is one of the oldest tasks over the minimumTaskTTLDuration while more than
+// maximumTasksPerNode tasks are retained.
+func (tr *TaskReaper) selectReapedTasks(tasks []diagnosisv1.Task) []diagnosisv1.Task {
+	reapedTasks := make([]diagnosisv1.Task, 0)
+	retainedTasks := make([]diagnosisv1.Task, 0)
+	for _, task := range tasks {
+		if time.Now().Sub(task.Status.StartTime.Time) > tr.taskTTL {
+			reapedTasks = append(reapedTasks, task)
+			continue
+		}
+		if task.Status.Phase == diagnosisv1.TaskFailed || task.Status.Phase == diagnosisv1.TaskSucceeded {
+			if time.Now().Sub(task.Status.StartTime.Time) > tr.minimumTaskTTLDuration {
+				reapedTasks = append(reapedTasks, task)
+				continue
+			}
+		}
+
+		retainedTasks = append(retainedTasks, task)
+	}
+
+	// Garbage collect old tasks if count of retained tasks is greater than maximumTasksPerNode.
+	// Tasks under the minimumTaskTTLDuration are retained even over the per node maximum.
+	if len(retainedTasks) > int(tr.maximumTasksPerNode) {
+		sorted := types.SortedTaskListByStartTime(retainedTasks)
+		sort.Sort(sorted)
+		overflow := len(retainedTasks) - int(tr.maximumTasksPerNode)
+		for i := 0; i < len(sorted) && overflow > 0; i++ {
+			if time.Now().Sub(sorted[i].Status.StartTime.Time) <= tr.minimumTaskTTLDuration {
+				continue
+			}
+			reapedTasks = append(reapedTasks, sorted[i])
+			overflow--
+		}
+	}
+
+	return reapedTasks
+}
+
+// expireTaskResults blanks out results of finished tasks whose operation declares a result TTL
+// once the TTL has elapsed since the task start. The task objects are kept so sensitive outputs
+// do not persist for the full task TTL.
+func (tr *TaskReaper) expireTaskResults(tasks []diagnosisv1.Task) {
+	for _, task := range tasks {
+		if task.Status.Phase != diagnosisv1.TaskFailed && task.Status.Phase != diagnosisv1.TaskSucceeded {
+			continue
+		}
+		if len(task.Status.Results) == 0 {
+			continue
+		}
+
+		var operation diagnosisv1.Operation
+		if err := tr.cache.Get(tr, client.ObjectKey{Name: task.Spec.Operation}, &operation); err != nil {
+			continue
+		}
+		if operation.Spec.ResultTTLSeconds == nil {
+			continue
+		}
+		if time.Now().Sub(task.Status.StartTime.Time) <= time.Duration(*operation.Spec.ResultTTLSeconds)*time.Second {
+			continue
+		}
+
+		task.Status.Results = nil
+		if err := tr.client.Status().Update(tr, &task); err != nil {
+			taskGarbageCollectionErrorCount.Inc()
+			tr.Error(err, "failed to clear expired results of task", "task", client.ObjectKey{
+				Name:      task.Name,
+				Namespace: task.Namespace,
+			})
+			continue
+		}
+		tr.Info("cleared expired results of task", "task", client.ObjectKey{
+			Name:      task.Name,
+			Namespace: task.Namespace,
+		})
+	}
+}
+
 // listTasks lists Diagnoses from cache.
 func (tr *TaskReaper) listTasks() ([]diagnosisv1.Task, error) {
 	var taskList diagnosisv1.TaskList
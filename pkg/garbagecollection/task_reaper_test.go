@@ -0,0 +1,239 @@
+/*
+Copyright 2022 The KubeDiag Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package garbagecollection
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	diagnosisv1 "github.com/kubediag/kubediag/api/v1"
+)
+
+// fakeCache is a cache.Cache backed by a fake client for testing.
+type fakeCache struct {
+	client.Reader
+}
+
+func (f fakeCache) GetInformer(obj runtime.Object) (cache.Informer, error) {
+	return nil, nil
+}
+
+func (f fakeCache) GetInformerForKind(gvk schema.GroupVersionKind) (cache.Informer, error) {
+	return nil, nil
+}
+
+func (f fakeCache) Start(stopCh <-chan struct{}) error {
+	return nil
+}
+
+func (f fakeCache) WaitForCacheSync(stop <-chan struct{}) bool {
+	return true
+}
+
+func (f fakeCache) IndexField(obj runtime.Object, field string, extractValue client.IndexerFunc) error {
+	return nil
+}
+
+func TestExpireTaskResults(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = diagnosisv1.AddToScheme(scheme)
+
+	resultTTLSeconds := int32(60)
+	operation := &diagnosisv1.Operation{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "operation1",
+		},
+		Spec: diagnosisv1.OperationSpec{
+			ResultTTLSeconds: &resultTTLSeconds,
+		},
+	}
+	operationWithoutTTL := &diagnosisv1.Operation{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "operation2",
+		},
+	}
+	expiredTask := &diagnosisv1.Task{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "task1",
+			Namespace: "default",
+		},
+		Spec: diagnosisv1.TaskSpec{
+			Operation: "operation1",
+			NodeName:  "node1",
+		},
+		Status: diagnosisv1.TaskStatus{
+			Phase:     diagnosisv1.TaskSucceeded,
+			StartTime: metav1.NewTime(time.Now().Add(-2 * time.Minute)),
+			Results: map[string]string{
+				"collector.token": "secret",
+			},
+		},
+	}
+	freshTask := &diagnosisv1.Task{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "task2",
+			Namespace: "default",
+		},
+		Spec: diagnosisv1.TaskSpec{
+			Operation: "operation1",
+			NodeName:  "node1",
+		},
+		Status: diagnosisv1.TaskStatus{
+			Phase:     diagnosisv1.TaskSucceeded,
+			StartTime: metav1.Now(),
+			Results: map[string]string{
+				"collector.token": "secret",
+			},
+		},
+	}
+	taskWithoutTTL := &diagnosisv1.Task{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "task3",
+			Namespace: "default",
+		},
+		Spec: diagnosisv1.TaskSpec{
+			Operation: "operation2",
+			NodeName:  "node1",
+		},
+		Status: diagnosisv1.TaskStatus{
+			Phase:     diagnosisv1.TaskSucceeded,
+			StartTime: metav1.NewTime(time.Now().Add(-2 * time.Minute)),
+			Results: map[string]string{
+				"collector.value": "value1",
+			},
+		},
+	}
+	runningTask := &diagnosisv1.Task{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "task4",
+			Namespace: "default",
+		},
+		Spec: diagnosisv1.TaskSpec{
+			Operation: "operation1",
+			NodeName:  "node1",
+		},
+		Status: diagnosisv1.TaskStatus{
+			Phase:     diagnosisv1.TaskRunning,
+			StartTime: metav1.NewTime(time.Now().Add(-2 * time.Minute)),
+			Results: map[string]string{
+				"collector.token": "secret",
+			},
+		},
+	}
+
+	cli := fake.NewFakeClientWithScheme(scheme, operation, operationWithoutTTL, expiredTask, freshTask, taskWithoutTTL, runningTask)
+	tr := &TaskReaper{
+		Context:  context.Background(),
+		Logger:   ctrl.Log.WithName("garbagecollection/taskreaper"),
+		client:   cli,
+		cache:    fakeCache{Reader: cli},
+		nodeName: "node1",
+	}
+
+	tasks, err := tr.listTasks()
+	assert.NoError(t, err)
+	tr.expireTaskResults(tasks)
+
+	// Only the finished task of an operation with a result TTL older than the TTL is cleared.
+	var task diagnosisv1.Task
+	err = cli.Get(context.Background(), client.ObjectKey{Namespace: "default", Name: "task1"}, &task)
+	assert.NoError(t, err)
+	assert.Empty(t, task.Status.Results)
+	err = cli.Get(context.Background(), client.ObjectKey{Namespace: "default", Name: "task2"}, &task)
+	assert.NoError(t, err)
+	assert.Equal(t, "secret", task.Status.Results["collector.token"])
+	err = cli.Get(context.Background(), client.ObjectKey{Namespace: "default", Name: "task3"}, &task)
+	assert.NoError(t, err)
+	assert.Equal(t, "value1", task.Status.Results["collector.value"])
+	err = cli.Get(context.Background(), client.ObjectKey{Namespace: "default", Name: "task4"}, &task)
+	assert.NoError(t, err)
+	assert.Equal(t, "secret", task.Status.Results["collector.token"])
+}
+
+func TestSelectReapedTasks(t *testing.T) {
+	newTask := func(name string, phase diagnosisv1.TaskPhase, age time.Duration) diagnosisv1.Task {
+		return diagnosisv1.Task{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: "default",
+			},
+			Spec: diagnosisv1.TaskSpec{
+				Operation: "operation1",
+				NodeName:  "node1",
+			},
+			Status: diagnosisv1.TaskStatus{
+				Phase:     phase,
+				StartTime: metav1.Time{Time: time.Now().Add(-age)},
+			},
+		}
+	}
+	reapedNames := func(tasks []diagnosisv1.Task) []string {
+		names := make([]string, 0, len(tasks))
+		for _, task := range tasks {
+			names = append(names, task.Name)
+		}
+		return names
+	}
+
+	tr := &TaskReaper{
+		Context:                context.Background(),
+		Logger:                 ctrl.Log.WithName("garbagecollection/taskreaper"),
+		taskTTL:                240 * time.Hour,
+		minimumTaskTTLDuration: 30 * time.Minute,
+		maximumTasksPerNode:    2,
+	}
+
+	// A task over the taskTTL and a finished task over the minimum ttl are reaped.
+	reaped := tr.selectReapedTasks([]diagnosisv1.Task{
+		newTask("task1", diagnosisv1.TaskRunning, 300*time.Hour),
+		newTask("task2", diagnosisv1.TaskSucceeded, time.Hour),
+		newTask("task3", diagnosisv1.TaskFailed, 10*time.Minute),
+		newTask("task4", diagnosisv1.TaskRunning, time.Hour),
+	})
+	assert.Equal(t, []string{"task1", "task2"}, reapedNames(reaped))
+
+	// The oldest tasks over the per node maximum are reaped while tasks under the minimum
+	// ttl are retained.
+	reaped = tr.selectReapedTasks([]diagnosisv1.Task{
+		newTask("task1", diagnosisv1.TaskRunning, 3*time.Hour),
+		newTask("task2", diagnosisv1.TaskRunning, 2*time.Hour),
+		newTask("task3", diagnosisv1.TaskRunning, time.Hour),
+		newTask("task4", diagnosisv1.TaskRunning, 10*time.Minute),
+	})
+	assert.Equal(t, []string{"task1", "task2"}, reapedNames(reaped))
+
+	// Tasks under the minimum ttl are retained even if the per node maximum is exceeded.
+	tr.maximumTasksPerNode = 1
+	reaped = tr.selectReapedTasks([]diagnosisv1.Task{
+		newTask("task1", diagnosisv1.TaskRunning, time.Hour),
+		newTask("task2", diagnosisv1.TaskRunning, 20*time.Minute),
+		newTask("task3", diagnosisv1.TaskRunning, 10*time.Minute),
+	})
+	assert.Equal(t, []string{"task1"}, reapedNames(reaped))
+}
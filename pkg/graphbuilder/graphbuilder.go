@@ -18,15 +18,21 @@ package graphbuilder
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net/http"
+	"sync"
+	"time"
 
 	"github.com/go-logr/logr"
 	"github.com/prometheus/client_golang/prometheus"
 	"gonum.org/v1/gonum/graph/simple"
 	"gonum.org/v1/gonum/graph/topo"
+	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/workqueue"
 	"sigs.k8s.io/controller-runtime/pkg/cache"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/metrics"
@@ -35,6 +41,20 @@ import (
 	"github.com/kubediag/kubediag/pkg/util"
 )
 
+// errTooManyPaths indicates that path enumeration exceeded the maximum path limit of graph builder.
+var errTooManyPaths = errors.New("number of paths exceeds maximum path limit")
+
+const (
+	// DefaultRequeueBaseDelay is the default base delay for requeueing an operation set after a
+	// failed sync.
+	DefaultRequeueBaseDelay = time.Second
+	// DefaultRequeueMaxDelay is the default maximum delay for requeueing an operation set after
+	// a failed sync.
+	DefaultRequeueMaxDelay = 5 * time.Minute
+	// DefaultWorkers is the default number of workers syncing operation sets concurrently.
+	DefaultWorkers = 1
+)
+
 var (
 	graphbuilderSyncSuccessCount = prometheus.NewCounter(
 		prometheus.CounterOpts{
@@ -64,6 +84,8 @@ type GraphBuilder interface {
 	logr.Logger
 	// Run runs the GraphBuilder.
 	Run(<-chan struct{})
+	// Handler handles http requests.
+	Handler(http.ResponseWriter, *http.Request)
 }
 
 // graphBuilder validates directed acyclic graph defined in the operation set and generates paths
@@ -84,6 +106,17 @@ type graphBuilder struct {
 	cache cache.Cache
 	// graphBuilderCh is a channel for queuing OperationSets to be processed by graph builder.
 	graphBuilderCh chan diagnosisv1.OperationSet
+	// queue rate limits and coalesces operation sets to be synced. Failed syncs are retried
+	// with increasing delay.
+	queue workqueue.RateLimitingInterface
+	// syncFunc syncs an operation set. It points to syncOperationSet and may be overridden in
+	// tests.
+	syncFunc func(diagnosisv1.OperationSet) (diagnosisv1.OperationSet, error)
+	// maxPaths is the maximum number of paths to enumerate from an operation set.
+	// Path enumeration is unlimited if not positive.
+	maxPaths int
+	// workers is the number of workers syncing operation sets concurrently.
+	workers int
 }
 
 // NewGraphBuilder creates a new graph builder.
@@ -95,13 +128,27 @@ func NewGraphBuilder(
 	scheme *runtime.Scheme,
 	cache cache.Cache,
 	graphBuilderCh chan diagnosisv1.OperationSet,
+	requeueBaseDelay time.Duration,
+	requeueMaxDelay time.Duration,
+	maxPaths int,
+	workers int,
 ) GraphBuilder {
 	metrics.Registry.MustRegister(
 		graphbuilderSyncSuccessCount,
 		graphbuilderSyncSkipCount,
 		graphbuilderSyncErrorCount,
 	)
-	return &graphBuilder{
+	if requeueBaseDelay <= 0 {
+		requeueBaseDelay = DefaultRequeueBaseDelay
+	}
+	if requeueMaxDelay <= 0 {
+		requeueMaxDelay = DefaultRequeueMaxDelay
+	}
+	if workers <= 0 {
+		workers = DefaultWorkers
+	}
+
+	gb := &graphBuilder{
 		Context:        ctx,
 		Logger:         logger,
 		client:         cli,
@@ -109,7 +156,13 @@ func NewGraphBuilder(
 		scheme:         scheme,
 		cache:          cache,
 		graphBuilderCh: graphBuilderCh,
+		queue:          workqueue.NewRateLimitingQueue(workqueue.NewItemExponentialFailureRateLimiter(requeueBaseDelay, requeueMaxDelay)),
+		maxPaths:       maxPaths,
+		workers:        workers,
 	}
+	gb.syncFunc = gb.syncOperationSet
+
+	return gb
 }
 
 // Run runs the graph builder.
@@ -120,40 +173,80 @@ func (gb *graphBuilder) Run(stopCh <-chan struct{}) {
 		return
 	}
 
-	for {
-		select {
-		// Process operation sets queuing in graph builder channel.
-		case operationSet := <-gb.graphBuilderCh:
-
-			err := gb.client.Get(gb, client.ObjectKey{
-				Name: operationSet.Name,
-			}, &operationSet)
-			if err != nil {
-				if apierrors.IsNotFound(err) {
-					continue
-				}
-				gb.addDiagnosisToGraphBuilderQueue(operationSet)
-				continue
+	// Shut down the work queue on the stop signal to unblock the worker.
+	go func() {
+		<-stopCh
+		gb.queue.ShutDown()
+	}()
+
+	// Pump operation sets from the graph builder channel into the rate limiting work queue.
+	// Duplicate enqueues of the same operation set coalesce into a single queue entry.
+	go func() {
+		for {
+			select {
+			case operationSet := <-gb.graphBuilderCh:
+				util.UpdateOperationSetChannelLength(gb.graphBuilderCh)
+				gb.queue.Add(operationSet.Name)
+			case <-stopCh:
+				return
 			}
-
-			// Only process unready operation set.
-			if operationSet.Status.Ready {
-				graphbuilderSyncSkipCount.Inc()
-				continue
+		}
+	}()
+
+	// Process operation sets concurrently until the work queue has been shut down. The work
+	// queue guarantees that an operation set is never processed by two workers at once.
+	var wg sync.WaitGroup
+	for i := 0; i < gb.workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for gb.processNextOperationSet() {
 			}
+		}()
+	}
+	wg.Wait()
+}
 
-			operationSet, err = gb.syncOperationSet(operationSet)
-			if err != nil {
-				gb.Error(err, "failed to sync OperationSet", "operationSet", operationSet)
-				gb.addDiagnosisToGraphBuilderQueue(operationSet)
-				continue
-			}
-			graphbuilderSyncSuccessCount.Inc()
-		// Stop graph builder on stop signal.
-		case <-stopCh:
-			return
+// processNextOperationSet syncs the next operation set on the work queue. A failed sync is
+// retried with increasing delay. It returns false when the work queue has been shut down.
+func (gb *graphBuilder) processNextOperationSet() bool {
+	item, shutdown := gb.queue.Get()
+	if shutdown {
+		return false
+	}
+	defer gb.queue.Done(item)
+
+	name := item.(string)
+	var operationSet diagnosisv1.OperationSet
+	if err := gb.client.Get(gb, client.ObjectKey{Name: name}, &operationSet); err != nil {
+		if apierrors.IsNotFound(err) {
+			gb.queue.Forget(item)
+			return true
 		}
+		graphbuilderSyncErrorCount.Inc()
+		gb.queue.AddRateLimited(item)
+		return true
+	}
+
+	// Only process unready operation set.
+	if operationSet.Status.Ready {
+		graphbuilderSyncSkipCount.Inc()
+		gb.queue.Forget(item)
+		return true
 	}
+
+	if _, err := gb.syncFunc(operationSet); err != nil {
+		gb.Error(err, "failed to sync OperationSet", "operationset", client.ObjectKey{
+			Name: operationSet.Name,
+		})
+		graphbuilderSyncErrorCount.Inc()
+		gb.queue.AddRateLimited(item)
+		return true
+	}
+	gb.queue.Forget(item)
+	graphbuilderSyncSuccessCount.Inc()
+
+	return true
 }
 
 // syncOperationSet syncs operation sets.
@@ -193,8 +286,25 @@ func (gb *graphBuilder) syncOperationSet(operationSet diagnosisv1.OperationSet)
 	}
 
 	// Search all paths from start node to any node with outdegree of 0.
-	diagnosisPaths, err := searchDiagnosisPaths(graph, len(operationSet.Spec.AdjacencyList))
+	diagnosisPaths, err := searchDiagnosisPaths(graph, len(operationSet.Spec.AdjacencyList), gb.maxPaths)
 	if err != nil {
+		// Mark the operation set as not ready instead of retrying if path enumeration exceeds the
+		// maximum path limit. Retrying will not succeed until the specification is changed.
+		if errors.Is(err, errTooManyPaths) {
+			gb.eventRecorder.Eventf(&operationSet, corev1.EventTypeWarning, "TooManyPaths", "Failed to build operation set %s since %s", operationSet.Name, err)
+			operationSet.Status.Ready = false
+			util.UpdateOperationSetCondition(&operationSet.Status, &diagnosisv1.OperationSetCondition{
+				Type:    diagnosisv1.TooManyPaths,
+				Status:  corev1.ConditionTrue,
+				Reason:  "TooManyPaths",
+				Message: fmt.Sprintf("Path enumeration exceeds maximum path limit %d", gb.maxPaths),
+			})
+			if err := gb.client.Status().Update(gb, &operationSet); err != nil {
+				return operationSet, fmt.Errorf("unable to update OperationSet: %s", err)
+			}
+			return operationSet, nil
+		}
+
 		return operationSet, fmt.Errorf("unable to search diagnosis path: %s", err)
 	}
 
@@ -209,7 +319,7 @@ func (gb *graphBuilder) syncOperationSet(operationSet diagnosisv1.OperationSet)
 		}
 		paths = append(paths, path)
 	}
-	operationSet.Status.Paths = paths
+	operationSet.Status.Paths = insertPrePostOperations(paths, operationSet.Spec.PreOperations, operationSet.Spec.PostOperations)
 	operationSet.Status.Ready = true
 
 	if err := gb.client.Status().Update(gb, &operationSet); err != nil {
@@ -219,15 +329,28 @@ func (gb *graphBuilder) syncOperationSet(operationSet diagnosisv1.OperationSet)
 	return operationSet, nil
 }
 
-// addDiagnosisToGraphBuilderQueue adds OperationSets to the queue processed by graph builder.
-func (gb *graphBuilder) addDiagnosisToGraphBuilderQueue(operationSet diagnosisv1.OperationSet) {
-	graphbuilderSyncErrorCount.Inc()
-	err := util.QueueOperationSet(gb, gb.graphBuilderCh, operationSet)
-	if err != nil {
-		gb.Error(err, "failed to send operation set to graph builder queue", "operationset", client.ObjectKey{
-			Name: operationSet.Name,
-		})
+// insertPrePostOperations inserts implicit nodes of pre operations before the first node and implicit
+// nodes of post operations after the last node of every path. The diagnosis controller executes the
+// implicit nodes in the same manner as nodes defined in the adjacency list.
+func insertPrePostOperations(paths []diagnosisv1.Path, preOperations []string, postOperations []string) []diagnosisv1.Path {
+	if len(preOperations) == 0 && len(postOperations) == 0 {
+		return paths
+	}
+
+	result := make([]diagnosisv1.Path, 0, len(paths))
+	for _, path := range paths {
+		merged := make(diagnosisv1.Path, 0, len(preOperations)+len(path)+len(postOperations))
+		for _, operation := range preOperations {
+			merged = append(merged, diagnosisv1.Node{Operation: operation})
+		}
+		merged = append(merged, path...)
+		for _, operation := range postOperations {
+			merged = append(merged, diagnosisv1.Node{Operation: operation})
+		}
+		result = append(result, merged)
 	}
+
+	return result
 }
 
 // newGraphFromAdjacencyList builds a directed graph from a adjacency list.
@@ -247,12 +370,15 @@ func newGraphFromAdjacencyList(adjacencyList []diagnosisv1.Node) (*simple.Direct
 }
 
 // searchDiagnosisPaths traverses all nodes in the directed acyclic graph from start node with id of 0.
-// It returns all paths from start node to any node with outdegree of 0 and an error.
-func searchDiagnosisPaths(graph *simple.DirectedGraph, nodeCount int) ([][]int64, error) {
+// It returns all paths from start node to any node with outdegree of 0 and an error. An errTooManyPaths
+// is returned if the number of enumerated paths exceeds maxPaths. Path enumeration is unlimited if
+// maxPaths is not positive.
+func searchDiagnosisPaths(graph *simple.DirectedGraph, nodeCount int, maxPaths int) ([][]int64, error) {
 	var queue NodeQueue
 	visited := make([]bool, nodeCount)
 	nodePathCache := make([][][]int64, nodeCount)
 	sinkNodes := make([]int64, 0)
+	cachedPaths := 0
 
 	// Validate the graph contains start node with id of 0.
 	start := graph.Node(0)
@@ -307,6 +433,12 @@ func searchDiagnosisPaths(graph *simple.DirectedGraph, nodeCount int) ([][]int64
 				}
 				toNodePaths := nodePathCache[toNode.ID()]
 				for _, toNodePath := range toNodePaths {
+					// Return an error before the cache blows up memory if path enumeration exceeds
+					// maximum path limit.
+					cachedPaths++
+					if maxPaths > 0 && cachedPaths > maxPaths {
+						return nil, errTooManyPaths
+					}
 					nodePath := make([]int64, len(toNodePath))
 					copy(nodePath, toNodePath)
 					nodePath = append(nodePath, current.ID())
@@ -17,10 +17,20 @@ limitations under the License.
 package graphbuilder
 
 import (
+	"context"
 	"fmt"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/util/workqueue"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 
 	diagnosisv1 "github.com/kubediag/kubediag/api/v1"
 )
@@ -117,7 +127,7 @@ func TestSearchDiagnosisPaths(t *testing.T) {
 			t.Errorf("invalid adjacency list: %s", err)
 		}
 
-		paths, err := searchDiagnosisPaths(graph, len(test.adjacencyList))
+		paths, err := searchDiagnosisPaths(graph, len(test.adjacencyList), 0)
 		assert.Equal(t, len(test.expected.paths), len(paths), test.desc)
 		for i := 0; i < len(test.expected.paths); i++ {
 			found := false
@@ -137,3 +147,191 @@ func TestSearchDiagnosisPaths(t *testing.T) {
 		}
 	}
 }
+
+func TestInsertPrePostOperations(t *testing.T) {
+	paths := []diagnosisv1.Path{
+		{
+			{
+				ID:        1,
+				Operation: "operation1",
+			},
+			{
+				ID:        2,
+				Operation: "operation2",
+			},
+		},
+		{
+			{
+				ID:        3,
+				Operation: "operation3",
+			},
+		},
+	}
+
+	result := insertPrePostOperations(paths, []string{"setup"}, []string{"teardown"})
+	assert.Equal(t, len(paths), len(result))
+	for i, path := range result {
+		// The pre operation runs exactly once before the first real operation of the path.
+		assert.Equal(t, len(paths[i])+2, len(path))
+		assert.Equal(t, "setup", path[0].Operation)
+		assert.Equal(t, paths[i][0].Operation, path[1].Operation)
+		assert.Equal(t, "teardown", path[len(path)-1].Operation)
+	}
+
+	// Paths are unchanged if no pre or post operations are specified.
+	result = insertPrePostOperations(paths, nil, nil)
+	assert.Equal(t, paths, result)
+}
+
+func TestSearchDiagnosisPathsWithMaxPaths(t *testing.T) {
+	// Build a densely connected adjacency list with layers of fully connected nodes. The number of
+	// paths grows exponentially with layer count.
+	adjacencyList := make([]diagnosisv1.Node, 0)
+	layers := 12
+	adjacencyList = append(adjacencyList, diagnosisv1.Node{
+		ID: 0,
+		To: []int{1, 2},
+	})
+	for layer := 1; layer < layers; layer++ {
+		for i := 0; i < 2; i++ {
+			node := diagnosisv1.Node{
+				ID: 2*(layer-1) + i + 1,
+			}
+			if layer != layers-1 {
+				node.To = []int{2*layer + 1, 2*layer + 2}
+			}
+			adjacencyList = append(adjacencyList, node)
+		}
+	}
+
+	graph, err := newGraphFromAdjacencyList(adjacencyList)
+	if err != nil {
+		t.Errorf("invalid adjacency list: %s", err)
+	}
+
+	// The graph enumerates 2^12 paths which exceeds the maximum path limit.
+	paths, err := searchDiagnosisPaths(graph, len(adjacencyList), 1024)
+	assert.Nil(t, paths)
+	assert.Equal(t, errTooManyPaths, err)
+
+	// Path enumeration is unlimited if max paths is not positive.
+	paths, err = searchDiagnosisPaths(graph, len(adjacencyList), 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 4096, len(paths))
+}
+
+func TestProcessNextOperationSetRetriesTransientFailure(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = diagnosisv1.AddToScheme(scheme)
+
+	operationSet := &diagnosisv1.OperationSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "operationset1",
+		},
+	}
+	cli := fake.NewFakeClientWithScheme(scheme, operationSet)
+
+	gb := &graphBuilder{
+		Context: context.Background(),
+		Logger:  ctrl.Log.WithName("graphbuilder"),
+		client:  cli,
+		scheme:  scheme,
+		queue:   workqueue.NewRateLimitingQueue(workqueue.NewItemExponentialFailureRateLimiter(time.Millisecond, 50*time.Millisecond)),
+	}
+
+	// Fail the first two syncs to simulate a transient error, then mark the operation set as ready.
+	attempts := 0
+	gb.syncFunc = func(operationSet diagnosisv1.OperationSet) (diagnosisv1.OperationSet, error) {
+		attempts++
+		if attempts <= 2 {
+			return operationSet, fmt.Errorf("transient error")
+		}
+		operationSet.Status.Ready = true
+		err := cli.Status().Update(context.Background(), &operationSet)
+		return operationSet, err
+	}
+
+	gb.queue.Add(operationSet.Name)
+	for i := 1; i <= 2; i++ {
+		assert.True(t, gb.processNextOperationSet())
+		assert.Equal(t, i, gb.queue.NumRequeues(operationSet.Name))
+	}
+	assert.True(t, gb.processNextOperationSet())
+	assert.Equal(t, 3, attempts)
+	// A successful sync resets the rate limiter for the operation set.
+	assert.Equal(t, 0, gb.queue.NumRequeues(operationSet.Name))
+
+	var updated diagnosisv1.OperationSet
+	err := cli.Get(context.Background(), client.ObjectKey{Name: operationSet.Name}, &updated)
+	assert.NoError(t, err)
+	assert.True(t, updated.Status.Ready)
+
+	// A ready operation set is skipped without invoking the sync.
+	gb.queue.Add(operationSet.Name)
+	assert.True(t, gb.processNextOperationSet())
+	assert.Equal(t, 3, attempts)
+
+	gb.queue.ShutDown()
+	assert.False(t, gb.processNextOperationSet())
+}
+
+func TestRunProcessesOperationSetsConcurrently(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = diagnosisv1.AddToScheme(scheme)
+
+	operationSets := make([]runtime.Object, 0)
+	for i := 0; i < 8; i++ {
+		operationSets = append(operationSets, &diagnosisv1.OperationSet{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: fmt.Sprintf("operationset%d", i),
+			},
+		})
+	}
+	cli := fake.NewFakeClientWithScheme(scheme, operationSets...)
+
+	gb := &graphBuilder{
+		Context:        context.Background(),
+		Logger:         ctrl.Log.WithName("graphbuilder"),
+		client:         cli,
+		scheme:         scheme,
+		cache:          fakeCache{Reader: cli},
+		graphBuilderCh: make(chan diagnosisv1.OperationSet, 10),
+		queue:          workqueue.NewRateLimitingQueue(workqueue.NewItemExponentialFailureRateLimiter(time.Millisecond, 50*time.Millisecond)),
+		workers:        4,
+	}
+	var mu sync.Mutex
+	synced := make(map[string]bool)
+	gb.syncFunc = func(operationSet diagnosisv1.OperationSet) (diagnosisv1.OperationSet, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		synced[operationSet.Name] = true
+		return operationSet, nil
+	}
+
+	stopCh := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		gb.Run(stopCh)
+		close(done)
+	}()
+	for _, operationSet := range operationSets {
+		gb.graphBuilderCh <- *operationSet.(*diagnosisv1.OperationSet)
+	}
+
+	// All queued operation sets are processed by the worker pool.
+	assert.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(synced) == len(operationSets)
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// All workers exit once the stop signal shuts down the work queue.
+	close(stopCh)
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("workers did not exit after the stop signal")
+	}
+}
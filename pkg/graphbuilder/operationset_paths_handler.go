@@ -0,0 +1,75 @@
+/*
+Copyright 2022 The KubeDiag Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package graphbuilder
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	diagnosisv1 "github.com/kubediag/kubediag/api/v1"
+)
+
+// OperationSetPaths contains the enumerated paths and readiness of an operation set.
+type OperationSetPaths struct {
+	// Name is the name of the operation set.
+	Name string `json:"name"`
+	// Ready specifies whether the operation set is ready.
+	Ready bool `json:"ready"`
+	// Paths is the collection of all directed paths of the directed acyclic graph.
+	Paths []diagnosisv1.Path `json:"paths"`
+}
+
+// Handler handles http requests for retrieving paths of an operation set.
+func (gb *graphBuilder) Handler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+		name := mux.Vars(r)["name"]
+
+		var operationSet diagnosisv1.OperationSet
+		if err := gb.cache.Get(gb, client.ObjectKey{Name: name}, &operationSet); err != nil {
+			if apierrors.IsNotFound(err) {
+				http.Error(w, fmt.Sprintf("operation set %s not found", name), http.StatusNotFound)
+				return
+			}
+			gb.Error(err, "unable to get OperationSet", "operationset", name)
+			http.Error(w, fmt.Sprintf("unable to get operation set %s: %v", name, err), http.StatusInternalServerError)
+			return
+		}
+
+		operationSetPaths := OperationSetPaths{
+			Name:  operationSet.Name,
+			Ready: operationSet.Status.Ready,
+			Paths: operationSet.Status.Paths,
+		}
+		data, err := json.Marshal(operationSetPaths)
+		if err != nil {
+			gb.Error(err, "failed to marshal operation set paths", "operationset", name)
+			http.Error(w, fmt.Sprintf("failed to marshal operation set paths: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(data)
+	default:
+		http.Error(w, fmt.Sprintf("method %s is not supported", r.Method), http.StatusMethodNotAllowed)
+	}
+}
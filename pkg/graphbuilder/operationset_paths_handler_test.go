@@ -0,0 +1,137 @@
+/*
+Copyright 2022 The KubeDiag Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package graphbuilder
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	diagnosisv1 "github.com/kubediag/kubediag/api/v1"
+)
+
+// fakeCache adapts a fake client into a cache for testing.
+type fakeCache struct {
+	client.Reader
+}
+
+func (fakeCache) GetInformer(obj runtime.Object) (cache.Informer, error) {
+	return nil, nil
+}
+
+func (fakeCache) GetInformerForKind(gvk schema.GroupVersionKind) (cache.Informer, error) {
+	return nil, nil
+}
+
+func (fakeCache) Start(stopCh <-chan struct{}) error {
+	return nil
+}
+
+func (fakeCache) WaitForCacheSync(stop <-chan struct{}) bool {
+	return true
+}
+
+func (fakeCache) IndexField(obj runtime.Object, field string, extractValue client.IndexerFunc) error {
+	return nil
+}
+
+func TestOperationSetPathsHandler(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = diagnosisv1.AddToScheme(scheme)
+
+	operationSet := &diagnosisv1.OperationSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "operationset1",
+		},
+		Spec: diagnosisv1.OperationSetSpec{
+			AdjacencyList: []diagnosisv1.Node{
+				{
+					ID: 0,
+					To: diagnosisv1.NodeSet{1, 2},
+				},
+				{
+					ID:        1,
+					Operation: "operation1",
+				},
+				{
+					ID:        2,
+					Operation: "operation2",
+				},
+			},
+		},
+		// Paths built by graph builder from the adjacency list.
+		Status: diagnosisv1.OperationSetStatus{
+			Paths: []diagnosisv1.Path{
+				{
+					{
+						ID:        1,
+						Operation: "operation1",
+					},
+				},
+				{
+					{
+						ID:        2,
+						Operation: "operation2",
+					},
+				},
+			},
+			Ready: true,
+		},
+	}
+
+	cli := fake.NewFakeClientWithScheme(scheme, operationSet)
+	gb := &graphBuilder{
+		Context:       context.Background(),
+		Logger:        ctrl.Log.WithName("graphbuilder"),
+		client:        cli,
+		eventRecorder: record.NewFakeRecorder(100),
+		scheme:        scheme,
+		cache:         fakeCache{Reader: cli},
+	}
+
+	router := mux.NewRouter()
+	router.HandleFunc("/api/v1/operationsets/{name}/paths", gb.Handler)
+	req := httptest.NewRequest("GET", "/api/v1/operationsets/operationset1/paths", nil)
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+
+	assert.Equal(t, 200, recorder.Code)
+	var operationSetPaths OperationSetPaths
+	err := json.Unmarshal(recorder.Body.Bytes(), &operationSetPaths)
+	assert.NoError(t, err)
+	assert.Equal(t, "operationset1", operationSetPaths.Name)
+	assert.True(t, operationSetPaths.Ready)
+	assert.Equal(t, operationSet.Status.Paths, operationSetPaths.Paths)
+
+	// The handler returns 404 if the operation set does not exist.
+	req = httptest.NewRequest("GET", "/api/v1/operationsets/operationset2/paths", nil)
+	recorder = httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+	assert.Equal(t, 404, recorder.Code)
+}
@@ -0,0 +1,189 @@
+/*
+Copyright 2022 The KubeDiag Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package localdiagnoser
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/prometheus/client_golang/prometheus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	diagnosisv1 "github.com/kubediag/kubediag/api/v1"
+	"github.com/kubediag/kubediag/pkg/util"
+)
+
+var (
+	// LocalDiagnosisPrefix is the name prefix for diagnoses created via the local diagnosis endpoint.
+	LocalDiagnosisPrefix = "local"
+	// LocalDiagnosisAnnotation is the annotation used to mark a diagnosis created via the local
+	// diagnosis endpoint.
+	LocalDiagnosisAnnotation = util.KubeDiagPrefix + LocalDiagnosisPrefix
+)
+
+var (
+	localDiagnosisGenerationSuccessCount = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "local_diagnosis_generation_success_count",
+			Help: "Counter of successful diagnosis generations by local diagnoser",
+		},
+	)
+	localDiagnosisGenerationErrorCount = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "local_diagnosis_generation_error_count",
+			Help: "Counter of erroneous diagnosis generations by local diagnoser",
+		},
+	)
+)
+
+// LocalDiagnosisRequest is the request body to create a diagnosis targeting the local node.
+type LocalDiagnosisRequest struct {
+	// OperationSet is the name of referenced operation set in the created diagnosis.
+	OperationSet string `json:"operationSet"`
+	// Parameters is a set of the parameters to be passed to the diagnosis.
+	// +optional
+	Parameters map[string]string `json:"parameters,omitempty"`
+}
+
+// LocalDiagnoser creates diagnoses targeting the local node.
+type LocalDiagnoser interface {
+	// Handler handles http requests.
+	Handler(http.ResponseWriter, *http.Request)
+}
+
+// localDiagnoser creates diagnoses targeting the node the agent is running on.
+type localDiagnoser struct {
+	// Context carries values across API boundaries.
+	context.Context
+	// Logger represents the ability to log messages.
+	logr.Logger
+
+	// client knows how to perform CRUD operations on Kubernetes objects.
+	client client.Client
+	// nodeName specifies the node name.
+	nodeName string
+}
+
+// NewLocalDiagnoser creates a new LocalDiagnoser.
+func NewLocalDiagnoser(
+	ctx context.Context,
+	logger logr.Logger,
+	cli client.Client,
+	nodeName string,
+) LocalDiagnoser {
+	metrics.Registry.MustRegister(
+		localDiagnosisGenerationSuccessCount,
+		localDiagnosisGenerationErrorCount,
+	)
+
+	return &localDiagnoser{
+		Context:  ctx,
+		Logger:   logger,
+		client:   cli,
+		nodeName: nodeName,
+	}
+}
+
+// Handler handles http requests for creating diagnoses targeting the local node.
+func (ld *localDiagnoser) Handler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "POST":
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			localDiagnosisGenerationErrorCount.Inc()
+			ld.Error(err, "unable to read request body")
+			http.Error(w, fmt.Sprintf("unable to read request body: %v", err), http.StatusBadRequest)
+			return
+		}
+		defer r.Body.Close()
+
+		var request LocalDiagnosisRequest
+		err = json.Unmarshal(body, &request)
+		if err != nil {
+			localDiagnosisGenerationErrorCount.Inc()
+			ld.Error(err, "failed to unmarshal request body")
+			http.Error(w, fmt.Sprintf("failed to unmarshal request body: %v", err), http.StatusBadRequest)
+			return
+		}
+		if request.OperationSet == "" {
+			localDiagnosisGenerationErrorCount.Inc()
+			http.Error(w, "operation set must be specified", http.StatusBadRequest)
+			return
+		}
+
+		diagnosis, err := ld.createLocalDiagnosis(request)
+		if err != nil {
+			localDiagnosisGenerationErrorCount.Inc()
+			ld.Error(err, "unable to create Diagnosis")
+			http.Error(w, fmt.Sprintf("unable to create diagnosis: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		ld.Info("creating Diagnosis targeting local node successfully", "diagnosis", client.ObjectKey{
+			Name:      diagnosis.Name,
+			Namespace: diagnosis.Namespace,
+		})
+		localDiagnosisGenerationSuccessCount.Inc()
+
+		data, err := json.Marshal(diagnosis)
+		if err != nil {
+			ld.Error(err, "failed to marshal diagnosis")
+			http.Error(w, fmt.Sprintf("failed to marshal diagnosis: %v", err), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		w.Write(data)
+	default:
+		http.Error(w, fmt.Sprintf("method %s is not supported", r.Method), http.StatusMethodNotAllowed)
+	}
+}
+
+// createLocalDiagnosis creates a Diagnosis targeting the node the agent is running on.
+func (ld *localDiagnoser) createLocalDiagnosis(request LocalDiagnosisRequest) (*diagnosisv1.Diagnosis, error) {
+	name := fmt.Sprintf("%s.%s.%d", LocalDiagnosisPrefix, ld.nodeName, time.Now().Unix())
+	namespace := util.DefautlNamespace
+	annotations := make(map[string]string)
+	annotations[LocalDiagnosisAnnotation] = ld.nodeName
+	diagnosis := diagnosisv1.Diagnosis{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Namespace:   namespace,
+			Annotations: annotations,
+		},
+		Spec: diagnosisv1.DiagnosisSpec{
+			OperationSet: request.OperationSet,
+			Parameters:   request.Parameters,
+			TargetSelector: &diagnosisv1.TargetSelector{
+				NodeNames: []string{ld.nodeName},
+			},
+		},
+	}
+
+	if err := ld.client.Create(ld, &diagnosis); err != nil {
+		return nil, err
+	}
+
+	return &diagnosis, nil
+}
@@ -0,0 +1,80 @@
+/*
+Copyright 2022 The KubeDiag Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package localdiagnoser
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	diagnosisv1 "github.com/kubediag/kubediag/api/v1"
+)
+
+func TestLocalDiagnoserHandler(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = diagnosisv1.AddToScheme(scheme)
+
+	cli := fake.NewFakeClientWithScheme(scheme)
+	ld := &localDiagnoser{
+		Context:  context.Background(),
+		Logger:   ctrl.Log.WithName("localdiagnoser"),
+		client:   cli,
+		nodeName: "node1",
+	}
+
+	body, err := json.Marshal(LocalDiagnosisRequest{
+		OperationSet: "operationset1",
+		Parameters:   map[string]string{"param1": "value1"},
+	})
+	assert.NoError(t, err)
+	req := httptest.NewRequest("POST", "/api/v1/diagnoses/local", bytes.NewBuffer(body))
+	recorder := httptest.NewRecorder()
+	ld.Handler(recorder, req)
+	assert.Equal(t, 201, recorder.Code)
+
+	// The created diagnosis targets the node the agent is running on.
+	var diagnosisList diagnosisv1.DiagnosisList
+	err = cli.List(context.Background(), &diagnosisList)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(diagnosisList.Items))
+	diagnosis := diagnosisList.Items[0]
+	assert.Equal(t, "operationset1", diagnosis.Spec.OperationSet)
+	assert.Equal(t, map[string]string{"param1": "value1"}, diagnosis.Spec.Parameters)
+	assert.Equal(t, []string{"node1"}, diagnosis.Spec.TargetSelector.NodeNames)
+	assert.Equal(t, "node1", diagnosis.Annotations[LocalDiagnosisAnnotation])
+
+	// The operation set must be specified.
+	req = httptest.NewRequest("POST", "/api/v1/diagnoses/local", bytes.NewBufferString("{}"))
+	recorder = httptest.NewRecorder()
+	ld.Handler(recorder, req)
+	assert.Equal(t, 400, recorder.Code)
+
+	// Only POST requests are supported.
+	req = httptest.NewRequest("GET", "/api/v1/diagnoses/local", nil)
+	recorder = httptest.NewRecorder()
+	ld.Handler(recorder, req)
+	assert.Equal(t, 405, recorder.Code)
+}
@@ -0,0 +1,168 @@
+/*
+Copyright 2022 The KubeDiag Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package nodehealthevaluator
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/kubediag/kubediag/pkg/util"
+)
+
+// NodeHealth represents the aggregated health of the local node.
+type NodeHealth struct {
+	// NodeName is the name of the node.
+	NodeName string `json:"nodeName"`
+	// Ready indicates whether the ready condition of the node is true.
+	Ready bool `json:"ready"`
+	// Conditions are the observed conditions of the node.
+	Conditions []corev1.NodeCondition `json:"conditions,omitempty"`
+	// DataRootAvailableBytes is the number of available bytes in the filesystem backing the
+	// kubediag data root.
+	DataRootAvailableBytes uint64 `json:"dataRootAvailableBytes"`
+	// PodStatistics contains counts of pods on the node by phase.
+	PodStatistics NodePodStatistics `json:"podStatistics"`
+}
+
+// NodePodStatistics contains counts of pods on a node by phase.
+type NodePodStatistics struct {
+	// Total is the total number of pods on the node.
+	Total int `json:"total"`
+	// Running is the number of pods in Running phase.
+	Running int `json:"running"`
+	// Pending is the number of pods in Pending phase.
+	Pending int `json:"pending"`
+	// Succeeded is the number of pods in Succeeded phase.
+	Succeeded int `json:"succeeded"`
+	// Failed is the number of pods in Failed phase.
+	Failed int `json:"failed"`
+	// Unknown is the number of pods in Unknown phase.
+	Unknown int `json:"unknown"`
+}
+
+// NodeHealthEvaluator reports the aggregated health of the node the agent is running on.
+type NodeHealthEvaluator interface {
+	// Handler handles http requests.
+	Handler(http.ResponseWriter, *http.Request)
+}
+
+// nodeHealthEvaluator reports node conditions, available disk space of the data root and pod
+// counts of the local node in one call.
+type nodeHealthEvaluator struct {
+	// Context carries values across API boundaries.
+	context.Context
+	// Logger represents the ability to log messages.
+	logr.Logger
+
+	// cache knows how to load Kubernetes objects.
+	cache cache.Cache
+	// nodeName specifies the node name.
+	nodeName string
+	// dataRoot is root directory of persistent kubediag data.
+	dataRoot string
+}
+
+// NewNodeHealthEvaluator creates a new NodeHealthEvaluator.
+func NewNodeHealthEvaluator(
+	ctx context.Context,
+	logger logr.Logger,
+	cache cache.Cache,
+	nodeName string,
+	dataRoot string,
+) NodeHealthEvaluator {
+	return &nodeHealthEvaluator{
+		Context:  ctx,
+		Logger:   logger,
+		cache:    cache,
+		nodeName: nodeName,
+		dataRoot: dataRoot,
+	}
+}
+
+// Handler handles http requests for the health of the local node.
+func (ne *nodeHealthEvaluator) Handler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+		var node corev1.Node
+		if err := ne.cache.Get(ne, client.ObjectKey{Name: ne.nodeName}, &node); err != nil {
+			http.Error(w, fmt.Sprintf("unable to fetch Node %s: %v", ne.nodeName, err), http.StatusInternalServerError)
+			return
+		}
+
+		var podList corev1.PodList
+		if err := ne.cache.List(ne, &podList); err != nil {
+			http.Error(w, fmt.Sprintf("unable to list Pods: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		nodeHealth := evaluateNodeHealth(node, podList.Items, util.GetAvailableBytes(ne.dataRoot))
+		data, err := json.Marshal(nodeHealth)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("failed to marshal node health: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(data)
+	default:
+		http.Error(w, fmt.Sprintf("method %s is not supported", r.Method), http.StatusMethodNotAllowed)
+	}
+}
+
+// evaluateNodeHealth evaluates the health of the node from its conditions and the pods
+// scheduled on it.
+func evaluateNodeHealth(node corev1.Node, pods []corev1.Pod, availableBytes uint64) NodeHealth {
+	nodeHealth := NodeHealth{
+		NodeName:               node.Name,
+		Conditions:             node.Status.Conditions,
+		DataRootAvailableBytes: availableBytes,
+	}
+
+	for _, condition := range node.Status.Conditions {
+		if condition.Type == corev1.NodeReady && condition.Status == corev1.ConditionTrue {
+			nodeHealth.Ready = true
+		}
+	}
+
+	for _, pod := range pods {
+		if pod.Spec.NodeName != node.Name {
+			continue
+		}
+		nodeHealth.PodStatistics.Total++
+		switch pod.Status.Phase {
+		case corev1.PodRunning:
+			nodeHealth.PodStatistics.Running++
+		case corev1.PodPending:
+			nodeHealth.PodStatistics.Pending++
+		case corev1.PodSucceeded:
+			nodeHealth.PodStatistics.Succeeded++
+		case corev1.PodFailed:
+			nodeHealth.PodStatistics.Failed++
+		default:
+			nodeHealth.PodStatistics.Unknown++
+		}
+	}
+
+	return nodeHealth
+}
@@ -0,0 +1,153 @@
+/*
+Copyright 2022 The KubeDiag Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package nodehealthevaluator
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// fakeCache is a cache.Cache backed by a fake client for testing.
+type fakeCache struct {
+	client.Reader
+}
+
+func (f fakeCache) GetInformer(obj runtime.Object) (cache.Informer, error) {
+	return nil, nil
+}
+
+func (f fakeCache) GetInformerForKind(gvk schema.GroupVersionKind) (cache.Informer, error) {
+	return nil, nil
+}
+
+func (f fakeCache) Start(stopCh <-chan struct{}) error {
+	return nil
+}
+
+func (f fakeCache) WaitForCacheSync(stop <-chan struct{}) bool {
+	return true
+}
+
+func (f fakeCache) IndexField(obj runtime.Object, field string, extractValue client.IndexerFunc) error {
+	return nil
+}
+
+func TestNodeHealthEvaluatorHandler(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+
+	node := &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "node1",
+		},
+		Status: corev1.NodeStatus{
+			Conditions: []corev1.NodeCondition{
+				{
+					Type:   corev1.NodeReady,
+					Status: corev1.ConditionTrue,
+				},
+				{
+					Type:   corev1.NodeDiskPressure,
+					Status: corev1.ConditionFalse,
+				},
+			},
+		},
+	}
+	pods := []runtime.Object{
+		&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "pod1",
+				Namespace: "default",
+			},
+			Spec: corev1.PodSpec{
+				NodeName: "node1",
+			},
+			Status: corev1.PodStatus{
+				Phase: corev1.PodRunning,
+			},
+		},
+		&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "pod2",
+				Namespace: "default",
+			},
+			Spec: corev1.PodSpec{
+				NodeName: "node1",
+			},
+			Status: corev1.PodStatus{
+				Phase: corev1.PodPending,
+			},
+		},
+		&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "pod3",
+				Namespace: "default",
+			},
+			Spec: corev1.PodSpec{
+				NodeName: "node2",
+			},
+			Status: corev1.PodStatus{
+				Phase: corev1.PodRunning,
+			},
+		},
+	}
+
+	dataRoot, err := os.Getwd()
+	assert.NoError(t, err)
+	cli := fake.NewFakeClientWithScheme(scheme, append([]runtime.Object{node}, pods...)...)
+	ne := &nodeHealthEvaluator{
+		Context:  context.Background(),
+		Logger:   ctrl.Log.WithName("nodehealthevaluator"),
+		cache:    fakeCache{Reader: cli},
+		nodeName: "node1",
+		dataRoot: dataRoot,
+	}
+
+	recorder := httptest.NewRecorder()
+	ne.Handler(recorder, httptest.NewRequest("GET", "/api/v1/node/health", nil))
+	assert.Equal(t, 200, recorder.Code)
+
+	var nodeHealth NodeHealth
+	err = json.Unmarshal(recorder.Body.Bytes(), &nodeHealth)
+	assert.NoError(t, err)
+	assert.Equal(t, "node1", nodeHealth.NodeName)
+	assert.True(t, nodeHealth.Ready)
+	assert.Equal(t, 2, len(nodeHealth.Conditions))
+	assert.NotZero(t, nodeHealth.DataRootAvailableBytes)
+	// Only pods scheduled on the node are counted.
+	assert.Equal(t, 2, nodeHealth.PodStatistics.Total)
+	assert.Equal(t, 1, nodeHealth.PodStatistics.Running)
+	assert.Equal(t, 1, nodeHealth.PodStatistics.Pending)
+
+	recorder = httptest.NewRecorder()
+	ne.Handler(recorder, httptest.NewRequest("POST", "/api/v1/node/health", nil))
+	assert.Equal(t, 405, recorder.Code)
+}
@@ -0,0 +1,159 @@
+/*
+Copyright 2022 The KubeDiag Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package nodesummarizer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-logr/logr"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+
+	diagnosisv1 "github.com/kubediag/kubediag/api/v1"
+	"github.com/kubediag/kubediag/pkg/util"
+)
+
+// NodeSummary is the summary of all diagnoses and tasks assigned to a node.
+type NodeSummary struct {
+	// NodeName is the name of the node.
+	NodeName string `json:"nodeName"`
+	// Diagnoses are the summaries of diagnoses assigned to the node.
+	Diagnoses []DiagnosisSummary `json:"diagnoses"`
+	// Tasks are the summaries of tasks assigned to the node.
+	Tasks []TaskSummary `json:"tasks"`
+}
+
+// DiagnosisSummary is the summary of one diagnosis.
+type DiagnosisSummary struct {
+	// Namespace is the namespace of the diagnosis.
+	Namespace string `json:"namespace"`
+	// Name is the name of the diagnosis.
+	Name string `json:"name"`
+	// Phase is the phase of the diagnosis.
+	Phase diagnosisv1.DiagnosisPhase `json:"phase,omitempty"`
+	// AgeSeconds is the number of seconds since the diagnosis was created.
+	AgeSeconds int64 `json:"ageSeconds"`
+}
+
+// TaskSummary is the summary of one task.
+type TaskSummary struct {
+	// Namespace is the namespace of the task.
+	Namespace string `json:"namespace"`
+	// Name is the name of the task.
+	Name string `json:"name"`
+	// Phase is the phase of the task.
+	Phase diagnosisv1.TaskPhase `json:"phase,omitempty"`
+	// AgeSeconds is the number of seconds since the task was created.
+	AgeSeconds int64 `json:"ageSeconds"`
+}
+
+// NodeSummarizer reports a summary of all diagnoses and tasks assigned to the node the agent
+// is running on.
+type NodeSummarizer interface {
+	// Handler handles http requests.
+	Handler(http.ResponseWriter, *http.Request)
+}
+
+// nodeSummarizer lists diagnoses and tasks assigned to the local node with their phases and
+// ages in one call.
+type nodeSummarizer struct {
+	// Context carries values across API boundaries.
+	context.Context
+	// Logger represents the ability to log messages.
+	logr.Logger
+
+	// cache knows how to load Kubernetes objects.
+	cache cache.Cache
+	// nodeName specifies the node name.
+	nodeName string
+}
+
+// NewNodeSummarizer creates a new NodeSummarizer.
+func NewNodeSummarizer(
+	ctx context.Context,
+	logger logr.Logger,
+	cache cache.Cache,
+	nodeName string,
+) NodeSummarizer {
+	return &nodeSummarizer{
+		Context:  ctx,
+		Logger:   logger,
+		cache:    cache,
+		nodeName: nodeName,
+	}
+}
+
+// Handler handles http requests for the summary of diagnoses and tasks on the local node.
+func (ns *nodeSummarizer) Handler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+		var diagnosisList diagnosisv1.DiagnosisList
+		if err := ns.cache.List(ns, &diagnosisList); err != nil {
+			http.Error(w, fmt.Sprintf("unable to list Diagnoses: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		var taskList diagnosisv1.TaskList
+		if err := ns.cache.List(ns, &taskList); err != nil {
+			http.Error(w, fmt.Sprintf("unable to list Tasks: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		summary := summarizeNode(ns.nodeName, util.RetrieveDiagnosesOnNode(diagnosisList.Items, ns.nodeName), util.RetrieveTasksOnNode(taskList.Items, ns.nodeName), time.Now())
+		data, err := json.Marshal(summary)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("failed to marshal node summary: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(data)
+	default:
+		http.Error(w, fmt.Sprintf("method %s is not supported", r.Method), http.StatusMethodNotAllowed)
+	}
+}
+
+// summarizeNode builds the summary of diagnoses and tasks assigned to a node.
+func summarizeNode(nodeName string, diagnoses []diagnosisv1.Diagnosis, tasks []diagnosisv1.Task, now time.Time) NodeSummary {
+	summary := NodeSummary{
+		NodeName:  nodeName,
+		Diagnoses: make([]DiagnosisSummary, 0, len(diagnoses)),
+		Tasks:     make([]TaskSummary, 0, len(tasks)),
+	}
+
+	for _, diagnosis := range diagnoses {
+		summary.Diagnoses = append(summary.Diagnoses, DiagnosisSummary{
+			Namespace:  diagnosis.Namespace,
+			Name:       diagnosis.Name,
+			Phase:      diagnosis.Status.Phase,
+			AgeSeconds: int64(now.Sub(diagnosis.CreationTimestamp.Time).Seconds()),
+		})
+	}
+	for _, task := range tasks {
+		summary.Tasks = append(summary.Tasks, TaskSummary{
+			Namespace:  task.Namespace,
+			Name:       task.Name,
+			Phase:      task.Status.Phase,
+			AgeSeconds: int64(now.Sub(task.CreationTimestamp.Time).Seconds()),
+		})
+	}
+
+	return summary
+}
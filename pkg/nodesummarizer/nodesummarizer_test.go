@@ -0,0 +1,148 @@
+/*
+Copyright 2022 The KubeDiag Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package nodesummarizer
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	diagnosisv1 "github.com/kubediag/kubediag/api/v1"
+)
+
+// fakeCache is a cache.Cache backed by a fake client for testing.
+type fakeCache struct {
+	client.Reader
+}
+
+func (f fakeCache) GetInformer(obj runtime.Object) (cache.Informer, error) {
+	return nil, nil
+}
+
+func (f fakeCache) GetInformerForKind(gvk schema.GroupVersionKind) (cache.Informer, error) {
+	return nil, nil
+}
+
+func (f fakeCache) Start(stopCh <-chan struct{}) error {
+	return nil
+}
+
+func (f fakeCache) WaitForCacheSync(stop <-chan struct{}) bool {
+	return true
+}
+
+func (f fakeCache) IndexField(obj runtime.Object, field string, extractValue client.IndexerFunc) error {
+	return nil
+}
+
+func TestNodeSummarizerHandler(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = diagnosisv1.AddToScheme(scheme)
+
+	objects := []runtime.Object{
+		&diagnosisv1.Diagnosis{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              "diagnosis1",
+				Namespace:         "default",
+				CreationTimestamp: metav1.Time{Time: time.Now().Add(-time.Minute)},
+			},
+			Status: diagnosisv1.DiagnosisStatus{
+				Phase:     diagnosisv1.DiagnosisRunning,
+				NodeNames: []string{"node1", "node2"},
+			},
+		},
+		&diagnosisv1.Diagnosis{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "diagnosis2",
+				Namespace: "default",
+			},
+			Status: diagnosisv1.DiagnosisStatus{
+				Phase:     diagnosisv1.DiagnosisSucceeded,
+				NodeNames: []string{"node2"},
+			},
+		},
+		&diagnosisv1.Task{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "diagnosis1.task1",
+				Namespace: "default",
+			},
+			Spec: diagnosisv1.TaskSpec{
+				Operation: "operation1",
+				NodeName:  "node1",
+			},
+			Status: diagnosisv1.TaskStatus{
+				Phase: diagnosisv1.TaskRunning,
+			},
+		},
+		&diagnosisv1.Task{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "diagnosis2.task1",
+				Namespace: "default",
+			},
+			Spec: diagnosisv1.TaskSpec{
+				Operation: "operation1",
+				NodeName:  "node2",
+			},
+			Status: diagnosisv1.TaskStatus{
+				Phase: diagnosisv1.TaskSucceeded,
+			},
+		},
+	}
+
+	cli := fake.NewFakeClientWithScheme(scheme, objects...)
+	ns := &nodeSummarizer{
+		Context:  context.Background(),
+		Logger:   ctrl.Log.WithName("nodesummarizer"),
+		cache:    fakeCache{Reader: cli},
+		nodeName: "node1",
+	}
+
+	recorder := httptest.NewRecorder()
+	ns.Handler(recorder, httptest.NewRequest("GET", "/api/v1/node/summary", nil))
+	assert.Equal(t, 200, recorder.Code)
+
+	var summary NodeSummary
+	err := json.Unmarshal(recorder.Body.Bytes(), &summary)
+	assert.NoError(t, err)
+	assert.Equal(t, "node1", summary.NodeName)
+	// Only diagnoses and tasks assigned to the node are listed.
+	assert.Equal(t, 1, len(summary.Diagnoses))
+	assert.Equal(t, "diagnosis1", summary.Diagnoses[0].Name)
+	assert.Equal(t, "default", summary.Diagnoses[0].Namespace)
+	assert.Equal(t, diagnosisv1.DiagnosisRunning, summary.Diagnoses[0].Phase)
+	assert.True(t, summary.Diagnoses[0].AgeSeconds >= 60)
+	assert.Equal(t, 1, len(summary.Tasks))
+	assert.Equal(t, "diagnosis1.task1", summary.Tasks[0].Name)
+	assert.Equal(t, diagnosisv1.TaskRunning, summary.Tasks[0].Phase)
+
+	recorder = httptest.NewRecorder()
+	ns.Handler(recorder, httptest.NewRequest("POST", "/api/v1/node/summary", nil))
+	assert.Equal(t, 405, recorder.Code)
+}
@@ -0,0 +1,213 @@
+/*
+Copyright 2022 The KubeDiag Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package nodewatcher
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/prometheus/client_golang/prometheus"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	diagnosisv1 "github.com/kubediag/kubediag/api/v1"
+	"github.com/kubediag/kubediag/pkg/util"
+)
+
+var (
+	// NodeConditionDiagnosisPrefix is the name prefix for diagnoses generated by node watcher.
+	NodeConditionDiagnosisPrefix = "node-condition"
+	// NodeConditionAnnotation is the annotation used to store the node condition that triggers a diagnosis.
+	NodeConditionAnnotation = util.KubeDiagPrefix + NodeConditionDiagnosisPrefix
+)
+
+var (
+	nodeConditionDiagnosisGenerationSuccessCount = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "node_condition_diagnosis_generation_success_count",
+			Help: "Counter of successful diagnosis generations by node watcher",
+		},
+	)
+	nodeConditionDiagnosisGenerationErrorCount = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "node_condition_diagnosis_generation_error_count",
+			Help: "Counter of erroneous diagnosis generations by node watcher",
+		},
+	)
+)
+
+// NodeWatcher creates diagnoses for nodes with persisting unhealthy conditions.
+type NodeWatcher interface {
+	// Run runs the NodeWatcher.
+	Run(<-chan struct{})
+}
+
+// nodeWatcher watches node conditions and generates diagnoses for unhealthy nodes.
+type nodeWatcher struct {
+	// Context carries values across API boundaries.
+	context.Context
+	// Logger represents the ability to log messages.
+	logr.Logger
+
+	// client knows how to perform CRUD operations on Kubernetes objects.
+	client client.Client
+	// cache knows how to load Kubernetes objects.
+	cache cache.Cache
+	// operationSet is the name of referenced operation set in the generated diagnosis.
+	operationSet string
+	// gracePeriod is the amount of time an unhealthy condition must persist before a diagnosis
+	// is generated.
+	gracePeriod time.Duration
+	// nodeWatcherEnabled indicates whether node watcher is enabled.
+	nodeWatcherEnabled bool
+}
+
+// NewNodeWatcher creates a new NodeWatcher.
+func NewNodeWatcher(
+	ctx context.Context,
+	logger logr.Logger,
+	cli client.Client,
+	cache cache.Cache,
+	operationSet string,
+	gracePeriod time.Duration,
+	nodeWatcherEnabled bool,
+) NodeWatcher {
+	metrics.Registry.MustRegister(
+		nodeConditionDiagnosisGenerationSuccessCount,
+		nodeConditionDiagnosisGenerationErrorCount,
+	)
+
+	return &nodeWatcher{
+		Context:            ctx,
+		Logger:             logger,
+		client:             cli,
+		cache:              cache,
+		operationSet:       operationSet,
+		gracePeriod:        gracePeriod,
+		nodeWatcherEnabled: nodeWatcherEnabled,
+	}
+}
+
+// Run runs the node watcher.
+func (nw *nodeWatcher) Run(stopCh <-chan struct{}) {
+	if !nw.nodeWatcherEnabled {
+		return
+	}
+
+	// The node watcher is disabled if no operation set is specified.
+	if nw.operationSet == "" {
+		return
+	}
+
+	// Wait for all caches to sync before processing.
+	if !nw.cache.WaitForCacheSync(stopCh) {
+		return
+	}
+
+	go wait.Until(nw.sync, 30*time.Second, stopCh)
+	<-stopCh
+	nw.Info("shutting down node watcher")
+}
+
+func (nw *nodeWatcher) sync() {
+	var nodeList corev1.NodeList
+	if err := nw.cache.List(nw, &nodeList); err != nil {
+		nw.Error(err, "failed to list Nodes")
+		return
+	}
+
+	nw.syncNodes(nodeList.Items, time.Now())
+}
+
+// syncNodes generates diagnoses for nodes with unhealthy conditions persisting beyond the grace period.
+func (nw *nodeWatcher) syncNodes(nodes []corev1.Node, now time.Time) {
+	for _, node := range nodes {
+		conditionType := util.GetNodeUnhealthyConditionType(node)
+		if conditionType == "Unknown" {
+			continue
+		}
+
+		// Skip the node if the unhealthy condition has not persisted beyond the grace period.
+		var lastTransitionTime time.Time
+		for _, condition := range node.Status.Conditions {
+			if condition.Type == conditionType {
+				lastTransitionTime = condition.LastTransitionTime.Time
+			}
+		}
+		if now.Sub(lastTransitionTime) < nw.gracePeriod {
+			continue
+		}
+
+		diagnosis, err := nw.createDiagnosisFromNodeCondition(node, conditionType)
+		if err != nil {
+			nodeConditionDiagnosisGenerationErrorCount.Inc()
+			nw.Error(err, "unable to create Diagnosis", "node", node.Name, "condition", conditionType)
+			continue
+		}
+
+		if diagnosis != nil {
+			nw.Info("creating Diagnosis from node condition successfully", "diagnosis", client.ObjectKey{
+				Name:      diagnosis.Name,
+				Namespace: diagnosis.Namespace,
+			})
+			nodeConditionDiagnosisGenerationSuccessCount.Inc()
+		}
+	}
+}
+
+// createDiagnosisFromNodeCondition creates a Diagnosis targeting the node with the unhealthy condition.
+// It returns nil if a diagnosis for the node condition already exists.
+func (nw *nodeWatcher) createDiagnosisFromNodeCondition(node corev1.Node, conditionType corev1.NodeConditionType) (*diagnosisv1.Diagnosis, error) {
+	nw.Info("creating Diagnosis from node condition", "node", node.Name, "condition", conditionType)
+
+	// The diagnosis name does not contain a timestamp so a persisting condition generates only
+	// one diagnosis.
+	name := fmt.Sprintf("%s.%s.%s", NodeConditionDiagnosisPrefix, node.Name, strings.ToLower(string(conditionType)))
+	namespace := util.DefautlNamespace
+	annotations := make(map[string]string)
+	annotations[NodeConditionAnnotation] = string(conditionType)
+	diagnosis := diagnosisv1.Diagnosis{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Namespace:   namespace,
+			Annotations: annotations,
+		},
+		Spec: diagnosisv1.DiagnosisSpec{
+			OperationSet: nw.operationSet,
+			TargetSelector: &diagnosisv1.TargetSelector{
+				NodeNames: []string{node.Name},
+			},
+		},
+	}
+
+	if err := nw.client.Create(nw, &diagnosis); err != nil {
+		if apierrors.IsAlreadyExists(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &diagnosis, nil
+}
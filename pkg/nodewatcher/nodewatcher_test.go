@@ -0,0 +1,100 @@
+/*
+Copyright 2022 The KubeDiag Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package nodewatcher
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	diagnosisv1 "github.com/kubediag/kubediag/api/v1"
+)
+
+func TestSyncNodes(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = diagnosisv1.AddToScheme(scheme)
+
+	now := time.Now()
+	newNode := func(name string, conditions []corev1.NodeCondition) corev1.Node {
+		return corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: name,
+			},
+			Status: corev1.NodeStatus{
+				Conditions: conditions,
+			},
+		}
+	}
+	nodes := []corev1.Node{
+		// The unhealthy condition has persisted beyond the grace period.
+		newNode("node1", []corev1.NodeCondition{
+			{
+				Type:               corev1.NodeDiskPressure,
+				Status:             corev1.ConditionTrue,
+				LastTransitionTime: metav1.Time{Time: now.Add(-10 * time.Minute)},
+			},
+		}),
+		// The unhealthy condition is within the grace period.
+		newNode("node2", []corev1.NodeCondition{
+			{
+				Type:               corev1.NodeMemoryPressure,
+				Status:             corev1.ConditionTrue,
+				LastTransitionTime: metav1.Time{Time: now.Add(-1 * time.Minute)},
+			},
+		}),
+		// The node is healthy.
+		newNode("node3", []corev1.NodeCondition{
+			{
+				Type:               corev1.NodeReady,
+				Status:             corev1.ConditionTrue,
+				LastTransitionTime: metav1.Time{Time: now.Add(-10 * time.Minute)},
+			},
+		}),
+	}
+
+	cli := fake.NewFakeClientWithScheme(scheme)
+	nw := &nodeWatcher{
+		Context:      context.Background(),
+		Logger:       ctrl.Log.WithName("nodewatcher"),
+		client:       cli,
+		operationSet: "node-condition-operationset",
+		gracePeriod:  5 * time.Minute,
+	}
+
+	// Syncing twice generates only one diagnosis for the persisting condition.
+	nw.syncNodes(nodes, now)
+	nw.syncNodes(nodes, now)
+
+	var diagnosisList diagnosisv1.DiagnosisList
+	err := cli.List(context.Background(), &diagnosisList)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(diagnosisList.Items))
+	diagnosis := diagnosisList.Items[0]
+	assert.Equal(t, "node-condition.node1.diskpressure", diagnosis.Name)
+	assert.Equal(t, "node-condition-operationset", diagnosis.Spec.OperationSet)
+	assert.Equal(t, []string{"node1"}, diagnosis.Spec.TargetSelector.NodeNames)
+	assert.Equal(t, string(corev1.NodeDiskPressure), diagnosis.Annotations[NodeConditionAnnotation])
+}
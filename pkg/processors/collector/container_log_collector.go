@@ -0,0 +1,187 @@
+/*
+Copyright 2022 The KubeDiag Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package collector
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/kubediag/kubediag/pkg/executor"
+	"github.com/kubediag/kubediag/pkg/processors"
+	"github.com/kubediag/kubediag/pkg/processors/utils"
+)
+
+const (
+	ContainerLogTailLines = "param.collector.kubernetes.container.log.tailLines"
+	ContainerLogSinceTime = "param.collector.kubernetes.container.log.sinceTime"
+	ContainerLogPrevious  = "param.collector.kubernetes.container.log.previous"
+
+	ContextKeyContainerLogPath = "collector.kubernetes.container.log.path"
+	ContextKeyContainerLogTail = "collector.kubernetes.container.log.tail"
+
+	// containerLogSubPath is the subpath for kubediag to store collected container logs.
+	containerLogSubPath = "container-log"
+	// containerLogTailLength is the number of trailing log lines returned in results.
+	containerLogTailLength = 20
+)
+
+// containerLogCollector fetches container logs through the kubernetes api.
+type containerLogCollector struct {
+	// Context carries values across API boundaries.
+	context.Context
+	// Logger represents the ability to log messages.
+	logr.Logger
+
+	// client is a kubernetes clientset for fetching container logs.
+	client kubernetes.Interface
+	// dataRoot is root directory of persistent kubediag data.
+	dataRoot string
+	// containerLogCollectorEnabled indicates whether containerLogCollector is enabled.
+	containerLogCollectorEnabled bool
+}
+
+// NewContainerLogCollector creates a new containerLogCollector.
+func NewContainerLogCollector(
+	ctx context.Context,
+	logger logr.Logger,
+	client kubernetes.Interface,
+	dataRoot string,
+	containerLogCollectorEnabled bool,
+) processors.Processor {
+	return &containerLogCollector{
+		Context:                      ctx,
+		Logger:                       logger,
+		client:                       client,
+		dataRoot:                     dataRoot,
+		containerLogCollectorEnabled: containerLogCollectorEnabled,
+	}
+}
+
+// Handler handles http requests for collecting container logs through the kubernetes api.
+func (cl *containerLogCollector) Handler(w http.ResponseWriter, r *http.Request) {
+	if !cl.containerLogCollectorEnabled {
+		http.Error(w, fmt.Sprintf("container log collector is not enabled"), http.StatusUnprocessableEntity)
+		return
+	}
+
+	switch r.Method {
+	case "POST":
+		contexts, err := utils.ExtractParametersFromHTTPContext(r)
+		if err != nil {
+			cl.Error(err, "extract contexts failed")
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		namespace := contexts[executor.PodNamespaceTelemetryKey]
+		name := contexts[executor.PodNameTelemetryKey]
+		if namespace == "" || name == "" {
+			http.Error(w, "pod namespace and name must be specified", http.StatusBadRequest)
+			return
+		}
+
+		options := corev1.PodLogOptions{
+			Container: contexts[executor.ContainerTelemetryKey],
+		}
+		if value, ok := contexts[ContainerLogTailLines]; ok && value != "" {
+			tailLines, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				http.Error(w, fmt.Sprintf("invalid tail lines: %v", err), http.StatusBadRequest)
+				return
+			}
+			options.TailLines = &tailLines
+		}
+		if value, ok := contexts[ContainerLogSinceTime]; ok && value != "" {
+			sinceTime, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				http.Error(w, fmt.Sprintf("invalid since time: %v", err), http.StatusBadRequest)
+				return
+			}
+			metaSinceTime := metav1.NewTime(sinceTime)
+			options.SinceTime = &metaSinceTime
+		}
+		if value, ok := contexts[ContainerLogPrevious]; ok && value != "" {
+			previous, err := strconv.ParseBool(value)
+			if err != nil {
+				http.Error(w, fmt.Sprintf("invalid previous: %v", err), http.StatusBadRequest)
+				return
+			}
+			options.Previous = previous
+		}
+
+		log, err := cl.client.CoreV1().Pods(namespace).GetLogs(name, &options).DoRaw()
+		if err != nil {
+			cl.Error(err, "failed to fetch container log", "pod", fmt.Sprintf("%s/%s", namespace, name), "container", options.Container)
+			http.Error(w, fmt.Sprintf("failed to fetch container log: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		// Store the full log under kubediag data root and return the path with a short tail
+		// in results.
+		logDir := filepath.Join(cl.dataRoot, containerLogSubPath)
+		if err := os.MkdirAll(logDir, os.ModePerm); err != nil {
+			http.Error(w, fmt.Sprintf("unable to create directory %s to store container logs: %v", logDir, err), http.StatusInternalServerError)
+			return
+		}
+		logFileName := namespace + "." + name
+		if options.Container != "" {
+			logFileName = logFileName + "." + options.Container
+		}
+		logPath := filepath.Join(logDir, logFileName+".log")
+		if err := ioutil.WriteFile(logPath, log, 0644); err != nil {
+			http.Error(w, fmt.Sprintf("unable to write container log to %s: %v", logPath, err), http.StatusInternalServerError)
+			return
+		}
+
+		result := make(map[string]string)
+		result[ContextKeyContainerLogPath] = logPath
+		result[ContextKeyContainerLogTail] = lastLines(string(log), containerLogTailLength)
+		data, err := json.Marshal(result)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("failed to marshal result: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(data)
+	default:
+		http.Error(w, fmt.Sprintf("method %s is not supported", r.Method), http.StatusMethodNotAllowed)
+	}
+}
+
+// lastLines returns the last n lines of the log.
+func lastLines(log string, n int) string {
+	lines := strings.Split(strings.TrimRight(log, "\n"), "\n")
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+
+	return strings.Join(lines, "\n")
+}
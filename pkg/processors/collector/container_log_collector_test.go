@@ -0,0 +1,122 @@
+/*
+Copyright 2022 The KubeDiag Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package collector
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	"github.com/kubediag/kubediag/pkg/executor"
+)
+
+func TestContainerLogCollectorHandler(t *testing.T) {
+	dir, err := ioutil.TempDir("", "container-log-collector")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	// Serve container logs on the pod log subresource as the kubernetes api would.
+	var logRequest *http.Request
+	apiServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/namespaces/default/pods/pod1/log" {
+			http.NotFound(w, r)
+			return
+		}
+		logRequest = r
+		w.Write([]byte("line1\nline2\nline3\n"))
+	}))
+	defer apiServer.Close()
+	client, err := kubernetes.NewForConfig(&rest.Config{Host: apiServer.URL})
+	assert.NoError(t, err)
+
+	cl := &containerLogCollector{
+		Context:                      context.Background(),
+		Logger:                       ctrl.Log.WithName("collector/containerLogCollector"),
+		client:                       client,
+		dataRoot:                     dir,
+		containerLogCollectorEnabled: true,
+	}
+
+	body, err := json.Marshal(map[string]string{
+		executor.PodNamespaceTelemetryKey: "default",
+		executor.PodNameTelemetryKey:      "pod1",
+		executor.ContainerTelemetryKey:    "container1",
+		ContainerLogTailLines:             "100",
+		ContainerLogPrevious:              "true",
+	})
+	assert.NoError(t, err)
+	req := httptest.NewRequest("POST", "/processor/containerLogCollector", bytes.NewBuffer(body))
+	recorder := httptest.NewRecorder()
+	cl.Handler(recorder, req)
+	assert.Equal(t, 200, recorder.Code)
+
+	// The log options are passed to the kubernetes api as query parameters.
+	if assert.NotNil(t, logRequest) {
+		assert.Equal(t, "container1", logRequest.URL.Query().Get("container"))
+		assert.Equal(t, "100", logRequest.URL.Query().Get("tailLines"))
+		assert.Equal(t, "true", logRequest.URL.Query().Get("previous"))
+	}
+
+	// The full log is stored under data root and the result contains the path and a short tail.
+	result := make(map[string]string)
+	err = json.Unmarshal(recorder.Body.Bytes(), &result)
+	assert.NoError(t, err)
+	stored, err := ioutil.ReadFile(result[ContextKeyContainerLogPath])
+	assert.NoError(t, err)
+	assert.Equal(t, "line1\nline2\nline3\n", string(stored))
+	assert.Equal(t, "line1\nline2\nline3", result[ContextKeyContainerLogTail])
+
+	// The pod namespace and name must be specified.
+	req = httptest.NewRequest("POST", "/processor/containerLogCollector", bytes.NewBufferString("{}"))
+	recorder = httptest.NewRecorder()
+	cl.Handler(recorder, req)
+	assert.Equal(t, 400, recorder.Code)
+
+	// An invalid tail lines parameter is rejected.
+	body, err = json.Marshal(map[string]string{
+		executor.PodNamespaceTelemetryKey: "default",
+		executor.PodNameTelemetryKey:      "pod1",
+		ContainerLogTailLines:             "not-a-number",
+	})
+	assert.NoError(t, err)
+	req = httptest.NewRequest("POST", "/processor/containerLogCollector", bytes.NewBuffer(body))
+	recorder = httptest.NewRecorder()
+	cl.Handler(recorder, req)
+	assert.Equal(t, 400, recorder.Code)
+
+	// Only POST requests are supported.
+	req = httptest.NewRequest("GET", "/processor/containerLogCollector", nil)
+	recorder = httptest.NewRecorder()
+	cl.Handler(recorder, req)
+	assert.Equal(t, 405, recorder.Code)
+}
+
+func TestLastLines(t *testing.T) {
+	assert.Equal(t, "line2\nline3", lastLines("line1\nline2\nline3\n", 2))
+	assert.Equal(t, "line1\nline2", lastLines("line1\nline2", 5))
+	assert.Equal(t, "", lastLines("", 5))
+}
@@ -0,0 +1,144 @@
+/*
+Copyright 2022 The KubeDiag Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package collector
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/go-logr/logr"
+
+	"github.com/kubediag/kubediag/pkg/processors"
+	"github.com/kubediag/kubediag/pkg/processors/utils"
+	"github.com/kubediag/kubediag/pkg/util"
+)
+
+const (
+	DmesgGrepPattern = "param.collector.kernel.dmesg.grepPattern"
+	DmesgTimeSince   = "param.collector.kernel.dmesg.timeSince"
+	DmesgFile        = "param.collector.kernel.dmesg.file"
+
+	ContextKeyDmesgLines        = "collector.kernel.dmesg.lines"
+	ContextKeyDmesgOOMKillCount = "collector.kernel.dmesg.oomKillCount"
+)
+
+// oomKillPattern matches kernel ring buffer lines reporting out of memory kill events.
+var oomKillPattern = regexp.MustCompile(`(?i)out of memory|oom-kill|invoked oom-killer|killed process`)
+
+// dmesgCollector collects the kernel ring buffer of a node.
+type dmesgCollector struct {
+	// Context carries values across API boundaries.
+	context.Context
+	// Logger represents the ability to log messages.
+	logr.Logger
+
+	// dmesgCollectorEnabled indicates whether dmesgCollector is enabled.
+	dmesgCollectorEnabled bool
+}
+
+// NewDmesgCollector creates a new dmesgCollector.
+func NewDmesgCollector(
+	ctx context.Context,
+	logger logr.Logger,
+	dmesgCollectorEnabled bool,
+) processors.Processor {
+	return &dmesgCollector{
+		Context:               ctx,
+		Logger:                logger,
+		dmesgCollectorEnabled: dmesgCollectorEnabled,
+	}
+}
+
+// Handler handles http requests for collecting the kernel ring buffer.
+func (dc *dmesgCollector) Handler(w http.ResponseWriter, r *http.Request) {
+	if !dc.dmesgCollectorEnabled {
+		http.Error(w, fmt.Sprintf("dmesg collector is not enabled"), http.StatusUnprocessableEntity)
+		return
+	}
+
+	switch r.Method {
+	case "POST":
+		contexts, err := utils.ExtractParametersFromHTTPContext(r)
+		if err != nil {
+			dc.Error(err, "extract contexts failed")
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		var pattern *regexp.Regexp
+		if value, ok := contexts[DmesgGrepPattern]; ok && value != "" {
+			pattern, err = regexp.Compile(value)
+			if err != nil {
+				http.Error(w, fmt.Sprintf("invalid grep pattern: %v", err), http.StatusBadRequest)
+				return
+			}
+		}
+
+		// Read the kernel ring buffer from a file if specified, e.g. a saved dump, otherwise
+		// from the dmesg command.
+		var data []byte
+		if file := contexts[DmesgFile]; file != "" {
+			data, err = ioutil.ReadFile(file)
+		} else {
+			command := []string{"dmesg", "--ctime"}
+			if since := contexts[DmesgTimeSince]; since != "" {
+				command = append(command, "--since", since)
+			}
+			data, err = util.BlockingRunCommandWithTimeout(command, processors.DefaultTimeoutSeconds)
+		}
+		if err != nil {
+			dc.Error(err, "failed to collect kernel ring buffer")
+			http.Error(w, fmt.Sprintf("failed to collect kernel ring buffer: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		lines := grepLines(string(data), pattern)
+
+		result := make(map[string]string)
+		result[ContextKeyDmesgLines] = lines
+		result[ContextKeyDmesgOOMKillCount] = strconv.Itoa(countOOMKillEvents(string(data)))
+		raw, err := json.Marshal(result)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("failed to marshal result: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(raw)
+	default:
+		http.Error(w, fmt.Sprintf("method %s is not supported", r.Method), http.StatusMethodNotAllowed)
+	}
+}
+
+// countOOMKillEvents returns the number of lines of the kernel ring buffer reporting out of
+// memory kill events.
+func countOOMKillEvents(log string) int {
+	count := 0
+	for _, line := range strings.Split(log, "\n") {
+		if oomKillPattern.MatchString(line) {
+			count++
+		}
+	}
+
+	return count
+}
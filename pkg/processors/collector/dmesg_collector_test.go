@@ -0,0 +1,109 @@
+/*
+Copyright 2022 The KubeDiag Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package collector
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+const dmesgFixture = `[Mon Jan  3 10:00:00 2022] eth0: link up
+[Mon Jan  3 10:00:01 2022] nginx invoked oom-killer: gfp_mask=0x14200ca, order=0, oom_score_adj=0
+[Mon Jan  3 10:00:01 2022] Out of memory: Kill process 1234 (nginx) score 987 or sacrifice child
+[Mon Jan  3 10:00:01 2022] Killed process 1234 (nginx) total-vm:100000kB, anon-rss:90000kB
+[Mon Jan  3 10:00:02 2022] EXT4-fs (sda1): mounted filesystem with ordered data mode
+`
+
+func TestDmesgCollectorHandler(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dmesg-collector")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	dmesgFile := filepath.Join(dir, "dmesg.log")
+	err = ioutil.WriteFile(dmesgFile, []byte(dmesgFixture), 0644)
+	assert.NoError(t, err)
+
+	dc := &dmesgCollector{
+		Context:               context.Background(),
+		Logger:                ctrl.Log.WithName("collector/dmesgCollector"),
+		dmesgCollectorEnabled: true,
+	}
+
+	body, err := json.Marshal(map[string]string{
+		DmesgFile:        dmesgFile,
+		DmesgGrepPattern: "oom-killer",
+	})
+	assert.NoError(t, err)
+	req := httptest.NewRequest("POST", "/processor/dmesgCollector", bytes.NewBuffer(body))
+	recorder := httptest.NewRecorder()
+	dc.Handler(recorder, req)
+	assert.Equal(t, 200, recorder.Code)
+
+	result := make(map[string]string)
+	err = json.Unmarshal(recorder.Body.Bytes(), &result)
+	assert.NoError(t, err)
+	lines := result[ContextKeyDmesgLines]
+	assert.Contains(t, lines, "nginx invoked oom-killer")
+	assert.NotContains(t, lines, "link up")
+	assert.Equal(t, "3", result[ContextKeyDmesgOOMKillCount])
+
+	// The whole ring buffer is returned if no grep pattern is specified.
+	body, err = json.Marshal(map[string]string{
+		DmesgFile: dmesgFile,
+	})
+	assert.NoError(t, err)
+	req = httptest.NewRequest("POST", "/processor/dmesgCollector", bytes.NewBuffer(body))
+	recorder = httptest.NewRecorder()
+	dc.Handler(recorder, req)
+	assert.Equal(t, 200, recorder.Code)
+	result = make(map[string]string)
+	err = json.Unmarshal(recorder.Body.Bytes(), &result)
+	assert.NoError(t, err)
+	assert.Contains(t, result[ContextKeyDmesgLines], "link up")
+
+	// An invalid grep pattern is rejected.
+	body, err = json.Marshal(map[string]string{
+		DmesgFile:        dmesgFile,
+		DmesgGrepPattern: "[",
+	})
+	assert.NoError(t, err)
+	req = httptest.NewRequest("POST", "/processor/dmesgCollector", bytes.NewBuffer(body))
+	recorder = httptest.NewRecorder()
+	dc.Handler(recorder, req)
+	assert.Equal(t, 400, recorder.Code)
+
+	// Only POST requests are supported.
+	req = httptest.NewRequest("GET", "/processor/dmesgCollector", nil)
+	recorder = httptest.NewRecorder()
+	dc.Handler(recorder, req)
+	assert.Equal(t, 405, recorder.Code)
+}
+
+func TestCountOOMKillEvents(t *testing.T) {
+	assert.Equal(t, 3, countOOMKillEvents(dmesgFixture))
+	assert.Equal(t, 0, countOOMKillEvents("eth0: link up\nEXT4-fs (sda1): mounted filesystem"))
+	assert.Equal(t, 0, countOOMKillEvents(""))
+}
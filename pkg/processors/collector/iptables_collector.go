@@ -0,0 +1,177 @@
+/*
+Copyright 2022 The KubeDiag Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package collector
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-logr/logr"
+
+	"github.com/kubediag/kubediag/pkg/processors"
+	"github.com/kubediag/kubediag/pkg/processors/utils"
+	"github.com/kubediag/kubediag/pkg/util"
+)
+
+const (
+	IptablesBackend = "param.collector.network.iptables.backend"
+	IptablesTable   = "param.collector.network.iptables.table"
+	IptablesChain   = "param.collector.network.iptables.chain"
+
+	ContextKeyIptablesRules     = "collector.network.iptables.rules"
+	ContextKeyIptablesRuleCount = "collector.network.iptables.ruleCount"
+
+	// iptablesBackendIptables dumps rules via the iptables command.
+	iptablesBackendIptables = "iptables"
+	// iptablesBackendNftables dumps rules via the nft command.
+	iptablesBackendNftables = "nftables"
+)
+
+// iptablesCollector collects packet filter rules of a node.
+type iptablesCollector struct {
+	// Context carries values across API boundaries.
+	context.Context
+	// Logger represents the ability to log messages.
+	logr.Logger
+
+	// runCommand runs a command with a timeout. It is a field so tests can stub the iptables
+	// and nft commands.
+	runCommand func(command []string, timeoutSeconds int32) ([]byte, error)
+	// iptablesCollectorEnabled indicates whether iptablesCollector is enabled.
+	iptablesCollectorEnabled bool
+}
+
+// NewIptablesCollector creates a new iptablesCollector.
+func NewIptablesCollector(
+	ctx context.Context,
+	logger logr.Logger,
+	iptablesCollectorEnabled bool,
+) processors.Processor {
+	return &iptablesCollector{
+		Context:                  ctx,
+		Logger:                   logger,
+		runCommand:               util.BlockingRunCommandWithTimeout,
+		iptablesCollectorEnabled: iptablesCollectorEnabled,
+	}
+}
+
+// Handler handles http requests for collecting packet filter rules.
+func (ic *iptablesCollector) Handler(w http.ResponseWriter, r *http.Request) {
+	if !ic.iptablesCollectorEnabled {
+		http.Error(w, fmt.Sprintf("iptables collector is not enabled"), http.StatusUnprocessableEntity)
+		return
+	}
+
+	switch r.Method {
+	case "POST":
+		contexts, err := utils.ExtractParametersFromHTTPContext(r)
+		if err != nil {
+			ic.Error(err, "extract contexts failed")
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		backend := contexts[IptablesBackend]
+		if backend == "" {
+			backend = iptablesBackendIptables
+		}
+		table := contexts[IptablesTable]
+		chain := contexts[IptablesChain]
+
+		var command []string
+		switch backend {
+		case iptablesBackendIptables:
+			command = []string{"iptables", "-S"}
+			if chain != "" {
+				command = append(command, chain)
+			}
+			if table != "" {
+				command = append(command, "-t", table)
+			}
+		case iptablesBackendNftables:
+			command = []string{"nft", "list", "ruleset"}
+			if table != "" && chain != "" {
+				command = []string{"nft", "list", "chain", "ip", table, chain}
+			} else if table != "" {
+				command = []string{"nft", "list", "table", "ip", table}
+			}
+		default:
+			http.Error(w, fmt.Sprintf("backend %s is not supported", backend), http.StatusBadRequest)
+			return
+		}
+
+		data, err := ic.runCommand(command, processors.DefaultTimeoutSeconds)
+		if err != nil {
+			ic.Error(err, "failed to collect packet filter rules", "backend", backend)
+			http.Error(w, fmt.Sprintf("failed to collect packet filter rules: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		var count int
+		if backend == iptablesBackendIptables {
+			count = countIptablesRules(string(data))
+		} else {
+			count = countNftablesRules(string(data))
+		}
+
+		result := make(map[string]string)
+		result[ContextKeyIptablesRules] = string(data)
+		result[ContextKeyIptablesRuleCount] = strconv.Itoa(count)
+		raw, err := json.Marshal(result)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("failed to marshal result: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(raw)
+	default:
+		http.Error(w, fmt.Sprintf("method %s is not supported", r.Method), http.StatusMethodNotAllowed)
+	}
+}
+
+// countIptablesRules returns the number of appended rules in an iptables rule listing.
+func countIptablesRules(rules string) int {
+	count := 0
+	for _, line := range strings.Split(rules, "\n") {
+		if strings.HasPrefix(line, "-A ") {
+			count++
+		}
+	}
+
+	return count
+}
+
+// countNftablesRules returns the number of rules in an nft ruleset listing. Structural lines
+// declaring tables and chains are not counted.
+func countNftablesRules(rules string) int {
+	count := 0
+	for _, line := range strings.Split(rules, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "table ") || strings.HasPrefix(trimmed, "chain ") ||
+			strings.HasPrefix(trimmed, "type ") || strings.HasSuffix(trimmed, "{") || trimmed == "}" {
+			continue
+		}
+		count++
+	}
+
+	return count
+}
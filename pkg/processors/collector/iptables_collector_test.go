@@ -0,0 +1,124 @@
+/*
+Copyright 2022 The KubeDiag Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package collector
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+const iptablesFixture = `-P PREROUTING ACCEPT
+-P OUTPUT ACCEPT
+-N KUBE-SERVICES
+-A PREROUTING -m comment --comment "kubernetes service portals" -j KUBE-SERVICES
+-A KUBE-SERVICES -d 10.96.0.1/32 -p tcp -m tcp --dport 443 -j KUBE-SVC-NPX46M4PTMTKRN6Y
+`
+
+const nftablesFixture = `table ip nat {
+	chain PREROUTING {
+		type nat hook prerouting priority dstnat; policy accept;
+		counter jump KUBE-SERVICES
+	}
+	chain KUBE-SERVICES {
+		ip daddr 10.96.0.1 tcp dport 443 counter jump KUBE-SVC-NPX46M4PTMTKRN6Y
+	}
+}
+`
+
+func TestIptablesCollectorHandler(t *testing.T) {
+	var executed []string
+	ic := &iptablesCollector{
+		Context: context.Background(),
+		Logger:  ctrl.Log.WithName("collector/iptablesCollector"),
+		runCommand: func(command []string, timeoutSeconds int32) ([]byte, error) {
+			executed = command
+			if command[0] == "nft" {
+				return []byte(nftablesFixture), nil
+			}
+			return []byte(iptablesFixture), nil
+		},
+		iptablesCollectorEnabled: true,
+	}
+
+	body, err := json.Marshal(map[string]string{
+		IptablesTable: "nat",
+		IptablesChain: "KUBE-SERVICES",
+	})
+	assert.NoError(t, err)
+	req := httptest.NewRequest("POST", "/processor/iptablesCollector", bytes.NewBuffer(body))
+	recorder := httptest.NewRecorder()
+	ic.Handler(recorder, req)
+	assert.Equal(t, 200, recorder.Code)
+	assert.Equal(t, []string{"iptables", "-S", "KUBE-SERVICES", "-t", "nat"}, executed)
+
+	result := make(map[string]string)
+	err = json.Unmarshal(recorder.Body.Bytes(), &result)
+	assert.NoError(t, err)
+	assert.Contains(t, result[ContextKeyIptablesRules], "KUBE-SVC-NPX46M4PTMTKRN6Y")
+	assert.Equal(t, "2", result[ContextKeyIptablesRuleCount])
+
+	// The nftables backend dumps rules via the nft command.
+	body, err = json.Marshal(map[string]string{
+		IptablesBackend: "nftables",
+		IptablesTable:   "nat",
+		IptablesChain:   "KUBE-SERVICES",
+	})
+	assert.NoError(t, err)
+	req = httptest.NewRequest("POST", "/processor/iptablesCollector", bytes.NewBuffer(body))
+	recorder = httptest.NewRecorder()
+	ic.Handler(recorder, req)
+	assert.Equal(t, 200, recorder.Code)
+	assert.Equal(t, []string{"nft", "list", "chain", "ip", "nat", "KUBE-SERVICES"}, executed)
+	result = make(map[string]string)
+	err = json.Unmarshal(recorder.Body.Bytes(), &result)
+	assert.NoError(t, err)
+	assert.Equal(t, "2", result[ContextKeyIptablesRuleCount])
+
+	// An unsupported backend is rejected.
+	body, err = json.Marshal(map[string]string{
+		IptablesBackend: "ipvs",
+	})
+	assert.NoError(t, err)
+	req = httptest.NewRequest("POST", "/processor/iptablesCollector", bytes.NewBuffer(body))
+	recorder = httptest.NewRecorder()
+	ic.Handler(recorder, req)
+	assert.Equal(t, 400, recorder.Code)
+
+	// Only POST requests are supported.
+	req = httptest.NewRequest("GET", "/processor/iptablesCollector", nil)
+	recorder = httptest.NewRecorder()
+	ic.Handler(recorder, req)
+	assert.Equal(t, 405, recorder.Code)
+}
+
+func TestCountIptablesRules(t *testing.T) {
+	assert.Equal(t, 2, countIptablesRules(iptablesFixture))
+	assert.Equal(t, 0, countIptablesRules("-P INPUT ACCEPT\n-N KUBE-SERVICES"))
+	assert.Equal(t, 0, countIptablesRules(""))
+}
+
+func TestCountNftablesRules(t *testing.T) {
+	assert.Equal(t, 2, countNftablesRules(nftablesFixture))
+	assert.Equal(t, 0, countNftablesRules("table ip nat {\n}"))
+	assert.Equal(t, 0, countNftablesRules(""))
+}
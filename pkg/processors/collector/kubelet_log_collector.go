@@ -0,0 +1,184 @@
+/*
+Copyright 2022 The KubeDiag Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package collector
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/go-logr/logr"
+
+	"github.com/kubediag/kubediag/pkg/processors"
+	"github.com/kubediag/kubediag/pkg/processors/utils"
+	"github.com/kubediag/kubediag/pkg/util"
+)
+
+const (
+	KubeletLogSource      = "param.collector.kubelet.log.source"
+	KubeletLogFile        = "param.collector.kubelet.log.file"
+	KubeletLogGrepPattern = "param.collector.kubelet.log.grepPattern"
+	KubeletLogTimeSince   = "param.collector.kubelet.log.timeSince"
+	KubeletLogTimeUntil   = "param.collector.kubelet.log.timeUntil"
+
+	ContextKeyKubeletLogLines = "collector.kubelet.log.lines"
+
+	// kubeletLogSourceSystemd collects the kubelet log from the systemd journal.
+	kubeletLogSourceSystemd = "systemd"
+	// kubeletLogSourceFile collects the kubelet log from a log file.
+	kubeletLogSourceFile = "file"
+)
+
+// kubeletLogCollector collects the kubelet log of a node.
+type kubeletLogCollector struct {
+	// Context carries values across API boundaries.
+	context.Context
+	// Logger represents the ability to log messages.
+	logr.Logger
+
+	// kubeletLogCollectorEnabled indicates whether kubeletLogCollector is enabled.
+	kubeletLogCollectorEnabled bool
+}
+
+// NewKubeletLogCollector creates a new kubeletLogCollector.
+func NewKubeletLogCollector(
+	ctx context.Context,
+	logger logr.Logger,
+	kubeletLogCollectorEnabled bool,
+) processors.Processor {
+	return &kubeletLogCollector{
+		Context:                    ctx,
+		Logger:                     logger,
+		kubeletLogCollectorEnabled: kubeletLogCollectorEnabled,
+	}
+}
+
+// Handler handles http requests for collecting the kubelet log.
+func (klc *kubeletLogCollector) Handler(w http.ResponseWriter, r *http.Request) {
+	if !klc.kubeletLogCollectorEnabled {
+		http.Error(w, fmt.Sprintf("kubelet log collector is not enabled"), http.StatusUnprocessableEntity)
+		return
+	}
+
+	switch r.Method {
+	case "POST":
+		contexts, err := utils.ExtractParametersFromHTTPContext(r)
+		if err != nil {
+			klc.Error(err, "extract contexts failed")
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		source := contexts[KubeletLogSource]
+		if source == "" {
+			source = kubeletLogSourceSystemd
+		}
+		var pattern *regexp.Regexp
+		if value, ok := contexts[KubeletLogGrepPattern]; ok && value != "" {
+			pattern, err = regexp.Compile(value)
+			if err != nil {
+				http.Error(w, fmt.Sprintf("invalid grep pattern: %v", err), http.StatusBadRequest)
+				return
+			}
+		}
+
+		var lines string
+		switch source {
+		case kubeletLogSourceSystemd:
+			lines, err = klc.collectFromJournal(contexts[KubeletLogTimeSince], contexts[KubeletLogTimeUntil], pattern)
+		case kubeletLogSourceFile:
+			logFile := contexts[KubeletLogFile]
+			if logFile == "" {
+				logFile = filepath.Join(util.KubeletRunDirectory, "kubelet.log")
+			}
+			lines, err = klc.collectFromFile(logFile, pattern)
+		default:
+			http.Error(w, fmt.Sprintf("log source %s is not supported", source), http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			klc.Error(err, "failed to collect kubelet log", "source", source)
+			http.Error(w, fmt.Sprintf("failed to collect kubelet log: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		result := make(map[string]string)
+		result[ContextKeyKubeletLogLines] = lines
+		data, err := json.Marshal(result)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("failed to marshal result: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(data)
+	default:
+		http.Error(w, fmt.Sprintf("method %s is not supported", r.Method), http.StatusMethodNotAllowed)
+	}
+}
+
+// collectFromJournal collects the kubelet log from the systemd journal within the provided
+// time window.
+func (klc *kubeletLogCollector) collectFromJournal(timeSince string, timeUntil string, pattern *regexp.Regexp) (string, error) {
+	command := []string{"journalctl", "--unit", "kubelet", "--no-pager"}
+	if timeSince != "" {
+		command = append(command, "--since", timeSince)
+	}
+	if timeUntil != "" {
+		command = append(command, "--until", timeUntil)
+	}
+
+	data, err := util.BlockingRunCommandWithTimeout(command, processors.DefaultTimeoutSeconds)
+	if err != nil {
+		return "", err
+	}
+
+	return grepLines(string(data), pattern), nil
+}
+
+// collectFromFile collects the kubelet log from a log file. The time window does not apply to
+// the file source since log files do not carry a uniform timestamp format.
+func (klc *kubeletLogCollector) collectFromFile(logFile string, pattern *regexp.Regexp) (string, error) {
+	data, err := ioutil.ReadFile(logFile)
+	if err != nil {
+		return "", err
+	}
+
+	return grepLines(string(data), pattern), nil
+}
+
+// grepLines returns the lines of the log matching the pattern. All lines are returned if the
+// pattern is nil.
+func grepLines(log string, pattern *regexp.Regexp) string {
+	if pattern == nil {
+		return log
+	}
+
+	matched := make([]string, 0)
+	for _, line := range strings.Split(log, "\n") {
+		if pattern.MatchString(line) {
+			matched = append(matched, line)
+		}
+	}
+
+	return strings.Join(matched, "\n")
+}
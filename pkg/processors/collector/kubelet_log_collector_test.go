@@ -0,0 +1,95 @@
+/*
+Copyright 2022 The KubeDiag Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package collector
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+func TestKubeletLogCollectorHandler(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kubelet-log-collector")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	logFile := filepath.Join(dir, "kubelet.log")
+	err = ioutil.WriteFile(logFile, []byte(`I0101 12:00:00.000000 1234 kubelet.go:100] kubelet started
+E0101 12:00:01.000000 1234 pod_workers.go:200] error syncing pod
+I0101 12:00:02.000000 1234 kubelet.go:300] pod synced
+E0101 12:00:03.000000 1234 kuberuntime_manager.go:400] failed to create sandbox
+`), 0644)
+	assert.NoError(t, err)
+
+	klc := &kubeletLogCollector{
+		Context:                    context.Background(),
+		Logger:                     ctrl.Log.WithName("collector/kubeletLogCollector"),
+		kubeletLogCollectorEnabled: true,
+	}
+
+	body, err := json.Marshal(map[string]string{
+		KubeletLogSource:      "file",
+		KubeletLogFile:        logFile,
+		KubeletLogGrepPattern: "^E",
+	})
+	assert.NoError(t, err)
+	req := httptest.NewRequest("POST", "/processor/kubeletLogCollector", bytes.NewBuffer(body))
+	recorder := httptest.NewRecorder()
+	klc.Handler(recorder, req)
+	assert.Equal(t, 200, recorder.Code)
+
+	result := make(map[string]string)
+	err = json.Unmarshal(recorder.Body.Bytes(), &result)
+	assert.NoError(t, err)
+	lines := result[ContextKeyKubeletLogLines]
+	assert.Contains(t, lines, "error syncing pod")
+	assert.Contains(t, lines, "failed to create sandbox")
+	assert.NotContains(t, lines, "kubelet started")
+
+	// An unsupported log source is rejected.
+	body, err = json.Marshal(map[string]string{
+		KubeletLogSource: "syslog",
+	})
+	assert.NoError(t, err)
+	req = httptest.NewRequest("POST", "/processor/kubeletLogCollector", bytes.NewBuffer(body))
+	recorder = httptest.NewRecorder()
+	klc.Handler(recorder, req)
+	assert.Equal(t, 400, recorder.Code)
+
+	// Only POST requests are supported.
+	req = httptest.NewRequest("GET", "/processor/kubeletLogCollector", nil)
+	recorder = httptest.NewRecorder()
+	klc.Handler(recorder, req)
+	assert.Equal(t, 405, recorder.Code)
+}
+
+func TestGrepLines(t *testing.T) {
+	log := "error one\ninfo two\nerror three"
+
+	assert.Equal(t, log, grepLines(log, nil))
+	assert.Equal(t, "error one\nerror three", grepLines(log, regexp.MustCompile("^error")))
+	assert.Equal(t, "", grepLines(log, regexp.MustCompile("warning")))
+}
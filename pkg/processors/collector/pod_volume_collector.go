@@ -0,0 +1,186 @@
+/*
+Copyright 2022 The KubeDiag Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package collector
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-logr/logr"
+
+	"github.com/kubediag/kubediag/pkg/processors"
+	"github.com/kubediag/kubediag/pkg/processors/utils"
+	"github.com/kubediag/kubediag/pkg/util"
+)
+
+const (
+	PodVolumeCollectorPodUID = "param.collector.pod.volume.uid"
+
+	ContextKeyPodVolumes = "collector.pod.volumes"
+)
+
+// podVolume contains details of a volume under the kubelet pod directory.
+type podVolume struct {
+	// Name is the name of the volume.
+	Name string `json:"name"`
+	// Type is the volume plugin directory name the volume is managed by.
+	Type string `json:"type"`
+	// Path is the absolute path of the volume directory.
+	Path string `json:"path"`
+	// SizeBytes is the disk usage of the volume directory in bytes.
+	SizeBytes int `json:"sizeBytes"`
+	// Mounted indicates whether the volume directory is a mount point.
+	Mounted bool `json:"mounted"`
+}
+
+// podVolumeCollector enumerates the volumes of a pod under the kubelet pod directory.
+type podVolumeCollector struct {
+	// Context carries values across API boundaries.
+	context.Context
+	// Logger represents the ability to log messages.
+	logr.Logger
+
+	// podDirectory is the directory where the kubelet pod information is stored.
+	podDirectory string
+	// podVolumeCollectorEnabled indicates whether podVolumeCollector is enabled.
+	podVolumeCollectorEnabled bool
+}
+
+// NewPodVolumeCollector creates a new podVolumeCollector.
+func NewPodVolumeCollector(
+	ctx context.Context,
+	logger logr.Logger,
+	podVolumeCollectorEnabled bool,
+) processors.Processor {
+	return &podVolumeCollector{
+		Context:                   ctx,
+		Logger:                    logger,
+		podDirectory:              util.KubeletPodDirectory,
+		podVolumeCollectorEnabled: podVolumeCollectorEnabled,
+	}
+}
+
+// Handler handles http requests for collecting pod volume details.
+func (pvc *podVolumeCollector) Handler(w http.ResponseWriter, r *http.Request) {
+	if !pvc.podVolumeCollectorEnabled {
+		http.Error(w, fmt.Sprintf("pod volume collector is not enabled"), http.StatusUnprocessableEntity)
+		return
+	}
+
+	switch r.Method {
+	case "POST":
+		contexts, err := utils.ExtractParametersFromHTTPContext(r)
+		if err != nil {
+			pvc.Error(err, "extract contexts failed")
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		uid := contexts[PodVolumeCollectorPodUID]
+		if uid == "" {
+			http.Error(w, fmt.Sprintf("must specify pod uid."), http.StatusBadRequest)
+			return
+		}
+
+		mountinfo, err := ioutil.ReadFile(mountinfoPath)
+		if err != nil {
+			pvc.Error(err, "unable to get mount info", "path", mountinfoPath)
+			mountinfo = nil
+		}
+
+		volumes, err := pvc.listPodVolumes(uid, string(mountinfo))
+		if err != nil {
+			pvc.Error(err, "failed to list pod volumes", "uid", uid)
+			http.Error(w, fmt.Sprintf("failed to list pod volumes: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		raw, err := json.Marshal(volumes)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("failed to marshal pod volumes: %v", err), http.StatusInternalServerError)
+			return
+		}
+		result := make(map[string]string)
+		result[ContextKeyPodVolumes] = string(raw)
+		data, err := json.Marshal(result)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("failed to marshal result: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(data)
+	default:
+		http.Error(w, fmt.Sprintf("method %s is not supported", r.Method), http.StatusMethodNotAllowed)
+	}
+}
+
+// listPodVolumes enumerates the volumes under the volumes directory of a pod.
+func (pvc *podVolumeCollector) listPodVolumes(uid string, mountinfo string) ([]podVolume, error) {
+	volumesDirectory := filepath.Join(pvc.podDirectory, uid, "volumes")
+	plugins, err := ioutil.ReadDir(volumesDirectory)
+	if err != nil {
+		return nil, err
+	}
+
+	volumes := make([]podVolume, 0)
+	for _, plugin := range plugins {
+		if !plugin.IsDir() {
+			continue
+		}
+		entries, err := ioutil.ReadDir(filepath.Join(volumesDirectory, plugin.Name()))
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			if !entry.IsDir() {
+				continue
+			}
+			path := filepath.Join(volumesDirectory, plugin.Name(), entry.Name())
+			size, err := util.DiskUsage(path)
+			if err != nil {
+				pvc.Error(err, "unable to calculate disk usage of volume", "path", path)
+			}
+			volumes = append(volumes, podVolume{
+				Name:      entry.Name(),
+				Type:      plugin.Name(),
+				Path:      path,
+				SizeBytes: size,
+				Mounted:   isMountPoint(mountinfo, path),
+			})
+		}
+	}
+
+	return volumes, nil
+}
+
+// isMountPoint reports whether the path is a mount point according to the mount info.
+func isMountPoint(mountinfo string, path string) bool {
+	for _, line := range strings.Split(mountinfo, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) >= 5 && fields[4] == path {
+			return true
+		}
+	}
+
+	return false
+}
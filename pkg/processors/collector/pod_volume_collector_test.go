@@ -0,0 +1,95 @@
+/*
+Copyright 2022 The KubeDiag Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package collector
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+func TestPodVolumeCollectorHandler(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pod-volume-collector")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	uid := "8b9e7f2c-4c25-4f0b-9a9e-8a2b7f3b1c5d"
+	emptyDir := filepath.Join(dir, uid, "volumes", "kubernetes.io~empty-dir", "cache")
+	secretDir := filepath.Join(dir, uid, "volumes", "kubernetes.io~secret", "token")
+	assert.NoError(t, os.MkdirAll(emptyDir, 0755))
+	assert.NoError(t, os.MkdirAll(secretDir, 0755))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(emptyDir, "data"), []byte("some cached data"), 0644))
+
+	pvc := &podVolumeCollector{
+		Context:                   context.Background(),
+		Logger:                    ctrl.Log.WithName("collector/podVolumeCollector"),
+		podDirectory:              dir,
+		podVolumeCollectorEnabled: true,
+	}
+
+	body, err := json.Marshal(map[string]string{
+		PodVolumeCollectorPodUID: uid,
+	})
+	assert.NoError(t, err)
+	req := httptest.NewRequest("POST", "/processor/podVolumeCollector", bytes.NewBuffer(body))
+	recorder := httptest.NewRecorder()
+	pvc.Handler(recorder, req)
+	assert.Equal(t, 200, recorder.Code)
+
+	result := make(map[string]string)
+	err = json.Unmarshal(recorder.Body.Bytes(), &result)
+	assert.NoError(t, err)
+	var volumes []podVolume
+	err = json.Unmarshal([]byte(result[ContextKeyPodVolumes]), &volumes)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(volumes))
+	assert.Equal(t, "cache", volumes[0].Name)
+	assert.Equal(t, "kubernetes.io~empty-dir", volumes[0].Type)
+	assert.True(t, volumes[0].SizeBytes > 0)
+	assert.False(t, volumes[0].Mounted)
+	assert.Equal(t, "token", volumes[1].Name)
+	assert.Equal(t, "kubernetes.io~secret", volumes[1].Type)
+
+	// The pod uid must be specified.
+	req = httptest.NewRequest("POST", "/processor/podVolumeCollector", bytes.NewBufferString("{}"))
+	recorder = httptest.NewRecorder()
+	pvc.Handler(recorder, req)
+	assert.Equal(t, 400, recorder.Code)
+
+	// Only POST requests are supported.
+	req = httptest.NewRequest("GET", "/processor/podVolumeCollector", nil)
+	recorder = httptest.NewRecorder()
+	pvc.Handler(recorder, req)
+	assert.Equal(t, 405, recorder.Code)
+}
+
+func TestIsMountPoint(t *testing.T) {
+	mountinfo := `25 30 0:23 / /sys rw,nosuid,nodev,noexec,relatime shared:7 - sysfs sysfs rw
+2239 30 0:150 / /var/lib/kubelet/pods/uid1/volumes/kubernetes.io~secret/token rw,relatime shared:716 - tmpfs tmpfs rw`
+
+	assert.True(t, isMountPoint(mountinfo, "/var/lib/kubelet/pods/uid1/volumes/kubernetes.io~secret/token"))
+	assert.False(t, isMountPoint(mountinfo, "/var/lib/kubelet/pods/uid1/volumes/kubernetes.io~empty-dir/cache"))
+	assert.False(t, isMountPoint("", "/sys"))
+}
@@ -0,0 +1,294 @@
+/*
+Copyright 2022 The KubeDiag Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package collector
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/go-logr/logr"
+
+	"github.com/kubediag/kubediag/pkg/processors"
+	"github.com/kubediag/kubediag/pkg/processors/utils"
+)
+
+const (
+	PSICollectorPodUID = "param.collector.system.psi.pod_uid"
+
+	ContextKeySystemPSI = "collector.system.psi"
+	ContextKeyPodPSI    = "collector.pod.psi"
+
+	procPressureDirectory = "/proc/pressure"
+	cgroupRootDirectory   = "/sys/fs/cgroup"
+)
+
+// pressureAverages contains the averaged pressure stall percentages of one psi line.
+type pressureAverages struct {
+	// Avg10 is the percentage of time stalled over the last 10 seconds.
+	Avg10 float64 `json:"avg10"`
+	// Avg60 is the percentage of time stalled over the last 60 seconds.
+	Avg60 float64 `json:"avg60"`
+	// Avg300 is the percentage of time stalled over the last 300 seconds.
+	Avg300 float64 `json:"avg300"`
+	// Total is the accumulated stall time in microseconds.
+	Total uint64 `json:"total"`
+}
+
+// pressureStats contains the some and full pressure stall information of one resource.
+type pressureStats struct {
+	// Some indicates the share of time in which at least some tasks are stalled.
+	Some pressureAverages `json:"some"`
+	// Full indicates the share of time in which all non-idle tasks are stalled simultaneously.
+	// It is nil if the kernel does not report full pressure for the resource.
+	Full *pressureAverages `json:"full,omitempty"`
+}
+
+// resourcePressure contains the pressure stall information of cpu, memory and io.
+type resourcePressure struct {
+	// CPU is the cpu pressure stall information.
+	CPU *pressureStats `json:"cpu,omitempty"`
+	// Memory is the memory pressure stall information.
+	Memory *pressureStats `json:"memory,omitempty"`
+	// IO is the io pressure stall information.
+	IO *pressureStats `json:"io,omitempty"`
+}
+
+// psiCollector collects pressure stall information of the node and optionally of a pod cgroup.
+type psiCollector struct {
+	// Context carries values across API boundaries.
+	context.Context
+	// Logger represents the ability to log messages.
+	logr.Logger
+
+	// pressureDirectory is the directory containing system psi files.
+	pressureDirectory string
+	// cgroupRoot is the root of the cgroup filesystem.
+	cgroupRoot string
+	// psiCollectorEnabled indicates whether psiCollector is enabled.
+	psiCollectorEnabled bool
+}
+
+// NewPSICollector creates a new psiCollector.
+func NewPSICollector(
+	ctx context.Context,
+	logger logr.Logger,
+	psiCollectorEnabled bool,
+) processors.Processor {
+	return &psiCollector{
+		Context:             ctx,
+		Logger:              logger,
+		pressureDirectory:   procPressureDirectory,
+		cgroupRoot:          cgroupRootDirectory,
+		psiCollectorEnabled: psiCollectorEnabled,
+	}
+}
+
+// Handler handles http requests for collecting pressure stall information.
+func (pc *psiCollector) Handler(w http.ResponseWriter, r *http.Request) {
+	if !pc.psiCollectorEnabled {
+		http.Error(w, fmt.Sprintf("psi collector is not enabled"), http.StatusUnprocessableEntity)
+		return
+	}
+
+	switch r.Method {
+	case "POST":
+		contexts, err := utils.ExtractParametersFromHTTPContext(r)
+		if err != nil {
+			pc.Error(err, "extract contexts failed")
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		systemPressure, err := pc.collectPressure(pc.pressureDirectory, "cpu", "memory", "io")
+		if err != nil {
+			pc.Error(err, "failed to collect system pressure stall information", "path", pc.pressureDirectory)
+			http.Error(w, fmt.Sprintf("failed to collect pressure stall information: %v", err), http.StatusInternalServerError)
+			return
+		}
+		systemRaw, err := json.Marshal(systemPressure)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("failed to marshal pressure stall information: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		result := make(map[string]string)
+		result[ContextKeySystemPSI] = string(systemRaw)
+
+		uid := contexts[PSICollectorPodUID]
+		if uid != "" {
+			podCgroup, err := findPodCgroup(pc.cgroupRoot, uid)
+			if err != nil {
+				pc.Error(err, "failed to find pod cgroup", "uid", uid)
+				http.Error(w, fmt.Sprintf("failed to find cgroup of pod %s: %v", uid, err), http.StatusInternalServerError)
+				return
+			}
+			podPressure, err := pc.collectPressure(podCgroup, "cpu.pressure", "memory.pressure", "io.pressure")
+			if err != nil {
+				pc.Error(err, "failed to collect pod pressure stall information", "path", podCgroup)
+				http.Error(w, fmt.Sprintf("failed to collect pressure stall information of pod %s: %v", uid, err), http.StatusInternalServerError)
+				return
+			}
+			podRaw, err := json.Marshal(podPressure)
+			if err != nil {
+				http.Error(w, fmt.Sprintf("failed to marshal pressure stall information: %v", err), http.StatusInternalServerError)
+				return
+			}
+			result[ContextKeyPodPSI] = string(podRaw)
+		}
+
+		data, err := json.Marshal(result)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("failed to marshal result: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(data)
+	default:
+		http.Error(w, fmt.Sprintf("method %s is not supported", r.Method), http.StatusMethodNotAllowed)
+	}
+}
+
+// collectPressure reads the cpu, memory and io psi files under the directory. A missing file
+// is skipped since kernels may not report psi for all resources.
+func (pc *psiCollector) collectPressure(directory string, cpuFile string, memoryFile string, ioFile string) (resourcePressure, error) {
+	pressure := resourcePressure{}
+	for _, entry := range []struct {
+		file  string
+		stats **pressureStats
+	}{
+		{cpuFile, &pressure.CPU},
+		{memoryFile, &pressure.Memory},
+		{ioFile, &pressure.IO},
+	} {
+		path := filepath.Join(directory, entry.file)
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return resourcePressure{}, err
+		}
+		stats, err := parsePressure(string(data))
+		if err != nil {
+			return resourcePressure{}, fmt.Errorf("unable to parse %s: %v", path, err)
+		}
+		*entry.stats = stats
+	}
+	if pressure.CPU == nil && pressure.Memory == nil && pressure.IO == nil {
+		return resourcePressure{}, fmt.Errorf("no psi file found under %s", directory)
+	}
+
+	return pressure, nil
+}
+
+// parsePressure parses the content of a psi file in the format:
+//
+//	some avg10=0.00 avg60=0.00 avg300=0.00 total=0
+//	full avg10=0.00 avg60=0.00 avg300=0.00 total=0
+func parsePressure(content string) (*pressureStats, error) {
+	stats := pressureStats{}
+	parsed := false
+	for _, line := range strings.Split(content, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 5 {
+			continue
+		}
+		averages := pressureAverages{}
+		for _, field := range fields[1:] {
+			pair := strings.SplitN(field, "=", 2)
+			if len(pair) != 2 {
+				return nil, fmt.Errorf("invalid field %q", field)
+			}
+			switch pair[0] {
+			case "avg10", "avg60", "avg300":
+				value, err := strconv.ParseFloat(pair[1], 64)
+				if err != nil {
+					return nil, fmt.Errorf("invalid field %q: %v", field, err)
+				}
+				switch pair[0] {
+				case "avg10":
+					averages.Avg10 = value
+				case "avg60":
+					averages.Avg60 = value
+				case "avg300":
+					averages.Avg300 = value
+				}
+			case "total":
+				value, err := strconv.ParseUint(pair[1], 10, 64)
+				if err != nil {
+					return nil, fmt.Errorf("invalid field %q: %v", field, err)
+				}
+				averages.Total = value
+			default:
+				return nil, fmt.Errorf("invalid field %q", field)
+			}
+		}
+		switch fields[0] {
+		case "some":
+			stats.Some = averages
+			parsed = true
+		case "full":
+			full := averages
+			stats.Full = &full
+			parsed = true
+		}
+	}
+	if !parsed {
+		return nil, fmt.Errorf("no psi line found")
+	}
+
+	return &stats, nil
+}
+
+// findPodCgroup searches the cgroup filesystem for the cgroup directory of a pod. Both the
+// cgroupfs and the systemd cgroup driver layouts are supported.
+func findPodCgroup(cgroupRoot string, uid string) (string, error) {
+	cgroupfsName := "pod" + uid
+	systemdName := "pod" + strings.ReplaceAll(uid, "-", "_")
+
+	var podCgroup string
+	err := filepath.Walk(cgroupRoot, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+		if !info.IsDir() {
+			return nil
+		}
+		name := filepath.Base(path)
+		if name == cgroupfsName || strings.Contains(name, systemdName) {
+			podCgroup = path
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	if podCgroup == "" {
+		return "", fmt.Errorf("cgroup of pod %s not found under %s", uid, cgroupRoot)
+	}
+
+	return podCgroup, nil
+}
@@ -0,0 +1,157 @@
+/*
+Copyright 2022 The KubeDiag Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package collector
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+func TestParsePressure(t *testing.T) {
+	stats, err := parsePressure(`some avg10=1.50 avg60=0.75 avg300=0.10 total=123456
+full avg10=0.20 avg60=0.05 avg300=0.00 total=7890
+`)
+	assert.NoError(t, err)
+	assert.Equal(t, 1.50, stats.Some.Avg10)
+	assert.Equal(t, 0.75, stats.Some.Avg60)
+	assert.Equal(t, 0.10, stats.Some.Avg300)
+	assert.Equal(t, uint64(123456), stats.Some.Total)
+	assert.NotNil(t, stats.Full)
+	assert.Equal(t, 0.20, stats.Full.Avg10)
+	assert.Equal(t, uint64(7890), stats.Full.Total)
+
+	// Older kernels do not report full cpu pressure.
+	stats, err = parsePressure("some avg10=0.00 avg60=0.00 avg300=0.00 total=0\n")
+	assert.NoError(t, err)
+	assert.Nil(t, stats.Full)
+
+	_, err = parsePressure("")
+	assert.Error(t, err)
+	_, err = parsePressure("some avg10=abc avg60=0.00 avg300=0.00 total=0\n")
+	assert.Error(t, err)
+}
+
+func TestPSICollectorHandler(t *testing.T) {
+	dir, err := ioutil.TempDir("", "psi-collector")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	pressureDirectory := filepath.Join(dir, "pressure")
+	assert.NoError(t, os.MkdirAll(pressureDirectory, 0755))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(pressureDirectory, "cpu"), []byte("some avg10=2.50 avg60=1.00 avg300=0.30 total=1000\n"), 0644))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(pressureDirectory, "memory"), []byte("some avg10=0.10 avg60=0.05 avg300=0.00 total=200\nfull avg10=0.05 avg60=0.00 avg300=0.00 total=100\n"), 0644))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(pressureDirectory, "io"), []byte("some avg10=5.00 avg60=3.00 avg300=1.00 total=3000\nfull avg10=4.00 avg60=2.00 avg300=0.50 total=2000\n"), 0644))
+
+	uid := "8b9e7f2c-4c25-4f0b-9a9e-8a2b7f3b1c5d"
+	cgroupRoot := filepath.Join(dir, "cgroup")
+	podCgroup := filepath.Join(cgroupRoot, "kubepods.slice", "kubepods-burstable.slice", "kubepods-burstable-pod8b9e7f2c_4c25_4f0b_9a9e_8a2b7f3b1c5d.slice")
+	assert.NoError(t, os.MkdirAll(podCgroup, 0755))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(podCgroup, "memory.pressure"), []byte("some avg10=9.00 avg60=6.00 avg300=2.00 total=5000\nfull avg10=8.00 avg60=5.00 avg300=1.00 total=4000\n"), 0644))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(podCgroup, "io.pressure"), []byte("some avg10=0.00 avg60=0.00 avg300=0.00 total=0\nfull avg10=0.00 avg60=0.00 avg300=0.00 total=0\n"), 0644))
+
+	pc := &psiCollector{
+		Context:             context.Background(),
+		Logger:              ctrl.Log.WithName("collector/psiCollector"),
+		pressureDirectory:   pressureDirectory,
+		cgroupRoot:          cgroupRoot,
+		psiCollectorEnabled: true,
+	}
+
+	body, err := json.Marshal(map[string]string{
+		PSICollectorPodUID: uid,
+	})
+	assert.NoError(t, err)
+	req := httptest.NewRequest("POST", "/processor/psiCollector", bytes.NewBuffer(body))
+	recorder := httptest.NewRecorder()
+	pc.Handler(recorder, req)
+	assert.Equal(t, 200, recorder.Code)
+
+	result := make(map[string]string)
+	err = json.Unmarshal(recorder.Body.Bytes(), &result)
+	assert.NoError(t, err)
+	var systemPressure resourcePressure
+	err = json.Unmarshal([]byte(result[ContextKeySystemPSI]), &systemPressure)
+	assert.NoError(t, err)
+	assert.NotNil(t, systemPressure.CPU)
+	assert.Equal(t, 2.50, systemPressure.CPU.Some.Avg10)
+	assert.Nil(t, systemPressure.CPU.Full)
+	assert.NotNil(t, systemPressure.Memory)
+	assert.NotNil(t, systemPressure.Memory.Full)
+	assert.Equal(t, 0.05, systemPressure.Memory.Full.Avg10)
+	assert.NotNil(t, systemPressure.IO)
+	assert.Equal(t, uint64(3000), systemPressure.IO.Some.Total)
+	var podPressure resourcePressure
+	err = json.Unmarshal([]byte(result[ContextKeyPodPSI]), &podPressure)
+	assert.NoError(t, err)
+	assert.Nil(t, podPressure.CPU)
+	assert.NotNil(t, podPressure.Memory)
+	assert.Equal(t, 9.00, podPressure.Memory.Some.Avg10)
+	assert.NotNil(t, podPressure.IO)
+
+	// System pressure only if no pod uid is specified.
+	req = httptest.NewRequest("POST", "/processor/psiCollector", bytes.NewBufferString("{}"))
+	recorder = httptest.NewRecorder()
+	pc.Handler(recorder, req)
+	assert.Equal(t, 200, recorder.Code)
+	result = make(map[string]string)
+	err = json.Unmarshal(recorder.Body.Bytes(), &result)
+	assert.NoError(t, err)
+	_, ok := result[ContextKeyPodPSI]
+	assert.False(t, ok)
+
+	// An unknown pod uid is an error.
+	body, err = json.Marshal(map[string]string{
+		PSICollectorPodUID: "00000000-0000-0000-0000-000000000000",
+	})
+	assert.NoError(t, err)
+	req = httptest.NewRequest("POST", "/processor/psiCollector", bytes.NewBuffer(body))
+	recorder = httptest.NewRecorder()
+	pc.Handler(recorder, req)
+	assert.Equal(t, 500, recorder.Code)
+
+	// Only POST requests are supported.
+	req = httptest.NewRequest("GET", "/processor/psiCollector", nil)
+	recorder = httptest.NewRecorder()
+	pc.Handler(recorder, req)
+	assert.Equal(t, 405, recorder.Code)
+}
+
+func TestFindPodCgroup(t *testing.T) {
+	dir, err := ioutil.TempDir("", "psi-collector-cgroup")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	uid := "11111111-2222-3333-4444-555555555555"
+	cgroupfsPath := filepath.Join(dir, "kubepods", "burstable", "pod"+uid)
+	assert.NoError(t, os.MkdirAll(cgroupfsPath, 0755))
+
+	found, err := findPodCgroup(dir, uid)
+	assert.NoError(t, err)
+	assert.Equal(t, cgroupfsPath, found)
+
+	_, err = findPodCgroup(dir, "00000000-0000-0000-0000-000000000000")
+	assert.Error(t, err)
+}
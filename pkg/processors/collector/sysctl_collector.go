@@ -0,0 +1,159 @@
+/*
+Copyright 2022 The KubeDiag Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package collector
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-logr/logr"
+
+	"github.com/kubediag/kubediag/pkg/processors"
+	"github.com/kubediag/kubediag/pkg/processors/utils"
+)
+
+const (
+	SysctlCollectorSysctls   = "param.collector.system.sysctl.sysctls"
+	SysctlCollectorProcFiles = "param.collector.system.sysctl.procFiles"
+
+	ContextKeySysctlPrefix   = "collector.system.sysctl."
+	ContextKeyProcFilePrefix = "collector.system.proc."
+
+	procDirectory = "/proc"
+)
+
+// sysctlCollector collects sysctls and kernel parameters of a node.
+type sysctlCollector struct {
+	// Context carries values across API boundaries.
+	context.Context
+	// Logger represents the ability to log messages.
+	logr.Logger
+
+	// procDirectory is the mount point of the proc filesystem.
+	procDirectory string
+	// sysctlCollectorEnabled indicates whether sysctlCollector is enabled.
+	sysctlCollectorEnabled bool
+}
+
+// NewSysctlCollector creates a new sysctlCollector.
+func NewSysctlCollector(
+	ctx context.Context,
+	logger logr.Logger,
+	sysctlCollectorEnabled bool,
+) processors.Processor {
+	return &sysctlCollector{
+		Context:                ctx,
+		Logger:                 logger,
+		procDirectory:          procDirectory,
+		sysctlCollectorEnabled: sysctlCollectorEnabled,
+	}
+}
+
+// Handler handles http requests for collecting sysctls and kernel parameters.
+func (sc *sysctlCollector) Handler(w http.ResponseWriter, r *http.Request) {
+	if !sc.sysctlCollectorEnabled {
+		http.Error(w, fmt.Sprintf("sysctl collector is not enabled"), http.StatusUnprocessableEntity)
+		return
+	}
+
+	switch r.Method {
+	case "POST":
+		contexts, err := utils.ExtractParametersFromHTTPContext(r)
+		if err != nil {
+			sc.Error(err, "extract contexts failed")
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		sysctls := splitCommaSeparated(contexts[SysctlCollectorSysctls])
+		procFiles := splitCommaSeparated(contexts[SysctlCollectorProcFiles])
+		if len(sysctls) == 0 && len(procFiles) == 0 {
+			http.Error(w, fmt.Sprintf("must specify sysctls or proc files to collect."), http.StatusBadRequest)
+			return
+		}
+
+		result := make(map[string]string)
+		for _, sysctl := range sysctls {
+			value, err := sc.readSysctl(sysctl)
+			if err != nil {
+				sc.Error(err, "unable to read sysctl", "sysctl", sysctl)
+				http.Error(w, fmt.Sprintf("unable to read sysctl %s: %v", sysctl, err), http.StatusInternalServerError)
+				return
+			}
+			result[ContextKeySysctlPrefix+sysctl] = value
+		}
+		for _, procFile := range procFiles {
+			value, err := sc.readProcFile(procFile)
+			if err != nil {
+				sc.Error(err, "unable to read proc file", "file", procFile)
+				http.Error(w, fmt.Sprintf("unable to read proc file %s: %v", procFile, err), http.StatusInternalServerError)
+				return
+			}
+			result[ContextKeyProcFilePrefix+procFile] = value
+		}
+
+		data, err := json.Marshal(result)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("failed to marshal result: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(data)
+	default:
+		http.Error(w, fmt.Sprintf("method %s is not supported", r.Method), http.StatusMethodNotAllowed)
+	}
+}
+
+// readSysctl reads the value of a sysctl in dotted notation from the proc filesystem.
+func (sc *sysctlCollector) readSysctl(sysctl string) (string, error) {
+	path := filepath.Join(append([]string{sc.procDirectory, "sys"}, strings.Split(sysctl, ".")...)...)
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(string(data)), nil
+}
+
+// readProcFile reads a file under the proc filesystem by its relative path.
+func (sc *sysctlCollector) readProcFile(procFile string) (string, error) {
+	data, err := ioutil.ReadFile(filepath.Join(sc.procDirectory, procFile))
+	if err != nil {
+		return "", err
+	}
+
+	return string(data), nil
+}
+
+// splitCommaSeparated splits a comma separated value into trimmed non empty items.
+func splitCommaSeparated(value string) []string {
+	items := make([]string, 0)
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item != "" {
+			items = append(items, item)
+		}
+	}
+
+	return items
+}
@@ -0,0 +1,92 @@
+/*
+Copyright 2022 The KubeDiag Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package collector
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+func TestSysctlCollectorHandler(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sysctl-collector")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, "sys", "vm"), 0755))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "sys", "vm", "max_map_count"), []byte("65530\n"), 0644))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "loadavg"), []byte("0.52 0.58 0.59 1/467 12345\n"), 0644))
+
+	sc := &sysctlCollector{
+		Context:                context.Background(),
+		Logger:                 ctrl.Log.WithName("collector/sysctlCollector"),
+		procDirectory:          dir,
+		sysctlCollectorEnabled: true,
+	}
+
+	body, err := json.Marshal(map[string]string{
+		SysctlCollectorSysctls:   "vm.max_map_count",
+		SysctlCollectorProcFiles: "loadavg",
+	})
+	assert.NoError(t, err)
+	req := httptest.NewRequest("POST", "/processor/sysctlCollector", bytes.NewBuffer(body))
+	recorder := httptest.NewRecorder()
+	sc.Handler(recorder, req)
+	assert.Equal(t, 200, recorder.Code)
+
+	result := make(map[string]string)
+	err = json.Unmarshal(recorder.Body.Bytes(), &result)
+	assert.NoError(t, err)
+	assert.Equal(t, "65530", result[ContextKeySysctlPrefix+"vm.max_map_count"])
+	assert.Equal(t, "0.52 0.58 0.59 1/467 12345\n", result[ContextKeyProcFilePrefix+"loadavg"])
+
+	// A nonexistent sysctl results in an internal server error.
+	body, err = json.Marshal(map[string]string{
+		SysctlCollectorSysctls: "net.ipv4.ip_forward",
+	})
+	assert.NoError(t, err)
+	req = httptest.NewRequest("POST", "/processor/sysctlCollector", bytes.NewBuffer(body))
+	recorder = httptest.NewRecorder()
+	sc.Handler(recorder, req)
+	assert.Equal(t, 500, recorder.Code)
+
+	// The keys to collect must be specified.
+	req = httptest.NewRequest("POST", "/processor/sysctlCollector", bytes.NewBufferString("{}"))
+	recorder = httptest.NewRecorder()
+	sc.Handler(recorder, req)
+	assert.Equal(t, 400, recorder.Code)
+
+	// Only POST requests are supported.
+	req = httptest.NewRequest("GET", "/processor/sysctlCollector", nil)
+	recorder = httptest.NewRecorder()
+	sc.Handler(recorder, req)
+	assert.Equal(t, 405, recorder.Code)
+}
+
+func TestSplitCommaSeparated(t *testing.T) {
+	assert.Equal(t, []string{"a", "b"}, splitCommaSeparated("a, b"))
+	assert.Equal(t, []string{"a"}, splitCommaSeparated("a,"))
+	assert.Equal(t, []string{}, splitCommaSeparated(""))
+}
@@ -0,0 +1,187 @@
+/*
+Copyright 2022 The KubeDiag Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package diagnoser
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/kubediag/kubediag/pkg/processors"
+	"github.com/kubediag/kubediag/pkg/processors/utils"
+)
+
+const (
+	ParameterKeyCertExpiryEndpoints     = "param.diagnoser.cert_expiry.endpoints"
+	ParameterKeyCertExpiryThresholdDays = "param.diagnoser.cert_expiry.thresholdDays"
+
+	ContextKeyCertExpirySummary  = "diagnoser.cert_expiry.summary"
+	ContextKeyCertExpiryExpiring = "diagnoser.cert_expiry.expiring"
+
+	// defaultCertExpiryThresholdDays is the default number of days until expiry within which a
+	// certificate is flagged as expiring.
+	defaultCertExpiryThresholdDays = 30
+	// certExpiryDialTimeout is the timeout for establishing a tls connection to an endpoint.
+	certExpiryDialTimeout = 10 * time.Second
+)
+
+// certExpirySummary is a summary of the serving certificate of an endpoint.
+type certExpirySummary struct {
+	// Endpoint is the host:port endpoint serving the certificate.
+	Endpoint string `json:"endpoint"`
+	// Issuer is the issuer of the certificate.
+	Issuer string `json:"issuer"`
+	// NotAfter is the time at which the certificate expires.
+	NotAfter metav1.Time `json:"notAfter"`
+	// DaysUntilExpiry is the number of days until the certificate expires. It is negative if
+	// the certificate has expired.
+	DaysUntilExpiry int `json:"daysUntilExpiry"`
+	// Expiring indicates whether the certificate expires within the threshold.
+	Expiring bool `json:"expiring"`
+	// Error is the message of an error on connecting to the endpoint.
+	Error string `json:"error,omitempty"`
+}
+
+// certExpiryDiagnoser checks certificate expiry of serving endpoints over tls.
+type certExpiryDiagnoser struct {
+	// Context carries values across API boundaries.
+	context.Context
+	// Logger represents the ability to log messages.
+	logr.Logger
+
+	// certExpiryDiagnoserEnabled indicates whether certExpiryDiagnoser is enabled.
+	certExpiryDiagnoserEnabled bool
+}
+
+// NewCertExpiryDiagnoser creates a new certExpiryDiagnoser.
+func NewCertExpiryDiagnoser(
+	ctx context.Context,
+	logger logr.Logger,
+	certExpiryDiagnoserEnabled bool,
+) processors.Processor {
+	return &certExpiryDiagnoser{
+		Context:                    ctx,
+		Logger:                     logger,
+		certExpiryDiagnoserEnabled: certExpiryDiagnoserEnabled,
+	}
+}
+
+// Handler handles http requests for checking certificate expiry of serving endpoints.
+func (cd *certExpiryDiagnoser) Handler(w http.ResponseWriter, r *http.Request) {
+	if !cd.certExpiryDiagnoserEnabled {
+		http.Error(w, fmt.Sprintf("cert expiry diagnoser is not enabled"), http.StatusUnprocessableEntity)
+		return
+	}
+
+	switch r.Method {
+	case "POST":
+		contexts, err := utils.ExtractParametersFromHTTPContext(r)
+		if err != nil {
+			cd.Error(err, "extract contexts failed")
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if contexts[ParameterKeyCertExpiryEndpoints] == "" {
+			http.Error(w, "endpoints must be specified", http.StatusBadRequest)
+			return
+		}
+		endpoints := strings.Split(contexts[ParameterKeyCertExpiryEndpoints], ",")
+		thresholdDays := defaultCertExpiryThresholdDays
+		if value, ok := contexts[ParameterKeyCertExpiryThresholdDays]; ok && value != "" {
+			thresholdDays, err = strconv.Atoi(value)
+			if err != nil {
+				http.Error(w, fmt.Sprintf("invalid threshold days: %v", err), http.StatusBadRequest)
+				return
+			}
+		}
+
+		summaries := make([]certExpirySummary, 0, len(endpoints))
+		expiring := make([]string, 0)
+		for _, endpoint := range endpoints {
+			endpoint = strings.TrimSpace(endpoint)
+			if endpoint == "" {
+				continue
+			}
+			summary := cd.checkEndpoint(endpoint, thresholdDays)
+			summaries = append(summaries, summary)
+			if summary.Expiring {
+				expiring = append(expiring, endpoint)
+			}
+		}
+
+		raw, err := json.Marshal(summaries)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("failed to marshal cert expiry summaries: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		result := make(map[string]string)
+		result[ContextKeyCertExpirySummary] = string(raw)
+		result[ContextKeyCertExpiryExpiring] = strings.Join(expiring, ",")
+		data, err := json.Marshal(result)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("failed to marshal result: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(data)
+	default:
+		http.Error(w, fmt.Sprintf("method %s is not supported", r.Method), http.StatusMethodNotAllowed)
+	}
+}
+
+// checkEndpoint connects to an endpoint over tls and summarizes its serving certificate. The
+// certificate chain is not verified since only the expiry of the presented certificate matters.
+func (cd *certExpiryDiagnoser) checkEndpoint(endpoint string, thresholdDays int) certExpirySummary {
+	summary := certExpirySummary{
+		Endpoint: endpoint,
+	}
+	conn, err := tls.DialWithDialer(&net.Dialer{Timeout: certExpiryDialTimeout}, "tcp", endpoint, &tls.Config{
+		InsecureSkipVerify: true,
+	})
+	if err != nil {
+		cd.Error(err, "failed to connect to endpoint", "endpoint", endpoint)
+		summary.Error = err.Error()
+		return summary
+	}
+	defer conn.Close()
+
+	certificates := conn.ConnectionState().PeerCertificates
+	if len(certificates) == 0 {
+		summary.Error = "no certificate presented by endpoint"
+		return summary
+	}
+
+	certificate := certificates[0]
+	summary.Issuer = certificate.Issuer.String()
+	summary.NotAfter = metav1.NewTime(certificate.NotAfter)
+	summary.DaysUntilExpiry = int(time.Until(certificate.NotAfter).Hours() / 24)
+	summary.Expiring = summary.DaysUntilExpiry < thresholdDays
+
+	return summary
+}
@@ -0,0 +1,154 @@
+/*
+Copyright 2022 The KubeDiag Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package diagnoser
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"math/big"
+	"net"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+// newTLSServer starts a tls server on a local port with a self signed certificate valid for the
+// provided duration and returns its endpoint.
+func newTLSServer(t *testing.T, validFor time.Duration) string {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+	template := x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject: pkix.Name{
+			CommonName: "kubediag-test",
+		},
+		Issuer: pkix.Name{
+			CommonName: "kubediag-test",
+		},
+		NotBefore:   time.Now().Add(-time.Hour),
+		NotAfter:    time.Now().Add(validFor),
+		IPAddresses: []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	certificate, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	assert.NoError(t, err)
+
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		Certificates: []tls.Certificate{
+			{
+				Certificate: [][]byte{certificate},
+				PrivateKey:  key,
+			},
+		},
+	})
+	assert.NoError(t, err)
+	t.Cleanup(func() {
+		listener.Close()
+	})
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			// Complete the handshake so the client observes the certificate.
+			_ = conn.(*tls.Conn).Handshake()
+			conn.Close()
+		}
+	}()
+
+	return listener.Addr().String()
+}
+
+func TestCertExpiryDiagnoserHandler(t *testing.T) {
+	// One certificate expires within the threshold and one does not.
+	expiringEndpoint := newTLSServer(t, 10*24*time.Hour)
+	healthyEndpoint := newTLSServer(t, 365*24*time.Hour)
+
+	cd := &certExpiryDiagnoser{
+		Context:                    context.Background(),
+		Logger:                     ctrl.Log.WithName("diagnoser/certExpiryDiagnoser"),
+		certExpiryDiagnoserEnabled: true,
+	}
+
+	body, err := json.Marshal(map[string]string{
+		ParameterKeyCertExpiryEndpoints:     expiringEndpoint + "," + healthyEndpoint,
+		ParameterKeyCertExpiryThresholdDays: "30",
+	})
+	assert.NoError(t, err)
+	req := httptest.NewRequest("POST", "/processor/certExpiryDiagnoser", bytes.NewBuffer(body))
+	recorder := httptest.NewRecorder()
+	cd.Handler(recorder, req)
+	assert.Equal(t, 200, recorder.Code)
+
+	result := make(map[string]string)
+	err = json.Unmarshal(recorder.Body.Bytes(), &result)
+	assert.NoError(t, err)
+	assert.Equal(t, expiringEndpoint, result[ContextKeyCertExpiryExpiring])
+
+	var summaries []certExpirySummary
+	err = json.Unmarshal([]byte(result[ContextKeyCertExpirySummary]), &summaries)
+	assert.NoError(t, err)
+	if assert.Equal(t, 2, len(summaries)) {
+		assert.Equal(t, expiringEndpoint, summaries[0].Endpoint)
+		assert.True(t, summaries[0].Expiring)
+		assert.Equal(t, 9, summaries[0].DaysUntilExpiry)
+		assert.Contains(t, summaries[0].Issuer, "kubediag-test")
+		assert.False(t, summaries[0].NotAfter.IsZero())
+		assert.Equal(t, healthyEndpoint, summaries[1].Endpoint)
+		assert.False(t, summaries[1].Expiring)
+	}
+
+	// An unreachable endpoint is reported with an error instead of failing the whole request.
+	body, err = json.Marshal(map[string]string{
+		ParameterKeyCertExpiryEndpoints: "127.0.0.1:1",
+	})
+	assert.NoError(t, err)
+	req = httptest.NewRequest("POST", "/processor/certExpiryDiagnoser", bytes.NewBuffer(body))
+	recorder = httptest.NewRecorder()
+	cd.Handler(recorder, req)
+	assert.Equal(t, 200, recorder.Code)
+	result = make(map[string]string)
+	err = json.Unmarshal(recorder.Body.Bytes(), &result)
+	assert.NoError(t, err)
+	err = json.Unmarshal([]byte(result[ContextKeyCertExpirySummary]), &summaries)
+	assert.NoError(t, err)
+	if assert.Equal(t, 1, len(summaries)) {
+		assert.NotEmpty(t, summaries[0].Error)
+		assert.False(t, summaries[0].Expiring)
+	}
+
+	// The endpoints must be specified.
+	req = httptest.NewRequest("POST", "/processor/certExpiryDiagnoser", bytes.NewBufferString("{}"))
+	recorder = httptest.NewRecorder()
+	cd.Handler(recorder, req)
+	assert.Equal(t, 400, recorder.Code)
+
+	// Only POST requests are supported.
+	req = httptest.NewRequest("GET", "/processor/certExpiryDiagnoser", nil)
+	recorder = httptest.NewRecorder()
+	cd.Handler(recorder, req)
+	assert.Equal(t, 405, recorder.Code)
+}
@@ -25,7 +25,10 @@ import (
 	"net/http"
 	"os"
 	"path"
+	"path/filepath"
+	"sort"
 	"strconv"
+	"strings"
 	"time"
 
 	dockertypes "github.com/docker/docker/api/types"
@@ -48,7 +51,8 @@ const (
 	ParameterKeyCoreFileProfilerType              = "param.diagnoser.runtime.core_file_profiler.type"
 	ParameterKeyCoreFileProfilerFilepath          = "param.diagnoser.runtime.core_file_profiler.filepath"
 
-	ContextKeyCoreFileProfilerResultEndpoint = "diagnoser.runtime.core_file_profiler.result.endpoint"
+	ContextKeyCoreFileProfilerResultEndpoint  = "diagnoser.runtime.core_file_profiler.result.endpoint"
+	ContextKeyCoreFileProfilerResultCoreFiles = "diagnoser.runtime.core_file_profiler.result.core_files"
 
 	l1CoreFileSubPath      = "corefile/"
 	l2CoreFileSubPathOfPod = "kubernetes/"
@@ -124,6 +128,17 @@ type CoreFileConfig struct {
 	Processes container.ContainerTopOKBody `json:"processes,omitempty"`
 }
 
+// coreFileInfo describes a discovered core file.
+type coreFileInfo struct {
+	// Path is the absolute path of the core file on the node.
+	Path string `json:"path"`
+	// SizeBytes is the size of the core file in bytes.
+	SizeBytes int64 `json:"sizeBytes"`
+	// Container is the name of the container the core file belongs to. It is empty if the
+	// core file is not stored under the pod core file directory.
+	Container string `json:"container,omitempty"`
+}
+
 // coreFileProfiler will manage pods' core dump file and provide a websocket to do gdb online
 type coreFileProfiler struct {
 	// Context carries values across API boundaries.
@@ -136,10 +151,14 @@ type coreFileProfiler struct {
 	corefilePorfilerEnabled bool
 	// podCoreFilePath is root path of corefiles
 	podCoreFilePath string
+	// minExpirationSeconds is the minimum expiration of profiler endpoints.
+	minExpirationSeconds int
+	// maxExpirationSeconds is the maximum expiration of profiler endpoints.
+	maxExpirationSeconds int
 }
 
 // NewCoreFileProfiler creates a new coreFileProfiler.
-func NewCoreFileProfiler(ctx context.Context, log logr.Logger, dockerEndpoint string, corefileProfilerEnabled bool, dataRoot string) (processors.Processor, error) {
+func NewCoreFileProfiler(ctx context.Context, log logr.Logger, dockerEndpoint string, corefileProfilerEnabled bool, dataRoot string, minExpirationSeconds int, maxExpirationSeconds int) (processors.Processor, error) {
 	coreFilePath := path.Join(dataRoot, l1CoreFileSubPath)
 	podCoreFilePath := path.Join(coreFilePath, l2CoreFileSubPathOfPod)
 	if corefileProfilerEnabled {
@@ -158,6 +177,8 @@ func NewCoreFileProfiler(ctx context.Context, log logr.Logger, dockerEndpoint st
 		client:                  cli,
 		corefilePorfilerEnabled: corefileProfilerEnabled,
 		podCoreFilePath:         podCoreFilePath,
+		minExpirationSeconds:    minExpirationSeconds,
+		maxExpirationSeconds:    maxExpirationSeconds,
 	}, nil
 }
 
@@ -184,6 +205,7 @@ func (c *coreFileProfiler) Handler(w http.ResponseWriter, r *http.Request) {
 			http.Error(w, err.Error(), http.StatusNotAcceptable)
 			return
 		}
+		expirationSeconds = processors.ClampExpirationSeconds(expirationSeconds, c.minExpirationSeconds, c.maxExpirationSeconds)
 
 		pid := 0
 		if pidParam, exist := contexts[ParameterKeyCoreFileProfilerPid]; exist {
@@ -222,11 +244,12 @@ func (c *coreFileProfiler) Handler(w http.ResponseWriter, r *http.Request) {
 
 		mux := http.NewServeMux()
 		var server *http.Server
+		var coreFiles []coreFileInfo
 		switch config.Type {
 		case typeGCore:
 			server, err = c.buildGCoreHTTPServer(config, port, mux)
 		case typeCoreDump:
-			server, err = c.buildCoreDumpHTTPServer(config, port, mux)
+			server, coreFiles, err = c.buildCoreDumpHTTPServer(config, port, mux)
 		default:
 			err = fmt.Errorf("unknown core type: %s", config.Type)
 		}
@@ -265,6 +288,17 @@ func (c *coreFileProfiler) Handler(w http.ResponseWriter, r *http.Request) {
 
 		result := make(map[string]string)
 		result[ContextKeyCoreFileProfilerResultEndpoint] = fmt.Sprintf("http://%s:%d", contexts[executor.NodeTelemetryKey], port)
+		// Record the core file inventory in results so that the diagnosis keeps it after
+		// the endpoint expired.
+		if config.Type == typeCoreDump {
+			raw, err := json.Marshal(coreFiles)
+			if err != nil {
+				c.Error(err, "failed to marshal core files")
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			result[ContextKeyCoreFileProfilerResultCoreFiles] = string(raw)
+		}
 		data, err := json.Marshal(result)
 		if err != nil {
 			c.Error(err, "failed to marshal response body")
@@ -324,7 +358,8 @@ func (c *coreFileProfiler) completeConfig(config *CoreFileConfig, podInfo v1.Pod
 			return fmt.Errorf("list container failed, %s", err.Error())
 		}
 
-		var cid string
+		// All running containers of the pod are matched if the container of pod info is empty.
+		cids := make(map[string]string)
 		for _, cont := range containers {
 			c.V(5).Info("get list container",
 				"ns", cont.Labels["io.kubernetes.pod.namespace"],
@@ -333,14 +368,22 @@ func (c *coreFileProfiler) completeConfig(config *CoreFileConfig, podInfo v1.Pod
 				"state", cont.State)
 			if cont.Labels["io.kubernetes.pod.namespace"] == podInfo.Namespace &&
 				cont.Labels["io.kubernetes.pod.name"] == podInfo.Name &&
-				cont.Labels["io.kubernetes.container.name"] == podInfo.Container &&
 				cont.State == "running" {
+				if podInfo.Container != "" && cont.Labels["io.kubernetes.container.name"] != podInfo.Container {
+					continue
+				}
 				c.V(5).Info("get container", "container", cont)
-				cid = cont.ID
-				break
+				cids[cont.Labels["io.kubernetes.container.name"]] = cont.ID
 			}
 		}
-		if cid != "" {
+		if len(cids) == 0 {
+			err = fmt.Errorf("failed to get a matched running container, pod info: %+v", podInfo)
+			c.Error(err, "")
+			return err
+		}
+
+		topResults := make(map[string]container.ContainerTopOKBody)
+		for name, cid := range cids {
 			topResult, err := c.client.ContainerTop(c, cid, nil)
 			if err != nil {
 				c.Error(err, "top container failed")
@@ -348,13 +391,11 @@ func (c *coreFileProfiler) completeConfig(config *CoreFileConfig, podInfo v1.Pod
 			}
 			c.V(4).Info("top result", "titles", topResult.Titles)
 			c.V(4).Info("top result", "processes", topResult.Processes)
-			if len(topResult.Processes) == 0 {
-				c.Error(err, "top container empty")
-				return fmt.Errorf("top container emtpy, %s", err.Error())
-			}
-			config.Processes = topResult
-		} else {
-			err = fmt.Errorf("failed to get a matched running container, pod info: %+v", podInfo)
+			topResults[name] = topResult
+		}
+		config.Processes = mergeContainerTopResults(topResults)
+		if len(config.Processes.Processes) == 0 {
+			err = fmt.Errorf("top container empty, pod info: %+v", podInfo)
 			c.Error(err, "")
 			return err
 		}
@@ -362,6 +403,36 @@ func (c *coreFileProfiler) completeConfig(config *CoreFileConfig, podInfo v1.Pod
 	return nil
 }
 
+// mergeContainerTopResults aggregates top results of containers keyed by container name. The
+// result of a single container is returned as is, while results of multiple containers are merged
+// with a CONTAINER column indicating which container each process belongs to.
+func mergeContainerTopResults(topResults map[string]container.ContainerTopOKBody) container.ContainerTopOKBody {
+	if len(topResults) == 1 {
+		for _, topResult := range topResults {
+			return topResult
+		}
+	}
+
+	names := make([]string, 0, len(topResults))
+	for name := range topResults {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var merged container.ContainerTopOKBody
+	for _, name := range names {
+		topResult := topResults[name]
+		if len(merged.Titles) == 0 {
+			merged.Titles = append([]string{"CONTAINER"}, topResult.Titles...)
+		}
+		for _, process := range topResult.Processes {
+			merged.Processes = append(merged.Processes, append([]string{name}, process...))
+		}
+	}
+
+	return merged
+}
+
 // buildGCoreHTTPServer will start a web server to list processes and support gdb online
 func (c *coreFileProfiler) buildGCoreHTTPServer(config *CoreFileConfig, port int, serveMux *http.ServeMux) (*http.Server, error) {
 	if config.Pid > 0 {
@@ -385,19 +456,15 @@ func (c *coreFileProfiler) buildGCoreHTTPServer(config *CoreFileConfig, port int
 // buildCoreDumpHTTPServer will build a HTTP server to provide:
 // 1. download coredump file
 // 2. gdb debug coredump file online
-func (c *coreFileProfiler) buildCoreDumpHTTPServer(config *CoreFileConfig, port int, serveMux *http.ServeMux) (*http.Server, error) {
-	var files []string
-	rootFileInfo, err := os.Stat(config.FilePath)
+// It also returns the discovered core files for recording in results.
+func (c *coreFileProfiler) buildCoreDumpHTTPServer(config *CoreFileConfig, port int, serveMux *http.ServeMux) (*http.Server, []coreFileInfo, error) {
+	coreFiles, err := c.enumerateCoreFiles(config.FilePath)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
-	if rootFileInfo.IsDir() {
-		files, err = getAllFile(config.FilePath)
-		if err != nil {
-			return nil, err
-		}
-	} else {
-		files = []string{config.FilePath}
+	files := make([]string, 0, len(coreFiles))
+	for _, coreFile := range coreFiles {
+		files = append(files, coreFile.Path)
 	}
 	c.V(5).Info("get all corefiles of pod", "files", files)
 
@@ -414,7 +481,56 @@ func (c *coreFileProfiler) buildCoreDumpHTTPServer(config *CoreFileConfig, port
 	return &http.Server{
 		Addr:    fmt.Sprintf(":%d", port),
 		Handler: serveMux,
-	}, nil
+	}, coreFiles, nil
+}
+
+// enumerateCoreFiles returns details of core files under the path, or of the single core file
+// if the path is a regular file.
+func (c *coreFileProfiler) enumerateCoreFiles(filePath string) ([]coreFileInfo, error) {
+	var files []string
+	rootFileInfo, err := os.Stat(filePath)
+	if err != nil {
+		return nil, err
+	}
+	if rootFileInfo.IsDir() {
+		files, err = getAllFile(filePath)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		files = []string{filePath}
+	}
+
+	coreFiles := make([]coreFileInfo, 0, len(files))
+	for _, file := range files {
+		fileInfo, err := os.Stat(file)
+		if err != nil {
+			c.Error(err, "unable to stat core file", "path", file)
+			continue
+		}
+		coreFiles = append(coreFiles, coreFileInfo{
+			Path:      file,
+			SizeBytes: fileInfo.Size(),
+			Container: coreFileContainer(c.podCoreFilePath, file),
+		})
+	}
+
+	return coreFiles, nil
+}
+
+// coreFileContainer derives the container a core file belongs to from the
+// <namespace>/<name>/<container> directory layout under the pod core file directory.
+func coreFileContainer(podCoreFilePath string, file string) string {
+	rel, err := filepath.Rel(podCoreFilePath, file)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return ""
+	}
+	segments := strings.Split(rel, string(filepath.Separator))
+	if len(segments) < 4 {
+		return ""
+	}
+
+	return segments[2]
 }
 
 // downloadHandler is a handler to download core dump files
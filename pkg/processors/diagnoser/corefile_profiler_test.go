@@ -0,0 +1,156 @@
+/*
+Copyright 2022 The KubeDiag Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package diagnoser
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/stretchr/testify/assert"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	"github.com/kubediag/kubediag/pkg/executor"
+)
+
+func TestMergeContainerTopResults(t *testing.T) {
+	topResults := map[string]container.ContainerTopOKBody{
+		"container1": {
+			Titles: []string{"PID", "CMD"},
+			Processes: [][]string{
+				{"1", "sleep"},
+			},
+		},
+		"container2": {
+			Titles: []string{"PID", "CMD"},
+			Processes: [][]string{
+				{"1", "nginx"},
+				{"7", "nginx worker"},
+			},
+		},
+	}
+
+	merged := mergeContainerTopResults(topResults)
+	assert.Equal(t, []string{"CONTAINER", "PID", "CMD"}, merged.Titles)
+	assert.Equal(t, [][]string{
+		{"container1", "1", "sleep"},
+		{"container2", "1", "nginx"},
+		{"container2", "7", "nginx worker"},
+	}, merged.Processes)
+}
+
+func TestMergeContainerTopResultsSingleContainer(t *testing.T) {
+	topResult := container.ContainerTopOKBody{
+		Titles: []string{"PID", "CMD"},
+		Processes: [][]string{
+			{"1", "sleep"},
+		},
+	}
+
+	// The top result of a single container is returned without a CONTAINER column.
+	merged := mergeContainerTopResults(map[string]container.ContainerTopOKBody{"container1": topResult})
+	assert.Equal(t, topResult, merged)
+}
+
+func TestEnumerateCoreFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "corefile-profiler")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	podCoreFilePath := filepath.Join(dir, l1CoreFileSubPath, l2CoreFileSubPathOfPod)
+	containerDirectory := filepath.Join(podCoreFilePath, "default", "pod1", "app")
+	assert.NoError(t, os.MkdirAll(containerDirectory, 0755))
+	corePath := filepath.Join(containerDirectory, "1234_20220101-000000")
+	assert.NoError(t, ioutil.WriteFile(corePath, []byte("core data"), 0644))
+
+	c := &coreFileProfiler{
+		Context:         context.Background(),
+		Logger:          ctrl.Log.WithName("diagnoser/coreFileProfiler"),
+		podCoreFilePath: podCoreFilePath,
+	}
+
+	coreFiles, err := c.enumerateCoreFiles(containerDirectory)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(coreFiles))
+	assert.Equal(t, corePath, coreFiles[0].Path)
+	assert.Equal(t, int64(len("core data")), coreFiles[0].SizeBytes)
+	assert.Equal(t, "app", coreFiles[0].Container)
+
+	// A core file outside the pod core file directory is not matched to a container.
+	orphanPath := filepath.Join(dir, "5678_20220101-000000")
+	assert.NoError(t, ioutil.WriteFile(orphanPath, []byte("core data"), 0644))
+	coreFiles, err = c.enumerateCoreFiles(orphanPath)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(coreFiles))
+	assert.Equal(t, "", coreFiles[0].Container)
+
+	_, err = c.enumerateCoreFiles(filepath.Join(dir, "nonexistent"))
+	assert.Error(t, err)
+}
+
+func TestCoreFileProfilerHandlerCoreDumpResult(t *testing.T) {
+	dir, err := ioutil.TempDir("", "corefile-profiler-handler")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	podCoreFilePath := filepath.Join(dir, l1CoreFileSubPath, l2CoreFileSubPathOfPod)
+	containerDirectory := filepath.Join(podCoreFilePath, "default", "pod1", "app")
+	assert.NoError(t, os.MkdirAll(containerDirectory, 0755))
+	corePath := filepath.Join(containerDirectory, "1234_20220101-000000")
+	assert.NoError(t, ioutil.WriteFile(corePath, []byte("core data"), 0644))
+
+	c := &coreFileProfiler{
+		Context:                 context.Background(),
+		Logger:                  ctrl.Log.WithName("diagnoser/coreFileProfiler"),
+		corefilePorfilerEnabled: true,
+		podCoreFilePath:         podCoreFilePath,
+		minExpirationSeconds:    1,
+		maxExpirationSeconds:    1,
+	}
+
+	body, err := json.Marshal(map[string]string{
+		ParameterKeyCoreFileProfilerExpirationSeconds: "1",
+		ParameterKeyCoreFileProfilerType:              string(typeCoreDump),
+		executor.PodNamespaceTelemetryKey:             "default",
+		executor.PodNameTelemetryKey:                  "pod1",
+		executor.ContainerTelemetryKey:                "app",
+		executor.NodeTelemetryKey:                     "node1",
+	})
+	assert.NoError(t, err)
+	req := httptest.NewRequest("POST", "/processor/coreFileProfiler", bytes.NewBuffer(body))
+	recorder := httptest.NewRecorder()
+	c.Handler(recorder, req)
+	assert.Equal(t, 200, recorder.Code)
+
+	result := make(map[string]string)
+	err = json.Unmarshal(recorder.Body.Bytes(), &result)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, result[ContextKeyCoreFileProfilerResultEndpoint])
+	var coreFiles []coreFileInfo
+	err = json.Unmarshal([]byte(result[ContextKeyCoreFileProfilerResultCoreFiles]), &coreFiles)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(coreFiles))
+	assert.Equal(t, corePath, coreFiles[0].Path)
+	assert.Equal(t, int64(len("core data")), coreFiles[0].SizeBytes)
+	assert.Equal(t, "app", coreFiles[0].Container)
+}
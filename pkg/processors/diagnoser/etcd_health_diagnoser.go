@@ -0,0 +1,255 @@
+/*
+Copyright 2022 The KubeDiag Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package diagnoser
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/kubediag/kubediag/pkg/processors"
+	"github.com/kubediag/kubediag/pkg/processors/utils"
+)
+
+const (
+	EtcdHealthEndpoints        = "param.diagnoser.kubernetes.etcd_health.endpoints"
+	EtcdHealthClientCertSecret = "param.diagnoser.kubernetes.etcd_health.clientCertSecret"
+
+	ContextKeyEtcdHealthMembers        = "diagnoser.kubernetes.etcd_health.members"
+	ContextKeyEtcdHealthHealthyCount   = "diagnoser.kubernetes.etcd_health.healthyCount"
+	ContextKeyEtcdHealthUnhealthyCount = "diagnoser.kubernetes.etcd_health.unhealthyCount"
+
+	// etcdDBSizeMetric is the etcd metric reporting the total size of the underlying database.
+	etcdDBSizeMetric = "etcd_mvcc_db_total_size_in_bytes"
+	// etcdIsLeaderMetric is the etcd metric reporting whether the member is the leader.
+	etcdIsLeaderMetric = "etcd_server_is_leader"
+)
+
+// etcdMemberStatus describes the health of one etcd member.
+type etcdMemberStatus struct {
+	// Endpoint is the client endpoint of the member.
+	Endpoint string `json:"endpoint"`
+	// Healthy indicates whether the member reported itself as healthy.
+	Healthy bool `json:"healthy"`
+	// DBSizeBytes is the total size of the underlying database of the member.
+	// +optional
+	DBSizeBytes int64 `json:"dbSizeBytes,omitempty"`
+	// Leader indicates whether the member is the leader.
+	Leader bool `json:"leader"`
+	// Error is the message of the error encountered while querying the member.
+	// +optional
+	Error string `json:"error,omitempty"`
+}
+
+// etcdHealthDiagnoser snapshots the health of etcd members of a self managed control plane.
+type etcdHealthDiagnoser struct {
+	// Context carries values across API boundaries.
+	context.Context
+	// Logger represents the ability to log messages.
+	logr.Logger
+
+	// cache knows how to load Kubernetes objects.
+	cache cache.Cache
+	// etcdHealthDiagnoserEnabled indicates whether etcdHealthDiagnoser is enabled.
+	etcdHealthDiagnoserEnabled bool
+}
+
+// NewEtcdHealthDiagnoser creates a new etcdHealthDiagnoser.
+func NewEtcdHealthDiagnoser(
+	ctx context.Context,
+	logger logr.Logger,
+	cache cache.Cache,
+	etcdHealthDiagnoserEnabled bool,
+) processors.Processor {
+	return &etcdHealthDiagnoser{
+		Context:                    ctx,
+		Logger:                     logger,
+		cache:                      cache,
+		etcdHealthDiagnoserEnabled: etcdHealthDiagnoserEnabled,
+	}
+}
+
+// Handler handles http requests for snapshotting etcd member health.
+func (ed *etcdHealthDiagnoser) Handler(w http.ResponseWriter, r *http.Request) {
+	if !ed.etcdHealthDiagnoserEnabled {
+		http.Error(w, fmt.Sprintf("etcd health diagnoser is not enabled"), http.StatusUnprocessableEntity)
+		return
+	}
+
+	switch r.Method {
+	case "POST":
+		contexts, err := utils.ExtractParametersFromHTTPContext(r)
+		if err != nil {
+			ed.Error(err, "extract contexts failed")
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		endpoints := strings.Split(contexts[EtcdHealthEndpoints], ",")
+		if contexts[EtcdHealthEndpoints] == "" {
+			http.Error(w, fmt.Sprintf("etcd endpoints must be specified via %s", EtcdHealthEndpoints), http.StatusBadRequest)
+			return
+		}
+
+		httpClient, err := ed.newEtcdClient(contexts[EtcdHealthClientCertSecret])
+		if err != nil {
+			ed.Error(err, "failed to build etcd client")
+			http.Error(w, fmt.Sprintf("failed to build etcd client: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		members := make([]etcdMemberStatus, 0, len(endpoints))
+		healthy := 0
+		for _, endpoint := range endpoints {
+			member := queryEtcdMember(httpClient, strings.TrimSpace(endpoint))
+			if member.Healthy {
+				healthy++
+			}
+			members = append(members, member)
+		}
+
+		raw, err := json.Marshal(members)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("failed to marshal etcd member statuses: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		result := make(map[string]string)
+		result[ContextKeyEtcdHealthMembers] = string(raw)
+		result[ContextKeyEtcdHealthHealthyCount] = strconv.Itoa(healthy)
+		result[ContextKeyEtcdHealthUnhealthyCount] = strconv.Itoa(len(members) - healthy)
+		data, err := json.Marshal(result)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("failed to marshal result: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(data)
+	default:
+		http.Error(w, fmt.Sprintf("method %s is not supported", r.Method), http.StatusMethodNotAllowed)
+	}
+}
+
+// newEtcdClient builds an http client for querying etcd endpoints. The client certificate and
+// key are loaded from the referenced secret in namespace/name form, e.g. a kubeadm etcd client
+// certificate secret containing ca.crt, tls.crt and tls.key. A plain client is returned if no
+// secret is referenced.
+func (ed *etcdHealthDiagnoser) newEtcdClient(secretRef string) (*http.Client, error) {
+	httpClient := &http.Client{
+		Timeout: 10 * time.Second,
+	}
+	if secretRef == "" {
+		return httpClient, nil
+	}
+
+	parts := strings.Split(secretRef, "/")
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("invalid client cert secret reference %s, expected namespace/name", secretRef)
+	}
+	var secret corev1.Secret
+	if err := ed.cache.Get(ed, client.ObjectKey{Namespace: parts[0], Name: parts[1]}, &secret); err != nil {
+		return nil, fmt.Errorf("unable to fetch secret %s: %v", secretRef, err)
+	}
+
+	certificate, err := tls.X509KeyPair(secret.Data[corev1.TLSCertKey], secret.Data[corev1.TLSPrivateKeyKey])
+	if err != nil {
+		return nil, fmt.Errorf("invalid client certificate in secret %s: %v", secretRef, err)
+	}
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{certificate},
+	}
+	if caData, ok := secret.Data["ca.crt"]; ok {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caData) {
+			return nil, fmt.Errorf("invalid ca certificate in secret %s", secretRef)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	httpClient.Transport = &http.Transport{
+		TLSClientConfig: tlsConfig,
+	}
+
+	return httpClient, nil
+}
+
+// queryEtcdMember queries the health and metrics endpoints of an etcd member.
+func queryEtcdMember(httpClient *http.Client, endpoint string) etcdMemberStatus {
+	member := etcdMemberStatus{
+		Endpoint: endpoint,
+	}
+
+	res, err := httpClient.Get(endpoint + "/health")
+	if err != nil {
+		member.Error = err.Error()
+		return member
+	}
+	body, err := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		member.Error = err.Error()
+		return member
+	}
+	health := struct {
+		Health string `json:"health"`
+	}{}
+	if err := json.Unmarshal(body, &health); err != nil {
+		member.Error = fmt.Sprintf("invalid health response: %v", err)
+		return member
+	}
+	member.Healthy = health.Health == "true"
+
+	res, err = httpClient.Get(endpoint + "/metrics")
+	if err != nil {
+		member.Error = err.Error()
+		return member
+	}
+	metrics, err := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		member.Error = err.Error()
+		return member
+	}
+	for _, line := range strings.Split(string(metrics), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		switch fields[0] {
+		case etcdDBSizeMetric:
+			if size, err := strconv.ParseFloat(fields[1], 64); err == nil {
+				member.DBSizeBytes = int64(size)
+			}
+		case etcdIsLeaderMetric:
+			member.Leader = fields[1] == "1"
+		}
+	}
+
+	return member
+}
@@ -0,0 +1,111 @@
+/*
+Copyright 2022 The KubeDiag Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package diagnoser
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+// newStubEtcdMember starts a stub etcd member serving health and metrics endpoints.
+func newStubEtcdMember(healthy bool, dbSize int64, leader bool) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/health":
+			fmt.Fprintf(w, `{"health":"%t"}`, healthy)
+		case "/metrics":
+			leaderValue := 0
+			if leader {
+				leaderValue = 1
+			}
+			fmt.Fprintf(w, "%s %d\n%s %d\n", etcdDBSizeMetric, dbSize, etcdIsLeaderMetric, leaderValue)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+}
+
+func TestEtcdHealthDiagnoserHandler(t *testing.T) {
+	leaderMember := newStubEtcdMember(true, 4096, true)
+	defer leaderMember.Close()
+	followerMember := newStubEtcdMember(true, 4000, false)
+	defer followerMember.Close()
+	downMember := newStubEtcdMember(false, 0, false)
+	downMember.Close()
+
+	ed := &etcdHealthDiagnoser{
+		Context:                    context.Background(),
+		Logger:                     ctrl.Log.WithName("diagnoser/etcdHealthDiagnoser"),
+		etcdHealthDiagnoserEnabled: true,
+	}
+
+	parameters := map[string]string{
+		EtcdHealthEndpoints: leaderMember.URL + "," + followerMember.URL + "," + downMember.URL,
+	}
+	body, err := json.Marshal(parameters)
+	assert.NoError(t, err)
+	recorder := httptest.NewRecorder()
+	ed.Handler(recorder, httptest.NewRequest("POST", "/processor/etcdHealthDiagnoser", bytes.NewReader(body)))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+
+	result := make(map[string]string)
+	err = json.Unmarshal(recorder.Body.Bytes(), &result)
+	assert.NoError(t, err)
+	assert.Equal(t, "2", result[ContextKeyEtcdHealthHealthyCount])
+	assert.Equal(t, "1", result[ContextKeyEtcdHealthUnhealthyCount])
+
+	var members []etcdMemberStatus
+	err = json.Unmarshal([]byte(result[ContextKeyEtcdHealthMembers]), &members)
+	assert.NoError(t, err)
+	if assert.Len(t, members, 3) {
+		assert.Equal(t, leaderMember.URL, members[0].Endpoint)
+		assert.True(t, members[0].Healthy)
+		assert.True(t, members[0].Leader)
+		assert.Equal(t, int64(4096), members[0].DBSizeBytes)
+		assert.True(t, members[1].Healthy)
+		assert.False(t, members[1].Leader)
+		assert.False(t, members[2].Healthy)
+		assert.NotEmpty(t, members[2].Error)
+	}
+
+	// The endpoints parameter is required.
+	recorder = httptest.NewRecorder()
+	ed.Handler(recorder, httptest.NewRequest("POST", "/processor/etcdHealthDiagnoser", bytes.NewReader([]byte("{}"))))
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+
+	recorder = httptest.NewRecorder()
+	ed.Handler(recorder, httptest.NewRequest("GET", "/processor/etcdHealthDiagnoser", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, recorder.Code)
+}
+
+func TestQueryEtcdMemberUnhealthy(t *testing.T) {
+	member := newStubEtcdMember(false, 2048, false)
+	defer member.Close()
+
+	status := queryEtcdMember(http.DefaultClient, member.URL)
+	assert.False(t, status.Healthy)
+	assert.Empty(t, status.Error)
+	assert.Equal(t, int64(2048), status.DBSizeBytes)
+}
@@ -39,6 +39,7 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	diagnosisv1 "github.com/kubediag/kubediag/api/v1"
+	"github.com/kubediag/kubediag/pkg/artifactserver"
 	"github.com/kubediag/kubediag/pkg/executor"
 	"github.com/kubediag/kubediag/pkg/processors"
 	"github.com/kubediag/kubediag/pkg/processors/utils"
@@ -51,8 +52,10 @@ const (
 	ParameterKeyGoProfilerSource             = "param.diagnoser.runtime.go_profiler.source"
 	ParameterKeyGoProfilerTLSSecretNamespace = "param.diagnoser.runtime.go_profiler.tls.secret_reference.namespace"
 	ParameterKeyGoProfilerTLSSecretName      = "param.diagnoser.runtime.go_profiler.tls.secret_reference.name"
+	ParameterKeyGoProfilerUploadURLPrefix    = "param.diagnoser.runtime.go_profiler.object_storage.upload_url_prefix"
 
-	ContextKeyGoProfilerResultEndpoint = "diagnoser.runtime.go_profiler.result.endpoint"
+	ContextKeyGoProfilerResultEndpoint    = "diagnoser.runtime.go_profiler.result.endpoint"
+	ContextKeyGoProfilerResultDownloadURL = "diagnoser.runtime.go_profiler.result.download_url"
 )
 
 // goProfiler manages information of all pods on the node.
@@ -67,8 +70,17 @@ type goProfiler struct {
 	dataRoot string
 	// BindAddress is the address on which to advertise.
 	BindAddress string
+	// port is the port for the kubediag to serve on.
+	port int
+	// artifactServer serves profiler results over the agent port. The profiler starts an
+	// ephemeral pprof server on a random port instead if it is nil.
+	artifactServer artifactserver.ArtifactServer
 	// GoProfilerEnabled indicates whether goProfiler is enabled.
 	goProfilerEnabled bool
+	// minExpirationSeconds is the minimum expiration of profiler endpoints.
+	minExpirationSeconds int
+	// maxExpirationSeconds is the maximum expiration of profiler endpoints.
+	maxExpirationSeconds int
 }
 
 // goProfilerRequestParameter specifies the action to perform for profiling a go program.
@@ -90,6 +102,12 @@ type goProfilerRequestParameter struct {
 	// TLS specifies the secret reference for source
 	// +optional
 	TLS goProfilerTLS `json:"tls,omitempty"`
+
+	// UploadURLPrefix is the object storage url prefix to upload the captured profile file to
+	// via an http put request, e.g. a presigned bucket url. A durable download url is returned
+	// alongside the ephemeral pprof endpoint if specified.
+	// +optional
+	UploadURLPrefix string `json:"uploadURLPrefix,omitempty"`
 }
 
 // GoProfilerType is a valid value for GoProfiler.Type.
@@ -119,15 +137,23 @@ func NewGoProfiler(
 	cache cache.Cache,
 	dataRoot string,
 	bindAddress string,
+	port int,
+	artifactServer artifactserver.ArtifactServer,
 	goProfilerEnabled bool,
+	minExpirationSeconds int,
+	maxExpirationSeconds int,
 ) processors.Processor {
 	return &goProfiler{
-		Context:           ctx,
-		Logger:            logger,
-		cache:             cache,
-		dataRoot:          dataRoot,
-		BindAddress:       bindAddress,
-		goProfilerEnabled: goProfilerEnabled,
+		Context:              ctx,
+		Logger:               logger,
+		cache:                cache,
+		dataRoot:             dataRoot,
+		BindAddress:          bindAddress,
+		port:                 port,
+		artifactServer:       artifactServer,
+		goProfilerEnabled:    goProfilerEnabled,
+		minExpirationSeconds: minExpirationSeconds,
+		maxExpirationSeconds: maxExpirationSeconds,
 	}
 }
 
@@ -162,6 +188,7 @@ func (gp *goProfiler) Handler(w http.ResponseWriter, r *http.Request) {
 				expirationSeconds = processors.DefaultExpirationSeconds
 			}
 		}
+		expirationSeconds = processors.ClampExpirationSeconds(expirationSeconds, gp.minExpirationSeconds, gp.maxExpirationSeconds)
 
 		parameter := goProfilerRequestParameter{
 			GoProfilerType:    goProfilerType(contexts[ParameterKeyGoProfilerType]),
@@ -173,6 +200,7 @@ func (gp *goProfiler) Handler(w http.ResponseWriter, r *http.Request) {
 					Name:      contexts[ParameterKeyGoProfilerTLSSecretName],
 				},
 			},
+			UploadURLPrefix: contexts[ParameterKeyGoProfilerUploadURLPrefix],
 		}
 
 		if parameter.GoProfilerType != cpuGoProfilerType && parameter.GoProfilerType != goroutineGoProfilerType &&
@@ -196,7 +224,7 @@ func (gp *goProfiler) Handler(w http.ResponseWriter, r *http.Request) {
 		name := contexts[executor.DiagnosisNameTelemetryKey]
 		podInfo := utils.GetPodInfoFromContext(contexts)
 
-		endpoint, err := gp.runGoProfiler(name, namespace, gp.BindAddress, parameter, &podInfo, gp.dataRoot)
+		endpoint, downloadURL, err := gp.runGoProfiler(name, namespace, gp.BindAddress, parameter, &podInfo, gp.dataRoot)
 		if err != nil {
 			http.Error(w, fmt.Sprintf("failed to run go profiler: %v", err), http.StatusInternalServerError)
 			return
@@ -204,6 +232,9 @@ func (gp *goProfiler) Handler(w http.ResponseWriter, r *http.Request) {
 
 		result := make(map[string]string)
 		result[ContextKeyGoProfilerResultEndpoint] = endpoint
+		if downloadURL != "" {
+			result[ContextKeyGoProfilerResultDownloadURL] = downloadURL
+		}
 		data, err := json.Marshal(result)
 		if err != nil {
 			http.Error(w, fmt.Sprintf("failed to marshal go profiler results: %v", err), http.StatusInternalServerError)
@@ -218,19 +249,14 @@ func (gp *goProfiler) Handler(w http.ResponseWriter, r *http.Request) {
 }
 
 // RunGoProfiler runs go profiling with timeout.
-func (gp *goProfiler) runGoProfiler(name string, namespace string, bindAddress string, parameter goProfilerRequestParameter, podReference *diagnosisv1.PodReference, dataRoot string) (string, error) {
+func (gp *goProfiler) runGoProfiler(name string, namespace string, bindAddress string, parameter goProfilerRequestParameter, podReference *diagnosisv1.PodReference, dataRoot string) (string, string, error) {
 	gp.Info("Start to run go profiling", "diagnosis", client.ObjectKey{
 		Name:      name,
 		Namespace: namespace,
 	})
 
-	port, err := util.GetAvailablePort()
-	if err != nil {
-		return "", err
-	}
-	endpoint := fmt.Sprintf("%s:%d", bindAddress, port)
-
 	// Add timeout seconds for cpu profile
+	var err error
 	timeout := time.Duration(5) * time.Second
 	source := fmt.Sprintf("%s%s%s", parameter.Source, goProfilerPathPrefix, strings.ToLower(string(parameter.GoProfilerType)))
 	if parameter.GoProfilerType == cpuGoProfilerType {
@@ -250,7 +276,7 @@ func (gp *goProfiler) runGoProfiler(name string, namespace string, bindAddress s
 	if _, err := os.Stat(datadir); os.IsNotExist(err) {
 		err := os.MkdirAll(datadir, os.ModePerm)
 		if err != nil {
-			return "", err
+			return "", "", err
 		}
 		gp.Info("Make dir succeessfully.", "datadir", datadir)
 	}
@@ -268,31 +294,56 @@ func (gp *goProfiler) runGoProfiler(name string, namespace string, bindAddress s
 
 		secretData, err := GetSecretData(gp.cache, name, namespace)
 		if err != nil {
-			return "", err
+			return "", "", err
 		}
 
 		tokenByte, ok := secretData[corev1.ServiceAccountTokenKey]
 		if !ok {
-			return "", fmt.Errorf("secret token is not specified")
+			return "", "", fmt.Errorf("secret token is not specified")
 
 		}
 		caByte, ok := secretData[corev1.ServiceAccountRootCAKey]
 		if !ok {
-			return "", fmt.Errorf("secret ca.crt is not specified")
+			return "", "", fmt.Errorf("secret ca.crt is not specified")
 		}
 
 		err = DownloadProfileFile(fmt.Sprintf("%s/%s", datadir, datafile), source, tokenByte, caByte, timeout)
 		if err != nil {
-			return "", fmt.Errorf("download file failed with error: %s", err)
+			return "", "", fmt.Errorf("download file failed with error: %s", err)
 		}
 		gp.Info("Save go profiler file successfully.", "path", fmt.Sprintf("%s/%s", datadir, datafile))
 	} else {
 		err = DownloadProfileFile(fmt.Sprintf("%s/%s", datadir, datafile), source, nil, nil, timeout)
 		if err != nil {
-			return "", fmt.Errorf("download file failed with error: %s", err)
+			return "", "", fmt.Errorf("download file failed with error: %s", err)
 		}
 		gp.Info("Save go profiler file successfully.", "path", fmt.Sprintf("%s/%s", datadir, datafile))
 	}
+	// Upload the captured profile file to object storage for durable access if requested.
+	var downloadURL string
+	if parameter.UploadURLPrefix != "" {
+		objectName := fmt.Sprintf("%s.%s", now, datafile)
+		downloadURL, err = UploadProfileFile(fmt.Sprintf("%s/%s", datadir, datafile), parameter.UploadURLPrefix, objectName, timeout)
+		if err != nil {
+			return "", "", fmt.Errorf("upload file failed with error: %s", err)
+		}
+		gp.Info("Upload go profiler file successfully.", "url", downloadURL)
+	}
+
+	// Serve the captured profile file as an artifact over the agent port if an artifact
+	// server is configured, so only the agent port needs to be exposed.
+	if gp.artifactServer != nil {
+		token := gp.artifactServer.Register(fmt.Sprintf("%s/%s", datadir, datafile), time.Duration(parameter.ExpirationSeconds)*time.Second)
+		return fmt.Sprintf("Visit http://%s:%d/artifacts/%s, this artifact will expire in %d seconds.", bindAddress, gp.port, token, parameter.ExpirationSeconds), downloadURL, nil
+	}
+
+	// Start an ephemeral pprof server on a random port otherwise.
+	port, err := util.GetAvailablePort()
+	if err != nil {
+		return "", "", err
+	}
+	endpoint := fmt.Sprintf("%s:%d", bindAddress, port)
+
 	gp.Info("Start to execute command.")
 	var buf bytes.Buffer
 	command := exec.Command("go", "tool", "pprof", "-no_browser", fmt.Sprintf("-http=%s", endpoint), fmt.Sprintf("%s/%s", datadir, datafile))
@@ -302,7 +353,7 @@ func (gp *goProfiler) runGoProfiler(name string, namespace string, bindAddress s
 	command.Stderr = &buf
 	err = command.Start()
 	if err != nil {
-		return "", err
+		return "", "", err
 	}
 	gp.Info("Command executed succeessfully.")
 
@@ -339,7 +390,7 @@ func (gp *goProfiler) runGoProfiler(name string, namespace string, bindAddress s
 		}
 	}()
 
-	return fmt.Sprintf("Visit http://%s, this server will expire in %d seconds.", endpoint, parameter.ExpirationSeconds), nil
+	return fmt.Sprintf("Visit http://%s, this server will expire in %d seconds.", endpoint, parameter.ExpirationSeconds), downloadURL, nil
 }
 
 // DownloadProfileFile do http request to download profile file and write into specified filepath
@@ -390,6 +441,37 @@ func DownloadProfileFile(filepath string, source string, token []byte, ca []byte
 	return nil
 }
 
+// UploadProfileFile uploads a profile file to object storage via an http put request, e.g. to
+// a presigned bucket url, and returns the durable download url of the uploaded object.
+func UploadProfileFile(filepath string, uploadURLPrefix string, objectName string, timeout time.Duration) (string, error) {
+	file, err := os.Open(filepath)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	url := fmt.Sprintf("%s/%s", strings.TrimSuffix(uploadURLPrefix, "/"), objectName)
+	req, err := http.NewRequest("PUT", url, file)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+
+	client := &http.Client{
+		Timeout: timeout,
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return "", fmt.Errorf("failed to upload to %s: %v", url, resp.Status)
+	}
+
+	return url, nil
+}
+
 // GetSecretData obtain secret.data from specified secret
 func GetSecretData(ca cache.Cache, name string, namespace string) (map[string][]byte, error) {
 	var secret corev1.Secret
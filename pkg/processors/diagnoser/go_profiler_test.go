@@ -0,0 +1,128 @@
+/*
+Copyright 2022 The KubeDiag Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package diagnoser
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	"github.com/kubediag/kubediag/pkg/artifactserver"
+)
+
+func TestUploadProfileFile(t *testing.T) {
+	dataDir, err := ioutil.TempDir("", "go-profiler-upload")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dataDir)
+	profileFile := filepath.Join(dataDir, "default.diagnosis1.Heap.prof")
+	err = ioutil.WriteFile(profileFile, []byte("profile data"), 0644)
+	assert.NoError(t, err)
+
+	var uploadedPath string
+	var uploadedBody []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "PUT" {
+			http.Error(w, "only PUT requests are supported", http.StatusMethodNotAllowed)
+			return
+		}
+		uploadedPath = r.URL.Path
+		uploadedBody, _ = ioutil.ReadAll(r.Body)
+	}))
+	defer server.Close()
+
+	// The profile file is uploaded to the object store and the durable url is returned.
+	downloadURL, err := UploadProfileFile(profileFile, server.URL+"/bucket/", "20220101000000.default.diagnosis1.Heap.prof", 5*time.Second)
+	assert.NoError(t, err)
+	assert.Equal(t, server.URL+"/bucket/20220101000000.default.diagnosis1.Heap.prof", downloadURL)
+	assert.Equal(t, "/bucket/20220101000000.default.diagnosis1.Heap.prof", uploadedPath)
+	assert.Equal(t, []byte("profile data"), uploadedBody)
+
+	// An erroneous response status fails the upload.
+	errorServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "access denied", http.StatusForbidden)
+	}))
+	defer errorServer.Close()
+	_, err = UploadProfileFile(profileFile, errorServer.URL, "object.prof", 5*time.Second)
+	assert.Error(t, err)
+
+	// A missing profile file fails the upload.
+	_, err = UploadProfileFile(filepath.Join(dataDir, "missing.prof"), server.URL, "object.prof", 5*time.Second)
+	assert.Error(t, err)
+}
+
+func TestRunGoProfilerWithArtifactServer(t *testing.T) {
+	dataDir, err := ioutil.TempDir("", "go-profiler-artifact")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dataDir)
+
+	// The profile source returns fake profile data for any pprof path.
+	source := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("profile data"))
+	}))
+	defer source.Close()
+
+	as := artifactserver.NewArtifactServer(context.Background(), ctrl.Log.WithName("artifactserver"))
+	gp := &goProfiler{
+		Context:              context.Background(),
+		Logger:               ctrl.Log.WithName("goprofiler"),
+		dataRoot:             dataDir,
+		BindAddress:          "127.0.0.1",
+		port:                 8090,
+		artifactServer:       as,
+		goProfilerEnabled:    true,
+		minExpirationSeconds: 1,
+		maxExpirationSeconds: 3600,
+	}
+
+	parameter := goProfilerRequestParameter{
+		GoProfilerType:    heapGoProfilerType,
+		Source:            source.URL,
+		ExpirationSeconds: 60,
+	}
+	endpoint, downloadURL, err := gp.runGoProfiler("diagnosis1", "default", gp.BindAddress, parameter, nil, dataDir)
+	assert.NoError(t, err)
+	assert.Empty(t, downloadURL)
+
+	// The profile file is served as an artifact over the agent port instead of an
+	// ephemeral pprof server.
+	assert.Contains(t, endpoint, "http://127.0.0.1:8090/artifacts/")
+	token := strings.TrimPrefix(endpoint, "Visit http://127.0.0.1:8090/artifacts/")
+	token = token[:strings.Index(token, ",")]
+
+	router := mux.NewRouter()
+	router.HandleFunc("/artifacts/{token}", as.Handler)
+	agent := httptest.NewServer(router)
+	defer agent.Close()
+
+	resp, err := http.Get(agent.URL + "/artifacts/" + token)
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	body, err := ioutil.ReadAll(resp.Body)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("profile data"), body)
+}
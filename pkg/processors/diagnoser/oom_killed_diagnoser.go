@@ -0,0 +1,163 @@
+/*
+Copyright 2022 The KubeDiag Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package diagnoser
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+
+	"github.com/kubediag/kubediag/pkg/processors"
+	"github.com/kubediag/kubediag/pkg/util"
+)
+
+const (
+	ContextKeyOOMKilledSummary = "diagnoser.kubernetes.oom_killed.summary"
+	ContextKeyOOMKilledCount   = "diagnoser.kubernetes.oom_killed.count"
+
+	// oomKilledReason is the termination reason reported by the kubelet for a container killed
+	// by the kernel out of memory killer.
+	oomKilledReason = "OOMKilled"
+)
+
+// oomKilledContainer describes a container on the node which was terminated by the out of
+// memory killer.
+type oomKilledContainer struct {
+	// Namespace is the namespace of the pod containing the container.
+	Namespace string `json:"namespace"`
+	// Pod is the name of the pod containing the container.
+	Pod string `json:"pod"`
+	// Container is the name of the container.
+	Container string `json:"container"`
+	// MemoryLimit is the memory limit of the container. It is empty if no limit is set.
+	// +optional
+	MemoryLimit string `json:"memoryLimit,omitempty"`
+	// RestartCount is the number of times the container has been restarted.
+	RestartCount int32 `json:"restartCount"`
+	// FinishedAt is the time at which the container was last terminated.
+	// +optional
+	FinishedAt metav1.Time `json:"finishedAt,omitempty"`
+}
+
+// oomKilledDiagnoser reports containers on the node which were terminated by the out of memory
+// killer.
+type oomKilledDiagnoser struct {
+	// Context carries values across API boundaries.
+	context.Context
+	// Logger represents the ability to log messages.
+	logr.Logger
+
+	// cache knows how to load Kubernetes objects.
+	cache cache.Cache
+	// nodeName specifies the node name.
+	nodeName string
+	// oomKilledDiagnoserEnabled indicates whether oomKilledDiagnoser is enabled.
+	oomKilledDiagnoserEnabled bool
+}
+
+// NewOOMKilledDiagnoser creates a new oomKilledDiagnoser.
+func NewOOMKilledDiagnoser(
+	ctx context.Context,
+	logger logr.Logger,
+	cache cache.Cache,
+	nodeName string,
+	oomKilledDiagnoserEnabled bool,
+) processors.Processor {
+	return &oomKilledDiagnoser{
+		Context:                   ctx,
+		Logger:                    logger,
+		cache:                     cache,
+		nodeName:                  nodeName,
+		oomKilledDiagnoserEnabled: oomKilledDiagnoserEnabled,
+	}
+}
+
+// Handler handles http requests for reporting oom killed containers on the node.
+func (od *oomKilledDiagnoser) Handler(w http.ResponseWriter, r *http.Request) {
+	if !od.oomKilledDiagnoserEnabled {
+		http.Error(w, fmt.Sprintf("oom killed diagnoser is not enabled"), http.StatusUnprocessableEntity)
+		return
+	}
+
+	switch r.Method {
+	case "POST":
+		var podList corev1.PodList
+		if err := od.cache.List(od, &podList); err != nil {
+			http.Error(w, fmt.Sprintf("failed to list pods: %v", err), http.StatusInternalServerError)
+			return
+		}
+		podsOnNode := util.RetrievePodsOnNode(podList.Items, od.nodeName)
+		summaries := summarizeOOMKilledContainers(podsOnNode)
+
+		raw, err := json.Marshal(summaries)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("failed to marshal oom killed container summaries: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		result := make(map[string]string)
+		result[ContextKeyOOMKilledSummary] = string(raw)
+		result[ContextKeyOOMKilledCount] = strconv.Itoa(len(summaries))
+		data, err := json.Marshal(result)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("failed to marshal result: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(data)
+	default:
+		http.Error(w, fmt.Sprintf("method %s is not supported", r.Method), http.StatusMethodNotAllowed)
+	}
+}
+
+// summarizeOOMKilledContainers reports containers of the pods with a last termination caused by
+// the out of memory killer along with their memory limits and restart counts.
+func summarizeOOMKilledContainers(pods []corev1.Pod) []oomKilledContainer {
+	summaries := make([]oomKilledContainer, 0)
+	for _, pod := range pods {
+		memoryLimits := make(map[string]string)
+		for _, container := range pod.Spec.Containers {
+			if limit, ok := container.Resources.Limits[corev1.ResourceMemory]; ok {
+				memoryLimits[container.Name] = limit.String()
+			}
+		}
+		for _, containerStatus := range pod.Status.ContainerStatuses {
+			terminated := containerStatus.LastTerminationState.Terminated
+			if terminated == nil || terminated.Reason != oomKilledReason {
+				continue
+			}
+			summaries = append(summaries, oomKilledContainer{
+				Namespace:    pod.Namespace,
+				Pod:          pod.Name,
+				Container:    containerStatus.Name,
+				MemoryLimit:  memoryLimits[containerStatus.Name],
+				RestartCount: containerStatus.RestartCount,
+				FinishedAt:   terminated.FinishedAt,
+			})
+		}
+	}
+
+	return summaries
+}
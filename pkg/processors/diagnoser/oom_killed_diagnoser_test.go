@@ -0,0 +1,148 @@
+/*
+Copyright 2022 The KubeDiag Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package diagnoser
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// newOOMKilledPod returns a pod on the provided node with a container whose last termination
+// was caused by the out of memory killer.
+func newOOMKilledPod(name string, nodeName string, memoryLimit string, restartCount int32) *corev1.Pod {
+	container := corev1.Container{
+		Name: "container1",
+	}
+	if memoryLimit != "" {
+		container.Resources = corev1.ResourceRequirements{
+			Limits: corev1.ResourceList{
+				corev1.ResourceMemory: resource.MustParse(memoryLimit),
+			},
+		}
+	}
+
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: "default",
+		},
+		Spec: corev1.PodSpec{
+			NodeName:   nodeName,
+			Containers: []corev1.Container{container},
+		},
+		Status: corev1.PodStatus{
+			ContainerStatuses: []corev1.ContainerStatus{
+				{
+					Name:         "container1",
+					RestartCount: restartCount,
+					LastTerminationState: corev1.ContainerState{
+						Terminated: &corev1.ContainerStateTerminated{
+							Reason:     oomKilledReason,
+							FinishedAt: metav1.Now(),
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func TestOOMKilledDiagnoserHandler(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+
+	healthyPod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "pod2",
+			Namespace: "default",
+		},
+		Spec: corev1.PodSpec{
+			NodeName: "node1",
+		},
+	}
+
+	cli := fake.NewFakeClientWithScheme(scheme,
+		newOOMKilledPod("pod1", "node1", "128Mi", 3),
+		healthyPod,
+		newOOMKilledPod("pod3", "node2", "", 1),
+	)
+	od := &oomKilledDiagnoser{
+		Context:                   context.Background(),
+		Logger:                    ctrl.Log.WithName("diagnoser/oomKilledDiagnoser"),
+		cache:                     fakeCache{Reader: cli},
+		nodeName:                  "node1",
+		oomKilledDiagnoserEnabled: true,
+	}
+
+	req := httptest.NewRequest("POST", "/processor/oomKilledDiagnoser", nil)
+	recorder := httptest.NewRecorder()
+	od.Handler(recorder, req)
+	assert.Equal(t, 200, recorder.Code)
+
+	result := make(map[string]string)
+	err := json.Unmarshal(recorder.Body.Bytes(), &result)
+	assert.NoError(t, err)
+	assert.Equal(t, "1", result[ContextKeyOOMKilledCount])
+
+	// Only the oom killed container on the node is reported.
+	var summaries []oomKilledContainer
+	err = json.Unmarshal([]byte(result[ContextKeyOOMKilledSummary]), &summaries)
+	assert.NoError(t, err)
+	if assert.Equal(t, 1, len(summaries)) {
+		assert.Equal(t, "default", summaries[0].Namespace)
+		assert.Equal(t, "pod1", summaries[0].Pod)
+		assert.Equal(t, "container1", summaries[0].Container)
+		assert.Equal(t, "128Mi", summaries[0].MemoryLimit)
+		assert.Equal(t, int32(3), summaries[0].RestartCount)
+		assert.False(t, summaries[0].FinishedAt.IsZero())
+	}
+
+	// Only POST requests are supported.
+	req = httptest.NewRequest("GET", "/processor/oomKilledDiagnoser", nil)
+	recorder = httptest.NewRecorder()
+	od.Handler(recorder, req)
+	assert.Equal(t, 405, recorder.Code)
+}
+
+func TestSummarizeOOMKilledContainers(t *testing.T) {
+	// A container terminated for another reason is not reported.
+	evictedPod := newOOMKilledPod("pod4", "node1", "", 1)
+	evictedPod.Status.ContainerStatuses[0].LastTerminationState.Terminated.Reason = "Error"
+
+	summaries := summarizeOOMKilledContainers([]corev1.Pod{
+		*newOOMKilledPod("pod1", "node1", "128Mi", 3),
+		*newOOMKilledPod("pod5", "node1", "", 2),
+		*evictedPod,
+	})
+	if assert.Equal(t, 2, len(summaries)) {
+		assert.Equal(t, "128Mi", summaries[0].MemoryLimit)
+		assert.Equal(t, "", summaries[1].MemoryLimit)
+	}
+
+	assert.Empty(t, summarizeOOMKilledContainers(nil))
+}
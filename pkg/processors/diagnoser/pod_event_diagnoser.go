@@ -0,0 +1,179 @@
+/*
+Copyright 2022 The KubeDiag Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package diagnoser
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/kubediag/kubediag/pkg/executor"
+	"github.com/kubediag/kubediag/pkg/processors"
+	"github.com/kubediag/kubediag/pkg/processors/utils"
+)
+
+const (
+	ContextKeyPodEventSummary = "diagnoser.kubernetes.pod_event.summary"
+)
+
+// podEventSummary is a condensed summary of events with an identical reason involving a pod.
+type podEventSummary struct {
+	// Reason is the reason of the events.
+	Reason string `json:"reason"`
+	// Type is the type of the events.
+	Type string `json:"type"`
+	// Count is the accumulated count of the events.
+	Count int32 `json:"count"`
+	// LastTimestamp is the time at which the most recent occurrence of the events was recorded.
+	LastTimestamp metav1.Time `json:"lastTimestamp"`
+	// Message is the message of the most recent occurrence of the events.
+	Message string `json:"message"`
+}
+
+// podEventDiagnoser summarizes kubernetes events involving a pod.
+type podEventDiagnoser struct {
+	// Context carries values across API boundaries.
+	context.Context
+	// Logger represents the ability to log messages.
+	logr.Logger
+
+	// cache knows how to load Kubernetes objects.
+	cache cache.Cache
+	// podEventDiagnoserEnabled indicates whether podEventDiagnoser is enabled.
+	podEventDiagnoserEnabled bool
+}
+
+// NewPodEventDiagnoser creates a new podEventDiagnoser.
+func NewPodEventDiagnoser(
+	ctx context.Context,
+	logger logr.Logger,
+	cache cache.Cache,
+	podEventDiagnoserEnabled bool,
+) processors.Processor {
+	return &podEventDiagnoser{
+		Context:                  ctx,
+		Logger:                   logger,
+		cache:                    cache,
+		podEventDiagnoserEnabled: podEventDiagnoserEnabled,
+	}
+}
+
+// Handler handles http requests for summarizing pod events.
+func (pd *podEventDiagnoser) Handler(w http.ResponseWriter, r *http.Request) {
+	if !pd.podEventDiagnoserEnabled {
+		http.Error(w, fmt.Sprintf("pod event diagnoser is not enabled"), http.StatusUnprocessableEntity)
+		return
+	}
+
+	switch r.Method {
+	case "POST":
+		contexts, err := utils.ExtractParametersFromHTTPContext(r)
+		if err != nil {
+			pd.Error(err, "extract contexts failed")
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if contexts[executor.PodNamespaceTelemetryKey] == "" ||
+			contexts[executor.PodNameTelemetryKey] == "" {
+			http.Error(w, "extract contexts lack of pod namespace and name", http.StatusInternalServerError)
+			return
+		}
+		namespace := contexts[executor.PodNamespaceTelemetryKey]
+		name := contexts[executor.PodNameTelemetryKey]
+
+		var eventList corev1.EventList
+		err = pd.cache.List(pd.Context, &eventList, client.InNamespace(namespace))
+		if err != nil {
+			http.Error(w, fmt.Sprintf("failed to list events: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		// Retain events involving the pod and its containers only.
+		events := make([]corev1.Event, 0)
+		for _, event := range eventList.Items {
+			if event.InvolvedObject.Kind == "Pod" && event.InvolvedObject.Name == name {
+				events = append(events, event)
+			}
+		}
+		summaries := summarizePodEvents(events)
+
+		raw, err := json.Marshal(summaries)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("failed to marshal pod event summaries: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		result := make(map[string]string)
+		result[ContextKeyPodEventSummary] = string(raw)
+		data, err := json.Marshal(result)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("failed to marshal result: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(data)
+	default:
+		http.Error(w, fmt.Sprintf("method %s is not supported", r.Method), http.StatusMethodNotAllowed)
+	}
+}
+
+// summarizePodEvents condenses events involving a pod into summaries by reason sorted by last timestamp.
+func summarizePodEvents(events []corev1.Event) []podEventSummary {
+	summaryByReason := make(map[string]*podEventSummary)
+	for _, event := range events {
+		count := event.Count
+		if count == 0 {
+			count = 1
+		}
+		summary, ok := summaryByReason[event.Reason]
+		if !ok {
+			summaryByReason[event.Reason] = &podEventSummary{
+				Reason:        event.Reason,
+				Type:          event.Type,
+				Count:         count,
+				LastTimestamp: event.LastTimestamp,
+				Message:       event.Message,
+			}
+			continue
+		}
+		summary.Count += count
+		if summary.LastTimestamp.Before(&event.LastTimestamp) {
+			summary.LastTimestamp = event.LastTimestamp
+			summary.Message = event.Message
+			summary.Type = event.Type
+		}
+	}
+
+	summaries := make([]podEventSummary, 0, len(summaryByReason))
+	for _, summary := range summaryByReason {
+		summaries = append(summaries, *summary)
+	}
+	sort.Slice(summaries, func(i, j int) bool {
+		return summaries[i].LastTimestamp.Before(&summaries[j].LastTimestamp)
+	})
+
+	return summaries
+}
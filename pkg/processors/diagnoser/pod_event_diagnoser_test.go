@@ -0,0 +1,89 @@
+/*
+Copyright 2022 The KubeDiag Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package diagnoser
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestSummarizePodEvents(t *testing.T) {
+	now := metav1.Now()
+	earlier := metav1.NewTime(now.Add(-10 * time.Minute))
+
+	tests := []struct {
+		events   []corev1.Event
+		expected []podEventSummary
+		desc     string
+	}{
+		{
+			events:   []corev1.Event{},
+			expected: []podEventSummary{},
+			desc:     "no events",
+		},
+		{
+			events: []corev1.Event{
+				{
+					Reason:        "BackOff",
+					Type:          corev1.EventTypeWarning,
+					Count:         3,
+					LastTimestamp: earlier,
+					Message:       "Back-off restarting failed container",
+				},
+				{
+					Reason:        "BackOff",
+					Type:          corev1.EventTypeWarning,
+					Count:         2,
+					LastTimestamp: now,
+					Message:       "Back-off pulling image",
+				},
+				{
+					Reason:        "Scheduled",
+					Type:          corev1.EventTypeNormal,
+					LastTimestamp: earlier,
+					Message:       "Successfully assigned pod to node",
+				},
+			},
+			expected: []podEventSummary{
+				{
+					Reason:        "Scheduled",
+					Type:          corev1.EventTypeNormal,
+					Count:         1,
+					LastTimestamp: earlier,
+					Message:       "Successfully assigned pod to node",
+				},
+				{
+					Reason:        "BackOff",
+					Type:          corev1.EventTypeWarning,
+					Count:         5,
+					LastTimestamp: now,
+					Message:       "Back-off pulling image",
+				},
+			},
+			desc: "events condensed by reason and sorted by last timestamp",
+		},
+	}
+
+	for _, test := range tests {
+		summaries := summarizePodEvents(test.events)
+		assert.Equal(t, test.expected, summaries, test.desc)
+	}
+}
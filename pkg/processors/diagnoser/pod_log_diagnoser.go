@@ -0,0 +1,225 @@
+/*
+Copyright 2022 The KubeDiag Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package diagnoser
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/go-logr/logr"
+
+	"github.com/kubediag/kubediag/pkg/executor"
+	"github.com/kubediag/kubediag/pkg/processors"
+	"github.com/kubediag/kubediag/pkg/processors/utils"
+)
+
+const (
+	ParameterKeyPodLogPattern   = "param.diagnoser.kubernetes.pod_log.pattern"
+	ParameterKeyPodLogTailLines = "param.diagnoser.kubernetes.pod_log.tail_lines"
+
+	ContextKeyPodLogMatches    = "diagnoser.kubernetes.pod_log.matches"
+	ContextKeyPodLogMatchCount = "diagnoser.kubernetes.pod_log.match_count"
+	ContextKeyPodLogFiles      = "diagnoser.kubernetes.pod_log.files"
+
+	// podLogsDirectory is the directory where the kubelet stores container logs.
+	podLogsDirectory = "/var/log/pods"
+	// podLogDefaultTailLines is the number of matched lines returned if not specified.
+	podLogDefaultTailLines = 20
+)
+
+// podLogDiagnoser greps the log files of a pod container under the kubelet log directory on
+// the node. It reads rotated log files directly and thus complements fetching logs through
+// the kubernetes api.
+type podLogDiagnoser struct {
+	// Context carries values across API boundaries.
+	context.Context
+	// Logger represents the ability to log messages.
+	logr.Logger
+
+	// podLogsDirectory is the directory where the kubelet stores container logs.
+	podLogsDirectory string
+	// podLogDiagnoserEnabled indicates whether podLogDiagnoser is enabled.
+	podLogDiagnoserEnabled bool
+}
+
+// NewPodLogDiagnoser creates a new podLogDiagnoser.
+func NewPodLogDiagnoser(
+	ctx context.Context,
+	logger logr.Logger,
+	podLogDiagnoserEnabled bool,
+) processors.Processor {
+	return &podLogDiagnoser{
+		Context:                ctx,
+		Logger:                 logger,
+		podLogsDirectory:       podLogsDirectory,
+		podLogDiagnoserEnabled: podLogDiagnoserEnabled,
+	}
+}
+
+// Handler handles http requests for grepping pod container logs on the node.
+func (pld *podLogDiagnoser) Handler(w http.ResponseWriter, r *http.Request) {
+	if !pld.podLogDiagnoserEnabled {
+		http.Error(w, fmt.Sprintf("pod log diagnoser is not enabled"), http.StatusUnprocessableEntity)
+		return
+	}
+
+	switch r.Method {
+	case "POST":
+		contexts, err := utils.ExtractParametersFromHTTPContext(r)
+		if err != nil {
+			pld.Error(err, "extract contexts failed")
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		namespace := contexts[executor.PodNamespaceTelemetryKey]
+		name := contexts[executor.PodNameTelemetryKey]
+		if namespace == "" || name == "" {
+			http.Error(w, "pod namespace and name must be specified", http.StatusBadRequest)
+			return
+		}
+		container := contexts[executor.ContainerTelemetryKey]
+
+		var pattern *regexp.Regexp
+		if value := contexts[ParameterKeyPodLogPattern]; value != "" {
+			pattern, err = regexp.Compile(value)
+			if err != nil {
+				http.Error(w, fmt.Sprintf("invalid pattern: %v", err), http.StatusBadRequest)
+				return
+			}
+		}
+		tailLines := podLogDefaultTailLines
+		if value := contexts[ParameterKeyPodLogTailLines]; value != "" {
+			tailLines, err = strconv.Atoi(value)
+			if err != nil || tailLines <= 0 {
+				http.Error(w, fmt.Sprintf("invalid tail lines: %s", value), http.StatusBadRequest)
+				return
+			}
+		}
+
+		files, err := pld.listContainerLogFiles(namespace, name, container)
+		if err != nil {
+			pld.Error(err, "failed to list container log files", "pod", fmt.Sprintf("%s/%s", namespace, name), "container", container)
+			http.Error(w, fmt.Sprintf("failed to list container log files: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		matches, err := grepTail(files, pattern, tailLines)
+		if err != nil {
+			pld.Error(err, "failed to grep container log files", "pod", fmt.Sprintf("%s/%s", namespace, name), "container", container)
+			http.Error(w, fmt.Sprintf("failed to grep container log files: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		result := make(map[string]string)
+		result[ContextKeyPodLogMatches] = strings.Join(matches, "\n")
+		result[ContextKeyPodLogMatchCount] = strconv.Itoa(len(matches))
+		result[ContextKeyPodLogFiles] = strings.Join(files, "\n")
+		data, err := json.Marshal(result)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("failed to marshal result: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(data)
+	default:
+		http.Error(w, fmt.Sprintf("method %s is not supported", r.Method), http.StatusMethodNotAllowed)
+	}
+}
+
+// listContainerLogFiles returns the log files of a pod container under the kubelet log
+// directory in chronological order, including rotated files. Compressed rotations are
+// skipped. The directory layout is <namespace>_<name>_<uid>/<container>/<instance>.log.
+func (pld *podLogDiagnoser) listContainerLogFiles(namespace string, name string, container string) ([]string, error) {
+	podDirectories, err := filepath.Glob(filepath.Join(pld.podLogsDirectory, namespace+"_"+name+"_*"))
+	if err != nil {
+		return nil, err
+	}
+	if len(podDirectories) == 0 {
+		return nil, fmt.Errorf("log directory of pod %s/%s not found under %s", namespace, name, pld.podLogsDirectory)
+	}
+
+	containerPattern := container
+	if containerPattern == "" {
+		containerPattern = "*"
+	}
+	containerDirectories, err := filepath.Glob(filepath.Join(podDirectories[0], containerPattern))
+	if err != nil {
+		return nil, err
+	}
+	if len(containerDirectories) == 0 {
+		return nil, fmt.Errorf("log directory of container %q of pod %s/%s not found", container, namespace, name)
+	}
+
+	files := make([]string, 0)
+	for _, containerDirectory := range containerDirectories {
+		entries, err := ioutil.ReadDir(containerDirectory)
+		if err != nil {
+			return nil, err
+		}
+		infos := make([]os.FileInfo, 0, len(entries))
+		for _, entry := range entries {
+			if entry.IsDir() || strings.HasSuffix(entry.Name(), ".gz") {
+				continue
+			}
+			infos = append(infos, entry)
+		}
+		sort.Slice(infos, func(i, j int) bool {
+			return infos[i].ModTime().Before(infos[j].ModTime())
+		})
+		for _, info := range infos {
+			files = append(files, filepath.Join(containerDirectory, info.Name()))
+		}
+	}
+
+	return files, nil
+}
+
+// grepTail returns the last n lines matching the pattern across the files. All lines match
+// if the pattern is nil.
+func grepTail(files []string, pattern *regexp.Regexp, n int) ([]string, error) {
+	matches := make([]string, 0)
+	for _, file := range files {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		for _, line := range strings.Split(strings.TrimRight(string(data), "\n"), "\n") {
+			if line == "" {
+				continue
+			}
+			if pattern == nil || pattern.MatchString(line) {
+				matches = append(matches, line)
+			}
+		}
+	}
+	if len(matches) > n {
+		matches = matches[len(matches)-n:]
+	}
+
+	return matches, nil
+}
@@ -0,0 +1,132 @@
+/*
+Copyright 2022 The KubeDiag Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package diagnoser
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	"github.com/kubediag/kubediag/pkg/executor"
+)
+
+func TestPodLogDiagnoserHandler(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pod-log-diagnoser")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	// Fixture container log directory with a rotated and a current log file.
+	containerDirectory := filepath.Join(dir, "default_pod1_8b9e7f2c-4c25-4f0b-9a9e-8a2b7f3b1c5d", "app")
+	assert.NoError(t, os.MkdirAll(containerDirectory, 0755))
+	rotated := filepath.Join(containerDirectory, "0.log.20220101-000000")
+	current := filepath.Join(containerDirectory, "0.log")
+	compressed := filepath.Join(containerDirectory, "0.log.20211231-000000.gz")
+	assert.NoError(t, ioutil.WriteFile(rotated, []byte("line1 INFO started\nline2 ERROR connection refused\n"), 0644))
+	assert.NoError(t, ioutil.WriteFile(current, []byte("line3 INFO running\nline4 ERROR timeout\nline5 INFO done\n"), 0644))
+	assert.NoError(t, ioutil.WriteFile(compressed, []byte("compressed"), 0644))
+	// Ensure the rotated file is older than the current one.
+	assert.NoError(t, os.Chtimes(rotated, time.Now().Add(-time.Hour), time.Now().Add(-time.Hour)))
+
+	pld := &podLogDiagnoser{
+		Context:                context.Background(),
+		Logger:                 ctrl.Log.WithName("diagnoser/podLogDiagnoser"),
+		podLogsDirectory:       dir,
+		podLogDiagnoserEnabled: true,
+	}
+
+	body, err := json.Marshal(map[string]string{
+		executor.PodNamespaceTelemetryKey: "default",
+		executor.PodNameTelemetryKey:      "pod1",
+		executor.ContainerTelemetryKey:    "app",
+		ParameterKeyPodLogPattern:         "ERROR",
+	})
+	assert.NoError(t, err)
+	req := httptest.NewRequest("POST", "/processor/podLogDiagnoser", bytes.NewBuffer(body))
+	recorder := httptest.NewRecorder()
+	pld.Handler(recorder, req)
+	assert.Equal(t, 200, recorder.Code)
+
+	result := make(map[string]string)
+	err = json.Unmarshal(recorder.Body.Bytes(), &result)
+	assert.NoError(t, err)
+	assert.Equal(t, "2", result[ContextKeyPodLogMatchCount])
+	assert.Equal(t, "line2 ERROR connection refused\nline4 ERROR timeout", result[ContextKeyPodLogMatches])
+	assert.Contains(t, result[ContextKeyPodLogFiles], rotated)
+	assert.Contains(t, result[ContextKeyPodLogFiles], current)
+	assert.NotContains(t, result[ContextKeyPodLogFiles], compressed)
+
+	// All lines are returned if no pattern is specified, limited by tail lines.
+	body, err = json.Marshal(map[string]string{
+		executor.PodNamespaceTelemetryKey: "default",
+		executor.PodNameTelemetryKey:      "pod1",
+		ParameterKeyPodLogTailLines:       "2",
+	})
+	assert.NoError(t, err)
+	req = httptest.NewRequest("POST", "/processor/podLogDiagnoser", bytes.NewBuffer(body))
+	recorder = httptest.NewRecorder()
+	pld.Handler(recorder, req)
+	assert.Equal(t, 200, recorder.Code)
+	result = make(map[string]string)
+	err = json.Unmarshal(recorder.Body.Bytes(), &result)
+	assert.NoError(t, err)
+	assert.Equal(t, "2", result[ContextKeyPodLogMatchCount])
+	assert.Equal(t, "line4 ERROR timeout\nline5 INFO done", result[ContextKeyPodLogMatches])
+
+	// The pod namespace and name must be specified.
+	req = httptest.NewRequest("POST", "/processor/podLogDiagnoser", bytes.NewBufferString("{}"))
+	recorder = httptest.NewRecorder()
+	pld.Handler(recorder, req)
+	assert.Equal(t, 400, recorder.Code)
+
+	// An invalid pattern is rejected.
+	body, err = json.Marshal(map[string]string{
+		executor.PodNamespaceTelemetryKey: "default",
+		executor.PodNameTelemetryKey:      "pod1",
+		ParameterKeyPodLogPattern:         "(",
+	})
+	assert.NoError(t, err)
+	req = httptest.NewRequest("POST", "/processor/podLogDiagnoser", bytes.NewBuffer(body))
+	recorder = httptest.NewRecorder()
+	pld.Handler(recorder, req)
+	assert.Equal(t, 400, recorder.Code)
+
+	// An unknown pod is an error.
+	body, err = json.Marshal(map[string]string{
+		executor.PodNamespaceTelemetryKey: "default",
+		executor.PodNameTelemetryKey:      "pod2",
+	})
+	assert.NoError(t, err)
+	req = httptest.NewRequest("POST", "/processor/podLogDiagnoser", bytes.NewBuffer(body))
+	recorder = httptest.NewRecorder()
+	pld.Handler(recorder, req)
+	assert.Equal(t, 500, recorder.Code)
+
+	// Only POST requests are supported.
+	req = httptest.NewRequest("GET", "/processor/podLogDiagnoser", nil)
+	recorder = httptest.NewRecorder()
+	pld.Handler(recorder, req)
+	assert.Equal(t, 405, recorder.Code)
+}
@@ -0,0 +1,199 @@
+/*
+Copyright 2022 The KubeDiag Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package diagnoser
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/kubediag/kubediag/pkg/executor"
+	"github.com/kubediag/kubediag/pkg/processors"
+	"github.com/kubediag/kubediag/pkg/processors/utils"
+)
+
+const (
+	ContextKeyPodSchedulingFailure       = "diagnoser.kubernetes.pod_scheduling.failure"
+	ContextKeyPodSchedulingFailureReason = "diagnoser.kubernetes.pod_scheduling.reason"
+
+	// failedSchedulingEventReason is the reason of events recorded by the scheduler on
+	// scheduling failures.
+	failedSchedulingEventReason = "FailedScheduling"
+)
+
+// Structured reasons of pod scheduling failures derived from FailedScheduling messages.
+const (
+	schedulingFailureReasonInsufficientCPU    = "InsufficientCPU"
+	schedulingFailureReasonInsufficientMemory = "InsufficientMemory"
+	schedulingFailureReasonTaints             = "UntoleratedTaints"
+	schedulingFailureReasonAffinityMismatch   = "AffinityMismatch"
+	schedulingFailureReasonUnknown            = "Unknown"
+)
+
+// podSchedulingFailure describes why a pending pod failed to be scheduled.
+type podSchedulingFailure struct {
+	// Phase is the phase of the pod.
+	Phase corev1.PodPhase `json:"phase"`
+	// Scheduled indicates whether the pod has been scheduled to a node.
+	Scheduled bool `json:"scheduled"`
+	// Reason is the structured reason of the scheduling failure. It is one of InsufficientCPU,
+	// InsufficientMemory, UntoleratedTaints, AffinityMismatch or Unknown.
+	// +optional
+	Reason string `json:"reason,omitempty"`
+	// Message is the message of the most recent FailedScheduling event.
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+// podSchedulingDiagnoser analyzes scheduling failures of a pending pod.
+type podSchedulingDiagnoser struct {
+	// Context carries values across API boundaries.
+	context.Context
+	// Logger represents the ability to log messages.
+	logr.Logger
+
+	// cache knows how to load Kubernetes objects.
+	cache cache.Cache
+	// podSchedulingDiagnoserEnabled indicates whether podSchedulingDiagnoser is enabled.
+	podSchedulingDiagnoserEnabled bool
+}
+
+// NewPodSchedulingDiagnoser creates a new podSchedulingDiagnoser.
+func NewPodSchedulingDiagnoser(
+	ctx context.Context,
+	logger logr.Logger,
+	cache cache.Cache,
+	podSchedulingDiagnoserEnabled bool,
+) processors.Processor {
+	return &podSchedulingDiagnoser{
+		Context:                       ctx,
+		Logger:                        logger,
+		cache:                         cache,
+		podSchedulingDiagnoserEnabled: podSchedulingDiagnoserEnabled,
+	}
+}
+
+// Handler handles http requests for analyzing pod scheduling failures.
+func (pd *podSchedulingDiagnoser) Handler(w http.ResponseWriter, r *http.Request) {
+	if !pd.podSchedulingDiagnoserEnabled {
+		http.Error(w, fmt.Sprintf("pod scheduling diagnoser is not enabled"), http.StatusUnprocessableEntity)
+		return
+	}
+
+	switch r.Method {
+	case "POST":
+		contexts, err := utils.ExtractParametersFromHTTPContext(r)
+		if err != nil {
+			pd.Error(err, "extract contexts failed")
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if contexts[executor.PodNamespaceTelemetryKey] == "" ||
+			contexts[executor.PodNameTelemetryKey] == "" {
+			http.Error(w, "extract contexts lack of pod namespace and name", http.StatusInternalServerError)
+			return
+		}
+		namespace := contexts[executor.PodNamespaceTelemetryKey]
+		name := contexts[executor.PodNameTelemetryKey]
+
+		var pod corev1.Pod
+		if err := pd.cache.Get(pd, client.ObjectKey{Namespace: namespace, Name: name}, &pod); err != nil {
+			http.Error(w, fmt.Sprintf("failed to get pod %s/%s: %v", namespace, name, err), http.StatusInternalServerError)
+			return
+		}
+
+		var eventList corev1.EventList
+		if err := pd.cache.List(pd, &eventList, client.InNamespace(namespace)); err != nil {
+			http.Error(w, fmt.Sprintf("failed to list events: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		// Retain the most recent FailedScheduling event involving the pod.
+		var lastFailedScheduling *corev1.Event
+		for index, event := range eventList.Items {
+			if event.InvolvedObject.Kind != "Pod" || event.InvolvedObject.Name != name {
+				continue
+			}
+			if event.Reason != failedSchedulingEventReason {
+				continue
+			}
+			if lastFailedScheduling == nil || lastFailedScheduling.LastTimestamp.Before(&event.LastTimestamp) {
+				lastFailedScheduling = &eventList.Items[index]
+			}
+		}
+
+		failure := podSchedulingFailure{
+			Phase:     pod.Status.Phase,
+			Scheduled: pod.Spec.NodeName != "",
+		}
+		for _, condition := range pod.Status.Conditions {
+			if condition.Type == corev1.PodScheduled && condition.Status == corev1.ConditionTrue {
+				failure.Scheduled = true
+			}
+		}
+		if lastFailedScheduling != nil {
+			failure.Message = lastFailedScheduling.Message
+			failure.Reason = classifySchedulingFailureMessage(lastFailedScheduling.Message)
+		}
+
+		raw, err := json.Marshal(failure)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("failed to marshal pod scheduling failure: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		result := make(map[string]string)
+		result[ContextKeyPodSchedulingFailure] = string(raw)
+		result[ContextKeyPodSchedulingFailureReason] = failure.Reason
+		data, err := json.Marshal(result)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("failed to marshal result: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(data)
+	default:
+		http.Error(w, fmt.Sprintf("method %s is not supported", r.Method), http.StatusMethodNotAllowed)
+	}
+}
+
+// classifySchedulingFailureMessage derives a structured reason from the message of a
+// FailedScheduling event, e.g. "0/3 nodes are available: 3 Insufficient memory.".
+func classifySchedulingFailureMessage(message string) string {
+	lowered := strings.ToLower(message)
+	switch {
+	case strings.Contains(lowered, "insufficient cpu"):
+		return schedulingFailureReasonInsufficientCPU
+	case strings.Contains(lowered, "insufficient memory"):
+		return schedulingFailureReasonInsufficientMemory
+	case strings.Contains(lowered, "untolerated taint") || strings.Contains(lowered, "the pod didn't tolerate"):
+		return schedulingFailureReasonTaints
+	case strings.Contains(lowered, "didn't match pod affinity") || strings.Contains(lowered, "didn't match pod anti-affinity") ||
+		strings.Contains(lowered, "node selector") || strings.Contains(lowered, "didn't match pod's node affinity"):
+		return schedulingFailureReasonAffinityMismatch
+	default:
+		return schedulingFailureReasonUnknown
+	}
+}
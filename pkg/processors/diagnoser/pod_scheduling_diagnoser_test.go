@@ -0,0 +1,163 @@
+/*
+Copyright 2022 The KubeDiag Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package diagnoser
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/kubediag/kubediag/pkg/executor"
+)
+
+func TestPodSchedulingDiagnoserHandler(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+
+	now := metav1.Now()
+	earlier := metav1.NewTime(now.Add(-10 * time.Minute))
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "pod1",
+			Namespace: "default",
+		},
+		Status: corev1.PodStatus{
+			Phase: corev1.PodPending,
+			Conditions: []corev1.PodCondition{
+				{
+					Type:   corev1.PodScheduled,
+					Status: corev1.ConditionFalse,
+					Reason: corev1.PodReasonUnschedulable,
+				},
+			},
+		},
+	}
+	events := []runtime.Object{
+		&corev1.Event{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "event1",
+				Namespace: "default",
+			},
+			InvolvedObject: corev1.ObjectReference{Kind: "Pod", Name: "pod1", Namespace: "default"},
+			Reason:         failedSchedulingEventReason,
+			Message:        "0/3 nodes are available: 3 Insufficient memory.",
+			LastTimestamp:  now,
+		},
+		&corev1.Event{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "event2",
+				Namespace: "default",
+			},
+			InvolvedObject: corev1.ObjectReference{Kind: "Pod", Name: "pod1", Namespace: "default"},
+			Reason:         failedSchedulingEventReason,
+			Message:        "0/3 nodes are available: 3 Insufficient cpu.",
+			LastTimestamp:  earlier,
+		},
+		&corev1.Event{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "event3",
+				Namespace: "default",
+			},
+			InvolvedObject: corev1.ObjectReference{Kind: "Pod", Name: "pod2", Namespace: "default"},
+			Reason:         failedSchedulingEventReason,
+			Message:        "0/3 nodes are available: 3 node(s) had untolerated taint.",
+			LastTimestamp:  now,
+		},
+	}
+
+	cli := fake.NewFakeClientWithScheme(scheme, append([]runtime.Object{pod}, events...)...)
+	pd := &podSchedulingDiagnoser{
+		Context:                       context.Background(),
+		Logger:                        ctrl.Log.WithName("diagnoser/podSchedulingDiagnoser"),
+		cache:                         fakeCache{Reader: cli},
+		podSchedulingDiagnoserEnabled: true,
+	}
+
+	parameters := map[string]string{
+		executor.PodNamespaceTelemetryKey: "default",
+		executor.PodNameTelemetryKey:      "pod1",
+	}
+	body, err := json.Marshal(parameters)
+	assert.NoError(t, err)
+	recorder := httptest.NewRecorder()
+	pd.Handler(recorder, httptest.NewRequest("POST", "/processor/podSchedulingDiagnoser", bytes.NewReader(body)))
+	assert.Equal(t, 200, recorder.Code)
+
+	result := make(map[string]string)
+	err = json.Unmarshal(recorder.Body.Bytes(), &result)
+	assert.NoError(t, err)
+
+	// The most recent FailedScheduling event of the pod determines the structured reason.
+	assert.Equal(t, schedulingFailureReasonInsufficientMemory, result[ContextKeyPodSchedulingFailureReason])
+	var failure podSchedulingFailure
+	err = json.Unmarshal([]byte(result[ContextKeyPodSchedulingFailure]), &failure)
+	assert.NoError(t, err)
+	assert.Equal(t, corev1.PodPending, failure.Phase)
+	assert.False(t, failure.Scheduled)
+	assert.Equal(t, "0/3 nodes are available: 3 Insufficient memory.", failure.Message)
+
+	recorder = httptest.NewRecorder()
+	pd.Handler(recorder, httptest.NewRequest("GET", "/processor/podSchedulingDiagnoser", nil))
+	assert.Equal(t, 405, recorder.Code)
+}
+
+func TestClassifySchedulingFailureMessage(t *testing.T) {
+	tests := []struct {
+		message  string
+		expected string
+	}{
+		{
+			message:  "0/3 nodes are available: 3 Insufficient cpu.",
+			expected: schedulingFailureReasonInsufficientCPU,
+		},
+		{
+			message:  "0/3 nodes are available: 3 Insufficient memory.",
+			expected: schedulingFailureReasonInsufficientMemory,
+		},
+		{
+			message:  "0/3 nodes are available: 3 node(s) had untolerated taint {node-role.kubernetes.io/master: }.",
+			expected: schedulingFailureReasonTaints,
+		},
+		{
+			message:  "0/3 nodes are available: 3 node(s) didn't match Pod's node affinity/selector.",
+			expected: schedulingFailureReasonAffinityMismatch,
+		},
+		{
+			message:  "0/3 nodes are available: 3 node(s) didn't match pod anti-affinity rules.",
+			expected: schedulingFailureReasonAffinityMismatch,
+		},
+		{
+			message:  "something else entirely",
+			expected: schedulingFailureReasonUnknown,
+		},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.expected, classifySchedulingFailureMessage(test.message), test.message)
+	}
+}
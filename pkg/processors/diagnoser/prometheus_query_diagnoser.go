@@ -0,0 +1,213 @@
+/*
+Copyright 2022 The KubeDiag Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package diagnoser
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/prometheus/common/model"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+
+	"github.com/kubediag/kubediag/pkg/processors"
+	"github.com/kubediag/kubediag/pkg/processors/utils"
+)
+
+const (
+	ParameterKeyPrometheusQueryEndpoint        = "param.diagnoser.prometheus_query.endpoint"
+	ParameterKeyPrometheusQueryQuery           = "param.diagnoser.prometheus_query.query"
+	ParameterKeyPrometheusQueryTime            = "param.diagnoser.prometheus_query.time"
+	ParameterKeyPrometheusQuerySecretNamespace = "param.diagnoser.prometheus_query.auth.secret_reference.namespace"
+	ParameterKeyPrometheusQuerySecretName      = "param.diagnoser.prometheus_query.auth.secret_reference.name"
+
+	ContextKeyPrometheusQueryResultCount  = "diagnoser.prometheus_query.result.count"
+	ContextKeyPrometheusQueryResultPrefix = "diagnoser.prometheus_query.result."
+)
+
+// prometheusQueryResponse is the envelope of a prometheus http api query response.
+type prometheusQueryResponse struct {
+	// Status is either success or error.
+	Status string `json:"status"`
+	// Data contains the query result.
+	Data prometheusQueryData `json:"data"`
+	// Error is the error message of an erroneous query.
+	Error string `json:"error"`
+}
+
+// prometheusQueryData contains the result of a prometheus query.
+type prometheusQueryData struct {
+	// ResultType is the type of the query result.
+	ResultType string `json:"resultType"`
+	// Result is the raw query result.
+	Result json.RawMessage `json:"result"`
+}
+
+// prometheusQueryDiagnoser runs a promql query against a prometheus endpoint and summarizes the
+// result vector into the result map.
+type prometheusQueryDiagnoser struct {
+	// Context carries values across API boundaries.
+	context.Context
+	// Logger represents the ability to log messages.
+	logr.Logger
+
+	// cache knows how to load Kubernetes objects.
+	cache cache.Cache
+	// prometheusQueryDiagnoserEnabled indicates whether prometheusQueryDiagnoser is enabled.
+	prometheusQueryDiagnoserEnabled bool
+}
+
+// NewPrometheusQueryDiagnoser creates a new prometheusQueryDiagnoser.
+func NewPrometheusQueryDiagnoser(
+	ctx context.Context,
+	logger logr.Logger,
+	cache cache.Cache,
+	prometheusQueryDiagnoserEnabled bool,
+) processors.Processor {
+	return &prometheusQueryDiagnoser{
+		Context:                         ctx,
+		Logger:                          logger,
+		cache:                           cache,
+		prometheusQueryDiagnoserEnabled: prometheusQueryDiagnoserEnabled,
+	}
+}
+
+// Handler handles http requests for running a promql query against a prometheus endpoint.
+func (pq *prometheusQueryDiagnoser) Handler(w http.ResponseWriter, r *http.Request) {
+	if !pq.prometheusQueryDiagnoserEnabled {
+		http.Error(w, fmt.Sprintf("prometheus query diagnoser is not enabled"), http.StatusUnprocessableEntity)
+		return
+	}
+
+	switch r.Method {
+	case "POST":
+		contexts, err := utils.ExtractParametersFromHTTPContext(r)
+		if err != nil {
+			pq.Error(err, "extract contexts failed")
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		endpoint := contexts[ParameterKeyPrometheusQueryEndpoint]
+		query := contexts[ParameterKeyPrometheusQueryQuery]
+		if endpoint == "" || query == "" {
+			http.Error(w, "prometheus endpoint and query must be specified", http.StatusBadRequest)
+			return
+		}
+
+		values := url.Values{}
+		values.Set("query", query)
+		if value := contexts[ParameterKeyPrometheusQueryTime]; value != "" {
+			if _, err := time.Parse(time.RFC3339, value); err != nil {
+				http.Error(w, fmt.Sprintf("invalid query time: %v", err), http.StatusBadRequest)
+				return
+			}
+			values.Set("time", value)
+		}
+
+		// Obtain the bearer token from the referenced secret if specified.
+		var token string
+		if name := contexts[ParameterKeyPrometheusQuerySecretName]; name != "" {
+			secretData, err := GetSecretData(pq.cache, name, contexts[ParameterKeyPrometheusQuerySecretNamespace])
+			if err != nil {
+				http.Error(w, fmt.Sprintf("failed to get secret: %v", err), http.StatusInternalServerError)
+				return
+			}
+			tokenByte, ok := secretData[corev1.ServiceAccountTokenKey]
+			if !ok {
+				http.Error(w, "secret token is not specified", http.StatusInternalServerError)
+				return
+			}
+			token = string(tokenByte)
+		}
+
+		vector, err := pq.runQuery(endpoint, values, token)
+		if err != nil {
+			pq.Error(err, "failed to run prometheus query", "endpoint", endpoint, "query", query)
+			http.Error(w, fmt.Sprintf("failed to run prometheus query: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		result := summarizeQueryResult(vector)
+		data, err := json.Marshal(result)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("failed to marshal result: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(data)
+	default:
+		http.Error(w, fmt.Sprintf("method %s is not supported", r.Method), http.StatusMethodNotAllowed)
+	}
+}
+
+// runQuery runs an instant query against the prometheus http api and returns the result vector.
+func (pq *prometheusQueryDiagnoser) runQuery(endpoint string, values url.Values, token string) (model.Vector, error) {
+	queryURL := strings.TrimRight(endpoint, "/") + "/api/v1/query?" + values.Encode()
+	req, err := http.NewRequest("GET", queryURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	cli := &http.Client{
+		Timeout: processors.DefaultTimeoutSeconds * time.Second,
+	}
+	res, err := cli.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	var response prometheusQueryResponse
+	if err := json.NewDecoder(res.Body).Decode(&response); err != nil {
+		return nil, err
+	}
+	if response.Status != "success" {
+		return nil, fmt.Errorf("query failed with status %s: %s", response.Status, response.Error)
+	}
+	if response.Data.ResultType != "vector" {
+		return nil, fmt.Errorf("result type %s is not supported", response.Data.ResultType)
+	}
+
+	var vector model.Vector
+	if err := json.Unmarshal(response.Data.Result, &vector); err != nil {
+		return nil, err
+	}
+
+	return vector, nil
+}
+
+// summarizeQueryResult summarizes a result vector into the result map with one key per sample.
+func summarizeQueryResult(vector model.Vector) map[string]string {
+	result := make(map[string]string)
+	result[ContextKeyPrometheusQueryResultCount] = fmt.Sprintf("%d", len(vector))
+	for _, sample := range vector {
+		result[ContextKeyPrometheusQueryResultPrefix+sample.Metric.String()] = sample.Value.String()
+	}
+
+	return result
+}
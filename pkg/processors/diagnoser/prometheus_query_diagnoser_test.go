@@ -0,0 +1,160 @@
+/*
+Copyright 2022 The KubeDiag Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package diagnoser
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// fakeCache is a cache.Cache backed by a fake client for testing.
+type fakeCache struct {
+	client.Reader
+}
+
+func (f fakeCache) GetInformer(obj runtime.Object) (cache.Informer, error) {
+	return nil, nil
+}
+
+func (f fakeCache) GetInformerForKind(gvk schema.GroupVersionKind) (cache.Informer, error) {
+	return nil, nil
+}
+
+func (f fakeCache) Start(stopCh <-chan struct{}) error {
+	return nil
+}
+
+func (f fakeCache) WaitForCacheSync(stop <-chan struct{}) bool {
+	return true
+}
+
+func (f fakeCache) IndexField(obj runtime.Object, field string, extractValue client.IndexerFunc) error {
+	return nil
+}
+
+func TestPrometheusQueryDiagnoserHandler(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "prometheus-token",
+			Namespace: "kubediag",
+		},
+		Data: map[string][]byte{
+			corev1.ServiceAccountTokenKey: []byte("token1"),
+		},
+	}
+	cli := fake.NewFakeClientWithScheme(scheme, secret)
+
+	// Serve a result vector on the prometheus query api.
+	var queryRequest *http.Request
+	prometheus := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/query" {
+			http.NotFound(w, r)
+			return
+		}
+		queryRequest = r
+		w.Write([]byte(`{"status":"success","data":{"resultType":"vector","result":[{"metric":{"__name__":"up","job":"node"},"value":[1660000000,"1"]},{"metric":{"__name__":"up","job":"kubelet"},"value":[1660000000,"0"]}]}}`))
+	}))
+	defer prometheus.Close()
+
+	pq := &prometheusQueryDiagnoser{
+		Context:                         context.Background(),
+		Logger:                          ctrl.Log.WithName("diagnoser/prometheusQueryDiagnoser"),
+		cache:                           fakeCache{Reader: cli},
+		prometheusQueryDiagnoserEnabled: true,
+	}
+
+	body, err := json.Marshal(map[string]string{
+		ParameterKeyPrometheusQueryEndpoint:        prometheus.URL,
+		ParameterKeyPrometheusQueryQuery:           "up",
+		ParameterKeyPrometheusQueryTime:            "2022-08-09T00:00:00Z",
+		ParameterKeyPrometheusQuerySecretNamespace: "kubediag",
+		ParameterKeyPrometheusQuerySecretName:      "prometheus-token",
+	})
+	assert.NoError(t, err)
+	req := httptest.NewRequest("POST", "/processor/prometheusQueryDiagnoser", bytes.NewBuffer(body))
+	recorder := httptest.NewRecorder()
+	pq.Handler(recorder, req)
+	assert.Equal(t, 200, recorder.Code)
+
+	// The query, time and bearer token are passed to the prometheus api.
+	if assert.NotNil(t, queryRequest) {
+		assert.Equal(t, "up", queryRequest.URL.Query().Get("query"))
+		assert.Equal(t, "2022-08-09T00:00:00Z", queryRequest.URL.Query().Get("time"))
+		assert.Equal(t, "Bearer token1", queryRequest.Header.Get("Authorization"))
+	}
+
+	// The result vector is summarized into the result map with one key per sample.
+	result := make(map[string]string)
+	err = json.Unmarshal(recorder.Body.Bytes(), &result)
+	assert.NoError(t, err)
+	assert.Equal(t, "2", result[ContextKeyPrometheusQueryResultCount])
+	assert.Equal(t, "1", result[ContextKeyPrometheusQueryResultPrefix+`up{job="node"}`])
+	assert.Equal(t, "0", result[ContextKeyPrometheusQueryResultPrefix+`up{job="kubelet"}`])
+
+	// The endpoint and query must be specified.
+	req = httptest.NewRequest("POST", "/processor/prometheusQueryDiagnoser", bytes.NewBufferString("{}"))
+	recorder = httptest.NewRecorder()
+	pq.Handler(recorder, req)
+	assert.Equal(t, 400, recorder.Code)
+
+	// Only POST requests are supported.
+	req = httptest.NewRequest("GET", "/processor/prometheusQueryDiagnoser", nil)
+	recorder = httptest.NewRecorder()
+	pq.Handler(recorder, req)
+	assert.Equal(t, 405, recorder.Code)
+}
+
+func TestPrometheusQueryDiagnoserHandlerQueryError(t *testing.T) {
+	prometheus := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"status":"error","error":"invalid query"}`))
+	}))
+	defer prometheus.Close()
+
+	pq := &prometheusQueryDiagnoser{
+		Context:                         context.Background(),
+		Logger:                          ctrl.Log.WithName("diagnoser/prometheusQueryDiagnoser"),
+		prometheusQueryDiagnoserEnabled: true,
+	}
+
+	body, err := json.Marshal(map[string]string{
+		ParameterKeyPrometheusQueryEndpoint: prometheus.URL,
+		ParameterKeyPrometheusQueryQuery:    "up{",
+	})
+	assert.NoError(t, err)
+	req := httptest.NewRequest("POST", "/processor/prometheusQueryDiagnoser", bytes.NewBuffer(body))
+	recorder := httptest.NewRecorder()
+	pq.Handler(recorder, req)
+	assert.Equal(t, 500, recorder.Code)
+}
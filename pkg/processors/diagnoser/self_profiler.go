@@ -0,0 +1,155 @@
+/*
+Copyright 2022 The KubeDiag Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package diagnoser
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+
+	"github.com/kubediag/kubediag/pkg/processors"
+	"github.com/kubediag/kubediag/pkg/processors/utils"
+)
+
+const (
+	// ParameterKeySelfProfilerTypes is a comma separated list of profile types to capture.
+	// Defaults to heap and goroutine profiles if not specified.
+	ParameterKeySelfProfilerTypes = "param.diagnoser.runtime.self_profiler.types"
+
+	// ContextKeySelfProfilerResultPathPrefix is the prefix of keys to store paths of captured profiles.
+	ContextKeySelfProfilerResultPathPrefix = "diagnoser.runtime.self_profiler.result."
+)
+
+const (
+	// heapSelfProfilerType means that a heap profile of kubediag is captured.
+	heapSelfProfilerType = "heap"
+	// goroutineSelfProfilerType means that a goroutine profile of kubediag is captured.
+	goroutineSelfProfilerType = "goroutine"
+)
+
+// selfProfiler captures pprof profiles of the kubediag agent itself.
+type selfProfiler struct {
+	// Context carries values across API boundaries.
+	context.Context
+	// Logger represents the ability to log messages.
+	logr.Logger
+	// dataRoot is root directory of persistent kubediag data.
+	dataRoot string
+	// bindAddress is the address on which kubediag serves its own pprof endpoints.
+	bindAddress string
+	// selfProfilerEnabled indicates whether selfProfiler is enabled.
+	selfProfilerEnabled bool
+}
+
+// NewSelfProfiler creates a new selfProfiler.
+func NewSelfProfiler(
+	ctx context.Context,
+	logger logr.Logger,
+	dataRoot string,
+	bindAddress string,
+	selfProfilerEnabled bool,
+) processors.Processor {
+	return &selfProfiler{
+		Context:             ctx,
+		Logger:              logger,
+		dataRoot:            dataRoot,
+		bindAddress:         bindAddress,
+		selfProfilerEnabled: selfProfilerEnabled,
+	}
+}
+
+// Handler handles http requests for capturing profiles of kubediag itself.
+func (sp *selfProfiler) Handler(w http.ResponseWriter, r *http.Request) {
+	if !sp.selfProfilerEnabled {
+		http.Error(w, fmt.Sprintf("self profiler is not enabled"), http.StatusUnprocessableEntity)
+		return
+	}
+
+	switch r.Method {
+	case "POST":
+		contexts, err := utils.ExtractParametersFromHTTPContext(r)
+		if err != nil {
+			sp.Error(err, "extract contexts failed")
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		profilerTypes := []string{heapSelfProfilerType, goroutineSelfProfilerType}
+		if value, ok := contexts[ParameterKeySelfProfilerTypes]; ok && value != "" {
+			profilerTypes = strings.Split(value, ",")
+		}
+		for _, profilerType := range profilerTypes {
+			if profilerType != heapSelfProfilerType && profilerType != goroutineSelfProfilerType {
+				http.Error(w, fmt.Sprintf("Self profiler type must be heap or goroutine."), http.StatusNotAcceptable)
+				return
+			}
+		}
+
+		paths, err := sp.captureProfiles(profilerTypes)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("failed to capture profiles of kubediag: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		result := make(map[string]string)
+		for profilerType, path := range paths {
+			result[ContextKeySelfProfilerResultPathPrefix+profilerType] = path
+		}
+		data, err := json.Marshal(result)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("failed to marshal self profiler results: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(data)
+	default:
+		http.Error(w, fmt.Sprintf("method %s is not supported", r.Method), http.StatusMethodNotAllowed)
+	}
+}
+
+// captureProfiles downloads profiles of specified types from local pprof endpoints and stores them
+// under kubediag data root. It returns a map from profile type to the path of stored profile file.
+func (sp *selfProfiler) captureProfiles(profilerTypes []string) (map[string]string, error) {
+	now := time.Now().Format("20060102150405")
+	datadir := filepath.Join(sp.dataRoot, "profilers/self/pprof", now)
+	if _, err := os.Stat(datadir); os.IsNotExist(err) {
+		if err := os.MkdirAll(datadir, os.ModePerm); err != nil {
+			return nil, err
+		}
+	}
+
+	paths := make(map[string]string)
+	for _, profilerType := range profilerTypes {
+		source := fmt.Sprintf("http://%s%s%s", sp.bindAddress, goProfilerPathPrefix, profilerType)
+		datafile := filepath.Join(datadir, fmt.Sprintf("kubediag.%s.prof", profilerType))
+		if err := DownloadProfileFile(datafile, source, nil, nil, 10*time.Second); err != nil {
+			return nil, fmt.Errorf("download file failed with error: %s", err)
+		}
+		sp.Info("Save self profiler file successfully.", "path", datafile)
+		paths[profilerType] = datafile
+	}
+
+	return paths, nil
+}
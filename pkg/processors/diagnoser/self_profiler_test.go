@@ -0,0 +1,71 @@
+/*
+Copyright 2022 The KubeDiag Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package diagnoser
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/http/pprof"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+func TestSelfProfilerHandler(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	dataRoot, err := ioutil.TempDir("", "selfprofiler")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dataRoot)
+
+	sp := &selfProfiler{
+		Context:             context.Background(),
+		Logger:              ctrl.Log.WithName("processor/selfProfiler"),
+		dataRoot:            dataRoot,
+		bindAddress:         strings.TrimPrefix(server.URL, "http://"),
+		selfProfilerEnabled: true,
+	}
+
+	body, err := json.Marshal(map[string]string{
+		ParameterKeySelfProfilerTypes: "heap",
+	})
+	assert.NoError(t, err)
+	req := httptest.NewRequest("POST", "/processor/selfProfiler", bytes.NewBuffer(body))
+	recorder := httptest.NewRecorder()
+	sp.Handler(recorder, req)
+
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	var result map[string]string
+	err = json.Unmarshal(recorder.Body.Bytes(), &result)
+	assert.NoError(t, err)
+	path, ok := result[ContextKeySelfProfilerResultPathPrefix+"heap"]
+	assert.True(t, ok)
+
+	info, err := os.Stat(path)
+	assert.NoError(t, err)
+	assert.True(t, info.Size() > 0)
+}
@@ -0,0 +1,221 @@
+/*
+Copyright 2022 The KubeDiag Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package diagnoser
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/go-logr/logr"
+	appsv1 "k8s.io/api/apps/v1"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+
+	"github.com/kubediag/kubediag/pkg/processors"
+	"github.com/kubediag/kubediag/pkg/processors/utils"
+	"github.com/kubediag/kubediag/pkg/types"
+)
+
+const (
+	// ParameterKeyWorkloadReplicaMinimumFraction is the minimum acceptable fraction of
+	// available replicas divided by desired replicas. Workloads below the fraction are
+	// reported as degraded. Defaults to 1 if not specified.
+	ParameterKeyWorkloadReplicaMinimumFraction = "param.diagnoser.kubernetes.workload_replica.minimum_fraction"
+
+	ContextKeyWorkloadReplicaDegraded      = "diagnoser.kubernetes.workload_replica.degraded"
+	ContextKeyWorkloadReplicaDegradedCount = "diagnoser.kubernetes.workload_replica.degraded_count"
+)
+
+// degradedWorkload describes a workload whose available replicas lag its desired replicas.
+type degradedWorkload struct {
+	// Kind is the kind of the workload. It is one of Deployment, StatefulSet or DaemonSet.
+	Kind string `json:"kind"`
+	// Namespace is the namespace of the workload.
+	Namespace string `json:"namespace"`
+	// Name is the name of the workload.
+	Name string `json:"name"`
+	// DesiredReplicas is the number of desired replicas of the workload.
+	DesiredReplicas int32 `json:"desiredReplicas"`
+	// AvailableReplicas is the number of available or ready replicas of the workload.
+	AvailableReplicas int32 `json:"availableReplicas"`
+	// AvailableFraction is the fraction of available replicas divided by desired replicas.
+	AvailableFraction float64 `json:"availableFraction"`
+}
+
+// workloadReplicaDiagnoser reports workloads whose available replicas lag desired replicas
+// beyond a threshold.
+type workloadReplicaDiagnoser struct {
+	// Context carries values across API boundaries.
+	context.Context
+	// Logger represents the ability to log messages.
+	logr.Logger
+
+	// cache knows how to load Kubernetes objects.
+	cache cache.Cache
+	// workloadReplicaDiagnoserEnabled indicates whether workloadReplicaDiagnoser is enabled.
+	workloadReplicaDiagnoserEnabled bool
+}
+
+// NewWorkloadReplicaDiagnoser creates a new workloadReplicaDiagnoser.
+func NewWorkloadReplicaDiagnoser(
+	ctx context.Context,
+	logger logr.Logger,
+	cache cache.Cache,
+	workloadReplicaDiagnoserEnabled bool,
+) processors.Processor {
+	return &workloadReplicaDiagnoser{
+		Context:                         ctx,
+		Logger:                          logger,
+		cache:                           cache,
+		workloadReplicaDiagnoserEnabled: workloadReplicaDiagnoserEnabled,
+	}
+}
+
+// Handler handles http requests for diagnosing degraded workloads.
+func (wd *workloadReplicaDiagnoser) Handler(w http.ResponseWriter, r *http.Request) {
+	if !wd.workloadReplicaDiagnoserEnabled {
+		http.Error(w, fmt.Sprintf("workload replica diagnoser is not enabled"), http.StatusUnprocessableEntity)
+		return
+	}
+
+	switch r.Method {
+	case "POST":
+		contexts, err := utils.ExtractParametersFromHTTPContext(r)
+		if err != nil {
+			wd.Error(err, "extract contexts failed")
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		minimumFraction := types.FourQuarters
+		if contexts[ParameterKeyWorkloadReplicaMinimumFraction] != "" {
+			minimumFraction, err = strconv.ParseFloat(contexts[ParameterKeyWorkloadReplicaMinimumFraction], 64)
+			if err != nil || minimumFraction <= 0 || minimumFraction > 1 {
+				http.Error(w, fmt.Sprintf("invalid minimum fraction %s: must be a float greater than 0 and less equal to 1", contexts[ParameterKeyWorkloadReplicaMinimumFraction]), http.StatusInternalServerError)
+				return
+			}
+		}
+
+		var deploymentList appsv1.DeploymentList
+		if err := wd.cache.List(wd, &deploymentList); err != nil {
+			http.Error(w, fmt.Sprintf("failed to list deployments: %v", err), http.StatusInternalServerError)
+			return
+		}
+		var statefulSetList appsv1.StatefulSetList
+		if err := wd.cache.List(wd, &statefulSetList); err != nil {
+			http.Error(w, fmt.Sprintf("failed to list statefulsets: %v", err), http.StatusInternalServerError)
+			return
+		}
+		var daemonSetList appsv1.DaemonSetList
+		if err := wd.cache.List(wd, &daemonSetList); err != nil {
+			http.Error(w, fmt.Sprintf("failed to list daemonsets: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		degraded := collectDegradedWorkloads(deploymentList.Items, statefulSetList.Items, daemonSetList.Items, minimumFraction)
+		raw, err := json.Marshal(degraded)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("failed to marshal degraded workloads: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		result := make(map[string]string)
+		result[ContextKeyWorkloadReplicaDegraded] = string(raw)
+		result[ContextKeyWorkloadReplicaDegradedCount] = strconv.Itoa(len(degraded))
+		data, err := json.Marshal(result)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("failed to marshal result: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(data)
+	default:
+		http.Error(w, fmt.Sprintf("method %s is not supported", r.Method), http.StatusMethodNotAllowed)
+	}
+}
+
+// collectDegradedWorkloads collects workloads whose fraction of available replicas divided by
+// desired replicas is below the minimum fraction.
+func collectDegradedWorkloads(deployments []appsv1.Deployment, statefulSets []appsv1.StatefulSet, daemonSets []appsv1.DaemonSet, minimumFraction float64) []degradedWorkload {
+	degraded := make([]degradedWorkload, 0)
+
+	for _, deployment := range deployments {
+		desired := int32(1)
+		if deployment.Spec.Replicas != nil {
+			desired = *deployment.Spec.Replicas
+		}
+		fraction := replicaFraction(deployment.Status.AvailableReplicas, desired)
+		if fraction < minimumFraction {
+			degraded = append(degraded, degradedWorkload{
+				Kind:              "Deployment",
+				Namespace:         deployment.Namespace,
+				Name:              deployment.Name,
+				DesiredReplicas:   desired,
+				AvailableReplicas: deployment.Status.AvailableReplicas,
+				AvailableFraction: fraction,
+			})
+		}
+	}
+	for _, statefulSet := range statefulSets {
+		desired := int32(1)
+		if statefulSet.Spec.Replicas != nil {
+			desired = *statefulSet.Spec.Replicas
+		}
+		fraction := replicaFraction(statefulSet.Status.ReadyReplicas, desired)
+		if fraction < minimumFraction {
+			degraded = append(degraded, degradedWorkload{
+				Kind:              "StatefulSet",
+				Namespace:         statefulSet.Namespace,
+				Name:              statefulSet.Name,
+				DesiredReplicas:   desired,
+				AvailableReplicas: statefulSet.Status.ReadyReplicas,
+				AvailableFraction: fraction,
+			})
+		}
+	}
+	for _, daemonSet := range daemonSets {
+		available := daemonSet.Status.NumberAvailable
+		if daemonSet.Status.CurrentNumberScheduled < available {
+			available = daemonSet.Status.CurrentNumberScheduled
+		}
+		fraction := replicaFraction(available, daemonSet.Status.DesiredNumberScheduled)
+		if fraction < minimumFraction {
+			degraded = append(degraded, degradedWorkload{
+				Kind:              "DaemonSet",
+				Namespace:         daemonSet.Namespace,
+				Name:              daemonSet.Name,
+				DesiredReplicas:   daemonSet.Status.DesiredNumberScheduled,
+				AvailableReplicas: available,
+				AvailableFraction: fraction,
+			})
+		}
+	}
+
+	return degraded
+}
+
+// replicaFraction calculates the fraction of available replicas divided by desired replicas.
+func replicaFraction(available int32, desired int32) float64 {
+	if desired == 0 {
+		return types.FourQuarters
+	}
+
+	return float64(available) / float64(desired)
+}
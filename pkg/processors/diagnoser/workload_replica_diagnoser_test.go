@@ -0,0 +1,147 @@
+/*
+Copyright 2022 The KubeDiag Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package diagnoser
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestWorkloadReplicaDiagnoserHandler(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+
+	degradedReplicas := int32(3)
+	healthyReplicas := int32(2)
+	workloads := []runtime.Object{
+		&appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "deployment1",
+				Namespace: "default",
+			},
+			Spec: appsv1.DeploymentSpec{
+				Replicas: &degradedReplicas,
+			},
+			Status: appsv1.DeploymentStatus{
+				AvailableReplicas: 1,
+			},
+		},
+		&appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "deployment2",
+				Namespace: "default",
+			},
+			Spec: appsv1.DeploymentSpec{
+				Replicas: &healthyReplicas,
+			},
+			Status: appsv1.DeploymentStatus{
+				AvailableReplicas: 2,
+			},
+		},
+		&appsv1.StatefulSet{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "statefulset1",
+				Namespace: "default",
+			},
+			Spec: appsv1.StatefulSetSpec{
+				Replicas: &healthyReplicas,
+			},
+			Status: appsv1.StatefulSetStatus{
+				ReadyReplicas: 2,
+			},
+		},
+		&appsv1.DaemonSet{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "daemonset1",
+				Namespace: "default",
+			},
+			Status: appsv1.DaemonSetStatus{
+				DesiredNumberScheduled: 3,
+				CurrentNumberScheduled: 3,
+				NumberAvailable:        2,
+			},
+		},
+	}
+
+	cli := fake.NewFakeClientWithScheme(scheme, workloads...)
+	wd := &workloadReplicaDiagnoser{
+		Context:                         context.Background(),
+		Logger:                          ctrl.Log.WithName("diagnoser/workloadReplicaDiagnoser"),
+		cache:                           fakeCache{Reader: cli},
+		workloadReplicaDiagnoserEnabled: true,
+	}
+
+	body, err := json.Marshal(map[string]string{})
+	assert.NoError(t, err)
+	recorder := httptest.NewRecorder()
+	wd.Handler(recorder, httptest.NewRequest("POST", "/processor/workloadReplicaDiagnoser", bytes.NewReader(body)))
+	assert.Equal(t, 200, recorder.Code)
+
+	result := make(map[string]string)
+	err = json.Unmarshal(recorder.Body.Bytes(), &result)
+	assert.NoError(t, err)
+	assert.Equal(t, "2", result[ContextKeyWorkloadReplicaDegradedCount])
+
+	var degraded []degradedWorkload
+	err = json.Unmarshal([]byte(result[ContextKeyWorkloadReplicaDegraded]), &degraded)
+	assert.NoError(t, err)
+	if assert.Equal(t, 2, len(degraded)) {
+		assert.Equal(t, "Deployment", degraded[0].Kind)
+		assert.Equal(t, "deployment1", degraded[0].Name)
+		assert.Equal(t, int32(3), degraded[0].DesiredReplicas)
+		assert.Equal(t, int32(1), degraded[0].AvailableReplicas)
+		assert.Equal(t, "DaemonSet", degraded[1].Kind)
+		assert.Equal(t, "daemonset1", degraded[1].Name)
+	}
+
+	// A lower minimum fraction tolerates the partially available daemonset.
+	body, err = json.Marshal(map[string]string{
+		ParameterKeyWorkloadReplicaMinimumFraction: "0.5",
+	})
+	assert.NoError(t, err)
+	recorder = httptest.NewRecorder()
+	wd.Handler(recorder, httptest.NewRequest("POST", "/processor/workloadReplicaDiagnoser", bytes.NewReader(body)))
+	assert.Equal(t, 200, recorder.Code)
+	result = make(map[string]string)
+	err = json.Unmarshal(recorder.Body.Bytes(), &result)
+	assert.NoError(t, err)
+	assert.Equal(t, "1", result[ContextKeyWorkloadReplicaDegradedCount])
+
+	// An invalid minimum fraction is rejected.
+	body, err = json.Marshal(map[string]string{
+		ParameterKeyWorkloadReplicaMinimumFraction: "two",
+	})
+	assert.NoError(t, err)
+	recorder = httptest.NewRecorder()
+	wd.Handler(recorder, httptest.NewRequest("POST", "/processor/workloadReplicaDiagnoser", bytes.NewReader(body)))
+	assert.Equal(t, 500, recorder.Code)
+
+	recorder = httptest.NewRecorder()
+	wd.Handler(recorder, httptest.NewRequest("GET", "/processor/workloadReplicaDiagnoser", nil))
+	assert.Equal(t, 405, recorder.Code)
+}
@@ -29,8 +29,34 @@ const (
 
 	// DefaultExpirationSeconds is the default seconds of profiler endpoint expires.
 	DefaultExpirationSeconds = 7200
+
+	// DefaultMinExpirationSeconds is the default minimum seconds of profiler endpoint expires.
+	DefaultMinExpirationSeconds = 60
+
+	// DefaultMaxExpirationSeconds is the default maximum seconds of profiler endpoint expires.
+	DefaultMaxExpirationSeconds = 86400
 )
 
+// ClampExpirationSeconds clamps a requested profiler endpoint expiration to the configured
+// bounds. A non positive bound falls back to its default.
+func ClampExpirationSeconds(expirationSeconds int, minExpirationSeconds int, maxExpirationSeconds int) int {
+	if minExpirationSeconds <= 0 {
+		minExpirationSeconds = DefaultMinExpirationSeconds
+	}
+	if maxExpirationSeconds <= 0 {
+		maxExpirationSeconds = DefaultMaxExpirationSeconds
+	}
+
+	if expirationSeconds < minExpirationSeconds {
+		return minExpirationSeconds
+	}
+	if expirationSeconds > maxExpirationSeconds {
+		return maxExpirationSeconds
+	}
+
+	return expirationSeconds
+}
+
 // Processor manages http requests for processing diagnoses.
 type Processor interface {
 	// Context carries values across API boundaries.
@@ -0,0 +1,73 @@
+/*
+Copyright 2022 The KubeDiag Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package processors
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClampExpirationSeconds(t *testing.T) {
+	tests := []struct {
+		expirationSeconds    int
+		minExpirationSeconds int
+		maxExpirationSeconds int
+		expected             int
+		message              string
+	}{
+		{
+			expirationSeconds:    7200,
+			minExpirationSeconds: 60,
+			maxExpirationSeconds: 86400,
+			expected:             7200,
+			message:              "expiration within bounds is unchanged",
+		},
+		{
+			expirationSeconds:    0,
+			minExpirationSeconds: 60,
+			maxExpirationSeconds: 86400,
+			expected:             60,
+			message:              "expiration below the minimum is clamped to it",
+		},
+		{
+			expirationSeconds:    2592000,
+			minExpirationSeconds: 60,
+			maxExpirationSeconds: 86400,
+			expected:             86400,
+			message:              "expiration above the maximum is clamped to it",
+		},
+		{
+			expirationSeconds:    30,
+			minExpirationSeconds: 0,
+			maxExpirationSeconds: 0,
+			expected:             DefaultMinExpirationSeconds,
+			message:              "non positive bounds fall back to defaults",
+		},
+		{
+			expirationSeconds:    2592000,
+			minExpirationSeconds: 0,
+			maxExpirationSeconds: 0,
+			expected:             DefaultMaxExpirationSeconds,
+			message:              "non positive bounds fall back to defaults",
+		},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.expected, ClampExpirationSeconds(test.expirationSeconds, test.minExpirationSeconds, test.maxExpirationSeconds), test.message)
+	}
+}
@@ -0,0 +1,225 @@
+/*
+Copyright 2022 The KubeDiag Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package recoverer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/kubediag/kubediag/pkg/processors"
+	"github.com/kubediag/kubediag/pkg/processors/utils"
+)
+
+const (
+	NodeDrainNodeName                  = "param.recoverer.kubernetes.node_drain.name"
+	NodeDrainDrain                     = "param.recoverer.kubernetes.node_drain.drain"
+	NodeDrainDeletionGracePeriod       = "param.recoverer.kubernetes.node_drain.deletionGracePeriodSeconds"
+	NodeDrainIgnoreDaemonSets          = "param.recoverer.kubernetes.node_drain.ignoreDaemonSets"
+	NodeDrainTimeoutSeconds            = "param.recoverer.kubernetes.node_drain.timeoutSeconds"
+	ContextKeyNodeDrainResultName      = "recover.kubernetes.node_drain.result.name"
+	ContextKeyNodeDrainDeletedPods     = "recover.kubernetes.node_drain.result.deletedPods"
+	ContextKeyNodeDrainSkippedPods     = "recover.kubernetes.node_drain.result.skippedPods"
+	ContextKeyNodeDrainFailedDeletions = "recover.kubernetes.node_drain.result.failedDeletions"
+
+	// defaultNodeDrainTimeoutSeconds is the default timeout of a drain.
+	defaultNodeDrainTimeoutSeconds = 30
+)
+
+// nodeDrain cordons a node and optionally evicts the pods running on it.
+type nodeDrain struct {
+	// Context carries values across API boundaries.
+	context.Context
+	// Logger represents the ability to log messages.
+	logr.Logger
+
+	// client knows how to perform CRUD operations on Kubernetes objects.
+	client client.Client
+	// nodeName specifies the node name.
+	nodeName string
+	// nodeDrainEnabled indicates whether nodeDrain is enabled.
+	nodeDrainEnabled bool
+}
+
+// NewNodeDrain creates a new nodeDrain.
+func NewNodeDrain(
+	ctx context.Context,
+	logger logr.Logger,
+	client client.Client,
+	nodeName string,
+	nodeDrainEnabled bool,
+) processors.Processor {
+	return &nodeDrain{
+		Context:          ctx,
+		Logger:           logger,
+		client:           client,
+		nodeName:         nodeName,
+		nodeDrainEnabled: nodeDrainEnabled,
+	}
+}
+
+// Handler handles http requests for cordoning and draining a node.
+func (nd *nodeDrain) Handler(w http.ResponseWriter, r *http.Request) {
+	if !nd.nodeDrainEnabled {
+		http.Error(w, fmt.Sprintf("node drain is not enabled"), http.StatusUnprocessableEntity)
+		return
+	}
+
+	switch r.Method {
+	case "POST":
+		contexts, err := utils.ExtractParametersFromHTTPContext(r)
+		if err != nil {
+			nd.Error(err, "extract contexts failed")
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		name := contexts[NodeDrainNodeName]
+		if name == "" {
+			name = nd.nodeName
+		}
+		gracePeriodSeconds := int64(-1)
+		if value, ok := contexts[NodeDrainDeletionGracePeriod]; ok && value != "" {
+			gracePeriodSeconds, err = strconv.ParseInt(value, 10, 64)
+			if err != nil || gracePeriodSeconds < 0 {
+				http.Error(w, fmt.Sprintf("invalid deletion grace period %s.", value), http.StatusBadRequest)
+				return
+			}
+		}
+		timeoutSeconds := int64(defaultNodeDrainTimeoutSeconds)
+		if value, ok := contexts[NodeDrainTimeoutSeconds]; ok && value != "" {
+			timeoutSeconds, err = strconv.ParseInt(value, 10, 64)
+			if err != nil || timeoutSeconds <= 0 {
+				http.Error(w, fmt.Sprintf("invalid timeout %s.", value), http.StatusBadRequest)
+				return
+			}
+		}
+		ignoreDaemonSets := contexts[NodeDrainIgnoreDaemonSets] == "true"
+
+		var node corev1.Node
+		if err := nd.client.Get(nd, client.ObjectKey{Name: name}, &node); err != nil {
+			http.Error(w, fmt.Sprintf("unable to fetch Node: %v", err), http.StatusUnprocessableEntity)
+			return
+		}
+
+		// Cordon the node before evicting any pod.
+		if !node.Spec.Unschedulable {
+			node.Spec.Unschedulable = true
+			if err := nd.client.Update(nd, &node); err != nil {
+				http.Error(w, fmt.Sprintf("unable to update Node: %v", err), http.StatusInternalServerError)
+				return
+			}
+		}
+		nd.Info("node cordoned", "node", name)
+
+		result := make(map[string]string)
+		result[ContextKeyNodeDrainResultName] = name
+
+		if contexts[NodeDrainDrain] == "true" {
+			deleted, skipped, failed := nd.drainNode(name, gracePeriodSeconds, ignoreDaemonSets, time.Duration(timeoutSeconds)*time.Second)
+			result[ContextKeyNodeDrainDeletedPods] = strings.Join(deleted, ",")
+			result[ContextKeyNodeDrainSkippedPods] = strings.Join(skipped, ",")
+			result[ContextKeyNodeDrainFailedDeletions] = strings.Join(failed, ",")
+		}
+
+		data, err := json.Marshal(result)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("failed to marshal result: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(data)
+	default:
+		http.Error(w, fmt.Sprintf("method %s is not supported", r.Method), http.StatusMethodNotAllowed)
+	}
+}
+
+// drainNode deletes the pods running on the node on a best effort basis, bounded by the
+// timeout. Mirror pods of static pods are always skipped and pods managed by daemonsets are
+// skipped if ignoreDaemonSets is set. Pods not processed before the timeout elapses are
+// skipped as well. It returns the deleted, skipped and failed pods.
+func (nd *nodeDrain) drainNode(name string, gracePeriodSeconds int64, ignoreDaemonSets bool, timeout time.Duration) ([]string, []string, []string) {
+	var podList corev1.PodList
+	if err := nd.client.List(nd, &podList); err != nil {
+		nd.Error(err, "unable to list Pods", "node", name)
+		return nil, nil, nil
+	}
+
+	deadline := time.Now().Add(timeout)
+	timedOut := false
+	deleted := make([]string, 0)
+	skipped := make([]string, 0)
+	failed := make([]string, 0)
+	for i := range podList.Items {
+		pod := podList.Items[i]
+		if pod.Spec.NodeName != name {
+			continue
+		}
+		if _, ok := pod.Annotations[mirrorPodAnnotation]; ok {
+			skipped = append(skipped, fmt.Sprintf("%s/%s", pod.Namespace, pod.Name))
+			continue
+		}
+		if ignoreDaemonSets && isDaemonSetPod(pod) {
+			skipped = append(skipped, fmt.Sprintf("%s/%s", pod.Namespace, pod.Name))
+			continue
+		}
+		if time.Now().After(deadline) {
+			if !timedOut {
+				nd.Info("drain timeout exceeded, skipping remaining pods", "node", name, "timeout", timeout)
+				timedOut = true
+			}
+			skipped = append(skipped, fmt.Sprintf("%s/%s", pod.Namespace, pod.Name))
+			continue
+		}
+
+		deleteOptions := make([]client.DeleteOption, 0)
+		if gracePeriodSeconds >= 0 {
+			deleteOptions = append(deleteOptions, client.GracePeriodSeconds(gracePeriodSeconds))
+		}
+		if err := nd.client.Delete(nd, &pod, deleteOptions...); err != nil {
+			nd.Error(err, "unable to delete Pod", "pod", client.ObjectKey{
+				Name:      pod.Name,
+				Namespace: pod.Namespace,
+			})
+			failed = append(failed, fmt.Sprintf("%s/%s", pod.Namespace, pod.Name))
+			continue
+		}
+		deleted = append(deleted, fmt.Sprintf("%s/%s", pod.Namespace, pod.Name))
+	}
+
+	return deleted, skipped, failed
+}
+
+// isDaemonSetPod reports whether the pod is managed by a daemonset.
+func isDaemonSetPod(pod corev1.Pod) bool {
+	for _, owner := range pod.OwnerReferences {
+		if owner.Kind == "DaemonSet" {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,177 @@
+/*
+Copyright 2022 The KubeDiag Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package recoverer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestNodeDrainHandler(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+
+	node := &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "node1",
+		},
+	}
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "pod1",
+			Namespace: "default",
+		},
+		Spec: corev1.PodSpec{
+			NodeName: "node1",
+		},
+	}
+	daemonSetPod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "fluentd-abcde",
+			Namespace: "kube-system",
+			OwnerReferences: []metav1.OwnerReference{
+				{
+					APIVersion: "apps/v1",
+					Kind:       "DaemonSet",
+					Name:       "fluentd",
+				},
+			},
+		},
+		Spec: corev1.PodSpec{
+			NodeName: "node1",
+		},
+	}
+
+	cli := fake.NewFakeClientWithScheme(scheme, node, pod, daemonSetPod)
+	nd := &nodeDrain{
+		Context:          context.Background(),
+		Logger:           ctrl.Log.WithName("recoverer/nodeDrain"),
+		client:           cli,
+		nodeName:         "node1",
+		nodeDrainEnabled: true,
+	}
+
+	body, err := json.Marshal(map[string]string{
+		NodeDrainDrain:               "true",
+		NodeDrainDeletionGracePeriod: "30",
+		NodeDrainIgnoreDaemonSets:    "true",
+	})
+	assert.NoError(t, err)
+	req := httptest.NewRequest("POST", "/processor/nodeDrain", bytes.NewBuffer(body))
+	recorder := httptest.NewRecorder()
+	nd.Handler(recorder, req)
+	assert.Equal(t, 200, recorder.Code)
+
+	result := make(map[string]string)
+	err = json.Unmarshal(recorder.Body.Bytes(), &result)
+	assert.NoError(t, err)
+	assert.Equal(t, "node1", result[ContextKeyNodeDrainResultName])
+	assert.Equal(t, "default/pod1", result[ContextKeyNodeDrainDeletedPods])
+	assert.Equal(t, "kube-system/fluentd-abcde", result[ContextKeyNodeDrainSkippedPods])
+
+	// The node is cordoned and the pod is deleted while the daemonset pod is retained.
+	var updated corev1.Node
+	err = cli.Get(context.Background(), client.ObjectKey{Name: "node1"}, &updated)
+	assert.NoError(t, err)
+	assert.True(t, updated.Spec.Unschedulable)
+	var deleted corev1.Pod
+	err = cli.Get(context.Background(), client.ObjectKey{Name: "pod1", Namespace: "default"}, &deleted)
+	assert.True(t, apierrors.IsNotFound(err))
+	var retained corev1.Pod
+	err = cli.Get(context.Background(), client.ObjectKey{Name: "fluentd-abcde", Namespace: "kube-system"}, &retained)
+	assert.NoError(t, err)
+
+	// An invalid deletion grace period is rejected.
+	body, err = json.Marshal(map[string]string{
+		NodeDrainDrain:               "true",
+		NodeDrainDeletionGracePeriod: "-1",
+	})
+	assert.NoError(t, err)
+	req = httptest.NewRequest("POST", "/processor/nodeDrain", bytes.NewBuffer(body))
+	recorder = httptest.NewRecorder()
+	nd.Handler(recorder, req)
+	assert.Equal(t, 400, recorder.Code)
+
+	// An invalid timeout is rejected.
+	body, err = json.Marshal(map[string]string{
+		NodeDrainDrain:          "true",
+		NodeDrainTimeoutSeconds: "0",
+	})
+	assert.NoError(t, err)
+	req = httptest.NewRequest("POST", "/processor/nodeDrain", bytes.NewBuffer(body))
+	recorder = httptest.NewRecorder()
+	nd.Handler(recorder, req)
+	assert.Equal(t, 400, recorder.Code)
+
+	// Only POST requests are supported.
+	req = httptest.NewRequest("GET", "/processor/nodeDrain", nil)
+	recorder = httptest.NewRecorder()
+	nd.Handler(recorder, req)
+	assert.Equal(t, 405, recorder.Code)
+}
+
+func TestDrainNodeTimeout(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+
+	pod1 := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "pod1",
+			Namespace: "default",
+		},
+		Spec: corev1.PodSpec{
+			NodeName: "node1",
+		},
+	}
+	pod2 := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "pod2",
+			Namespace: "default",
+		},
+		Spec: corev1.PodSpec{
+			NodeName: "node1",
+		},
+	}
+
+	cli := fake.NewFakeClientWithScheme(scheme, pod1, pod2)
+	nd := &nodeDrain{
+		Context:          context.Background(),
+		Logger:           ctrl.Log.WithName("recoverer/nodeDrain"),
+		client:           cli,
+		nodeName:         "node1",
+		nodeDrainEnabled: true,
+	}
+
+	// Pods not processed before the timeout elapses are skipped instead of deleted.
+	deleted, skipped, failed := nd.drainNode("node1", -1, false, 0)
+	assert.Empty(t, deleted)
+	assert.Empty(t, failed)
+	assert.Equal(t, 2, len(skipped))
+}
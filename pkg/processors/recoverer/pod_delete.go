@@ -0,0 +1,138 @@
+/*
+Copyright 2022 The KubeDiag Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package recoverer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/kubediag/kubediag/pkg/processors"
+	"github.com/kubediag/kubediag/pkg/processors/utils"
+)
+
+const (
+	PodDeletePodNamespace = "param.recoverer.kubernetes.pod_delete.namespace"
+	PodDeletePodName      = "param.recoverer.kubernetes.pod_delete.name"
+	PodDeleteConfirm      = "param.recoverer.kubernetes.pod_delete.confirm"
+
+	ContextKeyPodDeleteResultName = "recover.kubernetes.pod_delete.result.name"
+
+	// mirrorPodAnnotation is the annotation set by the kubelet on static pods.
+	mirrorPodAnnotation = "kubernetes.io/config.mirror"
+)
+
+// podDelete deletes a pod so that its controller recreates it.
+type podDelete struct {
+	// Context carries values across API boundaries.
+	context.Context
+	// Logger represents the ability to log messages.
+	logr.Logger
+
+	// client knows how to perform CRUD operations on Kubernetes objects.
+	client client.Client
+	// podDeleteEnabled indicates whether podDelete is enabled.
+	podDeleteEnabled bool
+}
+
+// NewPodDelete creates a new podDelete.
+func NewPodDelete(
+	ctx context.Context,
+	logger logr.Logger,
+	client client.Client,
+	podDeleteEnabled bool,
+) processors.Processor {
+	return &podDelete{
+		Context:          ctx,
+		Logger:           logger,
+		client:           client,
+		podDeleteEnabled: podDeleteEnabled,
+	}
+}
+
+// Handler handles http requests for deleting a pod.
+func (pd *podDelete) Handler(w http.ResponseWriter, r *http.Request) {
+	if !pd.podDeleteEnabled {
+		http.Error(w, fmt.Sprintf("pod delete is not enabled"), http.StatusUnprocessableEntity)
+		return
+	}
+
+	switch r.Method {
+	case "POST":
+		contexts, err := utils.ExtractParametersFromHTTPContext(r)
+		if err != nil {
+			pd.Error(err, "extract contexts failed")
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		namespace := contexts[PodDeletePodNamespace]
+		name := contexts[PodDeletePodName]
+		if namespace == "" || name == "" {
+			http.Error(w, fmt.Sprintf("must specify pod namespace and name."), http.StatusBadRequest)
+			return
+		}
+		if contexts[PodDeleteConfirm] != "true" {
+			http.Error(w, fmt.Sprintf("pod deletion must be confirmed by setting the confirm parameter to true."), http.StatusBadRequest)
+			return
+		}
+
+		var pod corev1.Pod
+		if err := pd.client.Get(pd, client.ObjectKey{
+			Name:      name,
+			Namespace: namespace,
+		}, &pod); err != nil {
+			http.Error(w, fmt.Sprintf("unable to fetch Pod: %v", err), http.StatusUnprocessableEntity)
+			return
+		}
+
+		// Refuse to delete static pods since the kubelet recreates the mirror pod without
+		// resolving the underlying problem.
+		if _, ok := pod.Annotations[mirrorPodAnnotation]; ok {
+			http.Error(w, fmt.Sprintf("pod %s/%s is a mirror pod of a static pod and will not be deleted.", namespace, name), http.StatusUnprocessableEntity)
+			return
+		}
+
+		if err := pd.client.Delete(pd, &pod); err != nil {
+			http.Error(w, fmt.Sprintf("unable to delete Pod: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		pd.Info("pod deleted for recovery", "pod", client.ObjectKey{
+			Name:      name,
+			Namespace: namespace,
+		})
+
+		result := make(map[string]string)
+		result[ContextKeyPodDeleteResultName] = fmt.Sprintf("%s/%s", namespace, name)
+		data, err := json.Marshal(result)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("failed to marshal result: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(data)
+	default:
+		http.Error(w, fmt.Sprintf("method %s is not supported", r.Method), http.StatusMethodNotAllowed)
+	}
+}
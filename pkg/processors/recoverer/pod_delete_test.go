@@ -0,0 +1,108 @@
+/*
+Copyright 2022 The KubeDiag Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package recoverer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestPodDeleteHandler(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "pod1",
+			Namespace: "default",
+		},
+	}
+	mirrorPod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "etcd-node1",
+			Namespace: "kube-system",
+			Annotations: map[string]string{
+				mirrorPodAnnotation: "da69bee3d51bd6aebd595bb5f7d33a83",
+			},
+		},
+	}
+
+	cli := fake.NewFakeClientWithScheme(scheme, pod, mirrorPod)
+	pd := &podDelete{
+		Context:          context.Background(),
+		Logger:           ctrl.Log.WithName("recoverer/podDelete"),
+		client:           cli,
+		podDeleteEnabled: true,
+	}
+
+	newRequest := func(parameters map[string]string) *httptest.ResponseRecorder {
+		body, err := json.Marshal(parameters)
+		assert.NoError(t, err)
+		req := httptest.NewRequest("POST", "/processor/podDelete", bytes.NewBuffer(body))
+		recorder := httptest.NewRecorder()
+		pd.Handler(recorder, req)
+		return recorder
+	}
+
+	// The referenced pod is deleted.
+	recorder := newRequest(map[string]string{
+		PodDeletePodNamespace: "default",
+		PodDeletePodName:      "pod1",
+		PodDeleteConfirm:      "true",
+	})
+	assert.Equal(t, 200, recorder.Code)
+	var deleted corev1.Pod
+	err := cli.Get(context.Background(), client.ObjectKey{Name: "pod1", Namespace: "default"}, &deleted)
+	assert.True(t, apierrors.IsNotFound(err))
+
+	// A mirror pod of a static pod is not deleted.
+	recorder = newRequest(map[string]string{
+		PodDeletePodNamespace: "kube-system",
+		PodDeletePodName:      "etcd-node1",
+		PodDeleteConfirm:      "true",
+	})
+	assert.Equal(t, 422, recorder.Code)
+	var mirror corev1.Pod
+	err = cli.Get(context.Background(), client.ObjectKey{Name: "etcd-node1", Namespace: "kube-system"}, &mirror)
+	assert.NoError(t, err)
+
+	// The deletion must be confirmed.
+	recorder = newRequest(map[string]string{
+		PodDeletePodNamespace: "kube-system",
+		PodDeletePodName:      "etcd-node1",
+	})
+	assert.Equal(t, 400, recorder.Code)
+
+	// Only POST requests are supported.
+	req := httptest.NewRequest("GET", "/processor/podDelete", nil)
+	getRecorder := httptest.NewRecorder()
+	pd.Handler(getRecorder, req)
+	assert.Equal(t, 405, getRecorder.Code)
+}
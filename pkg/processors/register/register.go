@@ -6,9 +6,11 @@ import (
 
 	"github.com/go-logr/logr"
 	"github.com/gorilla/mux"
+	"k8s.io/client-go/kubernetes"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
 
+	"github.com/kubediag/kubediag/pkg/artifactserver"
 	"github.com/kubediag/kubediag/pkg/features"
 	"github.com/kubediag/kubediag/pkg/processors/collector"
 	"github.com/kubediag/kubediag/pkg/processors/diagnoser"
@@ -25,6 +27,16 @@ type RegistryOption struct {
 	DataRoot string
 	// BindAddress is the address on which to advertise.
 	BindAddress string
+	// Port is the port for the kubediag to serve on.
+	Port int
+	// ArtifactServer serves operation result artifacts over the agent port. Profilers
+	// register their results as artifacts instead of starting ephemeral http servers
+	// on random ports if specified.
+	ArtifactServer artifactserver.ArtifactServer
+	// ProfilerMinExpirationSeconds is the minimum expiration of profiler endpoints.
+	ProfilerMinExpirationSeconds int
+	// ProfilerMaxExpirationSeconds is the maximum expiration of profiler endpoints.
+	ProfilerMaxExpirationSeconds int
 }
 
 // RegisterProcessors will initialize all processors and add into router to provide HTTP service.
@@ -84,11 +96,53 @@ func RegisterProcessors(mgr manager.Manager,
 		ctrl.Log.WithName("processor/containerdGoroutineCollector"),
 		featureGate.Enabled(features.ContainerdGoroutineCollector),
 	)
+	kubeletLogCollector := collector.NewKubeletLogCollector(
+		context.Background(),
+		ctrl.Log.WithName("processor/kubeletLogCollector"),
+		featureGate.Enabled(features.KubeletLogCollector),
+	)
+	kubeClient, err := kubernetes.NewForConfig(mgr.GetConfig())
+	if err != nil {
+		setupLog.Error(err, "unable to create processor", "processors", "containerLogCollector")
+		return fmt.Errorf("unable to create processor: %v", err)
+	}
+	containerLogCollector := collector.NewContainerLogCollector(
+		context.Background(),
+		ctrl.Log.WithName("processor/containerLogCollector"),
+		kubeClient,
+		opts.DataRoot,
+		featureGate.Enabled(features.ContainerLogCollector),
+	)
+	dmesgCollector := collector.NewDmesgCollector(
+		context.Background(),
+		ctrl.Log.WithName("processor/dmesgCollector"),
+		featureGate.Enabled(features.DmesgCollector),
+	)
+	iptablesCollector := collector.NewIptablesCollector(
+		context.Background(),
+		ctrl.Log.WithName("processor/iptablesCollector"),
+		featureGate.Enabled(features.IptablesCollector),
+	)
+	podVolumeCollector := collector.NewPodVolumeCollector(
+		context.Background(),
+		ctrl.Log.WithName("processor/podVolumeCollector"),
+		featureGate.Enabled(features.PodVolumeCollector),
+	)
+	sysctlCollector := collector.NewSysctlCollector(
+		context.Background(),
+		ctrl.Log.WithName("processor/sysctlCollector"),
+		featureGate.Enabled(features.SysctlCollector),
+	)
 	mountInfoCollector := collector.NewMountInfoCollector(
 		context.Background(),
 		ctrl.Log.WithName("processor/mountInfoCollector"),
 		featureGate.Enabled(features.MountInfoCollector),
 	)
+	psiCollector := collector.NewPSICollector(
+		context.Background(),
+		ctrl.Log.WithName("processor/psiCollector"),
+		featureGate.Enabled(features.PSICollector),
+	)
 	elasticsearchCollector := collector.NewElasticsearchCollector(
 		context.Background(),
 		ctrl.Log.WithName("processor/elasticsearchCollector"),
@@ -107,14 +161,20 @@ func RegisterProcessors(mgr manager.Manager,
 		mgr.GetCache(),
 		opts.DataRoot,
 		opts.BindAddress,
+		opts.Port,
+		opts.ArtifactServer,
 		featureGate.Enabled(features.GoProfiler),
+		opts.ProfilerMinExpirationSeconds,
+		opts.ProfilerMaxExpirationSeconds,
 	)
 	coreFileProfiler, err := diagnoser.NewCoreFileProfiler(
 		context.Background(),
 		ctrl.Log.WithName("processor/coreFileProfiler"),
 		opts.DockerEndpoint,
 		featureGate.Enabled(features.CoreFileProfiler),
-		opts.DataRoot)
+		opts.DataRoot,
+		opts.ProfilerMinExpirationSeconds,
+		opts.ProfilerMaxExpirationSeconds)
 	if err != nil {
 		setupLog.Error(err, "unable to create processor", "processors", "coreFileProfiler")
 		return fmt.Errorf("unable to create processor: %v", err)
@@ -137,6 +197,60 @@ func RegisterProcessors(mgr manager.Manager,
 		mgr.GetCache(),
 		featureGate.Enabled(features.SubpathRemountDiagnoser),
 	)
+	prometheusQueryDiagnoser := diagnoser.NewPrometheusQueryDiagnoser(
+		context.Background(),
+		ctrl.Log.WithName("processor/prometheusQueryDiagnoser"),
+		mgr.GetCache(),
+		featureGate.Enabled(features.PrometheusQueryDiagnoser),
+	)
+	certExpiryDiagnoser := diagnoser.NewCertExpiryDiagnoser(
+		context.Background(),
+		ctrl.Log.WithName("processor/certExpiryDiagnoser"),
+		featureGate.Enabled(features.CertExpiryDiagnoser),
+	)
+	podEventDiagnoser := diagnoser.NewPodEventDiagnoser(
+		context.Background(),
+		ctrl.Log.WithName("processor/podEventDiagnoser"),
+		mgr.GetCache(),
+		featureGate.Enabled(features.PodEventDiagnoser),
+	)
+	podSchedulingDiagnoser := diagnoser.NewPodSchedulingDiagnoser(
+		context.Background(),
+		ctrl.Log.WithName("processor/podSchedulingDiagnoser"),
+		mgr.GetCache(),
+		featureGate.Enabled(features.PodSchedulingDiagnoser),
+	)
+	oomKilledDiagnoser := diagnoser.NewOOMKilledDiagnoser(
+		context.Background(),
+		ctrl.Log.WithName("processor/oomKilledDiagnoser"),
+		mgr.GetCache(),
+		opts.NodeName,
+		featureGate.Enabled(features.OOMKilledDiagnoser),
+	)
+	etcdHealthDiagnoser := diagnoser.NewEtcdHealthDiagnoser(
+		context.Background(),
+		ctrl.Log.WithName("processor/etcdHealthDiagnoser"),
+		mgr.GetCache(),
+		featureGate.Enabled(features.EtcdHealthDiagnoser),
+	)
+	workloadReplicaDiagnoser := diagnoser.NewWorkloadReplicaDiagnoser(
+		context.Background(),
+		ctrl.Log.WithName("processor/workloadReplicaDiagnoser"),
+		mgr.GetCache(),
+		featureGate.Enabled(features.WorkloadReplicaDiagnoser),
+	)
+	podLogDiagnoser := diagnoser.NewPodLogDiagnoser(
+		context.Background(),
+		ctrl.Log.WithName("processor/podLogDiagnoser"),
+		featureGate.Enabled(features.PodLogDiagnoser),
+	)
+	selfProfiler := diagnoser.NewSelfProfiler(
+		context.Background(),
+		ctrl.Log.WithName("processor/selfProfiler"),
+		opts.DataRoot,
+		opts.BindAddress,
+		featureGate.Enabled(features.SelfProfiler),
+	)
 	sonobuoyResultDiagnoser := diagnoser.NewSonobuoyResultDiagnoser(
 		context.Background(),
 		ctrl.Log.WithName("processor/sonobuoyResultDiagnoser"),
@@ -158,6 +272,19 @@ func RegisterProcessors(mgr manager.Manager,
 		opts.NodeName,
 		featureGate.Enabled(features.NodeCordon),
 	)
+	nodeDrain := recoverer.NewNodeDrain(
+		context.Background(),
+		ctrl.Log.WithName("processor/nodeDrain"),
+		mgr.GetClient(),
+		opts.NodeName,
+		featureGate.Enabled(features.NodeDrain),
+	)
+	podDelete := recoverer.NewPodDelete(
+		context.Background(),
+		ctrl.Log.WithName("processor/podDelete"),
+		mgr.GetClient(),
+		featureGate.Enabled(features.PodDelete),
+	)
 	statefulsetStuck := recoverer.NewStatefuSetStuck(
 		context.Background(),
 		ctrl.Log.WithName("/processor/statefulsetStuck"),
@@ -173,18 +300,36 @@ func RegisterProcessors(mgr manager.Manager,
 	router.HandleFunc("/processor/dockerInfoCollector", dockerInfoCollector.Handler)
 	router.HandleFunc("/processor/dockerdGoroutineCollector", dockerdGoroutineCollector.Handler)
 	router.HandleFunc("/processor/containerdGoroutineCollector", containerdGoroutineCollector.Handler)
+	router.HandleFunc("/processor/kubeletLogCollector", kubeletLogCollector.Handler)
+	router.HandleFunc("/processor/containerLogCollector", containerLogCollector.Handler)
+	router.HandleFunc("/processor/dmesgCollector", dmesgCollector.Handler)
+	router.HandleFunc("/processor/iptablesCollector", iptablesCollector.Handler)
+	router.HandleFunc("/processor/podVolumeCollector", podVolumeCollector.Handler)
+	router.HandleFunc("/processor/sysctlCollector", sysctlCollector.Handler)
 	router.HandleFunc("/processor/mountInfoCollector", mountInfoCollector.Handler)
+	router.HandleFunc("/processor/psiCollector", psiCollector.Handler)
 	router.HandleFunc("/processor/elasticsearchCollector", elasticsearchCollector.Handler)
 	router.HandleFunc("/processor/statefulsetDetailCollector", statefulsetDetailCollector.Handler)
 	// Handlers for executing specified command.
 	router.HandleFunc("/processor/nodeCordon", nodeCordon.Handler)
+	router.HandleFunc("/processor/nodeDrain", nodeDrain.Handler)
+	router.HandleFunc("/processor/podDelete", podDelete.Handler)
 	// Handlers for profiling programs.
 	router.HandleFunc("/processor/coreFileProfiler", coreFileProfiler.Handler)
 	router.HandleFunc("/processor/goProfiler", goProfiler.Handler)
 	router.HandleFunc("/processor/tcpdumpProfiler", tcpdumpProfiler.Handler)
+	router.HandleFunc("/processor/selfProfiler", selfProfiler.Handler)
 
 	// Handlers for diagnosing programs
 	router.HandleFunc("/processor/subpathRemountDiagnoser", subpathRemountDiagnoser.Handler)
+	router.HandleFunc("/processor/podEventDiagnoser", podEventDiagnoser.Handler)
+	router.HandleFunc("/processor/podSchedulingDiagnoser", podSchedulingDiagnoser.Handler)
+	router.HandleFunc("/processor/prometheusQueryDiagnoser", prometheusQueryDiagnoser.Handler)
+	router.HandleFunc("/processor/certExpiryDiagnoser", certExpiryDiagnoser.Handler)
+	router.HandleFunc("/processor/oomKilledDiagnoser", oomKilledDiagnoser.Handler)
+	router.HandleFunc("/processor/etcdHealthDiagnoser", etcdHealthDiagnoser.Handler)
+	router.HandleFunc("/processor/workloadReplicaDiagnoser", workloadReplicaDiagnoser.Handler)
+	router.HandleFunc("/processor/podLogDiagnoser", podLogDiagnoser.Handler)
 
 	router.HandleFunc("/processor/subpathRemountRecover", subpathRemountRecover.Handler)
 	router.HandleFunc("/processor/statefulsetStuck", statefulsetStuck.Handler)
@@ -0,0 +1,125 @@
+/*
+Copyright 2022 The KubeDiag Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"io/ioutil"
+	"sort"
+	"strings"
+)
+
+// CompressedKeysResultKey is the key to record which result values are gzip compressed and
+// base64 encoded.
+var CompressedKeysResultKey = KubeDiagPrefix + "compressed-keys"
+
+// CompressValue compresses a value with gzip and encodes it with base64.
+func CompressValue(value string) (string, error) {
+	var buf bytes.Buffer
+	writer := gzip.NewWriter(&buf)
+	if _, err := writer.Write([]byte(value)); err != nil {
+		return "", err
+	}
+	if err := writer.Close(); err != nil {
+		return "", err
+	}
+
+	return base64.StdEncoding.EncodeToString(buf.Bytes()), nil
+}
+
+// DecompressValue decodes a base64 encoded value and decompresses it with gzip.
+func DecompressValue(value string) (string, error) {
+	compressed, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		return "", err
+	}
+	reader, err := gzip.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		return "", err
+	}
+	defer reader.Close()
+	decompressed, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return "", err
+	}
+
+	return string(decompressed), nil
+}
+
+// CompressResult compresses result values larger than thresholdBytes with gzip and encodes them
+// with base64. Keys of compressed values are recorded under CompressedKeysResultKey so readers
+// know to decode. Compression is disabled if thresholdBytes is not positive.
+func CompressResult(result map[string]string, thresholdBytes int) (map[string]string, error) {
+	if thresholdBytes <= 0 {
+		return result, nil
+	}
+
+	compressedKeys := make([]string, 0)
+	compressed := make(map[string]string, len(result))
+	for key, value := range result {
+		if len(value) > thresholdBytes {
+			encoded, err := CompressValue(value)
+			if err != nil {
+				return nil, err
+			}
+			compressed[key] = encoded
+			compressedKeys = append(compressedKeys, key)
+		} else {
+			compressed[key] = value
+		}
+	}
+
+	if len(compressedKeys) == 0 {
+		return result, nil
+	}
+
+	sort.Strings(compressedKeys)
+	compressed[CompressedKeysResultKey] = strings.Join(compressedKeys, ",")
+
+	return compressed, nil
+}
+
+// DecompressResult decodes result values recorded under CompressedKeysResultKey.
+func DecompressResult(result map[string]string) (map[string]string, error) {
+	compressedKeys, ok := result[CompressedKeysResultKey]
+	if !ok {
+		return result, nil
+	}
+
+	decompressed := make(map[string]string, len(result))
+	for key, value := range result {
+		if key == CompressedKeysResultKey {
+			continue
+		}
+		decompressed[key] = value
+	}
+	for _, key := range strings.Split(compressedKeys, ",") {
+		value, ok := decompressed[key]
+		if !ok {
+			continue
+		}
+		decoded, err := DecompressValue(value)
+		if err != nil {
+			return nil, err
+		}
+		decompressed[key] = decoded
+	}
+
+	return decompressed, nil
+}
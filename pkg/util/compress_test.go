@@ -0,0 +1,71 @@
+/*
+Copyright 2022 The KubeDiag Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompressValueRoundTrip(t *testing.T) {
+	value := strings.Repeat("kubediag", 100*1024)
+
+	compressed, err := CompressValue(value)
+	assert.NoError(t, err)
+	assert.True(t, len(compressed) < len(value))
+
+	decompressed, err := DecompressValue(compressed)
+	assert.NoError(t, err)
+	assert.Equal(t, value, decompressed)
+}
+
+func TestCompressResultRoundTrip(t *testing.T) {
+	largeValue := strings.Repeat("kubediag", 100*1024)
+	result := map[string]string{
+		"operation.large": largeValue,
+		"operation.small": "value1",
+	}
+
+	compressed, err := CompressResult(result, 1024)
+	assert.NoError(t, err)
+	assert.Equal(t, "operation.large", compressed[CompressedKeysResultKey])
+	assert.Equal(t, "value1", compressed["operation.small"])
+	assert.NotEqual(t, largeValue, compressed["operation.large"])
+	assert.True(t, len(compressed["operation.large"]) < len(largeValue))
+
+	decompressed, err := DecompressResult(compressed)
+	assert.NoError(t, err)
+	assert.Equal(t, result, decompressed)
+}
+
+func TestCompressResultBelowThreshold(t *testing.T) {
+	result := map[string]string{
+		"operation.small": "value1",
+	}
+
+	// The result is returned without a marker key if no value exceeds the threshold.
+	compressed, err := CompressResult(result, 1024)
+	assert.NoError(t, err)
+	assert.Equal(t, result, compressed)
+
+	// Compression is disabled if the threshold is not positive.
+	compressed, err = CompressResult(map[string]string{"operation.large": strings.Repeat("a", 2048)}, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, strings.Repeat("a", 2048), compressed["operation.large"])
+}
@@ -0,0 +1,114 @@
+/*
+Copyright 2022 The KubeDiag Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	diagnosisv1 "github.com/kubediag/kubediag/api/v1"
+)
+
+// Metrics for observing saturation of channels consumed by executor, graph builder and eventer.
+var (
+	diagnosisChannelLength = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "diagnosis_channel_length",
+			Help: "Gauge of diagnoses queued in diagnosis channel",
+		},
+	)
+	diagnosisChannelDroppedCount = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "diagnosis_channel_dropped_count",
+			Help: "Counter of diagnoses dropped due to full diagnosis channel",
+		},
+	)
+	taskChannelLength = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "task_channel_length",
+			Help: "Gauge of tasks queued in task channel",
+		},
+	)
+	taskChannelDroppedCount = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "task_channel_dropped_count",
+			Help: "Counter of tasks dropped due to full task channel",
+		},
+	)
+	operationSetChannelLength = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "operation_set_channel_length",
+			Help: "Gauge of operation sets queued in operation set channel",
+		},
+	)
+	operationSetChannelDroppedCount = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "operation_set_channel_dropped_count",
+			Help: "Counter of operation sets dropped due to full operation set channel",
+		},
+	)
+	eventChannelLength = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "event_channel_length",
+			Help: "Gauge of events queued in event channel",
+		},
+	)
+	eventChannelDroppedCount = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "event_channel_dropped_count",
+			Help: "Counter of events dropped due to full event channel",
+		},
+	)
+)
+
+// UpdateDiagnosisChannelLength sets the diagnosis channel length gauge. It should be called after
+// a diagnosis is dequeued from the channel.
+func UpdateDiagnosisChannelLength(channel chan diagnosisv1.Diagnosis) {
+	diagnosisChannelLength.Set(float64(len(channel)))
+}
+
+// UpdateTaskChannelLength sets the task channel length gauge. It should be called after a task is
+// dequeued from the channel.
+func UpdateTaskChannelLength(channel chan diagnosisv1.Task) {
+	taskChannelLength.Set(float64(len(channel)))
+}
+
+// UpdateOperationSetChannelLength sets the operation set channel length gauge. It should be called
+// after an operation set is dequeued from the channel.
+func UpdateOperationSetChannelLength(channel chan diagnosisv1.OperationSet) {
+	operationSetChannelLength.Set(float64(len(channel)))
+}
+
+// UpdateEventChannelLength sets the event channel length gauge. It should be called after an event
+// is dequeued from the channel.
+func UpdateEventChannelLength(channel chan corev1.Event) {
+	eventChannelLength.Set(float64(len(channel)))
+}
+
+func init() {
+	metrics.Registry.MustRegister(
+		diagnosisChannelLength,
+		diagnosisChannelDroppedCount,
+		taskChannelLength,
+		taskChannelDroppedCount,
+		operationSetChannelLength,
+		operationSetChannelDroppedCount,
+		eventChannelLength,
+		eventChannelDroppedCount,
+	)
+}
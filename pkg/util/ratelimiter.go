@@ -0,0 +1,88 @@
+/*
+Copyright 2022 The KubeDiag Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/time/rate"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+var (
+	sourceDiagnosisThrottledCount = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "source_diagnosis_throttled_count",
+			Help: "Counter of diagnosis creations throttled by source rate limiter",
+		},
+		[]string{"source"},
+	)
+)
+
+// SourceRateLimiter limits the rate of diagnosis creations with a token bucket per source identity.
+type SourceRateLimiter struct {
+	// qps is the maximum sustained rate of diagnosis creations per source.
+	qps float64
+	// mutex guards limiters.
+	mutex sync.Mutex
+	// limiters contains a token bucket per source identity.
+	limiters map[string]*rate.Limiter
+}
+
+// NewSourceRateLimiter creates a new SourceRateLimiter with the provided rate of diagnosis
+// creations per source. All creations are allowed if qps is not positive.
+func NewSourceRateLimiter(qps float64) *SourceRateLimiter {
+	return &SourceRateLimiter{
+		qps:      qps,
+		limiters: make(map[string]*rate.Limiter),
+	}
+}
+
+// Allow reports whether a diagnosis may be created for the source. A throttled creation is
+// recorded in the source diagnosis throttled count metric.
+func (s *SourceRateLimiter) Allow(source string) bool {
+	if s.qps <= 0 {
+		return true
+	}
+
+	s.mutex.Lock()
+	limiter, ok := s.limiters[source]
+	if !ok {
+		// The burst of the token bucket is derived from the rate and is one at minimum.
+		burst := int(s.qps)
+		if burst < 1 {
+			burst = 1
+		}
+		limiter = rate.NewLimiter(rate.Limit(s.qps), burst)
+		s.limiters[source] = limiter
+	}
+	s.mutex.Unlock()
+
+	if !limiter.Allow() {
+		sourceDiagnosisThrottledCount.WithLabelValues(source).Inc()
+		return false
+	}
+
+	return true
+}
+
+func init() {
+	metrics.Registry.MustRegister(
+		sourceDiagnosisThrottledCount,
+	)
+}
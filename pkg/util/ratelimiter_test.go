@@ -0,0 +1,47 @@
+/*
+Copyright 2022 The KubeDiag Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSourceRateLimiterAllow(t *testing.T) {
+	limiter := NewSourceRateLimiter(2)
+
+	// The first creations within the burst are allowed and the rest of the burst is throttled.
+	allowed := 0
+	for i := 0; i < 10; i++ {
+		if limiter.Allow("source1") {
+			allowed++
+		}
+	}
+	assert.Equal(t, 2, allowed)
+
+	// A creation from another source is not affected.
+	assert.True(t, limiter.Allow("source2"))
+}
+
+func TestSourceRateLimiterDisabled(t *testing.T) {
+	limiter := NewSourceRateLimiter(0)
+
+	for i := 0; i < 10; i++ {
+		assert.True(t, limiter.Allow("source1"))
+	}
+}
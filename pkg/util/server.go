@@ -0,0 +1,69 @@
+/*
+Copyright 2022 The KubeDiag Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// TLSCertFileName is the name of the serving certificate file under the certificate directory.
+	TLSCertFileName = "tls.crt"
+	// TLSKeyFileName is the name of the serving key file under the certificate directory.
+	TLSKeyFileName = "tls.key"
+)
+
+// healthCheckPaths are paths exempt from authentication so liveness and readiness probes
+// succeed without credentials.
+var healthCheckPaths = map[string]bool{
+	"/healthz": true,
+	"/readyz":  true,
+}
+
+// NewBearerTokenAuthHandler wraps the provided handler with static bearer token authentication.
+// A request is rejected with 401 unless it presents the expected token in the Authorization
+// header. Health check paths are exempt.
+func NewBearerTokenAuthHandler(handler http.Handler, token string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if healthCheckPaths[r.URL.Path] {
+			handler.ServeHTTP(w, r)
+			return
+		}
+
+		presented := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(presented), []byte(token)) != 1 {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// ListenAndServe serves the provided handler on the address. The server serves over tls using
+// the certificate and key pair under the certificate directory if serveTLS is specified,
+// otherwise it serves plain http.
+func ListenAndServe(address string, handler http.Handler, serveTLS bool, certDir string) error {
+	if serveTLS {
+		return http.ListenAndServeTLS(address, filepath.Join(certDir, TLSCertFileName), filepath.Join(certDir, TLSKeyFileName), handler)
+	}
+
+	return http.ListenAndServe(address, handler)
+}
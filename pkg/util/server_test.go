@@ -0,0 +1,165 @@
+/*
+Copyright 2022 The KubeDiag Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// writeSelfSignedCertificate writes a self signed certificate and key pair for 127.0.0.1 into
+// the provided directory using the serving certificate file names.
+func writeSelfSignedCertificate(t *testing.T, dir string) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+	template := x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject: pkix.Name{
+			CommonName: "kubediag-test",
+		},
+		NotBefore:   time.Now().Add(-time.Hour),
+		NotAfter:    time.Now().Add(time.Hour),
+		IPAddresses: []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	certificate, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	assert.NoError(t, err)
+
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certificate})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	err = ioutil.WriteFile(filepath.Join(dir, TLSCertFileName), certPEM, 0644)
+	assert.NoError(t, err)
+	err = ioutil.WriteFile(filepath.Join(dir, TLSKeyFileName), keyPEM, 0600)
+	assert.NoError(t, err)
+}
+
+// freeLocalAddress returns a local address with a port which is free at the time of the call.
+func freeLocalAddress(t *testing.T) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	address := listener.Addr().String()
+	listener.Close()
+
+	return address
+}
+
+func TestNewBearerTokenAuthHandler(t *testing.T) {
+	handler := NewBearerTokenAuthHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "ok")
+	}), "token1")
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	// An unauthenticated request is rejected.
+	res, err := http.Get(server.URL + "/processor/podListCollector")
+	assert.NoError(t, err)
+	res.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, res.StatusCode)
+
+	// A request with a wrong token is rejected.
+	req, err := http.NewRequest("GET", server.URL+"/processor/podListCollector", nil)
+	assert.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer token2")
+	res, err = http.DefaultClient.Do(req)
+	assert.NoError(t, err)
+	res.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, res.StatusCode)
+
+	// A request with the expected token is accepted.
+	req.Header.Set("Authorization", "Bearer token1")
+	res, err = http.DefaultClient.Do(req)
+	assert.NoError(t, err)
+	res.Body.Close()
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+
+	// Health check paths are exempt from authentication.
+	res, err = http.Get(server.URL + "/healthz")
+	assert.NoError(t, err)
+	res.Body.Close()
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+}
+
+func TestListenAndServeTLS(t *testing.T) {
+	dir, err := ioutil.TempDir("", "serving-certs")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	writeSelfSignedCertificate(t, dir)
+
+	address := freeLocalAddress(t)
+	go func() {
+		_ = ListenAndServe(address, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, "ok")
+		}), true, dir)
+	}()
+
+	// The server negotiates tls with the configured certificate.
+	var conn *tls.Conn
+	for i := 0; i < 50; i++ {
+		conn, err = tls.Dial("tcp", address, &tls.Config{InsecureSkipVerify: true})
+		if err == nil {
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	assert.NoError(t, err)
+	if assert.NotNil(t, conn) {
+		defer conn.Close()
+		certificates := conn.ConnectionState().PeerCertificates
+		if assert.NotEmpty(t, certificates) {
+			assert.Equal(t, "kubediag-test", certificates[0].Subject.CommonName)
+		}
+	}
+}
+
+func TestListenAndServePlainHTTP(t *testing.T) {
+	address := freeLocalAddress(t)
+	go func() {
+		_ = ListenAndServe(address, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, "ok")
+		}), false, "")
+	}()
+
+	var res *http.Response
+	var err error
+	for i := 0; i < 50; i++ {
+		res, err = http.Get("http://" + address)
+		if err == nil {
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	assert.NoError(t, err)
+	if assert.NotNil(t, res) {
+		defer res.Body.Close()
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+	}
+}
@@ -29,9 +29,11 @@ import (
 	"os/exec"
 	"path"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
 	"syscall"
+	"time"
 
 	"github.com/davecgh/go-spew/spew"
 	corev1 "k8s.io/api/core/v1"
@@ -78,8 +80,24 @@ const (
 	OperationSetUniqueLabelKey = "adjacency-list-hash"
 	// AlphaNums omits vowels from the set of available characters to reduce the chances of "bad words" being formed.
 	AlphaNums = "bcdfghjklmnpqrstvwxz2456789"
+	// DefaultMaxStatusConditions is the default maximum number of conditions retained in a
+	// diagnosis or task status.
+	DefaultMaxStatusConditions = 20
 )
 
+// maxStatusConditions is the maximum number of conditions retained in a diagnosis or task
+// status. The oldest non terminal conditions beyond the limit are pruned.
+var maxStatusConditions = DefaultMaxStatusConditions
+
+// SetMaxStatusConditions configures the maximum number of conditions retained in a diagnosis
+// or task status. A non positive limit falls back to the default.
+func SetMaxStatusConditions(limit int) {
+	if limit <= 0 {
+		limit = DefaultMaxStatusConditions
+	}
+	maxStatusConditions = limit
+}
+
 // UpdateDiagnosisCondition updates existing diagnosis condition or creates a new one. Sets
 // LastTransitionTime to now if the status has changed.
 // Returns true if diagnosis condition has changed or has been added.
@@ -91,6 +109,7 @@ func UpdateDiagnosisCondition(status *diagnosisv1.DiagnosisStatus, condition *di
 	if oldCondition == nil {
 		// We are adding new diagnosis condition.
 		status.Conditions = append(status.Conditions, *condition)
+		pruneDiagnosisConditions(status)
 		return true
 	}
 
@@ -146,6 +165,7 @@ func UpdateTaskCondition(status *diagnosisv1.TaskStatus, condition *diagnosisv1.
 	if oldCondition == nil {
 		// We are adding new task condition.
 		status.Conditions = append(status.Conditions, *condition)
+		pruneTaskConditions(status)
 		return true
 	}
 
@@ -165,6 +185,96 @@ func UpdateTaskCondition(status *diagnosisv1.TaskStatus, condition *diagnosisv1.
 	return !isEqual
 }
 
+// pruneDiagnosisConditions prunes the oldest non terminal conditions of a diagnosis status
+// beyond the maximum. Terminal conditions are always preserved.
+func pruneDiagnosisConditions(status *diagnosisv1.DiagnosisStatus) {
+	excess := len(status.Conditions) - maxStatusConditions
+	if excess <= 0 {
+		return
+	}
+
+	prunable := make([]int, 0, len(status.Conditions))
+	for index, condition := range status.Conditions {
+		if !isTerminalDiagnosisConditionType(condition.Type) {
+			prunable = append(prunable, index)
+		}
+	}
+	sort.SliceStable(prunable, func(i, j int) bool {
+		return status.Conditions[prunable[i]].LastTransitionTime.Before(&status.Conditions[prunable[j]].LastTransitionTime)
+	})
+	if excess > len(prunable) {
+		excess = len(prunable)
+	}
+
+	pruned := make(map[int]bool, excess)
+	for _, index := range prunable[:excess] {
+		pruned[index] = true
+	}
+	conditions := make([]diagnosisv1.DiagnosisCondition, 0, len(status.Conditions)-excess)
+	for index, condition := range status.Conditions {
+		if !pruned[index] {
+			conditions = append(conditions, condition)
+		}
+	}
+	status.Conditions = conditions
+}
+
+// isTerminalDiagnosisConditionType reports whether a diagnosis condition type describes a
+// terminal outcome of the diagnosis.
+func isTerminalDiagnosisConditionType(conditionType diagnosisv1.DiagnosisConditionType) bool {
+	switch conditionType {
+	case diagnosisv1.DiagnosisComplete, diagnosisv1.OperationSetChanged, diagnosisv1.OperationSetNotReady, diagnosisv1.OperationSetNotFound:
+		return true
+	}
+
+	return false
+}
+
+// pruneTaskConditions prunes the oldest non terminal conditions of a task status beyond the
+// maximum. Terminal conditions are always preserved.
+func pruneTaskConditions(status *diagnosisv1.TaskStatus) {
+	excess := len(status.Conditions) - maxStatusConditions
+	if excess <= 0 {
+		return
+	}
+
+	prunable := make([]int, 0, len(status.Conditions))
+	for index, condition := range status.Conditions {
+		if !isTerminalTaskConditionType(condition.Type) {
+			prunable = append(prunable, index)
+		}
+	}
+	sort.SliceStable(prunable, func(i, j int) bool {
+		return status.Conditions[prunable[i]].LastTransitionTime.Before(&status.Conditions[prunable[j]].LastTransitionTime)
+	})
+	if excess > len(prunable) {
+		excess = len(prunable)
+	}
+
+	pruned := make(map[int]bool, excess)
+	for _, index := range prunable[:excess] {
+		pruned[index] = true
+	}
+	conditions := make([]diagnosisv1.TaskCondition, 0, len(status.Conditions)-excess)
+	for index, condition := range status.Conditions {
+		if !pruned[index] {
+			conditions = append(conditions, condition)
+		}
+	}
+	status.Conditions = conditions
+}
+
+// isTerminalTaskConditionType reports whether a task condition type describes a terminal
+// outcome of the task.
+func isTerminalTaskConditionType(conditionType diagnosisv1.TaskConditionType) bool {
+	switch conditionType {
+	case diagnosisv1.TaskComplete, diagnosisv1.TaskIncomplete, diagnosisv1.OperationNotFound:
+		return true
+	}
+
+	return false
+}
+
 // GetTaskCondition extracts the provided condition from the given status.
 // Returns -1 and nil if the condition is not present, otherwise returns the index of the located condition.
 func GetTaskCondition(status *diagnosisv1.TaskStatus, conditionType diagnosisv1.TaskConditionType) (int, *diagnosisv1.TaskCondition) {
@@ -190,6 +300,61 @@ func GetTaskConditionFromList(conditions []diagnosisv1.TaskCondition, conditionT
 	return -1, nil
 }
 
+// UpdateOperationSetCondition updates existing operation set condition or creates a new one. Sets
+// LastTransitionTime to now if the status has changed.
+// Returns true if operation set condition has changed or has been added.
+func UpdateOperationSetCondition(status *diagnosisv1.OperationSetStatus, condition *diagnosisv1.OperationSetCondition) bool {
+	condition.LastTransitionTime = metav1.Now()
+	// Try to find this operation set condition.
+	conditionIndex, oldCondition := GetOperationSetCondition(status, condition.Type)
+
+	if oldCondition == nil {
+		// We are adding new operation set condition.
+		status.Conditions = append(status.Conditions, *condition)
+		return true
+	}
+
+	// We are updating an existing condition, so we need to check if it has changed.
+	if condition.Status == oldCondition.Status {
+		condition.LastTransitionTime = oldCondition.LastTransitionTime
+	}
+
+	isEqual := condition.Status == oldCondition.Status &&
+		condition.Reason == oldCondition.Reason &&
+		condition.Message == oldCondition.Message &&
+		condition.LastTransitionTime.Equal(&oldCondition.LastTransitionTime)
+
+	status.Conditions[conditionIndex] = *condition
+
+	// Return true if one of the fields have changed.
+	return !isEqual
+}
+
+// GetOperationSetCondition extracts the provided condition from the given status.
+// Returns -1 and nil if the condition is not present, otherwise returns the index of the located condition.
+func GetOperationSetCondition(status *diagnosisv1.OperationSetStatus, conditionType diagnosisv1.OperationSetConditionType) (int, *diagnosisv1.OperationSetCondition) {
+	if status == nil {
+		return -1, nil
+	}
+
+	return GetOperationSetConditionFromList(status.Conditions, conditionType)
+}
+
+// GetOperationSetConditionFromList extracts the provided condition from the given list of condition and
+// returns the index of the condition and the condition. Returns -1 and nil if the condition is not present.
+func GetOperationSetConditionFromList(conditions []diagnosisv1.OperationSetCondition, conditionType diagnosisv1.OperationSetConditionType) (int, *diagnosisv1.OperationSetCondition) {
+	if conditions == nil {
+		return -1, nil
+	}
+	for i := range conditions {
+		if conditions[i].Type == conditionType {
+			return i, &conditions[i]
+		}
+	}
+
+	return -1, nil
+}
+
 // GetPodUnhealthyReason extracts the reason of terminated or waiting container in the pod if the pod is
 // not ready. The parameter must be an unhealthy pod.
 // It returns the reason of the first terminated or waiting container.
@@ -293,48 +458,56 @@ func FormatURL(scheme string, host string, port string, path string) *url.URL {
 
 // QueueDiagnosis sends a diagnosis to a channel. It returns an error if the channel is blocked.
 func QueueDiagnosis(ctx context.Context, channel chan diagnosisv1.Diagnosis, diagnosis diagnosisv1.Diagnosis) error {
+	defer func() { diagnosisChannelLength.Set(float64(len(channel))) }()
 	select {
 	case <-ctx.Done():
 		return nil
 	case channel <- diagnosis:
 		return nil
 	default:
+		diagnosisChannelDroppedCount.Inc()
 		return fmt.Errorf("channel is blocked")
 	}
 }
 
 // QueueTask sends a task to a channel. It returns an error if the channel is blocked.
 func QueueTask(ctx context.Context, channel chan diagnosisv1.Task, task diagnosisv1.Task) error {
+	defer func() { taskChannelLength.Set(float64(len(channel))) }()
 	select {
 	case <-ctx.Done():
 		return nil
 	case channel <- task:
 		return nil
 	default:
+		taskChannelDroppedCount.Inc()
 		return fmt.Errorf("channel is blocked")
 	}
 }
 
 // QueueOperationSet sends an operation set to a channel. It returns an error if the channel is blocked.
 func QueueOperationSet(ctx context.Context, channel chan diagnosisv1.OperationSet, operationSet diagnosisv1.OperationSet) error {
+	defer func() { operationSetChannelLength.Set(float64(len(channel))) }()
 	select {
 	case <-ctx.Done():
 		return nil
 	case channel <- operationSet:
 		return nil
 	default:
+		operationSetChannelDroppedCount.Inc()
 		return fmt.Errorf("channel is blocked")
 	}
 }
 
 // QueueEvent sends an event to a channel. It returns an error if the channel is blocked.
 func QueueEvent(ctx context.Context, channel chan corev1.Event, event corev1.Event) error {
+	defer func() { eventChannelLength.Set(float64(len(channel))) }()
 	select {
 	case <-ctx.Done():
 		return nil
 	case channel <- event:
 		return nil
 	default:
+		eventChannelDroppedCount.Inc()
 		return fmt.Errorf("channel is blocked")
 	}
 }
@@ -374,6 +547,21 @@ func RetrieveTasksOnNode(tasks []diagnosisv1.Task, nodeName string) []diagnosisv
 	return tasksOnNode
 }
 
+// RetrieveDiagnosesOnNode retrieves all diagnoses assigned to the provided node.
+func RetrieveDiagnosesOnNode(diagnoses []diagnosisv1.Diagnosis, nodeName string) []diagnosisv1.Diagnosis {
+	diagnosesOnNode := make([]diagnosisv1.Diagnosis, 0)
+	for _, diagnosis := range diagnoses {
+		for _, name := range diagnosis.Status.NodeNames {
+			if name == nodeName {
+				diagnosesOnNode = append(diagnosesOnNode, diagnosis)
+				break
+			}
+		}
+	}
+
+	return diagnosesOnNode
+}
+
 // GetOwnerReference returns ownerRef for appending to objects's metadata
 func GetOwnerReference(kind, apiVersion, name string, uid types.UID) ([]metav1.OwnerReference, error) {
 	if name == "" {
@@ -659,14 +847,59 @@ func Unzip(src string, dst string, timeoutSeconds int32) error {
 
 // BlockingRunCommandWithTimeout executes command in blocking mode with timeout seconds.
 func BlockingRunCommandWithTimeout(command []string, timeoutSeconds int32) ([]byte, error) {
-	timeoutCommand := []string{"timeout", fmt.Sprintf("%ds", timeoutSeconds)}
-	timeoutCommand = append(timeoutCommand, command...)
-	out, err := exec.Command(timeoutCommand[0], timeoutCommand[1:]...).CombinedOutput()
-	if err != nil {
-		return out, err
+	return BlockingRunCommandWithTimeoutEnv(command, nil, timeoutSeconds)
+}
+
+// BlockingRunCommandWithTimeoutEnv executes command in blocking mode with timeout seconds and
+// additional environment variables appended to the current environment.
+func BlockingRunCommandWithTimeoutEnv(command []string, env []string, timeoutSeconds int32) ([]byte, error) {
+	return BlockingRunCommandWithContextEnv(context.Background(), command, env, timeoutSeconds)
+}
+
+// BlockingRunCommandWithContextEnv executes command in blocking mode with timeout seconds and
+// additional environment variables appended to the current environment. The process tree is
+// killed if the context is cancelled before the command finishes.
+func BlockingRunCommandWithContextEnv(ctx context.Context, command []string, env []string, timeoutSeconds int32) ([]byte, error) {
+	cmd := exec.Command(command[0], command[1:]...)
+	if len(env) != 0 {
+		cmd.Env = append(os.Environ(), env...)
+	}
+	// Setting a new process group id so the whole process tree can be killed on timeout.
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	var buf bytes.Buffer
+	cmd.Stdout = &buf
+	cmd.Stderr = &buf
+	if err := cmd.Start(); err != nil {
+		return nil, err
 	}
 
-	return out, nil
+	done := make(chan error, 1)
+	go func() {
+		done <- cmd.Wait()
+	}()
+
+	select {
+	case err := <-done:
+		return buf.Bytes(), err
+	case <-ctx.Done():
+		killProcessGroup(cmd)
+		<-done
+		return buf.Bytes(), fmt.Errorf("command aborted: %s", ctx.Err())
+	case <-time.After(time.Duration(timeoutSeconds) * time.Second):
+		killProcessGroup(cmd)
+		<-done
+		return buf.Bytes(), fmt.Errorf("command timed out after %d seconds", timeoutSeconds)
+	}
+}
+
+// killProcessGroup kills the process and all of its children with its process group id.
+func killProcessGroup(cmd *exec.Cmd) {
+	pgid, err := syscall.Getpgid(cmd.Process.Pid)
+	if err != nil {
+		cmd.Process.Kill()
+	} else {
+		syscall.Kill(-pgid, syscall.SIGKILL)
+	}
 }
 
 // GetAvailablePort returns a free open port that is ready to use.
@@ -764,6 +997,19 @@ func ScanLastNonEmptyLine(data []byte, eof bool) (advance int, token []byte, err
 	return advance, token, nil
 }
 
+// MergeStringMaps merges string maps into a new map. Values of maps later in the argument
+// list take precedence over values of the same key in earlier maps.
+func MergeStringMaps(maps ...map[string]string) map[string]string {
+	merged := make(map[string]string)
+	for _, m := range maps {
+		for key, value := range m {
+			merged[key] = value
+		}
+	}
+
+	return merged
+}
+
 // RemoveDuplicateStrings removes duplicated strings from a string slice
 func RemoveDuplicateStrings(stringSlice []string) []string {
 	if len(stringSlice) == 0 {
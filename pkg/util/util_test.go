@@ -17,9 +17,17 @@ limitations under the License.
 package util
 
 import (
+	"context"
+	"io/ioutil"
 	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -86,6 +94,69 @@ func TestUpdateDiagnosisCondition(t *testing.T) {
 	}
 }
 
+func TestUpdateDiagnosisConditionPrunesOldest(t *testing.T) {
+	SetMaxStatusConditions(3)
+	defer SetMaxStatusConditions(DefaultMaxStatusConditions)
+
+	now := metav1.Now()
+	status := &diagnosisv1.DiagnosisStatus{}
+	UpdateDiagnosisCondition(status, &diagnosisv1.DiagnosisCondition{
+		Type:   diagnosisv1.DiagnosisComplete,
+		Status: corev1.ConditionTrue,
+	})
+	UpdateDiagnosisCondition(status, &diagnosisv1.DiagnosisCondition{
+		Type:   diagnosisv1.DiagnosisConditionType("ConditionA"),
+		Status: corev1.ConditionTrue,
+	})
+	UpdateDiagnosisCondition(status, &diagnosisv1.DiagnosisCondition{
+		Type:   diagnosisv1.DiagnosisConditionType("ConditionB"),
+		Status: corev1.ConditionTrue,
+	})
+	// The terminal condition is the oldest, followed by ConditionA and ConditionB.
+	status.Conditions[0].LastTransitionTime = metav1.NewTime(now.Add(-3 * time.Minute))
+	status.Conditions[1].LastTransitionTime = metav1.NewTime(now.Add(-2 * time.Minute))
+	status.Conditions[2].LastTransitionTime = metav1.NewTime(now.Add(-1 * time.Minute))
+
+	UpdateDiagnosisCondition(status, &diagnosisv1.DiagnosisCondition{
+		Type:   diagnosisv1.DiagnosisConditionType("ConditionC"),
+		Status: corev1.ConditionTrue,
+	})
+
+	assert.Equal(t, 3, len(status.Conditions))
+	// ConditionA is pruned as the oldest non terminal condition while the older
+	// terminal condition is preserved.
+	assert.Equal(t, diagnosisv1.DiagnosisComplete, status.Conditions[0].Type)
+	assert.Equal(t, diagnosisv1.DiagnosisConditionType("ConditionB"), status.Conditions[1].Type)
+	assert.Equal(t, diagnosisv1.DiagnosisConditionType("ConditionC"), status.Conditions[2].Type)
+}
+
+func TestUpdateTaskConditionPrunesOldest(t *testing.T) {
+	SetMaxStatusConditions(2)
+	defer SetMaxStatusConditions(DefaultMaxStatusConditions)
+
+	now := metav1.Now()
+	status := &diagnosisv1.TaskStatus{}
+	UpdateTaskCondition(status, &diagnosisv1.TaskCondition{
+		Type:   diagnosisv1.TaskComplete,
+		Status: corev1.ConditionTrue,
+	})
+	UpdateTaskCondition(status, &diagnosisv1.TaskCondition{
+		Type:   diagnosisv1.TaskConditionType("ConditionA"),
+		Status: corev1.ConditionTrue,
+	})
+	status.Conditions[0].LastTransitionTime = metav1.NewTime(now.Add(-2 * time.Minute))
+	status.Conditions[1].LastTransitionTime = metav1.NewTime(now.Add(-1 * time.Minute))
+
+	UpdateTaskCondition(status, &diagnosisv1.TaskCondition{
+		Type:   diagnosisv1.TaskConditionType("ConditionB"),
+		Status: corev1.ConditionTrue,
+	})
+
+	assert.Equal(t, 2, len(status.Conditions))
+	assert.Equal(t, diagnosisv1.TaskComplete, status.Conditions[0].Type)
+	assert.Equal(t, diagnosisv1.TaskConditionType("ConditionB"), status.Conditions[1].Type)
+}
+
 func TestGetDiagnosisCondition(t *testing.T) {
 	type expectedStruct struct {
 		index     int
@@ -141,6 +212,25 @@ func TestGetDiagnosisCondition(t *testing.T) {
 	}
 }
 
+func TestQueueTaskChannelMetrics(t *testing.T) {
+	channel := make(chan diagnosisv1.Task, 2)
+	dropped := testutil.ToFloat64(taskChannelDroppedCount)
+
+	// Fill the channel and verify the length gauge reflects queued tasks.
+	err := QueueTask(context.Background(), channel, diagnosisv1.Task{})
+	assert.NoError(t, err)
+	assert.Equal(t, float64(1), testutil.ToFloat64(taskChannelLength))
+	err = QueueTask(context.Background(), channel, diagnosisv1.Task{})
+	assert.NoError(t, err)
+	assert.Equal(t, float64(2), testutil.ToFloat64(taskChannelLength))
+
+	// Queuing a task on a full channel increments the drop counter.
+	err = QueueTask(context.Background(), channel, diagnosisv1.Task{})
+	assert.Error(t, err)
+	assert.Equal(t, dropped+1, testutil.ToFloat64(taskChannelDroppedCount))
+	assert.Equal(t, float64(2), testutil.ToFloat64(taskChannelLength))
+}
+
 func TestGetPodUnhealthyReason(t *testing.T) {
 	tests := []struct {
 		pod      corev1.Pod
@@ -759,3 +849,72 @@ func TestRemoveDuplicateStrings(t *testing.T) {
 		assert.Equal(t, test.expected, dedup, test.desc)
 	}
 }
+
+func TestBlockingRunCommandWithTimeout(t *testing.T) {
+	dir, err := ioutil.TempDir("", "util")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	// The script prints the pid of a sleeping child process and blocks until the timeout.
+	script := "sleep 300 &\necho $!\nwait\n"
+	scriptFilePath := filepath.Join(dir, "script.sh")
+	err = ioutil.WriteFile(scriptFilePath, []byte(script), 0755)
+	assert.NoError(t, err)
+
+	out, err := BlockingRunCommandWithTimeout([]string{"/bin/sh", scriptFilePath}, 1)
+	assert.Error(t, err)
+
+	// The sleeping child process should be killed with the process group on timeout. A killed
+	// child may linger as a zombie until it is reaped, so a zombie state is accepted as well.
+	childPid, err := strconv.Atoi(strings.TrimSpace(string(out)))
+	assert.NoError(t, err)
+	stat, err := ioutil.ReadFile(filepath.Join("/proc", strconv.Itoa(childPid), "stat"))
+	if err == nil {
+		fields := strings.Fields(string(stat))
+		assert.Equal(t, "Z", fields[2])
+	}
+}
+
+func TestBlockingRunCommandWithTimeoutCompleted(t *testing.T) {
+	start := time.Now()
+	out, err := BlockingRunCommandWithTimeout([]string{"echo", "value1"}, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, "value1\n", string(out))
+	assert.True(t, time.Since(start) < 10*time.Second)
+}
+
+func TestMergeStringMaps(t *testing.T) {
+	tests := []struct {
+		maps     []map[string]string
+		expected map[string]string
+		desc     string
+	}{
+		{
+			maps:     nil,
+			expected: map[string]string{},
+			desc:     "no maps",
+		},
+		{
+			maps: []map[string]string{
+				{"param1": "default", "param2": "default"},
+				{"param1": "diagnosis"},
+				nil,
+			},
+			expected: map[string]string{"param1": "diagnosis", "param2": "default"},
+			desc:     "later maps take precedence",
+		},
+		{
+			maps: []map[string]string{
+				{"param1": "default"},
+				{"param1": "diagnosis"},
+				{"param1": "node"},
+			},
+			expected: map[string]string{"param1": "node"},
+			desc:     "last map takes precedence over all earlier maps",
+		},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.expected, MergeStringMaps(test.maps...), test.desc)
+	}
+}